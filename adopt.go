@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/akokshar/storage/server"
+	"github.com/akokshar/storage/server/modules"
+	"github.com/akokshar/storage/server/modules/filesdb"
+	"github.com/akokshar/storage/server/modules/photosdb"
+)
+
+const adoptUsage = `Usage: storage [-basedir dir] adopt <name> <path>
+
+  Hands a directory of the store over to a user, with everything below it. The path is
+  relative to basedir: files/<name> makes a directory which was there before the user their
+  root, files gives them what was put outside the roots of users. Photos go with their files,
+  photos also gives them the albums made before there were users.
+`
+
+// adopt hands a directory the server found in basedir over to a user. Nobody else reaches it
+// unless the user shares it, and what the directories above it grant stops there.
+func adopt(basedir string, args []string) {
+	if len(args) != 2 {
+		fmt.Fprint(os.Stderr, adoptUsage)
+		os.Exit(2)
+	}
+
+	list, err := openUsers(basedir).GetUsers()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// owners are recorded by the name the user was added with
+	name := ""
+	for _, u := range list {
+		if strings.EqualFold(u.Name, args[0]) {
+			name = u.Name
+		}
+	}
+	if name == "" {
+		fmt.Fprintf(os.Stderr, "adopt %s: no such user\n", args[0])
+		os.Exit(1)
+	}
+
+	dir := path.Join(basedir, path.Clean("/"+args[1]))
+	filesDB := filesdb.NewFilesDB(server.MetaDBPath(basedir))
+	id, err := filesDB.GetIDForPath(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "adopt %s: '%s' is not known yet, the server finds it when it starts\n", name, dir)
+		os.Exit(1)
+	}
+	if err := filesDB.SetOwner(id, name); err != nil {
+		if _, ok := err.(modules.HandlerError); ok {
+			fmt.Fprintf(os.Stderr, "adopt %s: '%s' is not a directory\n", name, dir)
+		} else {
+			fmt.Fprintf(os.Stderr, "adopt %s: %v\n", name, err)
+		}
+		os.Exit(1)
+	}
+
+	// the photo library finds the owners of photos when it opens
+	photosDB := photosdb.NewPhotosDB(server.MetaDBPath(basedir), filesDB)
+	if dir == path.Join(basedir, "photos") {
+		if err := photosDB.AdoptAlbums(name); err != nil {
+			fmt.Fprintf(os.Stderr, "adopt %s: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
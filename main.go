@@ -40,6 +40,18 @@ func main() {
 		}
 	}
 
+	switch flag.Arg(0) {
+	case "user":
+		manageUsers(basedir, flag.Args()[1:])
+		return
+	case "group":
+		manageGroups(basedir, flag.Args()[1:])
+		return
+	case "adopt":
+		adopt(basedir, flag.Args()[1:])
+		return
+	}
+
 	log.Printf("Storage is about to serve `%s` on port `%s`\n", basedir, port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port), server.CreateApplication(basedir)))
 }
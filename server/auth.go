@@ -0,0 +1,90 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+const (
+	authRoutePrefix = "/auth"
+	authLogin       = authRoutePrefix + "/login"
+	authRefresh     = authRoutePrefix + "/refresh"
+	authLogout      = authRoutePrefix + "/logout"
+
+	// handlers learn who is asking from these, whatever the client sent in them is dropped
+	headerUserID     = "X-Authenticated-User-Id"
+	headerUserName   = "X-Authenticated-User"
+	headerUserGroups = "X-Authenticated-Groups" /* comma separated */
+)
+
+// authenticate lets the request through with the user behind its bearer token, or answers 401
+func (app *application) authenticate(w http.ResponseWriter, r *http.Request) bool {
+	r.Header.Del(headerUserID)
+	r.Header.Del(headerUserName)
+	r.Header.Del(headerUserGroups)
+
+	authorization := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		unauthorized(w)
+		return false
+	}
+	user, err := app.usersDB.Authenticate(strings.TrimPrefix(authorization, "Bearer "))
+	if err != nil {
+		unauthorized(w)
+		return false
+	}
+	r.Header.Set(headerUserID, strconv.FormatInt(user.ID, 10))
+	r.Header.Set(headerUserName, user.Name)
+	if len(user.Groups) > 0 {
+		r.Header.Set(headerUserGroups, strings.Join(user.Groups, ","))
+	}
+	return true
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="storage"`)
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// serveAuth logs users in and out. Credentials and tokens come in a POSTed form, so that
+// they stay out of URLs and the logs keeping them.
+func (app *application) serveAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var session *modules.Session
+	var err error
+	switch r.URL.Path {
+	case authLogin:
+		session, err = app.usersDB.Login(r.PostFormValue("username"), r.PostFormValue("password"))
+	case authRefresh:
+		session, err = app.usersDB.Refresh(r.PostFormValue("refreshToken"))
+	case authLogout:
+		if err = app.usersDB.Logout(r.PostFormValue("refreshToken")); err == nil {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		if handlerErr, ok := err.(modules.HandlerError); ok && handlerErr.Code() == http.StatusUnauthorized {
+			unauthorized(w)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	sessionJSON, _ := json.MarshalIndent(session, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(sessionJSON)
+}
@@ -0,0 +1,114 @@
+package doctext
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxFileSize is the size of the largest file text is extracted from
+const MaxFileSize = 32 * 1024 * 1024
+
+// ErrNotText is returned for files whose content turns out not to be text
+var ErrNotText = errors.New("not a text document")
+
+type format int
+
+const (
+	formatNone format = iota
+	formatPlain
+	formatHTML
+	formatPDF
+	formatDOCX
+)
+
+var formatByExtension = map[string]format{
+	".txt": formatPlain, ".text": formatPlain, ".md": formatPlain, ".markdown": formatPlain,
+	".rst": formatPlain, ".csv": formatPlain, ".tsv": formatPlain, ".log": formatPlain,
+	".json": formatPlain, ".xml": formatPlain, ".yaml": formatPlain, ".yml": formatPlain,
+	".toml": formatPlain, ".ini": formatPlain, ".cfg": formatPlain, ".conf": formatPlain,
+	".go": formatPlain, ".c": formatPlain, ".h": formatPlain, ".cc": formatPlain, ".cpp": formatPlain,
+	".hpp": formatPlain, ".m": formatPlain, ".swift": formatPlain, ".java": formatPlain,
+	".kt": formatPlain, ".js": formatPlain, ".ts": formatPlain, ".jsx": formatPlain, ".tsx": formatPlain,
+	".css": formatPlain, ".py": formatPlain, ".rb": formatPlain, ".rs": formatPlain, ".php": formatPlain,
+	".sh": formatPlain, ".sql": formatPlain, ".tex": formatPlain,
+	".html": formatHTML, ".htm": formatHTML, ".xhtml": formatHTML,
+	".pdf":  formatPDF,
+	".docx": formatDOCX,
+}
+
+// CanExtract tells whether the file name has the extension of a format text can be extracted from
+func CanExtract(name string) bool {
+	return formatByExtension[strings.ToLower(filepath.Ext(name))] != formatNone
+}
+
+// Extract returns the text of the document, the format is told by the extension of the name
+func Extract(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	content, err := ioutil.ReadAll(io.LimitReader(f, MaxFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(content) > MaxFileSize {
+		return "", ErrNotText
+	}
+
+	switch formatByExtension[strings.ToLower(filepath.Ext(p))] {
+	case formatPlain:
+		return plainText(content)
+	case formatHTML:
+		return htmlText(content)
+	case formatPDF:
+		return pdfText(content)
+	case formatDOCX:
+		return docxText(content)
+	}
+	return "", ErrNotText
+}
+
+// plainText accepts UTF-8 without NUL bytes, which binary files sharing an extension have
+func plainText(content []byte) (string, error) {
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return "", ErrNotText
+	}
+	return string(content), nil
+}
+
+var (
+	htmlTitle     = regexp.MustCompile(`(?is)<title\b[^>]*>(.*?)</title>`)
+	htmlInvisible = regexp.MustCompile(`(?is)<(script|style|head)\b.*?</(script|style|head)\s*>|<!--.*?-->`)
+	htmlBlock     = regexp.MustCompile(`(?i)</?(p|div|br|li|tr|h[1-6]|title|section|article)\b[^>]*>`)
+	htmlTag       = regexp.MustCompile(`(?s)<[^>]*>`)
+	htmlSpace     = regexp.MustCompile(`[ \t\r\f]+`)
+	htmlLines     = regexp.MustCompile(`\n\s*\n\s*`)
+)
+
+// htmlText keeps what a browser would show. The title goes with the head, it is shown
+// as the name of the window, so it is put back in front.
+func htmlText(content []byte) (string, error) {
+	text, err := plainText(content)
+	if err != nil {
+		return "", err
+	}
+	title := ""
+	if m := htmlTitle.FindStringSubmatch(text); m != nil {
+		title = m[1] + "\n"
+	}
+	text = title + htmlInvisible.ReplaceAllString(text, "")
+	text = htmlBlock.ReplaceAllString(text, "\n")
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, ""))
+	text = htmlSpace.ReplaceAllString(text, " ")
+	return strings.TrimSpace(htmlLines.ReplaceAllString(text, "\n")), nil
+}
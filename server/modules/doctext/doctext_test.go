@@ -0,0 +1,108 @@
+package doctext
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func writeTemp(t *testing.T, name string, content []byte) string {
+	dir, err := ioutil.TempDir("", "doctext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestCanExtract(t *testing.T) {
+	for name, ok := range map[string]bool{
+		"notes.MD": true, "main.go": true, "index.html": true, "paper.pdf": true,
+		"letter.docx": true, "photo.jpg": false, "archive.zip": false, "Makefile": false,
+	} {
+		if CanExtract(name) != ok {
+			t.Errorf("%s: %v", name, !ok)
+		}
+	}
+}
+
+func TestPlain(t *testing.T) {
+	text, err := Extract(writeTemp(t, "a.txt", []byte("\xef\xbb\xbfhello wörld")))
+	if err != nil || text != "hello wörld" {
+		t.Errorf("'%s' %v", text, err)
+	}
+	if _, err := Extract(writeTemp(t, "b.txt", []byte("bin\x00ary"))); err != ErrNotText {
+		t.Errorf("binary: %v", err)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	page := `<html><head><title>Page title</title><style>p {color: red}</style></head>
+		<body><script>var hidden = 1;</script><p>First &amp; <b>bold</b></p><!-- note --><div>Second</div></body></html>`
+	text, err := Extract(writeTemp(t, "a.html", []byte(page)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Page title\nFirst & bold\nSecond" {
+		t.Errorf("'%s'", text)
+	}
+}
+
+func TestDOCX(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, _ := archive.Create("word/document.xml")
+	w.Write([]byte(`<?xml version="1.0"?>
+		<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+		<w:p><w:r><w:t>Quarterly</w:t></w:r><w:r><w:t xml:space="preserve"> report</w:t></w:r></w:p>
+		<w:p><w:r><w:t>Total</w:t><w:tab/><w:t>42</w:t></w:r></w:p>
+		</w:body></w:document>`))
+	archive.Close()
+
+	text, err := Extract(writeTemp(t, "a.docx", buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Quarterly report\nTotal\t42" {
+		t.Errorf("'%s'", text)
+	}
+	if _, err := Extract(writeTemp(t, "b.docx", []byte("not a zip"))); err != ErrNotText {
+		t.Errorf("broken: %v", err)
+	}
+}
+
+func TestPDF(t *testing.T) {
+	stream := `BT /F1 12 Tf 72 720 Td (Hello \(PDF\) w\366rld) Tj 0 -14 Td [(Ker) 20 (ning) -300 (works)] TJ
+		T* <FEFF004300AF00E9> Tj ET`
+	var deflated bytes.Buffer
+	z := zlib.NewWriter(&deflated)
+	z.Write([]byte(stream))
+	z.Close()
+
+	var doc bytes.Buffer
+	doc.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n")
+	doc.WriteString("2 0 obj\n<< /Length 3 /Length1 3 >>\nstream\nBT (font) Tj ET\nendstream\nendobj\n")
+	doc.WriteString("3 0 obj\n<< /Length " + strconv.Itoa(deflated.Len()) + " /Filter /FlateDecode >>\nstream\n")
+	doc.Write(deflated.Bytes())
+	doc.WriteString("\nendstream\nendobj\n%%EOF\n")
+
+	text, err := Extract(writeTemp(t, "a.pdf", doc.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Hello (PDF) wörld\nKerning works\nC¯é" {
+		t.Errorf("'%s'", text)
+	}
+	if _, err := Extract(writeTemp(t, "b.pdf", []byte("plain"))); err != ErrNotText {
+		t.Errorf("not a PDF: %v", err)
+	}
+}
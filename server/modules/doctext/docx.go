@@ -0,0 +1,68 @@
+package doctext
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// docxText reads the paragraphs of the main part of a Word document
+func docxText(content []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", ErrNotText
+	}
+	for _, part := range archive.File {
+		if part.Name != "word/document.xml" {
+			continue
+		}
+		r, err := part.Open()
+		if err != nil {
+			return "", err
+		}
+		defer r.Close()
+		return wordText(io.LimitReader(r, MaxFileSize))
+	}
+	return "", ErrNotText
+}
+
+// wordText collects runs of text, w:t elements, breaking lines at paragraphs
+func wordText(r io.Reader) (string, error) {
+	var b strings.Builder
+	decoder := xml.NewDecoder(r)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", ErrNotText
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(b.String()), nil
+}
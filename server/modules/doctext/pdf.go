@@ -0,0 +1,308 @@
+package doctext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	pdfStream = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+	// embedded fonts and images carry no text
+	pdfBinaryStream = regexp.MustCompile(`/Subtype\s*/(Image|Type1C|CIDFontType0C|OpenType)|/Length[123]\b`)
+	pdfFilter       = regexp.MustCompile(`/Filter\s*\[?\s*/(\w+)\s*\]?`)
+)
+
+// pdfText reads strings shown by text operators of the content streams. It handles the
+// common case of documents written with standard or Unicode encoded fonts, text of fonts
+// with custom encodings comes out as whatever their codes are in Latin-1.
+func pdfText(content []byte) (string, error) {
+	if !bytes.HasPrefix(content, []byte("%PDF-")) {
+		return "", ErrNotText
+	}
+
+	var b strings.Builder
+	for _, m := range pdfStream.FindAllSubmatchIndex(content, -1) {
+		dict := content[m[2]:m[3]]
+		start := m[1]
+		end := bytes.Index(content[start:], []byte("endstream"))
+		if end < 0 || pdfBinaryStream.Match(dict) {
+			continue
+		}
+		data := content[start : start+end]
+
+		if f := pdfFilter.FindSubmatch(dict); f != nil {
+			if string(f[1]) != "FlateDecode" {
+				continue
+			}
+			r, err := zlib.NewReader(bytes.NewReader(data))
+			if err != nil {
+				continue
+			}
+			// streams are often cut short of their checksum, whatever was inflated is good
+			inflated, _ := ioutil.ReadAll(io.LimitReader(r, MaxFileSize))
+			r.Close()
+			data = inflated
+		}
+		if bytes.Contains(data, []byte("BT")) {
+			contentText(data, &b)
+		}
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// pdfLexer splits a content stream into operands and operators
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+type pdfToken struct {
+	operator string // empty for operands
+	text     []byte // decoded string operand
+	number   float64
+	isNumber bool
+	isString bool
+	array    []pdfToken
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isPDFSpace(c byte) bool {
+	return strings.IndexByte(" \t\r\n\f\x00", c) >= 0
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			l.pos++
+			return pdfToken{text: l.literal(), isString: true}, true
+		case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+			l.pos += 2
+			return pdfToken{operator: "<<"}, true
+		case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+			l.pos += 2
+			return pdfToken{operator: ">>"}, true
+		case c == '<':
+			l.pos++
+			return pdfToken{text: l.hex(), isString: true}, true
+		case c == '[':
+			l.pos++
+			var array []pdfToken
+			for {
+				t, ok := l.next()
+				if !ok || t.operator == "]" {
+					break
+				}
+				array = append(array, t)
+			}
+			return pdfToken{array: array}, true
+		case c == ']':
+			l.pos++
+			return pdfToken{operator: "]"}, true
+		case c == '/':
+			l.pos++
+			l.word()
+			return pdfToken{}, true
+		default:
+			w := l.word()
+			if w == "" {
+				l.pos++
+				continue
+			}
+			if n, err := strconv.ParseFloat(w, 64); err == nil {
+				return pdfToken{number: n, isNumber: true}, true
+			}
+			return pdfToken{operator: w}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+func (l *pdfLexer) word() string {
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func (l *pdfLexer) literal() []byte {
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// a line continuation
+				if e == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					n := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						n = n*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(n)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (l *pdfLexer) hex() []byte {
+	end := bytes.IndexByte(l.data[l.pos:], '>')
+	if end < 0 {
+		end = len(l.data) - l.pos
+	}
+	digits := make([]byte, 0, end)
+	for _, c := range l.data[l.pos : l.pos+end] {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	l.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return out
+}
+
+// pdfString decodes UTF-16 strings, those starting with a byte order mark or the two byte
+// codes of fonts whose codes are Unicode, and takes anything else as Latin-1
+func pdfString(s []byte) string {
+	if bytes.HasPrefix(s, []byte{0xfe, 0xff}) {
+		return utf16String(s[2:])
+	}
+	if len(s) >= 2 && len(s)%2 == 0 {
+		zeros := 0
+		for i := 0; i < len(s); i += 2 {
+			if s[i] == 0 {
+				zeros++
+			}
+		}
+		if zeros*2 >= len(s)/2 {
+			return utf16String(s)
+		}
+	}
+	runes := make([]rune, len(s))
+	for i, c := range s {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func utf16String(s []byte) string {
+	units := make([]uint16, len(s)/2)
+	for i := range units {
+		units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+	}
+	return string(utf16.Decode(units))
+}
+
+// contentText follows the text showing and positioning operators
+func contentText(data []byte, b *strings.Builder) {
+	l := &pdfLexer{data: data}
+	operands := make([]pdfToken, 0, 8)
+	newLine := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	for {
+		t, ok := l.next()
+		if !ok {
+			break
+		}
+		if t.operator == "" {
+			operands = append(operands, t)
+			continue
+		}
+		switch t.operator {
+		case "Tj":
+			if len(operands) > 0 && operands[len(operands)-1].isString {
+				b.WriteString(pdfString(operands[len(operands)-1].text))
+			}
+		case "'", "\"":
+			newLine()
+			if len(operands) > 0 && operands[len(operands)-1].isString {
+				b.WriteString(pdfString(operands[len(operands)-1].text))
+			}
+		case "TJ":
+			if len(operands) == 0 {
+				break
+			}
+			for _, item := range operands[len(operands)-1].array {
+				switch {
+				case item.isString:
+					b.WriteString(pdfString(item.text))
+				// a large move to the right is the space between words
+				case item.isNumber && item.number < -200:
+					b.WriteByte(' ')
+				}
+			}
+		case "T*":
+			newLine()
+		case "Td", "TD":
+			if len(operands) >= 2 && operands[len(operands)-1].number != 0 {
+				newLine()
+			} else if len(operands) >= 2 && operands[len(operands)-2].number > 0 {
+				b.WriteByte(' ')
+			}
+		case "Tm", "ET":
+			newLine()
+		}
+		operands = operands[:0]
+	}
+}
@@ -0,0 +1,160 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+const (
+	optCmdACL      = "acl"
+	optCmdSetACL   = "setAcl"
+	optCmdResetACL = "resetAcl"
+	optCmdShared   = "shared"
+	optPrincipal   = "principal"
+	optPermissions = "permissions"
+)
+
+// isUnder tells if itemPath is dir itself or somewhere below it
+func isUnder(itemPath string, dir string) bool {
+	rel, err := filepath.Rel(dir, itemPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+func requestGroups(r *http.Request) []string {
+	if groups := r.Header.Get("X-Authenticated-Groups"); groups != "" {
+		return strings.Split(groups, ",")
+	}
+	return nil
+}
+
+// owns tells whether the item is in a root of the user of the request. Users may do anything
+// with what they own.
+func (f *files) owns(r *http.Request, id int64) bool {
+	owner, _ := f.filesDB.GetOwner(id)
+	return owner != "" && strings.EqualFold(owner, r.Header.Get("X-Authenticated-User"))
+}
+
+// permissions finds what the user of the request may do with the item at the path
+func (f *files) permissions(r *http.Request, id int64, itemPath string) int {
+	if !isUnder(itemPath, f.basedir) {
+		return modules.PermNone
+	}
+	if f.owns(r, id) {
+		return modules.PermAll
+	}
+	return f.filesDB.GetPermissions(id, r.Header.Get("X-Authenticated-User"), requestGroups(r))
+}
+
+// allowed finds the item and tells whether the user of the request has the permission,
+// answering for the client when not
+func (f *files) allowed(w http.ResponseWriter, r *http.Request, id int64, perm int) (string, bool) {
+	idPath, err := f.filesDB.GetPathForID(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return "", false
+	}
+	if f.permissions(r, id, idPath)&perm != perm {
+		w.WriteHeader(http.StatusForbidden)
+		return "", false
+	}
+	return idPath, true
+}
+
+// treeReadable tells whether the user of the request may read everything below the
+// directory, which searches and trees of it show. Below what they own, only the roots of
+// others may keep them out.
+func (f *files) treeReadable(w http.ResponseWriter, r *http.Request, id int64) bool {
+	groups := requestGroups(r)
+	if f.owns(r, id) {
+		groups = nil
+	}
+	if f.filesDB.RestrictedBelow(id, r.Header.Get("X-Authenticated-User"), groups) {
+		w.WriteHeader(http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// acl tells what the directory lets everyone do, and what it lets the user of the request do
+func (f *files) acl(w http.ResponseWriter, r *http.Request, id int64, idPath string) {
+	entries, err := f.filesDB.GetACL(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	aclJSON, _ := json.MarshalIndent(struct {
+		Permissions []string    `json:"permissions"`
+		Entries     interface{} `json:"entries"`
+	}{modules.PermissionNames(f.permissions(r, id, idPath)), entries}, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(aclJSON)
+}
+
+// setACL changes what the directory grants to a principal, or with cmd=resetAcl makes it
+// inherit again. Nobody grants more than they have.
+func (f *files) setACL(w http.ResponseWriter, r *http.Request, rootID int64, opts url.Values) {
+	id, ok := f.itemID(w, r, rootID, opts.Get(optID))
+	if !ok {
+		return
+	}
+	if id < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	idPath, ok := f.allowed(w, r, id, modules.PermShare)
+	if !ok {
+		return
+	}
+	principal := opts.Get(optPrincipal)
+	if !modules.ValidPrincipal(principal) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if opts.Get(optCmd) == optCmdResetACL {
+		if err := f.filesDB.ResetACL(id, principal); err != nil {
+			writeError(w, err)
+			return
+		}
+		f.acl(w, r, id, idPath)
+		return
+	}
+
+	perms, ok := modules.ParsePermissions(opts.Get(optPermissions))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if perms&^f.permissions(r, id, idPath) != 0 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if err := f.filesDB.SetACL(id, principal, perms); err != nil {
+		writeError(w, err)
+		return
+	}
+	f.acl(w, r, id, idPath)
+}
+
+// shared lists the directories of others the user of the request may read
+func (f *files) shared(w http.ResponseWriter, r *http.Request) {
+	user := r.Header.Get("X-Authenticated-User")
+	if user == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	shared := f.filesDB.GetShared(user, requestGroups(r))
+	if shared == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	sharedJSON, _ := json.MarshalIndent(shared, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(sharedJSON)
+}
@@ -0,0 +1,343 @@
+package files
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+const (
+	optCmdBatch    = "batch"
+	optTransaction = "transaction"
+	maxBatchSize   = 1000
+
+	batchInfo      = "info"
+	batchDelete    = "delete"
+	batchMove      = "move"
+	batchCreateDir = "createDir"
+)
+
+// batchOp is one operation of a batch. IDs are numbers, or the identifier of the root.
+type batchOp struct {
+	Op       string          `json:"op"`
+	ID       json.RawMessage `json:"id"`
+	ParentID json.RawMessage `json:"parentId"`
+	Name     string          `json:"name"`
+}
+
+// batchResult tells how an operation went with the status it would have on its own
+type batchResult struct {
+	Status int         `json:"status"`
+	Result interface{} `json:"result,omitempty"`
+}
+
+// batch runs operations in turn while keeping what it needs to undo them
+type batch struct {
+	f       *files
+	r       *http.Request
+	rootID  int64
+	atomic  bool
+	undo    []func() error
+	deletes []pendingDelete
+}
+
+// pendingDelete is a deletion waiting for the end of a transaction, the path of the item is
+// looked up when it is carried out as moves may change it meanwhile
+type pendingDelete struct {
+	index int
+	id    int64
+}
+
+// refID reads an ID of an operation
+func (b *batch) refID(raw json.RawMessage) (int64, bool) {
+	var rootID string
+	if json.Unmarshal(raw, &rootID) == nil && rootID == "NSFileProviderRootContainerItemIdentifier" {
+		return b.rootID, true
+	}
+	var id int64
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// itemPath finds an item the client has the permission for, items waiting to be deleted
+// are gone already
+func (b *batch) itemPath(id int64, perm int) (string, int) {
+	idPath, err := b.f.filesDB.GetPathForID(id)
+	if err != nil {
+		return "", http.StatusNotFound
+	}
+	for _, d := range b.deletes {
+		if d.id < 0 {
+			continue
+		}
+		if deletePath, err := b.f.filesDB.GetPathForID(d.id); err == nil && (idPath == deletePath || strings.HasPrefix(idPath, deletePath+"/")) {
+			return "", http.StatusNotFound
+		}
+	}
+	if b.f.permissions(b.r, id, idPath)&perm != perm {
+		return "", http.StatusForbidden
+	}
+	return idPath, http.StatusOK
+}
+
+func errorStatus(err error) int {
+	if handlerErr, ok := err.(modules.HandlerError); ok {
+		return handlerErr.Code()
+	}
+	return http.StatusInternalServerError
+}
+
+func (b *batch) run(index int, op *batchOp) batchResult {
+	switch op.Op {
+	case batchInfo:
+		id, ok := b.refID(op.ID)
+		if !ok {
+			return batchResult{Status: http.StatusBadRequest}
+		}
+		// saved searches are as readable as the directory showing them
+		reachID := id
+		if id < 0 {
+			parentID, err := b.f.filesDB.GetSavedSearchParent(id)
+			if err != nil {
+				return batchResult{Status: http.StatusNotFound}
+			}
+			reachID = parentID
+		}
+		if _, status := b.itemPath(reachID, modules.PermRead); status != http.StatusOK {
+			return batchResult{Status: status}
+		}
+		return batchResult{Status: http.StatusOK, Result: b.f.filesDB.GetMetaDataForItemWithID(id)}
+
+	case batchCreateDir:
+		parentID, ok := b.refID(op.ParentID)
+		if !ok || !modules.ValidItemName(op.Name) {
+			return batchResult{Status: http.StatusBadRequest}
+		}
+		if parentID < 0 {
+			return batchResult{Status: http.StatusForbidden}
+		}
+		if _, status := b.itemPath(parentID, modules.PermWrite); status != http.StatusOK {
+			return batchResult{Status: status}
+		}
+		id, err := b.f.makeDirectory(parentID, op.Name)
+		if err != nil {
+			return batchResult{Status: http.StatusInternalServerError}
+		}
+		b.undo = append(b.undo, func() error {
+			dirPath, err := b.f.filesDB.GetPathForID(id)
+			if err != nil {
+				return err
+			}
+			return b.f.removeItem(id, dirPath)
+		})
+		return batchResult{Status: http.StatusCreated, Result: b.f.filesDB.GetMetaDataForItemWithID(id)}
+
+	case batchMove:
+		id, ok := b.refID(op.ID)
+		if !ok || id < 0 {
+			return batchResult{Status: http.StatusBadRequest}
+		}
+		if b.f.isRoot(id) {
+			return batchResult{Status: http.StatusForbidden}
+		}
+		oldPath, status := b.itemPath(id, modules.PermDelete)
+		if status != http.StatusOK {
+			return batchResult{Status: status}
+		}
+		oldParentID, err := b.f.filesDB.GetIDForPath(path.Dir(oldPath))
+		if err != nil {
+			return batchResult{Status: http.StatusInternalServerError}
+		}
+		parentID := oldParentID
+		if len(op.ParentID) > 0 {
+			if parentID, ok = b.refID(op.ParentID); !ok {
+				return batchResult{Status: http.StatusBadRequest}
+			}
+		}
+		if parentID < 0 {
+			return batchResult{Status: http.StatusForbidden}
+		}
+		parentPath, status := b.itemPath(parentID, modules.PermWrite)
+		if status != http.StatusOK {
+			return batchResult{Status: status}
+		}
+		name := op.Name
+		if name == "" {
+			name = path.Base(oldPath)
+		}
+		if !modules.ValidItemName(name) {
+			return batchResult{Status: http.StatusBadRequest}
+		}
+
+		if status := b.f.moveItem(id, oldPath, parentID, path.Join(parentPath, name)); status != http.StatusOK {
+			return batchResult{Status: status}
+		}
+		b.undo = append(b.undo, func() error {
+			newPath, err := b.f.filesDB.GetPathForID(id)
+			if err != nil {
+				return err
+			}
+			if status := b.f.moveItem(id, newPath, oldParentID, oldPath); status != http.StatusOK {
+				return modules.NewHandlerErrorWithCode(status).(error)
+			}
+			return nil
+		})
+		return batchResult{Status: http.StatusOK, Result: b.f.filesDB.GetMetaDataForItemWithID(id)}
+
+	case batchDelete:
+		id, ok := b.refID(op.ID)
+		if !ok {
+			return batchResult{Status: http.StatusBadRequest}
+		}
+		if b.f.isRoot(id) {
+			return batchResult{Status: http.StatusForbidden}
+		}
+		if id < 0 {
+			parentID, err := b.f.filesDB.GetSavedSearchParent(id)
+			if err != nil {
+				return batchResult{Status: http.StatusNotFound}
+			}
+			if _, status := b.itemPath(parentID, modules.PermDelete); status != http.StatusOK {
+				return batchResult{Status: status}
+			}
+			if b.atomic {
+				b.deletes = append(b.deletes, pendingDelete{index: index, id: id})
+				return batchResult{Status: http.StatusOK}
+			}
+			if err := b.f.filesDB.DeleteSavedSearch(id); err != nil {
+				return batchResult{Status: errorStatus(err)}
+			}
+			return batchResult{Status: http.StatusOK}
+		}
+		idPath, status := b.itemPath(id, modules.PermDelete)
+		if status != http.StatusOK {
+			return batchResult{Status: status}
+		}
+		// deletions cannot be undone, in a transaction they wait for everything else to go through
+		if b.atomic {
+			b.deletes = append(b.deletes, pendingDelete{index: index, id: id})
+			return batchResult{Status: http.StatusOK}
+		}
+		if err := b.f.removeItem(id, idPath); err != nil {
+			return batchResult{Status: http.StatusInternalServerError}
+		}
+		return batchResult{Status: http.StatusOK}
+	}
+	return batchResult{Status: http.StatusBadRequest}
+}
+
+// moveItem moves the item on disk, then records the move. What is on disk is moved back when
+// the move cannot be recorded.
+func (f *files) moveItem(id int64, oldPath string, parentID int64, newPath string) int {
+	if newPath == oldPath {
+		return http.StatusOK
+	}
+	if strings.HasPrefix(newPath, oldPath+"/") {
+		return http.StatusBadRequest
+	}
+	// a file the database does not know about yet would be replaced without a trace
+	if _, err := os.Lstat(newPath); err == nil {
+		return http.StatusConflict
+	}
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return http.StatusInternalServerError
+	}
+	if err := f.filesDB.MoveItem(id, parentID, path.Base(newPath)); err != nil {
+		os.Rename(newPath, oldPath)
+		return errorStatus(err)
+	}
+	return http.StatusOK
+}
+
+// commit carries out the deletions waiting for the end of a transaction. It stops at the first
+// one to fail, the results of those not carried out are 424. What was done stays done.
+func (b *batch) commit(results []batchResult) bool {
+	for i, d := range b.deletes {
+		var err error
+		if d.id < 0 {
+			err = b.f.filesDB.DeleteSavedSearch(d.id)
+		} else {
+			var idPath string
+			if idPath, err = b.f.filesDB.GetPathForID(d.id); err == nil {
+				err = b.f.removeItem(d.id, idPath)
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to delete %d in a batch due to '%s'", d.id, err.Error())
+			results[d.index] = batchResult{Status: errorStatus(err)}
+			for _, left := range b.deletes[i+1:] {
+				results[left.index] = batchResult{Status: http.StatusFailedDependency}
+			}
+			return false
+		}
+	}
+	return true
+}
+
+// rollback undoes what was done, the latest first. It goes on past undos which fail and tells
+// whether all of them went through.
+func (b *batch) rollback() bool {
+	undone := true
+	for i := len(b.undo) - 1; i >= 0; i-- {
+		if err := b.undo[i](); err != nil {
+			log.Printf("Failed to undo a batch operation due to '%s'", err.Error())
+			undone = false
+		}
+	}
+	return undone
+}
+
+// batch runs a JSON array of operations and answers with a result for each. In a transaction
+// the batch stops at the first operation to fail and what was done is undone, the results of
+// operations undone or never run are 424. Deletions are carried out last, once everything
+// else went through. When an undo or a deletion fails the transaction is neither committed
+// nor rolled back, the batch answers 500.
+func (f *files) batch(w http.ResponseWriter, r *http.Request, rootID int64, opts url.Values) {
+	var ops []batchOp
+	if err := json.NewDecoder(r.Body).Decode(&ops); err != nil || len(ops) > maxBatchSize {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	atomic, _ := strconv.ParseBool(opts.Get(optTransaction))
+
+	b := &batch{f: f, r: r, rootID: rootID, atomic: atomic}
+	results := make([]batchResult, len(ops))
+	committed := true
+	code := http.StatusOK
+	for i := range ops {
+		results[i] = b.run(i, &ops[i])
+		if atomic && results[i].Status >= http.StatusBadRequest {
+			committed = false
+			if !b.rollback() {
+				code = http.StatusInternalServerError
+			}
+			for j := range results {
+				if j != i {
+					results[j] = batchResult{Status: http.StatusFailedDependency}
+				}
+			}
+			break
+		}
+	}
+	if committed && !b.commit(results) {
+		committed = false
+		code = http.StatusInternalServerError
+	}
+
+	resultJSON, _ := json.MarshalIndent(struct {
+		Committed bool          `json:"committed"`
+		Results   []batchResult `json:"results"`
+	}{committed, results}, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(resultJSON)
+}
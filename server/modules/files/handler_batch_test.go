@@ -0,0 +1,153 @@
+package files
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestBatchTransactions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(path.Join(dir, "files"), 0755)
+
+	f, request := newTestFiles(dir)
+	for _, target := range []string{"/files/docs?cmd=createDir", "/files/docs/a.txt", "/files/docs/b.txt"} {
+		if w := request("alice", "PUT", target, strings.NewReader("x")); w.Code != http.StatusCreated {
+			t.Fatalf("alice puts %s: %d", target, w.Code)
+		}
+	}
+	id := func(p string) int64 {
+		id, err := f.filesDB.GetIDForPath(path.Join(f.basedir, "alice", p))
+		if err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+		return id
+	}
+	docs, a, b := id("docs"), id("docs/a.txt"), id("docs/b.txt")
+	exists := func(p string) bool {
+		_, err := os.Stat(path.Join(f.basedir, "alice", p))
+		return err == nil
+	}
+	send := func(context string, ops string, code int, committed bool, statuses ...int) {
+		w := request("alice", "POST", "/files?cmd=batch&transaction=true", strings.NewReader(ops))
+		var answer struct {
+			Committed bool          `json:"committed"`
+			Results   []batchResult `json:"results"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &answer); err != nil || len(answer.Results) != len(statuses) {
+			t.Fatalf("%s: %d %s", context, w.Code, w.Body.String())
+		}
+		if w.Code != code || answer.Committed != committed {
+			t.Errorf("%s: %d committed %v", context, w.Code, answer.Committed)
+		}
+		for i, res := range answer.Results {
+			if res.Status != statuses[i] {
+				t.Errorf("%s: operation %d: %d, want %d", context, i, res.Status, statuses[i])
+			}
+		}
+	}
+
+	// a failure undoes what was done, the latest first
+	send("rollback", fmt.Sprintf(`[
+		{"op": "createDir", "parentId": %[1]d, "name": "new"},
+		{"op": "move", "id": %[2]d, "parentId": "NSFileProviderRootContainerItemIdentifier", "name": "c.txt"},
+		{"op": "delete", "id": %[3]d},
+		{"op": "move", "id": %[1]d, "name": "x/y"}
+	]`, docs, a, b), http.StatusOK, false, http.StatusFailedDependency, http.StatusFailedDependency, http.StatusFailedDependency, http.StatusBadRequest)
+	if exists("docs/new") || exists("c.txt") || !exists("docs/a.txt") || !exists("docs/b.txt") {
+		t.Errorf("rollback left changes")
+	}
+	if aPath, _ := f.filesDB.GetPathForID(a); aPath != path.Join(f.basedir, "alice/docs/a.txt") {
+		t.Errorf("rollback: a.txt is at %s", aPath)
+	}
+
+	// directories are made in the parent only
+	w := request("alice", "POST", "/files?cmd=batch", strings.NewReader(fmt.Sprintf(`[
+		{"op": "createDir", "parentId": %[1]d, "name": ".."},
+		{"op": "createDir", "parentId": %[1]d, "name": "."},
+		{"op": "createDir", "parentId": %[1]d, "name": "x\u0000"},
+		{"op": "move", "id": %[2]d, "name": ".."}
+	]`, docs, a)))
+	var answer struct {
+		Results []batchResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &answer); err != nil || len(answer.Results) != 4 {
+		t.Fatalf("names: %d %s", w.Code, w.Body.String())
+	}
+	for i, res := range answer.Results {
+		if res.Status != http.StatusBadRequest {
+			t.Errorf("names: operation %d: %d", i, res.Status)
+		}
+	}
+	var children struct {
+		Files []struct {
+			Name string `json:"name"`
+		} `json:"files"`
+	}
+	w = request("alice", "GET", fmt.Sprintf("/files?id=%d&cmd=children", docs), nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &children); err != nil || len(children.Files) != 2 {
+		t.Errorf("names: docs has %s", w.Body.String())
+	}
+
+	// items waiting to be deleted are gone for the rest of the batch
+	send("move after delete", fmt.Sprintf(`[
+		{"op": "delete", "id": %[1]d},
+		{"op": "move", "id": %[2]d, "parentId": "NSFileProviderRootContainerItemIdentifier"}
+	]`, docs, a), http.StatusOK, false, http.StatusFailedDependency, http.StatusNotFound)
+	if !exists("docs/a.txt") {
+		t.Errorf("move after delete: a.txt is gone")
+	}
+
+	// a deletion goes where the item was moved to meanwhile
+	send("delete before move", fmt.Sprintf(`[
+		{"op": "delete", "id": %[1]d},
+		{"op": "move", "id": %[2]d, "parentId": "NSFileProviderRootContainerItemIdentifier", "name": "papers"}
+	]`, a, docs), http.StatusOK, true, http.StatusOK, http.StatusOK)
+	if exists("papers/a.txt") || exists("docs") || !exists("papers/b.txt") {
+		t.Errorf("delete before move: a.txt is left")
+	}
+	if _, err := f.filesDB.GetPathForID(a); err == nil {
+		t.Errorf("delete before move: a.txt is still known")
+	}
+
+	// a failed deletion leaves the transaction half way
+	search, err := f.filesDB.SaveSearch(docs, "texts", "name:*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	send("failed delete", fmt.Sprintf(`[
+		{"op": "delete", "id": %[1]d},
+		{"op": "delete", "id": %[1]d},
+		{"op": "delete", "id": %[2]d}
+	]`, search, b), http.StatusInternalServerError, false, http.StatusOK, http.StatusNotFound, http.StatusFailedDependency)
+	if !exists("papers/b.txt") {
+		t.Errorf("failed delete: deletions went on")
+	}
+}
+
+func TestBatchRollback(t *testing.T) {
+	var undone []int
+	undo := func(i int, err error) func() error {
+		return func() error {
+			undone = append(undone, i)
+			return err
+		}
+	}
+	b := &batch{undo: []func() error{undo(0, nil), undo(1, errors.New("busy")), undo(2, nil)}}
+	if b.rollback() {
+		t.Errorf("rollback went through a failed undo")
+	}
+	if fmt.Sprint(undone) != "[2 1 0]" {
+		t.Errorf("undone %v", undone)
+	}
+}
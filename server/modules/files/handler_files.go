@@ -3,13 +3,17 @@ package files
 import (
 	"compress/gzip"
 	"encoding/json"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/akokshar/storage/server/modules"
 )
@@ -20,6 +24,11 @@ const (
 	optCmdListChanges     = "list"
 	optCmdInfo            = "info"
 	optCmdSyncStatus      = "syncStatus"
+	optCmdChildren        = "children"
+	optCmdTree            = "tree"
+	optCmdUsage           = "usage"
+	optDepth              = "depth"
+	optCursor             = "cursor"
 	optID                 = "id"
 	optParentID           = "parentId"
 	optName               = "name"
@@ -29,11 +38,18 @@ const (
 	optCountDefaultValue  = 10
 )
 
+var (
+	errForbidden = modules.NewHandlerErrorWithCode(http.StatusForbidden).(error)
+	// the directory named like the user was not made for them
+	errRootTaken = modules.NewHandlerErrorWithCode(http.StatusConflict).(error)
+)
+
 type files struct {
 	routePrefix string
 	basedir     string
 	filesDB     modules.FilesDB
 	rootID      int64
+	rootsLock   sync.Mutex
 }
 
 // New initializes backend to server files
@@ -55,92 +71,276 @@ func (f *files) GetBaseDir() string {
 }
 
 func (f *files) ServeHTTPRequest(w http.ResponseWriter, r *http.Request) {
+	rootID, err := f.userRoot(r)
+	if err != nil {
+		log.Printf("No root for '%s': %v", r.Header.Get("X-Authenticated-User"), err)
+		writeError(w, err)
+		return
+	}
+	// paths of the URL are below the root of the user, and so is everything the user reaches
+	localPath := strings.TrimPrefix(r.Header.Get("X-Local-Filepath"), f.basedir)
+	r.Header.Set("X-Local-Filepath", path.Join(f.userDir(r), localPath))
+
 	switch r.Method {
 	case http.MethodGet:
-		f.getFile(w, r)
+		f.getFile(w, r, rootID)
 	case http.MethodPost:
-		f.createFile(w, r)
+		f.createFile(w, r, rootID)
+	case http.MethodPut:
+		f.putFile(w, r)
 	case http.MethodDelete:
-		f.deleteFile(w, r)
+		f.deleteFile(w, r, rootID)
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
-func (f *files) getFile(w http.ResponseWriter, r *http.Request) {
+// userDir is the root directory of the user the request comes from
+func (f *files) userDir(r *http.Request) string {
+	return path.Join(f.basedir, r.Header.Get("X-Authenticated-User"))
+}
+
+// userRoot finds the ID of the root directory of the user, which is made on the first request.
+// A directory which is there already is only taken when it is owned by the user, what was put
+// there before has to be handed over to them with "storage adopt".
+func (f *files) userRoot(r *http.Request) (int64, error) {
+	name := r.Header.Get("X-Authenticated-User")
+	if name == "" {
+		return 0, errForbidden
+	}
+	dir := f.userDir(r)
+	if id, err := f.filesDB.GetIDForPath(dir); err == nil && f.isRootOf(id, name) {
+		return id, nil
+	}
+
+	f.rootsLock.Lock()
+	defer f.rootsLock.Unlock()
+	if id, err := f.filesDB.GetIDForPath(dir); err == nil {
+		if !f.isRootOf(id, name) {
+			return 0, errRootTaken
+		}
+		return id, nil
+	}
+	if _, err := os.Lstat(dir); err == nil {
+		return 0, errRootTaken
+	}
+	id, err := f.makeDirectory(f.rootID, path.Base(dir))
+	if err != nil {
+		return 0, err
+	}
+	// a file taking the name got the directory a suffix
+	if dirPath, err := f.filesDB.GetPathForID(id); err != nil || dirPath != dir {
+		return 0, fmt.Errorf("'%s' is not a directory", dir)
+	}
+	if err := f.filesDB.SetOwner(id, name); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// isRootOf tells whether the directory is a root the user owns
+func (f *files) isRootOf(id int64, name string) bool {
+	owner, rootID := f.filesDB.GetOwner(id)
+	return rootID == id && strings.EqualFold(owner, name)
+}
+
+// isRoot tells whether the item is the base directory or the root of a user, which stay
+// where they are
+func (f *files) isRoot(id int64) bool {
+	_, rootID := f.filesDB.GetOwner(id)
+	return id == f.rootID || id == rootID
+}
+
+// itemID reads the ID option. Without one the item is the one at the path of the URL,
+// so /files/docs/a.txt is the same as ?id= of a.txt and /files is the root.
+func (f *files) itemID(w http.ResponseWriter, r *http.Request, rootID int64, rawID string) (int64, bool) {
+	switch rawID {
+	case "NSFileProviderRootContainerItemIdentifier":
+		return rootID, true
+	case "":
+		id, err := f.filesDB.GetIDForPath(r.Header.Get("X-Local-Filepath"))
+		if err != nil {
+			w.WriteHeader(http.StatusNotFound)
+			return 0, false
+		}
+		return id, true
+	}
+	id, err := strconv.Atoi(rawID)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return 0, false
+	}
+	return int64(id), true
+}
+
+func (f *files) getFile(w http.ResponseWriter, r *http.Request, rootID int64) {
 	opts, err := url.ParseQuery(r.URL.RawQuery)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	var id int64
-	rawID, err := strconv.Atoi(opts.Get(optID))
-	if err != nil {
-		if opts.Get(optID) == "NSFileProviderRootContainerItemIdentifier" {
-			id = f.rootID
-		} else {
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-	} else {
-		id = int64(rawID)
+	if opts.Get(optCmd) == optCmdShared {
+		f.shared(w, r)
+		return
 	}
 
-	idPath, err := f.filesDB.GetPathForID(id)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+	id, ok := f.itemID(w, r, rootID, opts.Get(optID))
+	if !ok {
 		return
 	}
-	if !strings.HasPrefix(idPath, r.Header.Get("X-Local-Filepath")) {
-		w.WriteHeader(http.StatusForbidden)
+
+	if id < 0 {
+		f.getSavedSearch(w, r, id, opts)
 		return
 	}
 
+	idPath, ok := f.allowed(w, r, id, modules.PermRead)
+	if !ok {
+		return
+	}
+
+	// what is below the directory is shown as a whole, parts the user may not read can not be left out
 	switch opts.Get(optCmd) {
-	case optCmdInfo:
-		metaData := f.filesDB.GetMetaDataForItemWithID(id)
-		metaJSON, _ := json.MarshalIndent(metaData, "", "  ")
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(metaJSON)
-		break
-	case optCmdListChanges:
-		var syncAnchor, count int
-		if syncAnchor, err = strconv.Atoi(opts.Get(optAnchor)); err != nil {
-			syncAnchor = optAnchorDefaultValue
+	case optCmdTree, optCmdUsage, optCmdSavedSearches, optCmdSearch, optCmdQuery:
+		if !f.treeReadable(w, r, id) {
+			return
 		}
-		if count, err = strconv.Atoi(opts.Get(optCount)); err != nil {
-			count = optCountDefaultValue
+	case optCmdACL:
+		if _, ok := f.allowed(w, r, id, modules.PermShare); !ok {
+			return
 		}
+	}
 
-		metaData := f.filesDB.GetChangesInDirectorySince(id, int64(syncAnchor), count)
-		metaJSON, _ := json.MarshalIndent(metaData, "", "  ")
+	switch opts.Get(optCmd) {
+	case optCmdInfo:
+		f.info(w, id)
+	case optCmdListChanges:
+		f.listChanges(w, id, opts)
+	case optCmdChildren:
+		f.children(w, id, opts)
+	case optCmdTree:
+		f.tree(w, r, id, opts)
+	case optCmdUsage:
+		f.usage(w, id)
+	case optCmdSavedSearches:
+		f.savedSearches(w, id)
+	case optCmdSearch:
+		f.search(w, id, opts)
+	case optCmdQuery:
+		f.query(w, id, opts)
+	case optCmdACL:
+		f.acl(w, r, id, idPath)
+	default:
+		http.ServeFile(w, r, idPath)
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Content-Encoding", "gzip")
+}
+
+func (f *files) info(w http.ResponseWriter, id int64) {
+	metaData := f.filesDB.GetMetaDataForItemWithID(id)
+	metaJSON, _ := json.MarshalIndent(metaData, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(metaJSON)
+}
+
+func (f *files) listChanges(w http.ResponseWriter, id int64, opts url.Values) {
+	var syncAnchor, count int
+	var err error
+	if syncAnchor, err = strconv.Atoi(opts.Get(optAnchor)); err != nil {
+		syncAnchor = optAnchorDefaultValue
+	}
+	if count, err = strconv.Atoi(opts.Get(optCount)); err != nil {
+		count = optCountDefaultValue
+	}
+
+	metaData := f.filesDB.GetChangesInDirectorySince(id, int64(syncAnchor), count)
+	metaJSON, _ := json.MarshalIndent(metaData, "", "  ")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Encoding", "gzip")
+
+	gzipWriter := gzip.NewWriter(w)
+	defer gzipWriter.Close()
+	gzipWriter.Write(metaJSON)
+}
+
+// children lists the current items of a directory page by page, for clients not keeping in sync
+func (f *files) children(w http.ResponseWriter, id int64, opts url.Values) {
+	descending, ok := descendingOpt(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	count := intOpt(opts, optCount, optCountDefaultValue)
+
+	result, err := f.filesDB.GetChildren(id, opts.Get(optSort), descending, opts.Get(optCursor), count)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resultJSON)
+}
+
+// tree writes the directory with everything below it as it is read from the database
+func (f *files) tree(w http.ResponseWriter, r *http.Request, id int64, opts url.Values) {
+	depth := intOpt(opts, optDepth, 0)
+
+	w.Header().Set("Content-Type", "application/json")
+	var out io.Writer = w
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Set("Content-Encoding", "gzip")
 		gzipWriter := gzip.NewWriter(w)
 		defer gzipWriter.Close()
-		gzipWriter.Write(metaJSON)
-		break
-	default:
-		http.ServeFile(w, r, idPath)
+		out = gzipWriter
 	}
 
+	// the item is known to exist, what fails from here on fails half way through the answer
+	// and is told in the error field of the item at the top
+	if err := f.filesDB.WriteTree(id, depth, out); err != nil {
+		log.Printf("Failed to write the tree of %d: %v", id, err)
+	}
 }
 
-func (f *files) createFile(w http.ResponseWriter, r *http.Request) {
+// usage tells what takes the space below the directory, by folder and by content type
+func (f *files) usage(w http.ResponseWriter, id int64) {
+	usage := f.filesDB.GetUsage(id)
+	if usage == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	usageJSON, _ := json.MarshalIndent(usage, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(usageJSON)
+}
+
+func (f *files) createFile(w http.ResponseWriter, r *http.Request, rootID int64) {
 	opts, err := url.ParseQuery(r.URL.RawQuery)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	switch opts.Get(optCmd) {
+	case optCmdEditSearch:
+		f.editSearch(w, r, opts)
+		return
+	case optCmdBatch:
+		f.batch(w, r, rootID, opts)
+		return
+	case optCmdSetACL, optCmdResetACL:
+		f.setACL(w, r, rootID, opts)
+		return
+	}
+
 	var parentID int64
 	rawParentID, err := strconv.Atoi(opts.Get(optParentID))
 	if err != nil {
 		if opts.Get(optParentID) == "NSFileProviderRootContainerItemIdentifier" {
-			parentID = f.rootID
+			parentID = rootID
 		} else {
 			w.WriteHeader(http.StatusBadRequest)
 			return
@@ -149,39 +349,51 @@ func (f *files) createFile(w http.ResponseWriter, r *http.Request) {
 		parentID = int64(rawParentID)
 	}
 
-	name := opts.Get(optName)
-	if name == "" {
-		w.WriteHeader(http.StatusBadRequest)
+	// directories of saved searches are read only
+	if parentID < 0 {
+		w.WriteHeader(http.StatusForbidden)
 		return
 	}
 
-	parentPath, err := f.filesDB.GetPathForID(parentID)
-	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+	name := opts.Get(optName)
+	if !modules.ValidItemName(name) {
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	if !strings.HasPrefix(parentPath, r.Header.Get("X-Local-Filepath")) {
-		w.WriteHeader(http.StatusForbidden)
+
+	if _, ok := f.allowed(w, r, parentID, modules.PermWrite); !ok {
 		return
 	}
 
-	id, err := f.filesDB.CreateItemPlaceholder(parentID, name)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+	if opts.Get(optCmd) == optCmdSaveSearch {
+		if f.treeReadable(w, r, parentID) {
+			f.saveSearch(w, parentID, name, opts)
+		}
 		return
 	}
-	filePath, err := f.filesDB.GetPathForID(id)
 
-	if opts.Get(optCmd) == optCmdCreateDir {
-		// the directory might exist. Do not rase an error, just consume existing directory.
-		os.Mkdir(filePath, 0755)
-		err = f.filesDB.ImportItem(id, filePath)
+	f.createItem(w, r, parentID, name, opts.Get(optCmd) == optCmdCreateDir)
+}
+
+// createItem makes a new file of the request body, or a directory, in the parent directory.
+// The name gets a suffix when it is taken.
+func (f *files) createItem(w http.ResponseWriter, r *http.Request, parentID int64, name string, isDir bool) {
+	var id int64
+	var err error
+	if isDir {
+		id, err = f.makeDirectory(parentID, name)
 		if err != nil {
-			f.filesDB.DeleteItemPlaceholder(id)
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 	} else {
+		id, err = f.filesDB.CreateItemPlaceholder(parentID, name)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		filePath, _ := f.filesDB.GetPathForID(id)
+
 		nf, err := os.Create(filePath)
 		if err != nil {
 			f.filesDB.DeleteItemPlaceholder(id)
@@ -216,45 +428,145 @@ func (f *files) createFile(w http.ResponseWriter, r *http.Request) {
 	w.Write(metaJSON)
 }
 
-func (f *files) deleteFile(w http.ResponseWriter, r *http.Request) {
+// putFile stores the request body at the path of the URL, replacing the file there, or makes
+// a directory there with cmd=createDir. The parent directory has to exist, and replacing
+// a file takes the permission to delete it.
+func (f *files) putFile(w http.ResponseWriter, r *http.Request) {
 	opts, err := url.ParseQuery(r.URL.RawQuery)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	var id int64
-	rawID, err := strconv.Atoi(opts.Get(optID))
+	itemPath := r.Header.Get("X-Local-Filepath")
+	if itemPath == f.userDir(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	parentID, err := f.filesDB.GetIDForPath(path.Dir(itemPath))
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if _, ok := f.allowed(w, r, parentID, modules.PermWrite); !ok {
 		return
 	}
-	id = int64(rawID)
+	isDir := opts.Get(optCmd) == optCmdCreateDir
 
-	if id == f.rootID {
-		w.WriteHeader(http.StatusForbidden)
+	id, err := f.filesDB.GetIDForPath(itemPath)
+	if err != nil {
+		f.createItem(w, r, parentID, path.Base(itemPath), isDir)
 		return
 	}
 
-	idPath, err := f.filesDB.GetPathForID(id)
+	fi, err := os.Stat(itemPath)
 	if err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	if !strings.HasPrefix(idPath, r.Header.Get("X-Local-Filepath")) {
-		w.WriteHeader(http.StatusForbidden)
+	switch {
+	case isDir && fi.IsDir():
+		f.info(w, id)
+		return
+	case isDir || fi.IsDir():
+		w.WriteHeader(http.StatusConflict)
+		return
+	}
+	// what was there is lost, which is as much as deleting it
+	if _, ok := f.allowed(w, r, id, modules.PermDelete); !ok {
+		return
+	}
+
+	// the file is replaced once the upload is complete
+	nf, err := ioutil.TempFile(path.Dir(itemPath), ".upload-")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, err = io.Copy(nf, r.Body)
+	nf.Close()
+	if err == nil {
+		err = os.Chmod(nf.Name(), fi.Mode().Perm())
+	}
+	if err == nil {
+		err = os.Rename(nf.Name(), itemPath)
+	}
+	if err != nil {
+		log.Printf("Failed to replace '%s': %v", itemPath, err)
+		os.Remove(nf.Name())
+		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	if err := f.filesDB.ImportItem(id, itemPath); err != nil {
+		log.Printf("%v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	f.info(w, id)
+}
 
-	err = f.filesDB.RemoveItem(id)
+// makeDirectory makes a directory in the parent directory, the name gets a suffix when it is taken
+func (f *files) makeDirectory(parentID int64, name string) (int64, error) {
+	id, err := f.filesDB.CreateItemPlaceholder(parentID, name)
+	if err != nil {
+		return 0, err
+	}
+	dirPath, err := f.filesDB.GetPathForID(id)
+	if err != nil {
+		f.filesDB.DeleteItemPlaceholder(id)
+		return 0, err
+	}
+	// the directory might exist. Do not rase an error, just consume existing directory.
+	os.Mkdir(dirPath, 0755)
+	if err := f.filesDB.ImportItem(id, dirPath); err != nil {
+		f.filesDB.DeleteItemPlaceholder(id)
+		return 0, err
+	}
+	return id, nil
+}
+
+func (f *files) deleteFile(w http.ResponseWriter, r *http.Request, rootID int64) {
+	opts, err := url.ParseQuery(r.URL.RawQuery)
 	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, ok := f.itemID(w, r, rootID, opts.Get(optID))
+	if !ok {
+		return
+	}
+
+	if id < 0 {
+		f.deleteSavedSearch(w, r, id)
+		return
+	}
+
+	if f.isRoot(id) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	idPath, ok := f.allowed(w, r, id, modules.PermDelete)
+	if !ok {
+		return
+	}
+
+	if err := f.removeItem(id, idPath); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	w.WriteHeader(http.StatusOK)
+}
+
+// removeItem forgets the item, then deletes it from disk
+func (f *files) removeItem(id int64, idPath string) error {
+	if err := f.filesDB.RemoveItem(id); err != nil {
+		return err
+	}
 	if err := os.Remove(idPath); err != nil {
 		log.Printf("Failed to delete '%s' due to '%s'", idPath, err.Error())
 	}
-
-	w.WriteHeader(http.StatusOK)
+	return nil
 }
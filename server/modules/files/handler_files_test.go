@@ -0,0 +1,324 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/akokshar/storage/server/modules"
+	"github.com/akokshar/storage/server/modules/filesdb"
+)
+
+// newTestFiles serves the files below dir/files, requests are sent as the server would
+func newTestFiles(dir string) (*files, func(user, method, target string, body io.Reader) *httptest.ResponseRecorder) {
+	f := New(filesdb.NewFilesDB(path.Join(dir, ".meta.db")), "/files", path.Join(dir, "files")).(*files)
+	request := func(user, method, target string, body io.Reader) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, body)
+		r.Header.Set("X-Authenticated-User", user)
+		r.Header.Set("X-Local-Filepath", path.Join(f.basedir, strings.TrimPrefix(r.URL.Path, f.routePrefix)))
+		w := httptest.NewRecorder()
+		f.ServeHTTPRequest(w, r)
+		return w
+	}
+	return f, request
+}
+
+func TestUserRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// stored before there were users
+	os.MkdirAll(path.Join(dir, "files/carol"), 0755)
+	ioutil.WriteFile(path.Join(dir, "files/old.txt"), []byte("old"), 0644)
+
+	f, request := newTestFiles(dir)
+	id := func(p string) int64 {
+		id, err := f.filesDB.GetIDForPath(path.Join(f.basedir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	if w := request("alice", "PUT", "/files/docs?cmd=createDir", nil); w.Code != http.StatusCreated {
+		t.Fatalf("alice makes a directory: %d", w.Code)
+	}
+	if w := request("alice", "PUT", "/files/docs/a.txt", strings.NewReader("a")); w.Code != http.StatusCreated {
+		t.Fatalf("alice uploads: %d", w.Code)
+	}
+	if w := request("bob", "PUT", "/files/b.txt", strings.NewReader("b")); w.Code != http.StatusCreated {
+		t.Fatalf("bob uploads: %d", w.Code)
+	}
+	aliceRoot, docs, file, old := id("alice"), id("alice/docs"), id("alice/docs/a.txt"), id("old.txt")
+
+	cases := []struct {
+		user   string
+		method string
+		target string
+		status int
+	}{
+		{"bob", "GET", fmt.Sprintf("/files?id=%d", file), http.StatusForbidden},
+		{"bob", "GET", fmt.Sprintf("/files?id=%d&cmd=children", docs), http.StatusForbidden},
+		{"bob", "GET", fmt.Sprintf("/files?id=%d&cmd=tree", f.rootID), http.StatusForbidden},
+		{"bob", "GET", fmt.Sprintf("/files?id=%d", old), http.StatusForbidden},
+		{"bob", "DELETE", fmt.Sprintf("/files?id=%d", file), http.StatusForbidden},
+		{"bob", "GET", "/files/docs/a.txt", http.StatusNotFound},
+		{"bob", "GET", "/files/alice/docs/a.txt", http.StatusNotFound},
+		{"bob", "PUT", "/files/alice/docs/a.txt", http.StatusNotFound},
+		{"alice", "DELETE", "/files?id=NSFileProviderRootContainerItemIdentifier", http.StatusForbidden},
+		{"alice", "GET", "/files/docs/a.txt", http.StatusOK},
+		// a directory which is there already is not taken
+		{"carol", "GET", "/files?cmd=info", http.StatusConflict},
+	}
+	for _, c := range cases {
+		if w := request(c.user, c.method, c.target, nil); w.Code != c.status {
+			t.Errorf("%s %s %s: %d", c.user, c.method, c.target, w.Code)
+		}
+	}
+
+	batch := fmt.Sprintf(`[
+		{"op": "info", "id": %[1]d},
+		{"op": "move", "id": %[1]d, "parentId": "NSFileProviderRootContainerItemIdentifier"},
+		{"op": "move", "id": %[3]d, "parentId": %[2]d},
+		{"op": "createDir", "parentId": %[2]d, "name": "x"},
+		{"op": "delete", "id": %[2]d}
+	]`, file, docs, id("bob/b.txt"))
+	w := request("bob", "POST", "/files?cmd=batch", strings.NewReader(batch))
+	var answer struct {
+		Results []batchResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &answer); err != nil || len(answer.Results) != 5 {
+		t.Fatalf("batch: %d %s", w.Code, w.Body.String())
+	}
+	for i, res := range answer.Results {
+		if res.Status != http.StatusForbidden {
+			t.Errorf("batch operation %d: %d", i, res.Status)
+		}
+	}
+	if _, err := os.Stat(path.Join(f.basedir, "alice/docs/a.txt")); err != nil {
+		t.Errorf("the file of alice: %v", err)
+	}
+
+	// what was there before is handed over by the administrator
+	if err := f.filesDB.SetOwner(id("carol"), "carol"); err != nil {
+		t.Fatal(err)
+	}
+	if w := request("carol", "GET", "/files?cmd=info", nil); w.Code != http.StatusOK {
+		t.Errorf("carol after the handover: %d", w.Code)
+	}
+	if err := f.filesDB.SetOwner(f.rootID, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	cases = []struct {
+		user   string
+		method string
+		target string
+		status int
+	}{
+		{"admin", "GET", fmt.Sprintf("/files?id=%d", old), http.StatusOK},
+		{"admin", "GET", fmt.Sprintf("/files?id=%d", file), http.StatusForbidden},
+		{"admin", "GET", fmt.Sprintf("/files?id=%d&cmd=tree", f.rootID), http.StatusForbidden},
+		{"admin", "DELETE", fmt.Sprintf("/files?id=%d", aliceRoot), http.StatusForbidden},
+		{"admin", "DELETE", fmt.Sprintf("/files?id=%d", f.rootID), http.StatusForbidden},
+	}
+	for _, c := range cases {
+		if w := request(c.user, c.method, c.target, nil); w.Code != c.status {
+			t.Errorf("%s %s %s: %d", c.user, c.method, c.target, w.Code)
+		}
+	}
+
+	// what alice shares is reachable
+	if w := request("alice", "POST", fmt.Sprintf("/files?cmd=setAcl&id=%d&principal=user:bob&permissions=read", docs), nil); w.Code != http.StatusOK {
+		t.Fatalf("alice shares: %d", w.Code)
+	}
+	if w := request("bob", "GET", fmt.Sprintf("/files?id=%d", file), nil); w.Code != http.StatusOK || w.Body.String() != "a" {
+		t.Errorf("bob reads what is shared: %d", w.Code)
+	}
+}
+
+func TestPathAccess(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(path.Join(dir, "files"), 0755)
+
+	f, request := newTestFiles(dir)
+	for _, target := range []string{"/files/docs?cmd=createDir", "/files/docs/a.txt", "/files/docs/b.txt"} {
+		if w := request("alice", "PUT", target, strings.NewReader("a")); w.Code != http.StatusCreated {
+			t.Fatalf("alice puts %s: %d", target, w.Code)
+		}
+	}
+	id := func(p string) int64 {
+		id, err := f.filesDB.GetIDForPath(path.Join(f.basedir, "alice", p))
+		if err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+		return id
+	}
+	info := func(target string) (int64, int64) {
+		var meta struct {
+			ID   int64 `json:"id"`
+			Size int64 `json:"size"`
+		}
+		w := request("alice", "GET", target, nil)
+		if err := json.Unmarshal(w.Body.Bytes(), &meta); err != nil {
+			t.Errorf("%s: %d %s", target, w.Code, w.Body.String())
+		}
+		return meta.ID, meta.Size
+	}
+
+	// the path of the URL names the item unless the ID does
+	cases := []struct {
+		target string
+		want   int64
+	}{
+		{"/files?cmd=info", id("")},
+		{"/files/docs?cmd=info", id("docs")},
+		{"/files/docs/a.txt?cmd=info", id("docs/a.txt")},
+		{"/files?id=NSFileProviderRootContainerItemIdentifier&cmd=info", id("")},
+		{fmt.Sprintf("/files/docs?id=%d&cmd=info", id("docs/b.txt")), id("docs/b.txt")},
+	}
+	for _, c := range cases {
+		if got, _ := info(c.target); got != c.want {
+			t.Errorf("%s: %d, want %d", c.target, got, c.want)
+		}
+	}
+
+	statuses := []struct {
+		method string
+		target string
+		status int
+	}{
+		{"GET", "/files?id=docs", http.StatusBadRequest},
+		{"GET", "/files/docs/missing.txt", http.StatusNotFound},
+		{"DELETE", "/files/docs/missing.txt", http.StatusNotFound},
+		{"PUT", "/files/missing/a.txt", http.StatusNotFound},
+		{"PUT", "/files", http.StatusForbidden},
+		{"PUT", "/files/docs", http.StatusConflict},
+		{"PUT", "/files/docs/a.txt?cmd=createDir", http.StatusConflict},
+		{"PUT", "/files/docs?cmd=createDir", http.StatusOK},
+	}
+	for _, c := range statuses {
+		if w := request("alice", c.method, c.target, strings.NewReader("x")); w.Code != c.status {
+			t.Errorf("%s %s: %d", c.method, c.target, w.Code)
+		}
+	}
+
+	// a file put again is replaced in place, keeping its ID and mode
+	a := path.Join(f.basedir, "alice/docs/a.txt")
+	os.Chmod(a, 0600)
+	if w := request("alice", "PUT", "/files/docs/a.txt", strings.NewReader("replaced")); w.Code != http.StatusOK {
+		t.Errorf("replace: %d", w.Code)
+	}
+	if got, size := info("/files/docs/a.txt?cmd=info"); got != id("docs/a.txt") || size != int64(len("replaced")) {
+		t.Errorf("replaced: %d of %d bytes", got, size)
+	}
+	if w := request("alice", "GET", "/files/docs/a.txt", nil); w.Body.String() != "replaced" {
+		t.Errorf("replaced: %q", w.Body.String())
+	}
+	if fi, err := os.Stat(a); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("mode: %v %v", fi.Mode(), err)
+	}
+	entries, _ := ioutil.ReadDir(path.Dir(a))
+	if len(entries) != 2 {
+		t.Errorf("left in docs: %d entries", len(entries))
+	}
+
+	if w := request("alice", "DELETE", "/files/docs/b.txt", nil); w.Code != http.StatusOK {
+		t.Errorf("delete: %d", w.Code)
+	}
+	if _, err := os.Stat(path.Join(f.basedir, "alice/docs/b.txt")); !os.IsNotExist(err) {
+		t.Errorf("deleted: %v", err)
+	}
+}
+
+func TestItemNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(path.Join(dir, "files"), 0755)
+
+	f, request := newTestFiles(dir)
+	if w := request("bob", "PUT", "/files/secret.txt", strings.NewReader("bob")); w.Code != http.StatusCreated {
+		t.Fatalf("bob uploads: %d", w.Code)
+	}
+	if w := request("alice", "PUT", "/files/docs?cmd=createDir", nil); w.Code != http.StatusCreated {
+		t.Fatalf("alice makes a directory: %d", w.Code)
+	}
+	aliceRoot, err := f.filesDB.GetIDForPath(path.Join(f.basedir, "alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// names are one component of a path, nothing reaches out of the parent
+	for _, name := range []string{"../bob/secret.txt", "..", ".", "", "docs/x", "x%00"} {
+		for _, cmd := range []string{"", "&cmd=createDir", "&cmd=saveSearch"} {
+			target := fmt.Sprintf("/files?parentId=%d&name=%s%s", aliceRoot, name, cmd)
+			if w := request("alice", "POST", target, strings.NewReader("alice")); w.Code != http.StatusBadRequest {
+				t.Errorf("alice POST %s: %d", target, w.Code)
+			}
+		}
+	}
+	if content, err := ioutil.ReadFile(path.Join(f.basedir, "bob/secret.txt")); err != nil || string(content) != "bob" {
+		t.Errorf("the file of bob: %q %v", content, err)
+	}
+	if items, _ := ioutil.ReadDir(path.Join(f.basedir, "alice")); len(items) != 1 {
+		t.Errorf("alice has %d items", len(items))
+	}
+	if _, err := f.filesDB.CreateItemPlaceholder(aliceRoot, "../bob/secret.txt"); err == nil {
+		t.Errorf("placeholder out of the parent")
+	}
+}
+
+func TestReplacePermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(path.Join(dir, "files"), 0755)
+
+	f, request := newTestFiles(dir)
+	for _, target := range []string{"/files/inbox?cmd=createDir", "/files/inbox/a.txt"} {
+		if w := request("alice", "PUT", target, strings.NewReader("a")); w.Code != http.StatusCreated {
+			t.Fatalf("alice puts %s: %d", target, w.Code)
+		}
+	}
+	// the inbox in the root of alice is handed over to bob, who lets alice upload only
+	inbox, err := f.filesDB.GetIDForPath(path.Join(f.basedir, "alice/inbox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.filesDB.SetOwner(inbox, "bob")
+	f.filesDB.SetACL(inbox, "user:alice", modules.PermRead|modules.PermWrite)
+
+	if w := request("alice", "PUT", "/files/inbox/b.txt", strings.NewReader("b")); w.Code != http.StatusCreated {
+		t.Errorf("alice uploads: %d", w.Code)
+	}
+	if w := request("alice", "PUT", "/files/inbox/a.txt", strings.NewReader("replaced")); w.Code != http.StatusForbidden {
+		t.Errorf("alice replaces without delete: %d", w.Code)
+	}
+	if content, _ := ioutil.ReadFile(path.Join(f.basedir, "alice/inbox/a.txt")); string(content) != "a" {
+		t.Errorf("replaced without delete: %q", content)
+	}
+	f.filesDB.SetACL(inbox, "user:alice", modules.PermRead|modules.PermWrite|modules.PermDelete)
+	if w := request("alice", "PUT", "/files/inbox/a.txt", strings.NewReader("replaced")); w.Code != http.StatusOK {
+		t.Errorf("alice replaces with delete: %d", w.Code)
+	}
+	if content, _ := ioutil.ReadFile(path.Join(f.basedir, "alice/inbox/a.txt")); string(content) != "replaced" {
+		t.Errorf("replaced with delete: %q", content)
+	}
+}
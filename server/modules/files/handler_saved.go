@@ -0,0 +1,117 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+const (
+	optCmdSaveSearch    = "saveSearch"
+	optCmdEditSearch    = "editSearch"
+	optCmdSavedSearches = "searches"
+)
+
+// writeError answers with the code carried by modules.HandlerError and tells what went wrong,
+// or 500 for anything else
+func writeError(w http.ResponseWriter, err error) {
+	if handlerErr, ok := err.(modules.HandlerError); ok {
+		http.Error(w, err.Error(), handlerErr.Code())
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// savedSearchAllowed answers for the client when the directory showing the saved search
+// is gone or does not grant the permission. What the search finds comes from anywhere below
+// the directory, which has to be readable as a whole.
+func (f *files) savedSearchAllowed(w http.ResponseWriter, r *http.Request, id int64, perm int) bool {
+	parentID, err := f.filesDB.GetSavedSearchParent(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return false
+	}
+	if _, ok := f.allowed(w, r, parentID, perm); !ok {
+		return false
+	}
+	return f.treeReadable(w, r, parentID)
+}
+
+// getSavedSearch serves the directory of a saved search, which has no content of its own
+func (f *files) getSavedSearch(w http.ResponseWriter, r *http.Request, id int64, opts url.Values) {
+	if !f.savedSearchAllowed(w, r, id, modules.PermRead) {
+		return
+	}
+	switch opts.Get(optCmd) {
+	case optCmdInfo:
+		f.info(w, id)
+	case optCmdListChanges:
+		f.listChanges(w, id, opts)
+	case optCmdChildren:
+		f.children(w, id, opts)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// savedSearches lists saved searches in the directory and below it
+func (f *files) savedSearches(w http.ResponseWriter, id int64) {
+	searches := f.filesDB.GetSavedSearches(id)
+	if searches == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	searchesJSON, _ := json.MarshalIndent(searches, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(searchesJSON)
+}
+
+func (f *files) saveSearch(w http.ResponseWriter, parentID int64, name string, opts url.Values) {
+	id, err := f.filesDB.SaveSearch(parentID, name, opts.Get(optQuery))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	metaData := f.filesDB.GetMetaDataForItemWithID(id)
+	metaJSON, _ := json.MarshalIndent(metaData, "", "  ")
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(metaJSON)
+}
+
+func (f *files) editSearch(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, err := strconv.Atoi(opts.Get(optID))
+	if err != nil || id >= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	name := opts.Get(optName)
+	if !modules.ValidItemName(name) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !f.savedSearchAllowed(w, r, int64(id), modules.PermWrite) {
+		return
+	}
+
+	if err := f.filesDB.UpdateSavedSearch(int64(id), name, opts.Get(optQuery)); err != nil {
+		writeError(w, err)
+		return
+	}
+	f.info(w, int64(id))
+}
+
+func (f *files) deleteSavedSearch(w http.ResponseWriter, r *http.Request, id int64) {
+	if !f.savedSearchAllowed(w, r, id, modules.PermDelete) {
+		return
+	}
+	if err := f.filesDB.DeleteSavedSearch(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,124 @@
+package files
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+func TestSavedSearches(t *testing.T) {
+	dir, err := ioutil.TempDir("", "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(path.Join(dir, "files"), 0755)
+
+	f, request := newTestFiles(dir)
+	for _, target := range []string{"/files/docs?cmd=createDir", "/files/docs/private?cmd=createDir", "/files/docs/a.txt", "/files/docs/b.pdf", "/files/docs/private/c.txt"} {
+		if w := request("alice", "PUT", target, strings.NewReader("x")); w.Code != http.StatusCreated {
+			t.Fatalf("alice puts %s: %d", target, w.Code)
+		}
+	}
+	id := func(p string) int64 {
+		id, err := f.filesDB.GetIDForPath(path.Join(f.basedir, "alice", p))
+		if err != nil {
+			t.Fatalf("%s: %v", p, err)
+		}
+		return id
+	}
+	docs, private := id("docs"), id("docs/private")
+	save := func(name string, query string) *httptest.ResponseRecorder {
+		return request("alice", "POST", fmt.Sprintf("/files?cmd=saveSearch&parentId=%d&name=%s&q=%s", docs, name, url.QueryEscape(query)), nil)
+	}
+
+	w := save("texts", "name:*.txt")
+	var search struct {
+		ID       int64  `json:"id"`
+		ParentID int64  `json:"parentId"`
+		CType    string `json:"ctype"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &search); err != nil || w.Code != http.StatusCreated {
+		t.Fatalf("alice saves a search: %d %s", w.Code, w.Body.String())
+	}
+	// saved searches are directories with IDs no item has
+	if search.ID >= 0 || search.ParentID != docs || search.CType != "folder" {
+		t.Errorf("saved search: %+v", search)
+	}
+	if w := save("texts", "name:*.pdf"); w.Code != http.StatusConflict {
+		t.Errorf("name taken: %d", w.Code)
+	}
+	if w := save("broken", "size>lots"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad query: %d", w.Code)
+	}
+
+	var children struct {
+		Files []struct {
+			Name string `json:"name"`
+		} `json:"files"`
+	}
+	w = request("alice", "GET", fmt.Sprintf("/files?id=%d&cmd=children", search.ID), nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &children); err != nil {
+		t.Fatalf("found: %d %s", w.Code, w.Body.String())
+	}
+	found := make([]string, 0)
+	for _, c := range children.Files {
+		found = append(found, c.Name)
+	}
+	sort.Strings(found)
+	if fmt.Sprint(found) != "[a.txt c.txt]" {
+		t.Errorf("found: %v", found)
+	}
+
+	// saved searches are as permitted as their directory, which has to be readable as a whole
+	cases := []struct {
+		context string
+		grant   func()
+		method  string
+		target  string
+		status  int
+	}{
+		{"nothing granted", nil, "GET", fmt.Sprintf("/files?id=%d&cmd=info", search.ID), http.StatusForbidden},
+		{"read granted", func() {
+			f.filesDB.SetACL(docs, "user:bob", modules.PermRead)
+			f.filesDB.SetACL(private, "user:bob", modules.PermNone)
+		}, "GET", fmt.Sprintf("/files?id=%d&cmd=info", search.ID), http.StatusForbidden},
+		{"all read", func() { f.filesDB.ResetACL(private, "user:bob") }, "GET", fmt.Sprintf("/files?id=%d&cmd=info", search.ID), http.StatusOK},
+		{"read only", nil, "POST", fmt.Sprintf("/files?cmd=editSearch&id=%d&name=mine&q=name:*", search.ID), http.StatusForbidden},
+		{"read only", nil, "DELETE", fmt.Sprintf("/files?id=%d", search.ID), http.StatusForbidden},
+		{"write granted", func() { f.filesDB.SetACL(docs, "user:bob", modules.PermRead|modules.PermWrite) }, "POST", fmt.Sprintf("/files?cmd=editSearch&id=%d&name=mine&q=name:*", search.ID), http.StatusOK},
+		{"write granted", nil, "DELETE", fmt.Sprintf("/files?id=%d", search.ID), http.StatusForbidden},
+		// directories of saved searches are read only
+		{"inside", nil, "POST", fmt.Sprintf("/files?cmd=createDir&parentId=%d&name=x", search.ID), http.StatusForbidden},
+		{"not a search", nil, "POST", fmt.Sprintf("/files?cmd=editSearch&id=%d&name=x&q=name:*", docs), http.StatusBadRequest},
+		{"unknown", nil, "GET", "/files?id=-999&cmd=info", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if c.grant != nil {
+			c.grant()
+		}
+		if w := request("bob", c.method, c.target, nil); w.Code != c.status {
+			t.Errorf("%s: bob %s %s: %d", c.context, c.method, c.target, w.Code)
+		}
+	}
+
+	if w := request("alice", "DELETE", fmt.Sprintf("/files?id=%d", search.ID), nil); w.Code != http.StatusOK {
+		t.Errorf("alice deletes the search: %d", w.Code)
+	}
+	if w := request("alice", "GET", fmt.Sprintf("/files?id=%d&cmd=info", search.ID), nil); w.Code != http.StatusNotFound {
+		t.Errorf("deleted search: %d", w.Code)
+	}
+	if _, err := os.Stat(path.Join(f.basedir, "alice/docs/a.txt")); err != nil {
+		t.Errorf("what the search found: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package files
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	optCmdSearch          = "search"
+	optQuery              = "q"
+	optMode               = "mode"
+	modePrefix            = "prefix"
+	modeSubstring         = "substring"
+	modeContent           = "content"
+	optCmdQuery           = "query"
+	optSort               = "sort"
+	optOrder              = "order"
+	orderAscending        = "asc"
+	orderDescending       = "desc"
+	optOffset             = "offset"
+	optOffsetDefaultValue = 0
+)
+
+func intOpt(opts url.Values, name string, defaultValue int) int {
+	value, err := strconv.Atoi(opts.Get(name))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// search looks for items below the directory by name, or documents by their text
+func (f *files) search(w http.ResponseWriter, id int64, opts url.Values) {
+	query := opts.Get(optQuery)
+	if query == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+
+	var result interface{}
+	switch opts.Get(optMode) {
+	case "", modePrefix:
+		result = f.filesDB.SearchNames(id, query, false, offset, count)
+	case modeSubstring:
+		result = f.filesDB.SearchNames(id, query, true, offset, count)
+	case modeContent:
+		result = f.filesDB.SearchContent(id, query, offset, count)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if result == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resultJSON)
+}
+
+// descendingOpt tells the order asked for, ascending unless given
+func descendingOpt(opts url.Values) (descending bool, ok bool) {
+	switch opts.Get(optOrder) {
+	case "", orderAscending:
+		return false, true
+	case orderDescending:
+		return true, true
+	}
+	return false, false
+}
+
+// query lists items below the directory matching a query of the filesdb query language
+func (f *files) query(w http.ResponseWriter, id int64, opts url.Values) {
+	descending, ok := descendingOpt(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+
+	result, err := f.filesDB.QueryItems(id, opts.Get(optQuery), opts.Get(optSort), descending, offset, count)
+	if err != nil {
+		// tell what is wrong with the query
+		writeError(w, err)
+		return
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resultJSON)
+}
@@ -0,0 +1,347 @@
+package filesdb
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+// Directories grant permissions to principals, "user:<name>" or "group:<name>". Permissions
+// are inherited down the tree, a directory with entries of its own for the user or their
+// groups overrides what it would inherit. Among the entries of that directory, the one of
+// the user goes before those of the groups, which add up.
+//
+// Directories owned by a user are roots of theirs. Nothing is inherited across them, what the
+// directories above grant stops at the root of a user.
+
+// createACLs creates the tables of the entries and of the owners of directories
+func (m *filesDB) createACLs() {
+	_, err := m.database.Exec(`
+		CREATE TABLE IF NOT EXISTS acls (
+			dir_id INTEGER NOT NULL,
+			principal TEXT NOT NULL COLLATE NOCASE,
+			permissions INTEGER NOT NULL, /* modules.Perm* */
+
+			PRIMARY KEY (dir_id, principal),
+
+			CONSTRAINT fk_acl_dir
+				FOREIGN KEY (dir_id)
+				REFERENCES files (id)
+				ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS i_acls_principal ON acls (principal);
+
+		CREATE TABLE IF NOT EXISTS owners (
+			dir_id INTEGER PRIMARY KEY,
+			name TEXT NOT NULL COLLATE NOCASE,
+
+			CONSTRAINT fk_owner_dir
+				FOREIGN KEY (dir_id)
+				REFERENCES files (id)
+				ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// aclEntry is what a directory grants to a principal, inherited from the directory in from
+type aclEntry struct {
+	Principal   string   `json:"principal"`
+	Permissions []string `json:"permissions"`
+	Inherited   bool     `json:"inherited"`
+	From        int64    `json:"from"`
+}
+
+// principals are the user and their groups, the user first
+func principals(user string, groups []string) []interface{} {
+	p := []interface{}{modules.UserPrincipal(user)}
+	for _, g := range groups {
+		p = append(p, modules.GroupPrincipal(g))
+	}
+	return p
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// ancestors are the item and the directories above it up to the nearest owned one, nearest first
+const ancestors = `
+	WITH RECURSIVE up (id, level) AS (
+		SELECT ?, 0
+		UNION ALL
+		SELECT files.parent_id, up.level + 1 FROM files JOIN up ON files.id = up.id
+		WHERE files.parent_id IS NOT NULL AND files.id NOT IN (SELECT dir_id FROM owners)
+	)`
+
+// SetOwner hands the directory with everything below it over to the user
+func (m *filesDB) SetOwner(dirID int64, user string) error {
+	if !modules.ValidPrincipal(modules.UserPrincipal(user)) {
+		return errBadRequest
+	}
+	if err := m.checkDirectory(dirID); err != nil {
+		return err
+	}
+	_, err := m.database.Exec(`
+		INSERT INTO owners (dir_id, name) VALUES (?, ?)
+		ON CONFLICT (dir_id) DO UPDATE SET name = excluded.name`,
+		dirID, user)
+	return err
+}
+
+// GetOwner finds who owns the item, and the root of theirs it is in. Nobody owns items outside
+// the roots of users, for which the root is 0.
+func (m *filesDB) GetOwner(id int64) (string, int64) {
+	if isVirtualID(id) {
+		parentID, err := m.GetSavedSearchParent(id)
+		if err != nil {
+			return "", 0
+		}
+		id = parentID
+	}
+
+	var user string
+	var rootID int64
+	row := m.database.QueryRow(ancestors+`
+		SELECT owners.name, owners.dir_id FROM up JOIN owners ON owners.dir_id = up.id
+		ORDER BY up.level LIMIT 1`,
+		id)
+	if err := row.Scan(&user, &rootID); err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("%v", err)
+		}
+		return "", 0
+	}
+	return user, rootID
+}
+
+// ForgetUser drops what directories grant to the user, so that a user added later under the
+// name gets none of it. Directories the user owns have to be handed over first.
+func (m *filesDB) ForgetUser(user string) error {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+	var owned int
+	if err := tx.QueryRow(`SELECT count(*) FROM owners WHERE name = ?`, user).Scan(&owned); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if owned > 0 {
+		tx.Rollback()
+		return errConflict
+	}
+	if _, err := tx.Exec(`DELETE FROM acls WHERE principal = ?`, modules.UserPrincipal(user)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetPermissions finds what the user may do with the item, saved searches are as permitted
+// as the directory showing them
+func (m *filesDB) GetPermissions(id int64, user string, groups []string) int {
+	if isVirtualID(id) {
+		parentID, err := m.GetSavedSearchParent(id)
+		if err != nil {
+			return modules.PermNone
+		}
+		id = parentID
+	}
+
+	p := principals(user, groups)
+	args := append([]interface{}{id}, p...)
+	rows, err := m.database.Query(ancestors+`
+		SELECT up.level, acls.principal, acls.permissions
+		FROM up JOIN acls ON acls.dir_id = up.id
+		WHERE acls.principal IN (`+placeholders(len(p))+`)
+		ORDER BY up.level`,
+		args...)
+	if err != nil {
+		log.Printf("%v", err)
+		return modules.PermNone
+	}
+	defer rows.Close()
+
+	level := -1
+	perms := modules.PermNone
+	for rows.Next() {
+		var l, entry int
+		var principal string
+		if err := rows.Scan(&l, &principal, &entry); err != nil {
+			log.Printf("%v", err)
+			return modules.PermNone
+		}
+		if level >= 0 && l != level {
+			break
+		}
+		level = l
+		if strings.EqualFold(principal, p[0].(string)) {
+			return entry
+		}
+		perms |= entry
+	}
+	return perms
+}
+
+// RestrictedBelow tells whether a directory below dirID keeps the user from reading it, such
+// directories have to stay out of searches and trees of dirID. Roots of other users below
+// dirID inherit nothing, so they keep the user out unless they grant reading themselves.
+func (m *filesDB) RestrictedBelow(dirID int64, user string, groups []string) bool {
+	p := principals(user, groups)
+	args := append([]interface{}{dirID}, p...)
+	args = append(args, user)
+	rows, err := m.database.Query(`
+		WITH RECURSIVE tree (id) AS (
+			SELECT id FROM files WHERE parent_id = ?
+			UNION ALL
+			SELECT files.id FROM files JOIN tree ON files.parent_id = tree.id
+		)
+		SELECT acls.dir_id FROM acls JOIN tree ON tree.id = acls.dir_id
+		WHERE acls.principal IN (`+placeholders(len(p))+`)
+		UNION
+		SELECT owners.dir_id FROM owners JOIN tree ON tree.id = owners.dir_id
+		WHERE owners.name != ?`,
+		args...)
+	if err != nil {
+		log.Printf("%v", err)
+		return true
+	}
+	dirs := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			log.Printf("%v", err)
+			return true
+		}
+		dirs = append(dirs, id)
+	}
+	rows.Close()
+
+	for _, id := range dirs {
+		if m.GetPermissions(id, user, groups)&modules.PermRead == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetACL lists for every principal named by the directory or a directory above it what the
+// directory grants, and where that comes from
+func (m *filesDB) GetACL(dirID int64) (interface{}, error) {
+	if err := m.checkDirectory(dirID); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.database.Query(ancestors+`
+		SELECT up.id, up.level, acls.principal, acls.permissions
+		FROM up JOIN acls ON acls.dir_id = up.id
+		ORDER BY up.level, acls.principal`,
+		dirID)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*aclEntry, 0)
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var from int64
+		var level, perms int
+		var principal string
+		if err := rows.Scan(&from, &level, &principal, &perms); err != nil {
+			log.Printf("%v", err)
+			return nil, err
+		}
+		if seen[strings.ToLower(principal)] {
+			continue
+		}
+		seen[strings.ToLower(principal)] = true
+		entries = append(entries, &aclEntry{
+			Principal:   principal,
+			Permissions: modules.PermissionNames(perms),
+			Inherited:   level > 0,
+			From:        from,
+		})
+	}
+	return entries, rows.Err()
+}
+
+// checkDirectory fails unless the item is a directory
+func (m *filesDB) checkDirectory(id int64) error {
+	var ctype sql.NullString
+	if err := m.database.QueryRow(`SELECT ctype FROM files WHERE id = ?`, id).Scan(&ctype); err != nil {
+		return errNotFound
+	}
+	if ctype.String != contentTypeDirectory {
+		return errBadRequest
+	}
+	return nil
+}
+
+// SetACL makes the directory grant the permissions to the principal, whatever it inherits
+func (m *filesDB) SetACL(dirID int64, principal string, permissions int) error {
+	if !modules.ValidPrincipal(principal) || permissions&^modules.PermAll != 0 {
+		return errBadRequest
+	}
+	if err := m.checkDirectory(dirID); err != nil {
+		return err
+	}
+	_, err := m.database.Exec(`
+		INSERT INTO acls (dir_id, principal, permissions) VALUES (?, ?, ?)
+		ON CONFLICT (dir_id, principal) DO UPDATE SET permissions = excluded.permissions`,
+		dirID, principal, permissions)
+	return err
+}
+
+// ResetACL drops the entry of the principal, so that the directory inherits again
+func (m *filesDB) ResetACL(dirID int64, principal string) error {
+	res, err := m.database.Exec(`DELETE FROM acls WHERE dir_id = ? AND principal = ?`, dirID, principal)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// GetShared lists the directories granting the user or their groups to read them
+func (m *filesDB) GetShared(user string, groups []string) interface{} {
+	p := principals(user, groups)
+	rows, err := m.database.Query(`
+		SELECT `+fileColumns+` FROM files
+		WHERE files.id IN (SELECT dir_id FROM acls WHERE principal IN (`+placeholders(len(p))+`))
+		ORDER BY files.sname, files.id`,
+		p...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	candidates := make([]*fileMeta, 0)
+	for rows.Next() {
+		fm, err := scanFileMeta(rows)
+		if err != nil {
+			rows.Close()
+			log.Printf("%v", err)
+			return nil
+		}
+		candidates = append(candidates, fm)
+	}
+	rows.Close()
+
+	shared := make([]*fileMeta, 0)
+	for _, fm := range candidates {
+		if m.GetPermissions(fm.ID, user, groups)&modules.PermRead != 0 {
+			shared = append(shared, fm)
+		}
+	}
+	return shared
+}
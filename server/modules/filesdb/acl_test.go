@@ -0,0 +1,189 @@
+package filesdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+func TestPermissions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(path.Join(dir, "files/proj/secret/deeper"), 0755)
+	ioutil.WriteFile(path.Join(dir, "files/proj/secret/deeper/a.txt"), []byte("a"), 0644)
+
+	db := NewFilesDB(path.Join(dir, ".meta.db"))
+	db.ScanPath(path.Join(dir, "files"))
+	id := func(p string) int64 {
+		id, err := db.GetIDForPath(path.Join(dir, "files", p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	proj, secret, file := id("proj"), id("proj/secret"), id("proj/secret/deeper/a.txt")
+
+	if perms := db.GetPermissions(file, "bob", nil); perms != modules.PermNone {
+		t.Errorf("nothing granted: %v", modules.PermissionNames(perms))
+	}
+	if err := db.SetACL(file, "user:bob", modules.PermRead); err != errBadRequest {
+		t.Errorf("entry on a file: %v", err)
+	}
+	if err := db.SetACL(proj, "bob", modules.PermRead); err != errBadRequest {
+		t.Errorf("principal without a kind: %v", err)
+	}
+
+	db.SetACL(proj, "group:team", modules.PermRead|modules.PermWrite)
+	db.SetACL(proj, "group:readers", modules.PermRead|modules.PermShare)
+	cases := []struct {
+		id     int64
+		user   string
+		groups []string
+		perms  int
+	}{
+		{file, "bob", []string{"team"}, modules.PermRead | modules.PermWrite},
+		{file, "bob", []string{"team", "Readers"}, modules.PermRead | modules.PermWrite | modules.PermShare},
+		{file, "carol", nil, modules.PermNone},
+	}
+	for _, c := range cases {
+		if perms := db.GetPermissions(c.id, c.user, c.groups); perms != c.perms {
+			t.Errorf("%s %v: %v", c.user, c.groups, modules.PermissionNames(perms))
+		}
+	}
+
+	// the nearest directory decides, and the entry of the user goes before those of groups
+	db.SetACL(secret, "user:bob", modules.PermNone)
+	db.SetACL(secret, "group:team", modules.PermRead)
+	if perms := db.GetPermissions(file, "bob", []string{"team"}); perms != modules.PermNone {
+		t.Errorf("override of the user: %v", modules.PermissionNames(perms))
+	}
+	if perms := db.GetPermissions(file, "dave", []string{"team", "readers"}); perms != modules.PermRead {
+		t.Errorf("override of a group: %v", modules.PermissionNames(perms))
+	}
+	if perms := db.GetPermissions(proj, "bob", []string{"team"}); perms != modules.PermRead|modules.PermWrite {
+		t.Errorf("above the override: %v", modules.PermissionNames(perms))
+	}
+	if !db.RestrictedBelow(proj, "bob", []string{"team"}) {
+		t.Errorf("bob can not read the secret")
+	}
+	if db.RestrictedBelow(proj, "dave", []string{"team"}) {
+		t.Errorf("dave can read the secret")
+	}
+
+	if err := db.ResetACL(secret, "user:bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ResetACL(secret, "user:bob"); err != errNotFound {
+		t.Errorf("reset twice: %v", err)
+	}
+	if perms := db.GetPermissions(file, "bob", []string{"team"}); perms != modules.PermRead {
+		t.Errorf("after the reset: %v", modules.PermissionNames(perms))
+	}
+
+	entries, err := db.GetACL(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []aclEntry{
+		{"group:team", []string{"read"}, false, secret},
+		{"group:readers", []string{"read", "share"}, true, proj},
+	}
+	got := entries.([]*aclEntry)
+	if len(got) != len(want) {
+		t.Fatalf("%+v", got)
+	}
+	for i := range want {
+		if got[i].Principal != want[i].Principal || got[i].Inherited != want[i].Inherited || got[i].From != want[i].From ||
+			len(got[i].Permissions) != len(want[i].Permissions) {
+			t.Errorf("%+v", got[i])
+		}
+	}
+}
+
+func TestOwners(t *testing.T) {
+	dir, err := ioutil.TempDir("", "acl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(path.Join(dir, "files/alice/docs"), 0755)
+	os.MkdirAll(path.Join(dir, "files/shared"), 0755)
+
+	db := NewFilesDB(path.Join(dir, ".meta.db"))
+	db.ScanPath(path.Join(dir, "files"))
+	id := func(p string) int64 {
+		id, err := db.GetIDForPath(path.Join(dir, "files", p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	files, alice, docs, shared := id(""), id("alice"), id("alice/docs"), id("shared")
+
+	if user, rootID := db.GetOwner(docs); user != "" || rootID != 0 {
+		t.Errorf("nobody owns it yet: %s %d", user, rootID)
+	}
+	if err := db.SetOwner(alice, "bad name"); err != errBadRequest {
+		t.Errorf("bad name: %v", err)
+	}
+	db.SetOwner(files, "admin")
+	db.SetOwner(alice, "alice")
+	cases := []struct {
+		id     int64
+		user   string
+		rootID int64
+	}{
+		{docs, "alice", alice},
+		{alice, "alice", alice},
+		{shared, "admin", files},
+	}
+	for _, c := range cases {
+		if user, rootID := db.GetOwner(c.id); user != c.user || rootID != c.rootID {
+			t.Errorf("owner of %d: %s %d", c.id, user, rootID)
+		}
+	}
+
+	// what is granted above a root stops there
+	db.SetACL(files, "group:everyone", modules.PermRead)
+	if perms := db.GetPermissions(shared, "bob", []string{"everyone"}); perms != modules.PermRead {
+		t.Errorf("outside the root: %v", modules.PermissionNames(perms))
+	}
+	if perms := db.GetPermissions(docs, "bob", []string{"everyone"}); perms != modules.PermNone {
+		t.Errorf("inside the root: %v", modules.PermissionNames(perms))
+	}
+	if !db.RestrictedBelow(files, "bob", []string{"everyone"}) {
+		t.Errorf("the root of alice is in the tree")
+	}
+	if !db.RestrictedBelow(files, "admin", nil) {
+		t.Errorf("the root of alice is in the tree of admin")
+	}
+	db.SetACL(alice, "user:bob", modules.PermRead)
+	if db.RestrictedBelow(files, "bob", []string{"everyone"}) {
+		t.Errorf("alice shares their root")
+	}
+
+	// a user added later under a removed name gets nothing of what was there
+	if err := db.ForgetUser("Alice"); err != errConflict {
+		t.Errorf("alice owns a root: %v", err)
+	}
+	db.SetOwner(alice, "admin")
+	db.SetACL(shared, "user:alice", modules.PermAll)
+	if err := db.ForgetUser("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if perms := db.GetPermissions(shared, "alice", nil); perms != modules.PermNone {
+		t.Errorf("after alice is gone: %v", modules.PermissionNames(perms))
+	}
+	if user, _ := db.GetOwner(docs); user != "admin" {
+		t.Errorf("owner of the former root of alice: %s", user)
+	}
+	if perms := db.GetPermissions(alice, "bob", nil); perms != modules.PermRead {
+		t.Errorf("what alice granted others: %v", modules.PermissionNames(perms))
+	}
+}
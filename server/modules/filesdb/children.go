@@ -0,0 +1,180 @@
+package filesdb
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"strconv"
+)
+
+// childSortColumns are what children can be sorted by, the sort key of the name breaks ties.
+// Directories go by the bytes of the files below them, as their size is a count of items.
+var childSortColumns = map[string]string{
+	"name":  "sname",
+	"size":  "CASE ctype WHEN '" + contentTypeDirectory + "' THEN coalesce(tree_size, 0) ELSE size END",
+	"mdate": "mdate",
+	"type":  "ctype",
+}
+
+// childrenMeta is a page of the items of a directory, next is the cursor of the following page
+type childrenMeta struct {
+	Files []*fileMeta `json:"files"`
+	Next  string      `json:"next,omitempty"`
+	Size  int64       `json:"size"`
+}
+
+// childCursor is where the previous page ended. It holds the sort values of the last item,
+// so pages stay in step while items come and go.
+type childCursor struct {
+	Sort       string          `json:"s"`
+	Descending bool            `json:"d,omitempty"`
+	Key        json.RawMessage `json:"k"`
+	Name       string          `json:"n"`
+	ID         int64           `json:"i"`
+}
+
+func (c *childCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor reads a cursor made for the same order
+func decodeCursor(cursor string, sortBy string, descending bool) (*childCursor, interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, nil, errBadRequest
+	}
+	c := new(childCursor)
+	if err := json.Unmarshal(b, c); err != nil || c.Sort != sortBy || c.Descending != descending {
+		return nil, nil, errBadRequest
+	}
+	var key interface{}
+	if sortBy == "name" || sortBy == "type" {
+		var s string
+		err = json.Unmarshal(c.Key, &s)
+		key = s
+	} else {
+		var n int64
+		err = json.Unmarshal(c.Key, &n)
+		key = n
+	}
+	if err != nil {
+		return nil, nil, errBadRequest
+	}
+	return c, key, nil
+}
+
+// children are the items of a directory along with the saved searches showing in it, or
+// the items a saved search found
+const children = `
+	WITH children (id, size, mdate, cdate, name, ctype, media, tree_size, tree_count, sname) AS (
+		SELECT ` + fileColumns + `, coalesce(files.sname, '')
+		FROM files WHERE files.parent_id = $1 AND files.ctype IS NOT NULL
+		UNION ALL
+		SELECT -saved_searches.id,
+			(SELECT count(*) FROM saved_search_items WHERE search_id = saved_searches.id),
+			saved_searches.mdate, saved_searches.cdate, saved_searches.name, '` + contentTypeDirectory + `',
+			NULL, NULL, NULL, coalesce(saved_searches.sname, '')
+		FROM saved_searches WHERE saved_searches.parent_id = $1
+		UNION ALL
+		SELECT ` + fileColumns + `, coalesce(files.sname, '')
+		FROM saved_search_items JOIN files ON files.id = saved_search_items.file_id
+		WHERE saved_search_items.search_id = -$1 AND files.ctype IS NOT NULL
+	)`
+
+// GetChildren lists a page of the items of the directory in the order of a column of
+// childSortColumns, starting after the cursor returned with the previous page
+func (m *filesDB) GetChildren(dirID int64, sortBy string, descending bool, cursor string, count int) (interface{}, error) {
+	if sortBy == "" {
+		sortBy = "name"
+	}
+	column, ok := childSortColumns[sortBy]
+	if !ok || count < 1 {
+		return nil, errBadRequest
+	}
+	order, after := "ASC", ">"
+	if descending {
+		order, after = "DESC", "<"
+	}
+
+	if isVirtualID(dirID) {
+		if err := m.refreshSavedSearch(-dirID); err != nil {
+			log.Printf("%v", err)
+			return nil, errNotFound
+		}
+	} else if _, err := m.GetPathForID(dirID); err != nil {
+		return nil, errNotFound
+	}
+
+	result := &childrenMeta{
+		Files: make([]*fileMeta, 0, count),
+	}
+	row := m.database.QueryRow(children+` SELECT count(*) FROM children`, dirID)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil, err
+	}
+
+	where := ""
+	args := []interface{}{dirID}
+	if cursor != "" {
+		c, key, err := decodeCursor(cursor, sortBy, descending)
+		if err != nil {
+			return nil, err
+		}
+		where = ` WHERE (` + column + `, sname, id) ` + after + ` ($2, $3, $4)`
+		args = append(args, key, c.Name, c.ID)
+	}
+	// one more tells whether there is a next page
+	args = append(args, count+1)
+
+	rows, err := m.database.Query(children+`
+		SELECT id, size, mdate, cdate, name, ctype, media, tree_size, tree_count, sname FROM children`+where+`
+		ORDER BY `+column+` `+order+`, sname `+order+`, id `+order+`
+		LIMIT $`+strconv.Itoa(len(args)),
+		args...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var last string
+	for rows.Next() {
+		var sname string
+		fm, err := scanFileMeta(rowSuffix{rows, []interface{}{&sname}})
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, err
+		}
+		if len(result.Files) == count {
+			c := &childCursor{Sort: sortBy, Descending: descending, Name: last, ID: result.Files[count-1].ID}
+			switch sortBy {
+			case "name":
+				c.Key, _ = json.Marshal(last)
+			case "type":
+				c.Key, _ = json.Marshal(result.Files[count-1].CType)
+			case "size":
+				c.Key, _ = json.Marshal(sizeKey(result.Files[count-1]))
+			case "mdate":
+				c.Key, _ = json.Marshal(result.Files[count-1].MDate)
+			}
+			result.Next = c.encode()
+			break
+		}
+		result.Files = append(result.Files, fm)
+		last = sname
+	}
+	return result, nil
+}
+
+// sizeKey is what the item is sorted by in the order of size
+func sizeKey(fm *fileMeta) int64 {
+	if fm.CType != contentTypeDirectory {
+		return fm.Size
+	}
+	if fm.TreeSize == nil {
+		return 0
+	}
+	return *fm.TreeSize
+}
@@ -0,0 +1,121 @@
+package filesdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestChildrenPages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "children")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]int{
+		"a.txt":       10,
+		"c.txt":       10,
+		"b.txt":       30,
+		"big/x.bin":   100,
+		"small/y.txt": 5,
+		"small/z.txt": 1,
+	}
+	for p, size := range files {
+		os.MkdirAll(path.Dir(path.Join(dir, "files", p)), 0755)
+		ioutil.WriteFile(path.Join(dir, "files", p), []byte(strings.Repeat("x", size)), 0644)
+	}
+	os.MkdirAll(path.Join(dir, "files/empty"), 0755)
+
+	db := NewFilesDB(path.Join(dir, ".meta.db"))
+	db.ScanPath(path.Join(dir, "files"))
+	root, err := db.GetIDForPath(path.Join(dir, "files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// list walks the pages to the end and tells the names in the order they came
+	list := func(sortBy string, descending bool, count int) []string {
+		names := make([]string, 0)
+		cursor := ""
+		for pages := 0; pages < 10; pages++ {
+			result, err := db.GetChildren(root, sortBy, descending, cursor, count)
+			if err != nil {
+				t.Fatalf("%s: %v", sortBy, err)
+			}
+			page := result.(*childrenMeta)
+			if page.Size != 6 {
+				t.Errorf("%s: size %d", sortBy, page.Size)
+			}
+			for _, fm := range page.Files {
+				names = append(names, fm.Name)
+			}
+			// the last page has no next one, the others are full
+			if page.Next == "" {
+				return names
+			}
+			if len(page.Files) != count {
+				t.Errorf("%s: %d items on a page of %d", sortBy, len(page.Files), count)
+			}
+			cursor = page.Next
+		}
+		t.Fatalf("%s: pages do not end", sortBy)
+		return nil
+	}
+
+	cases := []struct {
+		sortBy     string
+		descending bool
+		names      string
+	}{
+		{"name", false, "[a.txt b.txt big c.txt empty small]"},
+		{"name", true, "[small empty c.txt big b.txt a.txt]"},
+		// directories go by the bytes below them, ties by name
+		{"size", false, "[empty small a.txt c.txt b.txt big]"},
+		{"size", true, "[big b.txt c.txt a.txt small empty]"},
+		{"type", false, "[big empty small a.txt b.txt c.txt]"},
+	}
+	for _, c := range cases {
+		for _, count := range []int{1, 2, 4, 6, 7} {
+			if names := fmt.Sprint(list(c.sortBy, c.descending, count)); names != c.names {
+				t.Errorf("%s, descending %v, %d a page: %s", c.sortBy, c.descending, count, names)
+			}
+		}
+	}
+
+	// a cursor goes on from where the page ended while items come and go before it
+	result, err := db.GetChildren(root, "name", false, "", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := result.(*childrenMeta).Next
+	if err := db.RemoveItem(result.(*childrenMeta).Files[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	result, err = db.GetChildren(root, "name", false, next, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page := result.(*childrenMeta); len(page.Files) != 2 || page.Files[0].Name != "big" || page.Files[1].Name != "c.txt" {
+		t.Errorf("after a removal: %+v", page.Files)
+	}
+
+	// cursors are good for the order they were made for only
+	for _, c := range []struct {
+		sortBy     string
+		descending bool
+		cursor     string
+	}{
+		{"size", false, next},
+		{"name", true, next},
+		{"name", false, "not a cursor"},
+		{"name", false, (&childCursor{Sort: "name", Key: []byte("12")}).encode()},
+		{"size", false, (&childCursor{Sort: "size", Key: []byte(`"big"`)}).encode()},
+	} {
+		if _, err := db.GetChildren(root, c.sortBy, c.descending, c.cursor, 2); err != errBadRequest {
+			t.Errorf("%s, descending %v, cursor %s: %v", c.sortBy, c.descending, c.cursor, err)
+		}
+	}
+}
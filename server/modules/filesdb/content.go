@@ -0,0 +1,203 @@
+package filesdb
+
+import (
+	"html"
+	"log"
+	"strings"
+
+	"github.com/akokshar/storage/server/modules/doctext"
+)
+
+const contentBatchSize = 100
+
+// indexContentTable creates the index of document text. Items with content_indexed unset
+// are picked up by indexContent.
+func (m *filesDB) indexContentTable() {
+	_, err := m.database.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS files_content USING fts4 (content, tokenize=unicode61 "remove_diacritics=1");
+
+		CREATE TRIGGER IF NOT EXISTS t_files_content_delete AFTER DELETE ON files
+		BEGIN
+			DELETE FROM files_content WHERE docid = old.id;
+		END;
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// storeContent replaces the indexed text of the item
+func (m *filesDB) storeContent(id int64, text string) error {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+	text = strings.NewReplacer(snippetOpen, "", snippetClose, "").Replace(text)
+	if _, err := tx.Exec(`DELETE FROM files_content WHERE docid = ?`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if text != "" {
+		if _, err := tx.Exec(`INSERT INTO files_content (docid, content) VALUES (?, ?)`, id, text); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(`UPDATE files SET content_indexed = 1 WHERE id = ?`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// indexItemContent extracts the text of a document, other items are only marked as indexed
+func (m *filesDB) indexItemContent(id int64, name string) error {
+	text := ""
+	if doctext.CanExtract(name) {
+		itemPath, err := m.GetPathForID(id)
+		if err != nil {
+			return err
+		}
+		if text, err = doctext.Extract(itemPath); err != nil && err != doctext.ErrNotText {
+			log.Printf("Failed to extract text of '%s': %v", itemPath, err)
+		}
+	}
+	return m.storeContent(id, text)
+}
+
+// indexContent indexes items added or changed since the last run, one run at a time
+func (m *filesDB) indexContent() {
+	m.contentLock.Lock()
+	defer m.contentLock.Unlock()
+
+	for {
+		rows, err := m.database.Query(`
+			SELECT id, name FROM files
+			WHERE content_indexed = 0 AND ctype IS NOT NULL AND ctype != ?
+			LIMIT ?`,
+			contentTypeDirectory, contentBatchSize)
+		if err != nil {
+			log.Printf("%v", err)
+			return
+		}
+		pending := make(map[int64]string)
+		for rows.Next() {
+			var id int64
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				rows.Close()
+				log.Printf("%v", err)
+				return
+			}
+			pending[id] = name
+		}
+		rows.Close()
+		if len(pending) == 0 {
+			return
+		}
+
+		for id, name := range pending {
+			if err := m.indexItemContent(id, name); err != nil {
+				log.Printf("Failed to index content of %d: %v", id, err)
+				return
+			}
+		}
+	}
+}
+
+// contentQuery makes the query match documents with all its words, in any order
+func contentQuery(query string) string {
+	terms := strings.Fields(query)
+	for i, term := range terms {
+		terms[i] = `"` + strings.Replace(term, `"`, `""`, -1) + `"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// snippets come with matches between snippetOpen and snippetClose, which are taken out of
+// the text when it is indexed, so that what is around them can be escaped before the matches
+// are marked up
+const (
+	snippetOpen  = "\x02"
+	snippetClose = "\x03"
+)
+
+// markSnippet escapes the text of the snippet for HTML and puts matches in <mark> elements
+func markSnippet(snippet string) string {
+	return strings.NewReplacer(snippetOpen, "<mark>", snippetClose, "</mark>").Replace(html.EscapeString(snippet))
+}
+
+// SearchContent finds documents below the directory containing all the words of the query,
+// the latest modified first, with the matching text highlighted in a snippet
+func (m *filesDB) SearchContent(dirID int64, query string, offset int, count int) interface{} {
+	result := &searchMeta{
+		Offset: offset,
+		Files:  make([]*foundMeta, 0),
+	}
+	if strings.TrimSpace(query) == "" {
+		return result
+	}
+
+	scope := ""
+	where := `files_content MATCH ?`
+	args := []interface{}{contentQuery(query)}
+	if dirID != m.rootID {
+		scope = `
+			WITH RECURSIVE tree (id) AS (
+				SELECT id FROM files WHERE parent_id = ?
+				UNION ALL
+				SELECT files.id FROM files JOIN tree ON files.parent_id = tree.id
+			)`
+		where += ` AND files.id IN tree`
+		args = append([]interface{}{dirID}, args...)
+	}
+
+	row := m.database.QueryRow(scope+`
+		SELECT count(*) FROM files_content JOIN files ON files.id = files_content.docid
+		WHERE `+where, args...)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	rows, err := m.database.Query(scope+`
+		SELECT `+fileColumns+`, snippet(files_content, char(2), char(3), '…', -1, 16)
+		FROM files_content JOIN files ON files.id = files_content.docid
+		WHERE `+where+`
+		ORDER BY files.mdate DESC, files.id
+		LIMIT ? OFFSET ?`,
+		append(args, count, offset)...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		found := new(foundMeta)
+		fm, err := scanFileMeta(rowSuffix{rows, []interface{}{&found.Snippet}})
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		found.fileMeta = fm
+		found.Snippet = markSnippet(found.Snippet)
+		result.Files = append(result.Files, found)
+	}
+	rows.Close()
+
+	for _, found := range result.Files {
+		found.Path, _ = m.GetPathForID(found.ID)
+	}
+	return result
+}
+
+// rowSuffix scans columns selected after those scanFileMeta expects
+type rowSuffix struct {
+	row  scanner
+	dest []interface{}
+}
+
+func (r rowSuffix) Scan(dest ...interface{}) error {
+	return r.row.Scan(append(dest, r.dest...)...)
+}
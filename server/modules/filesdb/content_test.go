@@ -0,0 +1,88 @@
+package filesdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestSearchContent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"files/notes.txt":       "the harbour <script>alert(1)</script> at dawn & dusk",
+		"files/other/plan.md":   "a \x02harbour\x03 walk",
+		"files/other/photo.jpg": "harbour",
+	}
+	for p, content := range files {
+		os.MkdirAll(path.Dir(path.Join(dir, p)), 0755)
+		ioutil.WriteFile(path.Join(dir, p), []byte(content), 0644)
+	}
+
+	db := NewFilesDB(path.Join(dir, ".meta.db"))
+	db.ScanPath(path.Join(dir, "files"))
+	m := db.(*filesDB)
+	m.indexContent()
+	other, err := db.GetIDForPath(path.Join(dir, "files/other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := func(dirID int64, query string) map[string]string {
+		found, ok := db.SearchContent(dirID, query, 0, 100).(*searchMeta)
+		if !ok {
+			t.Fatalf("%s: no result", query)
+		}
+		snippets := make(map[string]string)
+		for _, fm := range found.Files {
+			snippets[fm.Name] = fm.Snippet
+		}
+		if found.Size != int64(len(snippets)) {
+			t.Errorf("%s: size %d of %d", query, found.Size, len(snippets))
+		}
+		return snippets
+	}
+
+	// markup of documents comes escaped, only matches are marked up
+	found := search(m.rootID, "harbour")
+	if len(found) != 2 {
+		t.Errorf("harbour: %v", found)
+	}
+	if want := "the <mark>harbour</mark> &lt;script&gt;alert(1)&lt;/script&gt; at dawn &amp; dusk"; found["notes.txt"] != want {
+		t.Errorf("snippet: %s", found["notes.txt"])
+	}
+	if want := "a <mark>harbour</mark> walk"; found["plan.md"] != want {
+		t.Errorf("snippet with markers in the text: %s", found["plan.md"])
+	}
+
+	cases := []struct {
+		dirID int64
+		query string
+		want  int
+	}{
+		{m.rootID, "dusk harbour", 1},
+		{m.rootID, "harbour night", 0},
+		{m.rootID, "script", 1},
+		{m.rootID, "  ", 0},
+		{other, "harbour", 1},
+		{other, "dusk", 0},
+	}
+	for _, c := range cases {
+		if found := search(c.dirID, c.query); len(found) != c.want {
+			t.Errorf("%q in %d: %v", c.query, c.dirID, found)
+		}
+	}
+
+	// a file written again is indexed again
+	notes, _ := db.GetIDForPath(path.Join(dir, "files/notes.txt"))
+	ioutil.WriteFile(path.Join(dir, "files/notes.txt"), []byte("nothing left"), 0644)
+	if err := db.ImportItem(notes, path.Join(dir, "files/notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if found := search(m.rootID, "dusk"); len(found) != 0 {
+		t.Errorf("after the rewrite: %v", found)
+	}
+}
@@ -1,12 +1,16 @@
 package filesdb
 
 import (
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"log"
 	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+
+	"github.com/akokshar/storage/server/modules/media"
 )
 
 const (
@@ -18,13 +22,35 @@ type fileItem struct {
 	fi   os.FileInfo
 }
 
+// fileMeta tells about an item, the size of a directory is the number of items in it
 type fileMeta struct {
-	ID    int64  `json:"id"`
-	Size  int64  `json:"size"`
-	MDate int64  `json:"mdate"`
-	CDate int64  `json:"cdate"`
-	Name  string `json:"name"`
-	CType string `json:"ctype"`
+	ID    int64      `json:"id"`
+	Size  int64      `json:"size"`
+	MDate int64      `json:"mdate"`
+	CDate int64      `json:"cdate"`
+	Name  string     `json:"name"`
+	CType string     `json:"ctype"`
+	Media *mediaMeta `json:"media,omitempty"`
+	// bytes of the files below a directory and the number of items below it, told for
+	// directories only and not for saved searches
+	TreeSize  *int64 `json:"treeSize,omitempty"`
+	TreeCount *int64 `json:"treeCount,omitempty"`
+}
+
+// mediaMeta is what the content of a video tells about it
+type mediaMeta struct {
+	CaptureDate int64     `json:"capturedate,omitempty"`
+	Duration    float64   `json:"duration,omitempty"`
+	Width       int       `json:"width,omitempty"`
+	Height      int       `json:"height,omitempty"`
+	Orientation int       `json:"orientation,omitempty"`
+	Codec       string    `json:"codec,omitempty"`
+	Location    *location `json:"location,omitempty"`
+}
+
+type location struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
 }
 
 type dirMeta struct {
@@ -32,6 +58,33 @@ type dirMeta struct {
 	Files  []*fileMeta `json:"files"`
 }
 
+// fileColumns is the select list scanFileMeta expects, the size of a directory is the number of its items
+const fileColumns = `
+	files.id,
+	CASE files.ctype
+		WHEN '` + contentTypeDirectory + `' THEN (SELECT count(*) FROM files AS f_size WHERE f_size.parent_id=files.id)
+		ELSE files.size
+	END item_size,
+	files.mdate, files.cdate, files.name, files.ctype, files.media, files.tree_size, files.tree_count`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFileMeta(row scanner) (*fileMeta, error) {
+	fm := new(fileMeta)
+	var mediaColumn sql.NullString
+	var treeSize, treeCount sql.NullInt64
+	if err := row.Scan(&fm.ID, &fm.Size, &fm.MDate, &fm.CDate, &fm.Name, &fm.CType, &mediaColumn, &treeSize, &treeCount); err != nil {
+		return nil, err
+	}
+	fm.setMediaColumn(mediaColumn)
+	if fm.CType == contentTypeDirectory && treeSize.Valid && treeCount.Valid {
+		fm.TreeSize, fm.TreeCount = &treeSize.Int64, &treeCount.Int64
+	}
+	return fm, nil
+}
+
 // CreateFileItem initialize new fileItem
 func createFileItem(path string) (*fileItem, error) {
 	fi, err := os.Stat(path)
@@ -85,5 +138,59 @@ func (f *fileItem) fileMeta() *fileMeta {
 	}
 	fm.CDate, fm.MDate = getFileTimeStamps(f.fi)
 
+	if !f.fi.IsDir() && media.KindForName(fm.Name) == media.KindVideo {
+		fm.Media = readMediaMeta(f.path)
+	}
+
 	return fm
 }
+
+func readMediaMeta(p string) *mediaMeta {
+	m, err := media.ReadMetadata(p)
+	if err != nil {
+		log.Printf("Incomplete metadata for '%s': %v", p, err)
+	}
+	if m == nil {
+		return nil
+	}
+
+	mm := &mediaMeta{
+		Duration:    m.Duration,
+		Width:       m.Width,
+		Height:      m.Height,
+		Orientation: m.Orientation,
+		Codec:       m.Codec,
+	}
+	if !m.CaptureTime.IsZero() {
+		mm.CaptureDate = m.CaptureTime.Unix()
+	}
+	if m.HasGPS {
+		mm.Location = &location{
+			Latitude:  m.Latitude,
+			Longitude: m.Longitude,
+		}
+	}
+	return mm
+}
+
+// mediaColumn is how mediaMeta is kept in the files table
+func (fm *fileMeta) mediaColumn() sql.NullString {
+	if fm.Media == nil {
+		return sql.NullString{}
+	}
+	data, err := json.Marshal(fm.Media)
+	if err != nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(data), Valid: true}
+}
+
+func (fm *fileMeta) setMediaColumn(column sql.NullString) {
+	if !column.Valid {
+		return
+	}
+	fm.Media = new(mediaMeta)
+	if err := json.Unmarshal([]byte(column.String), fm.Media); err != nil {
+		fm.Media = nil
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/akokshar/storage/server/modules"
@@ -28,13 +29,17 @@ type filesDB struct {
 	dbFile    string
 	database  *sql.DB
 	rootID    int64
+	// one run of indexContent at a time
+	contentLock sync.Mutex
+	// one refresh of a saved search at a time
+	searchLock sync.Mutex
 }
 
 // NewFilesDB initializes a db instance
 func NewFilesDB(dbFile string) modules.FilesDB {
 	var err error
 	var database *sql.DB
-	database, err = sql.Open("sqlite3", dbFile)
+	database, err = sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=5000&_foreign_keys=1&_txlock=immediate", dbFile))
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -82,6 +87,20 @@ func NewFilesDB(dbFile string) modules.FilesDB {
 		log.Fatal(err)
 	}
 
+	db.addColumn("files", "media", "TEXT") /* JSON encoded mediaMeta */
+	db.addColumn("files", "fname", "TEXT") /* name folded for search */
+	db.indexNames()
+	db.addColumn("files", "content_indexed", "INTEGER NOT NULL DEFAULT 0") /* text is in files_content */
+	db.indexContentTable()
+	db.createSavedSearches()
+	db.addColumn("files", "sname", "TEXT") /* name as it sorts */
+	db.sortNames("files")
+	db.addColumn("saved_searches", "sname", "TEXT")
+	db.sortNames("saved_searches")
+	db.addColumn("files", "tree_size", "INTEGER NOT NULL DEFAULT 0")  /* bytes of the files below */
+	db.addColumn("files", "tree_count", "INTEGER NOT NULL DEFAULT 0") /* number of items below */
+	db.createACLs()
+
 	row := database.QueryRow(`SELECT id FROM files WHERE parent_id IS NULL`)
 	if err := row.Scan(&db.rootID); err != nil {
 		res, err := database.Exec(`INSERT INTO files (name) VALUES ("ROOT")`)
@@ -97,15 +116,41 @@ func NewFilesDB(dbFile string) modules.FilesDB {
 	return db
 }
 
+// addColumn extends tables created by earlier versions
+func (m *filesDB) addColumn(table string, column string, definition string) {
+	rows, err := m.database.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, ctype string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			log.Fatal(err)
+		}
+		if name == column {
+			rows.Close()
+			return
+		}
+	}
+	rows.Close()
+
+	_, err = m.database.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
 func (m *filesDB) dbRemoveFile(id int64) (err error) {
 	tx, err := m.database.Begin()
 	if err != nil {
 		return
 	}
 
-	var parentID int64
-	row := tx.QueryRow(`SELECT parent_id FROM files where id = ?`, id)
-	if err = row.Scan(&parentID); err != nil {
+	parentID, bytes, count, err := itemTotals(tx, id)
+	if err != nil {
+		tx.Rollback()
 		return
 	}
 
@@ -117,6 +162,11 @@ func (m *filesDB) dbRemoveFile(id int64) (err error) {
 		return
 	}
 
+	if err = addToTree(tx, parentID, -bytes, -count); err != nil {
+		tx.Rollback()
+		return
+	}
+
 	_, err = tx.Exec(
 		"insert into changelog (parent_id, file_id, action) values ($1, $2, $3)",
 		parentID, id, actionErase)
@@ -135,7 +185,7 @@ func (m *filesDB) dbCreateItemPlaceholder(parentID int64, name string) (id int64
 		panic(fmt.Sprintf("Failed at CreateItemPlaceholder '%v'", err))
 	}
 
-	res, err := tx.Exec("insert into files (parent_id, name) values ($1, $2)", parentID, name)
+	res, err := tx.Exec("insert into files (parent_id, name, fname, sname) values ($1, $2, $3, $4)", parentID, name, fold(name), sortKey(name))
 	if err != nil {
 		tx.Rollback()
 		return
@@ -157,12 +207,15 @@ func (m *filesDB) dbCreateItemPlaceholder(parentID int64, name string) (id int64
 // CreateItemPlaceholder creates a unique record in files table, so it hosds a parent/name constraint
 // Since not record created in changelog, other user wont be able to see this file until import is finished.
 func (m *filesDB) CreateItemPlaceholder(parentID int64, name string) (id int64, err error) {
+	if !modules.ValidItemName(name) {
+		return 0, errBadRequest
+	}
 	fileExt := path.Ext(name)
 	fileName := name[0 : len(name)-len(fileExt)]
 	id = 0
 	for suffix := 0; suffix < 100; suffix++ {
 		if suffix > 0 {
-			name = fmt.Sprintf("%s-%d.%s", fileName, suffix, fileExt)
+			name = fmt.Sprintf("%s-%d%s", fileName, suffix, fileExt)
 		}
 		id, err = m.dbCreateItemPlaceholder(parentID, name)
 		if err == nil {
@@ -196,17 +249,27 @@ func (m *filesDB) dbImportItem(itemID int64, fm *fileMeta) (err error) {
 		return
 	}
 
+	// a replaced file takes back what it added before
+	parentID, oldBytes, oldCount, err := itemTotals(tx, itemID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
 	_, err = tx.Exec(
-		"update files set scan_time = $1, size = $2, mdate = $3, cdate = $4, ctype = $5 where id = $6",
-		m.startTime, fm.Size, fm.MDate, fm.CDate, fm.CType, itemID)
+		"update files set scan_time = $1, size = $2, mdate = $3, cdate = $4, ctype = $5, media = $6, content_indexed = 0 where id = $7",
+		m.startTime, fm.Size, fm.MDate, fm.CDate, fm.CType, fm.mediaColumn(), itemID)
 	if err != nil {
 		tx.Rollback()
 		return
 	}
 
-	var parentID int64
-	row := tx.QueryRow(`SELECT parent_id FROM files where id = ?`, itemID)
-	if err = row.Scan(&parentID); err != nil {
+	_, bytes, count, err := itemTotals(tx, itemID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = addToTree(tx, parentID, bytes-oldBytes, count-oldCount); err != nil {
 		tx.Rollback()
 		return
 	}
@@ -228,7 +291,13 @@ func (m *filesDB) ImportItem(itemID int64, itemPath string) (err error) {
 	if err != nil {
 		return
 	}
-	err = m.dbImportItem(itemID, item.fileMeta())
+	if err = m.dbImportItem(itemID, item.fileMeta()); err != nil {
+		return
+	}
+	// documents are searchable once they are imported
+	if err := m.indexItemContent(itemID, path.Base(itemPath)); err != nil {
+		log.Printf("Failed to index content of '%s': %v", itemPath, err)
+	}
 	return
 }
 
@@ -237,8 +306,99 @@ func (m *filesDB) RemoveItem(id int64) (err error) {
 	return
 }
 
-func (m *filesDB) dbMoveFile(id int64, newParent int64) {
+func (m *filesDB) dbMoveFile(id int64, newParent int64, name string) (err error) {
+	if !modules.ValidItemName(name) {
+		return errBadRequest
+	}
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	parentID, bytes, count, err := itemTotals(tx, id)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			err = errNotFound
+		}
+		return
+	}
 
+	// the new parent has to be a directory outside of the item
+	var ctype sql.NullString
+	var inside int
+	row := tx.QueryRow(`
+		WITH RECURSIVE tree (id) AS (
+			SELECT $1
+			UNION ALL
+			SELECT files.id FROM files JOIN tree ON files.parent_id = tree.id
+		)
+		SELECT ctype, $2 IN tree FROM files WHERE id = $2`,
+		id, newParent)
+	if err = row.Scan(&ctype, &inside); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			err = errNotFound
+		}
+		return
+	}
+	if ctype.String != contentTypeDirectory || inside != 0 {
+		tx.Rollback()
+		return errBadRequest
+	}
+
+	var taken int
+	row = tx.QueryRow(`SELECT count(*) FROM files WHERE parent_id = $1 AND name = $2 AND id != $3`, newParent, name, id)
+	if err = row.Scan(&taken); err != nil {
+		tx.Rollback()
+		return
+	}
+	if taken > 0 {
+		tx.Rollback()
+		return errConflict
+	}
+
+	_, err = tx.Exec(
+		"update files set parent_id = $1, name = $2, fname = $3, sname = $4 where id = $5",
+		newParent, name, fold(name), sortKey(name), id)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if newParent != parentID {
+		if err = addToTree(tx, parentID, -bytes, -count); err != nil {
+			tx.Rollback()
+			return
+		}
+		if err = addToTree(tx, newParent, bytes, count); err != nil {
+			tx.Rollback()
+			return
+		}
+		_, err = tx.Exec(
+			"insert into changelog (parent_id, file_id, action) values ($1, $2, $3)",
+			parentID, id, actionMoveOut)
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+	_, err = tx.Exec(
+		"insert into changelog (parent_id, file_id, action) values ($1, $2, $3)",
+		newParent, id, actionMoveIn)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	return tx.Commit()
+}
+
+// MoveItem records the item under the new parent with the name, it is up to the caller
+// to move what is on disk
+func (m *filesDB) MoveItem(id int64, newParent int64, name string) (err error) {
+	err = m.dbMoveFile(id, newParent, name)
+	return
 }
 
 func (m *filesDB) ScanPath(p string) int64 {
@@ -264,6 +424,8 @@ func (m *filesDB) ScanPath(p string) int64 {
 	}
 	cPath = path.Join(pathComponents[0:i]...)
 	pathComponents = pathComponents[i:]
+	// whatever the scan changes is below the common parent
+	scanRoot := parentID
 
 	tx, err := m.database.Begin()
 	if err != nil {
@@ -272,14 +434,15 @@ func (m *filesDB) ScanPath(p string) int64 {
 	}
 
 	stmt, _ := tx.Prepare(`
-		insert into files (parent_id, scan_time, size, mdate, cdate, name, ctype)
-   			values ($1, $2, $3, $4, $5, $6, $7)
+		insert into files (parent_id, scan_time, size, mdate, cdate, name, ctype, media, fname, sname)
+   			values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
    		on conflict (parent_id, name) do
-   			update set scan_time=$2, size=$3, mdate=$4, cdate=$5, ctype=$7
+   			update set scan_time=$2, size=$3, mdate=$4, cdate=$5, ctype=$7, media=$8,
+   				content_indexed=(content_indexed AND size IS $3 AND mdate IS $4)
    			where parent_id=$1 and name=$6;
 		`)
 	updateOrCreateItem := func(parentID int64, fm *fileMeta) (int64, error) {
-		_, err := tx.Stmt(stmt).Exec(parentID, m.startTime, fm.Size, fm.MDate, fm.CDate, fm.Name, fm.CType)
+		_, err := tx.Stmt(stmt).Exec(parentID, m.startTime, fm.Size, fm.MDate, fm.CDate, fm.Name, fm.CType, fm.mediaColumn(), fold(fm.Name), sortKey(fm.Name))
 		if err != nil {
 			return -1, err
 		}
@@ -343,17 +506,31 @@ func (m *filesDB) ScanPath(p string) int64 {
 	}
 	walkFileItem(f, parentID)
 
-	// Clean orphaned items (cascade deletion)
+	// Clean orphaned items, anywhere in the subtree so that search does not find them
 	log.Printf("Cleaning orphans ... ")
-	_, err = tx.Exec(`DELETE FROM files WHERE parent_id=$1 and scan_time<$2`, parentID, m.startTime)
+	_, err = tx.Exec(`
+		WITH RECURSIVE tree (id) AS (
+			SELECT id FROM files WHERE parent_id = $1
+			UNION ALL
+			SELECT files.id FROM files JOIN tree ON files.parent_id = tree.id
+		)
+		DELETE FROM files WHERE id IN tree AND scan_time < $2`, parentID, m.startTime)
 	if err != nil {
 		log.Print(err)
 		tx.Rollback()
 	}
 
+	if err == nil {
+		if err := countTree(tx, scanRoot); err != nil {
+			log.Print(err)
+		}
+	}
+
 	tx.Commit()
 	log.Printf("Done")
 
+	go m.indexContent()
+
 	return parentID
 }
 
@@ -390,32 +567,37 @@ func (m *filesDB) GetIDForPath(p string) (int64, error) {
 }
 
 func (m *filesDB) GetMetaDataForItemWithID(id int64) interface{} {
-	fm := new(fileMeta)
+	if isVirtualID(id) {
+		sm, err := m.getSavedSearchMeta(id)
+		if err != nil {
+			return nil
+		}
+		return sm
+	}
 
-	row := m.database.QueryRow(`
-		SELECT 	id,
-				CASE ctype 
-					WHEN $1 THEN (SELECT count(*) FROM files AS f_size WHERE f_size.parent_id=files.id)
-					ELSE size
-				END item_size, 
-				mdate, cdate, name, ctype 
-		FROM files WHERE id=$2`,
-		contentTypeDirectory, id)
-	if err := row.Scan(&fm.ID, &fm.Size, &fm.MDate, &fm.CDate, &fm.Name, &fm.CType); err != nil {
+	fm, err := scanFileMeta(m.database.QueryRow(`SELECT `+fileColumns+` FROM files WHERE id = ?`, id))
+	if err != nil {
 		return nil
 	}
-
 	return fm
 }
 
 func (m *filesDB) GetChangesInDirectorySince(id int64, syncAnchor int64, count int) interface{} {
+	if isVirtualID(id) {
+		if err := m.refreshSavedSearch(-id); err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+	}
+
 	changes, err := m.database.Query(`
 		SELECT  changelog.id, changelog.file_id, changelog.action, 
 				files.name, files.ctype, files.mdate, files.cdate,
 				CASE ctype 
 					WHEN $1 THEN (SELECT count(*) FROM files AS f_size WHERE f_size.parent_id=files.id)
 					ELSE size
-				END item_size
+				END item_size,
+				files.media
 				FROM changelog LEFT JOIN files ON changelog.file_id = files.id 
 				WHERE changelog.parent_id = $2 AND changelog.id > $3
 				ORDER BY changelog.id ASC
@@ -444,23 +626,24 @@ func (m *filesDB) GetChangesInDirectorySince(id int64, syncAnchor int64, count i
 	for changes.Next() {
 		fm := new(fileMeta)
 		var action int64
-		var name, ctype sql.NullString
+		var name, ctype, mediaColumn sql.NullString
 		var mdate, cdate, size sql.NullInt64
-		if err := changes.Scan(&result.Anchor, &fm.ID, &action, &name, &ctype, &mdate, &cdate, &size); err != nil {
+		if err := changes.Scan(&result.Anchor, &fm.ID, &action, &name, &ctype, &mdate, &cdate, &size, &mediaColumn); err != nil {
 			log.Printf("%v", err)
 			return nil
 		}
 
 		switch action {
-		case actionAdd:
+		case actionAdd, actionMoveIn:
 			fm.Name = name.String
 			fm.CType = ctype.String
 			fm.MDate = mdate.Int64
 			fm.CDate = cdate.Int64
 			fm.Size = size.Int64
+			fm.setMediaColumn(mediaColumn)
 			result.New = append(result.New, fm)
 			break
-		case actionErase:
+		case actionErase, actionMoveOut:
 			result.Erase = append(result.Erase, fm.ID)
 			break
 		default:
@@ -478,7 +661,20 @@ func (m *filesDB) GetChangesInDirectorySince(id int64, syncAnchor int64, count i
 		return nil
 	}
 
-	itemSize := m.database.QueryRow(`SELECT count(*) FROM files where parent_id = $1`, id)
+	// saved searches show among the items of their directory
+	for i, fm := range result.New {
+		if isVirtualID(fm.ID) {
+			if sm, err := m.getSavedSearchMeta(fm.ID); err == nil {
+				result.New[i] = sm.fileMeta
+			}
+		}
+	}
+
+	itemSize := m.database.QueryRow(`
+		SELECT (SELECT count(*) FROM files WHERE parent_id = $1)
+			+ (SELECT count(*) FROM saved_searches WHERE parent_id = $1)
+			+ (SELECT count(*) FROM saved_search_items WHERE search_id = -$1)`,
+		id)
 	if err := itemSize.Scan(&result.Size); err != nil {
 		log.Printf("%v", err)
 		return nil
@@ -486,3 +682,45 @@ func (m *filesDB) GetChangesInDirectorySince(id int64, syncAnchor int64, count i
 
 	return result
 }
+
+// GetSyncAnchor returns the anchor of the most recent change in the whole database
+func (m *filesDB) GetSyncAnchor() int64 {
+	var anchor int64
+	row := m.database.QueryRow(`SELECT coalesce(max(id), 0) FROM changelog`)
+	if err := row.Scan(&anchor); err != nil {
+		log.Printf("%v", err)
+	}
+	return anchor
+}
+
+// GetChangesSince returns changes in all directories, so other modules can follow the files
+func (m *filesDB) GetChangesSince(syncAnchor int64, count int) ([]modules.FileChange, error) {
+	rows, err := m.database.Query(`
+		SELECT id, file_id, parent_id, action FROM changelog
+		WHERE id > $1 AND parent_id > 0 AND file_id > 0 /* not saved searches */
+		ORDER BY id ASC
+		LIMIT $2`,
+		syncAnchor, count)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]modules.FileChange, 0, count)
+	for rows.Next() {
+		var c modules.FileChange
+		var action int64
+		if err := rows.Scan(&c.Anchor, &c.ID, &c.ParentID, &action); err != nil {
+			return nil, err
+		}
+		switch action {
+		case actionAdd, actionMoveIn:
+			changes = append(changes, c)
+		case actionErase:
+			c.Erased = true
+			changes = append(changes, c)
+		}
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,40 @@
+package filesdb
+
+import (
+	"strings"
+	"unicode"
+)
+
+// foldedLetters maps precomposed Latin letters to what they are searched by
+var foldedLetters = func() map[rune]string {
+	table := map[string]string{
+		"a": "àáâãäåāăą", "c": "çćĉċč", "d": "ďđð", "e": "èéêëēĕėęě", "g": "ĝğġģ",
+		"h": "ĥħ", "i": "ìíîïĩīĭįı", "j": "ĵ", "k": "ķ", "l": "ĺļľŀł", "n": "ñńņňŉ",
+		"o": "òóôõöøōŏő", "r": "ŕŗř", "s": "śŝşšſ", "t": "ţťŧ", "u": "ùúûüũūŭůűų",
+		"w": "ŵ", "y": "ýÿŷ", "z": "źżž", "ss": "ß", "ae": "æ", "oe": "œ", "th": "þ",
+	}
+	letters := make(map[rune]string)
+	for folded, runes := range table {
+		for _, r := range runes {
+			letters[r] = folded
+		}
+	}
+	return letters
+}()
+
+// fold makes names compare regardless of case and diacritics. Names coming from macOS are
+// decomposed, their combining marks are dropped, precomposed letters are looked up.
+func fold(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if folded, ok := foldedLetters[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package filesdb
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// maxDigits is the longest run of digits compared by its value, longer runs are cut
+const maxDigits = 99
+
+// sortKey makes names sort the way people read them. The name is folded, so case and
+// diacritics do not matter, and every run of digits gets its length in front, so that
+// file2 goes before file10.
+func sortKey(name string) string {
+	folded := fold(name)
+	var b strings.Builder
+	for i := 0; i < len(folded); {
+		if folded[i] < '0' || folded[i] > '9' {
+			b.WriteByte(folded[i])
+			i++
+			continue
+		}
+		start := i
+		for i < len(folded) && folded[i] >= '0' && folded[i] <= '9' {
+			i++
+		}
+		digits := strings.TrimLeft(folded[start:i], "0")
+		if digits == "" {
+			digits = "0"
+		}
+		if len(digits) > maxDigits {
+			digits = digits[:maxDigits]
+		}
+		fmt.Fprintf(&b, "%02d%s", len(digits), digits)
+	}
+	return b.String()
+}
+
+// sortNames fills the sort keys of names stored by earlier versions
+func (m *filesDB) sortNames(table string) {
+	rows, err := m.database.Query(fmt.Sprintf(`SELECT id, name FROM %s WHERE sname IS NULL AND name IS NOT NULL`, table))
+	if err != nil {
+		log.Fatal(err)
+	}
+	keys := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			log.Fatal(err)
+		}
+		keys[id] = sortKey(name)
+	}
+	rows.Close()
+
+	tx, err := m.database.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for id, key := range keys {
+		if _, err := tx.Exec(fmt.Sprintf(`UPDATE %s SET sname = ? WHERE id = ?`, table), key, id); err != nil {
+			tx.Rollback()
+			log.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+}
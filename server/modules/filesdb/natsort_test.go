@@ -0,0 +1,24 @@
+package filesdb
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestSortKey(t *testing.T) {
+	names := []string{"file10.txt", "File2.txt", "file1.txt", "Éclair", "eclair 2", "a", "file02b", "B", "img9999999999999999999.jpg", "img123.jpg"}
+	sort.SliceStable(names, func(i, j int) bool { return sortKey(names[i]) < sortKey(names[j]) })
+	want := []string{"a", "B", "Éclair", "eclair 2", "file1.txt", "File2.txt", "file02b", "file10.txt", "img123.jpg", "img9999999999999999999.jpg"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("%q", names)
+		}
+	}
+
+	if sortKey("x007") != sortKey("X7") {
+		t.Errorf("%s %s", sortKey("x007"), sortKey("X7"))
+	}
+	if sortKey("0") != "010" {
+		t.Errorf("%s", sortKey("0"))
+	}
+}
@@ -0,0 +1,316 @@
+package filesdb
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Queries select items below a directory with terms like
+//
+//	ctype:application/pdf size>10MB mdate:thisyear name:*.pdf depth<=2 -ctype:folder
+//
+// Every term has to match. A term is a field, an operator out of : = < <= > >= and a value,
+// values with spaces are quoted. A leading minus negates the term.
+//
+//	name   glob over the name regardless of case and diacritics, without * or ? the value
+//	       matches anywhere in the name
+//	ctype  content type, folder for directories, a trailing * matches a prefix as in image/*
+//	size   bytes, or with a B, KB, MB, GB or TB suffix in powers of 1024
+//	mdate  modification and creation dates: YYYY, YYYY-MM, YYYY-MM-DD or today, thisweek,
+//	cdate  thismonth, thisyear in the local time of the server. Dates are periods, : and =
+//	       match within the period, < before it, > after it
+//	depth  levels below the directory, 1 for its own items
+
+// queryError tells which part of the query is wrong, it is a modules.HandlerError
+type queryError struct {
+	term   string
+	reason string
+}
+
+func (e *queryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.term, e.reason)
+}
+
+func (e *queryError) Code() int {
+	return http.StatusBadRequest
+}
+
+// queryOperators are looked for longest first
+var queryOperators = []string{"<=", ">=", ":", "=", "<", ">"}
+
+var sqlOperators = map[string]string{":": "=", "=": "=", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+var sizeUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1},
+}
+
+// sortColumns are what results can be sorted by
+var sortColumns = map[string]string{
+	"name":  "files.fname",
+	"size":  "item_size",
+	"mdate": "files.mdate",
+	"cdate": "files.cdate",
+	"ctype": "files.ctype",
+}
+
+// queryScope is the tree of items below the directory given as its argument, conditions of
+// compiled queries refer to it for the depth
+const queryScope = `
+	WITH RECURSIVE tree (id, depth) AS (
+		SELECT id, 1 FROM files WHERE parent_id = ?
+		UNION ALL
+		SELECT files.id, tree.depth + 1 FROM files JOIN tree ON files.parent_id = tree.id
+	)`
+
+// compiledQuery is the condition on files and the tree CTE, with arguments for its placeholders
+type compiledQuery struct {
+	where string
+	args  []interface{}
+}
+
+// splitQuery breaks the query into terms at spaces outside of quotes and drops the quotes
+func splitQuery(query string) ([]string, error) {
+	terms := make([]string, 0)
+	var term strings.Builder
+	quoted, started := false, false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if started {
+				terms = append(terms, term.String())
+				term.Reset()
+				started = false
+			}
+		default:
+			term.WriteRune(r)
+			started = true
+		}
+	}
+	if quoted {
+		return nil, &queryError{term.String(), "unterminated quote"}
+	}
+	if started {
+		terms = append(terms, term.String())
+	}
+	return terms, nil
+}
+
+// period is the time span a date value stands for, end is exclusive
+func period(value string, now time.Time) (time.Time, time.Time, bool) {
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch value {
+	case "today":
+		return day, day.AddDate(0, 0, 1), true
+	case "thisweek":
+		// weeks start on Monday
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7), true
+	case "thismonth":
+		start := day.AddDate(0, 0, 1-day.Day())
+		return start, start.AddDate(0, 1, 0), true
+	case "thisyear":
+		start := time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, now.Location())
+		return start, start.AddDate(1, 0, 0), true
+	}
+	for _, layout := range []struct {
+		format              string
+		years, months, days int
+	}{
+		{"2006-01-02", 0, 0, 1}, {"2006-01", 0, 1, 0}, {"2006", 1, 0, 0},
+	} {
+		if start, err := time.ParseInLocation(layout.format, value, now.Location()); err == nil {
+			return start, start.AddDate(layout.years, layout.months, layout.days), true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+func parseSize(value string) (int64, bool) {
+	upper := strings.ToUpper(value)
+	scale := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(upper, unit.suffix) {
+			upper, scale = strings.TrimSpace(strings.TrimSuffix(upper, unit.suffix)), unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return int64(n * float64(scale)), true
+}
+
+// compileTerm turns one term into a condition, every value is passed as an argument
+func compileTerm(term string, now time.Time) (string, []interface{}, error) {
+	negated := strings.HasPrefix(term, "-")
+	body := strings.TrimPrefix(term, "-")
+
+	field, op, value := "", "", ""
+	for i := 0; i < len(body) && op == ""; i++ {
+		for _, candidate := range queryOperators {
+			if strings.HasPrefix(body[i:], candidate) {
+				field, op, value = body[:i], candidate, body[i+len(candidate):]
+				break
+			}
+		}
+	}
+	if op == "" || field == "" {
+		return "", nil, &queryError{term, "expected field, operator and value"}
+	}
+	if value == "" {
+		return "", nil, &queryError{term, "missing value"}
+	}
+
+	var condition string
+	var args []interface{}
+	switch strings.ToLower(field) {
+	case "name":
+		if op != ":" && op != "=" {
+			return "", nil, &queryError{term, "names only match with : or ="}
+		}
+		pattern := fold(value)
+		if !strings.ContainsAny(pattern, "*?") {
+			pattern = "*" + pattern + "*"
+		}
+		condition, args = "files.fname GLOB ?", []interface{}{pattern}
+	case "ctype":
+		if op != ":" && op != "=" {
+			return "", nil, &queryError{term, "content types only match with : or ="}
+		}
+		if strings.HasSuffix(value, "*") {
+			prefix := strings.ToLower(strings.TrimSuffix(value, "*"))
+			condition, args = "substr(lower(files.ctype), 1, ?) = ?", []interface{}{len(prefix), prefix}
+		} else {
+			// content types of files may carry parameters such as the charset
+			condition = "(lower(files.ctype) = ? OR substr(lower(files.ctype), 1, ?) = ?)"
+			args = []interface{}{strings.ToLower(value), len(value) + 1, strings.ToLower(value) + ";"}
+		}
+	case "size":
+		size, ok := parseSize(value)
+		if !ok {
+			return "", nil, &queryError{term, "bad size"}
+		}
+		condition, args = "files.ctype != ? AND files.size "+sqlOperators[op]+" ?", []interface{}{contentTypeDirectory, size}
+	case "mdate", "cdate":
+		start, end, ok := period(value, now)
+		if !ok {
+			return "", nil, &queryError{term, "bad date"}
+		}
+		column := "files." + strings.ToLower(field)
+		switch op {
+		case ":", "=":
+			condition, args = column+" >= ? AND "+column+" < ?", []interface{}{start.Unix(), end.Unix()}
+		case "<":
+			condition, args = column+" < ?", []interface{}{start.Unix()}
+		case "<=":
+			condition, args = column+" < ?", []interface{}{end.Unix()}
+		case ">":
+			condition, args = column+" >= ?", []interface{}{end.Unix()}
+		case ">=":
+			condition, args = column+" >= ?", []interface{}{start.Unix()}
+		}
+	case "depth":
+		depth, err := strconv.Atoi(value)
+		if err != nil || depth < 1 {
+			return "", nil, &queryError{term, "bad depth"}
+		}
+		condition, args = "tree.depth "+sqlOperators[op]+" ?", []interface{}{depth}
+	default:
+		return "", nil, &queryError{term, "unknown field"}
+	}
+
+	if negated {
+		condition = "NOT (" + condition + ")"
+	}
+	return "(" + condition + ")", args, nil
+}
+
+// compileQuery makes the condition all the terms of the query put together
+func compileQuery(query string, now time.Time) (*compiledQuery, error) {
+	terms, err := splitQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	cq := &compiledQuery{where: "1", args: make([]interface{}, 0)}
+	conditions := make([]string, 0, len(terms))
+	for _, term := range terms {
+		condition, args, err := compileTerm(term, now)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+		cq.args = append(cq.args, args...)
+	}
+	if len(conditions) > 0 {
+		cq.where = strings.Join(conditions, " AND ")
+	}
+	return cq, nil
+}
+
+// QueryItems lists items below the directory matching the query, sorted by a column of sortColumns
+func (m *filesDB) QueryItems(dirID int64, query string, sortBy string, descending bool, offset int, count int) (interface{}, error) {
+	cq, err := compileQuery(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if sortBy == "" {
+		sortBy = "name"
+	}
+	column, ok := sortColumns[sortBy]
+	if !ok {
+		return nil, &queryError{sortBy, "cannot sort by it"}
+	}
+	order := "ASC"
+	if descending {
+		order = "DESC"
+	}
+
+	from := ` FROM files JOIN tree ON tree.id = files.id WHERE files.ctype IS NOT NULL AND ` + cq.where
+	args := append([]interface{}{dirID}, cq.args...)
+
+	result := &searchMeta{
+		Offset: offset,
+		Files:  make([]*foundMeta, 0),
+	}
+	row := m.database.QueryRow(queryScope+` SELECT count(*)`+from, args...)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil, err
+	}
+
+	rows, err := m.database.Query(queryScope+` SELECT `+fileColumns+from+`
+		ORDER BY `+column+` `+order+`, files.id `+order+`
+		LIMIT ? OFFSET ?`,
+		append(args, count, offset)...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		fm, err := scanFileMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil, err
+		}
+		result.Files = append(result.Files, &foundMeta{fileMeta: fm})
+	}
+	rows.Close()
+
+	for _, found := range result.Files {
+		found.Path, _ = m.GetPathForID(found.ID)
+	}
+	return result, nil
+}
@@ -0,0 +1,77 @@
+package filesdb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitQuery(t *testing.T) {
+	terms, err := splitQuery(` name:"annual report*"  ctype:application/pdf -size>1MB `)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"name:annual report*", "ctype:application/pdf", "-size>1MB"}; !reflect.DeepEqual(terms, want) {
+		t.Errorf("%q", terms)
+	}
+	if _, err := splitQuery(`name:"open`); err == nil {
+		t.Errorf("unterminated quote accepted")
+	}
+}
+
+func TestCompileTerm(t *testing.T) {
+	now := time.Date(2026, time.October, 18, 15, 0, 0, 0, time.UTC)
+	cases := []struct {
+		term      string
+		condition string
+		args      []interface{}
+	}{
+		{"name:Résumé", "(files.fname GLOB ?)", []interface{}{"*resume*"}},
+		{"name=*.PDF", "(files.fname GLOB ?)", []interface{}{"*.pdf"}},
+		{"ctype:image/*", "(substr(lower(files.ctype), 1, ?) = ?)", []interface{}{6, "image/"}},
+		{"size>=10MB", "(files.ctype != ? AND files.size >= ?)", []interface{}{"folder", int64(10 << 20)}},
+		{"-size<1.5KB", "(NOT (files.ctype != ? AND files.size < ?))", []interface{}{"folder", int64(1536)}},
+		{"mdate:thisyear", "(files.mdate >= ? AND files.mdate < ?)", []interface{}{
+			time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}},
+		{"cdate>2025-02", "(files.cdate >= ?)", []interface{}{time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC).Unix()}},
+		{"mdate<=2024-12-31", "(files.mdate < ?)", []interface{}{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Unix()}},
+		{"mdate:thisweek", "(files.mdate >= ? AND files.mdate < ?)", []interface{}{
+			time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC).Unix(), time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC).Unix()}},
+		{"depth<=2", "(tree.depth <= ?)", []interface{}{2}},
+	}
+	for _, c := range cases {
+		condition, args, err := compileTerm(c.term, now)
+		if err != nil {
+			t.Errorf("%s: %v", c.term, err)
+			continue
+		}
+		if condition != c.condition || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: %s %v", c.term, condition, args)
+		}
+	}
+
+	for _, term := range []string{"name", "size:", "owner:me", "size:big", "mdate:soon", "depth:0", "name>a", "ctype<x", "; DROP TABLE files"} {
+		if _, _, err := compileTerm(term, now); err == nil {
+			t.Errorf("%s accepted", term)
+		} else if _, ok := err.(*queryError); !ok {
+			t.Errorf("%s: %v", term, err)
+		}
+	}
+}
+
+func TestCompileQuery(t *testing.T) {
+	cq, err := compileQuery(`ctype:application/pdf size>10MB`, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cq.where != "((lower(files.ctype) = ? OR substr(lower(files.ctype), 1, ?) = ?)) AND (files.ctype != ? AND files.size > ?)" {
+		t.Errorf("%s", cq.where)
+	}
+	if len(cq.args) != 5 {
+		t.Errorf("%v", cq.args)
+	}
+
+	if cq, err := compileQuery("", time.Now()); err != nil || cq.where != "1" {
+		t.Errorf("empty query: %v", err)
+	}
+}
@@ -0,0 +1,404 @@
+package filesdb
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+// Saved searches are queries shown to clients as read only directories in the directory they
+// search. Their IDs are the negated IDs of saved_searches, so they never collide with items.
+// The items they found last are kept in saved_search_items, refreshing a search puts the items
+// entering or leaving its result into the changelog under the ID of the search, like changes
+// of a directory.
+
+var (
+	errNotFound   = modules.NewHandlerErrorWithCode(http.StatusNotFound).(error)
+	errBadRequest = modules.NewHandlerErrorWithCode(http.StatusBadRequest).(error)
+	errConflict   = modules.NewHandlerErrorWithCode(http.StatusConflict).(error)
+)
+
+// savedSearchMeta is the directory of a saved search with what it is made of
+type savedSearchMeta struct {
+	*fileMeta
+	ParentID int64  `json:"parentId"`
+	Query    string `json:"query"`
+}
+
+func isVirtualID(id int64) bool {
+	return id < 0
+}
+
+// createSavedSearches creates the tables of saved searches
+func (m *filesDB) createSavedSearches() {
+	_, err := m.database.Exec(`
+		CREATE TABLE IF NOT EXISTS saved_searches (
+			id INTEGER PRIMARY KEY AUTOINCREMENT, /* ids of the directories are negated */
+			parent_id INTEGER NOT NULL,
+			name  TEXT NOT NULL,
+			query TEXT NOT NULL,
+			mdate INTEGER,
+			cdate INTEGER,
+
+			CONSTRAINT fk_search_parent
+				FOREIGN KEY (parent_id)
+				REFERENCES files (id)
+				ON DELETE CASCADE,
+
+			CONSTRAINT k_search_name
+				UNIQUE (parent_id, name)
+		);
+
+		CREATE TABLE IF NOT EXISTS saved_search_items (
+			search_id INTEGER,
+			file_id INTEGER, /* not a foreign key, erased items have to be told to leave */
+			name  TEXT,
+			size  INTEGER,
+			mdate INTEGER,
+
+			PRIMARY KEY (search_id, file_id),
+
+			CONSTRAINT fk_search
+				FOREIGN KEY (search_id)
+				REFERENCES saved_searches (id)
+				ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// checkSearchName fails when another saved search in the directory has the name
+func checkSearchName(tx *sql.Tx, parentID int64, name string, searchID int64) error {
+	var taken int
+	row := tx.QueryRow(`SELECT count(*) FROM saved_searches WHERE parent_id = ? AND name = ? AND id != ?`, parentID, name, searchID)
+	if err := row.Scan(&taken); err != nil {
+		return err
+	}
+	if taken > 0 {
+		return errConflict
+	}
+	return nil
+}
+
+// SaveSearch shows items below the directory matching the query in a directory of the name
+func (m *filesDB) SaveSearch(parentID int64, name string, query string) (int64, error) {
+	if !modules.ValidItemName(name) {
+		return 0, errBadRequest
+	}
+	if _, err := compileQuery(query, time.Now()); err != nil {
+		return 0, err
+	}
+
+	var ctype sql.NullString
+	row := m.database.QueryRow(`SELECT ctype FROM files WHERE id = ?`, parentID)
+	if err := row.Scan(&ctype); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errNotFound
+		}
+		return 0, err
+	}
+	if ctype.String != contentTypeDirectory {
+		return 0, errBadRequest
+	}
+
+	tx, err := m.database.Begin()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkSearchName(tx, parentID, name, 0); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	now := time.Now().Unix()
+	res, err := tx.Exec(`
+		INSERT INTO saved_searches (parent_id, name, sname, query, mdate, cdate) VALUES (?, ?, ?, ?, ?, ?)`,
+		parentID, name, sortKey(name), query, now, now)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	searchID, err := res.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	_, err = tx.Exec(
+		"insert into changelog (parent_id, file_id, action) values ($1, $2, $3)",
+		parentID, -searchID, actionAdd)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if err := m.refreshSavedSearch(searchID); err != nil {
+		log.Printf("%v", err)
+	}
+	return -searchID, nil
+}
+
+// UpdateSavedSearch renames the directory of the saved search and replaces its query
+func (m *filesDB) UpdateSavedSearch(id int64, name string, query string) error {
+	if !isVirtualID(id) {
+		return errNotFound
+	}
+	if !modules.ValidItemName(name) {
+		return errBadRequest
+	}
+	if _, err := compileQuery(query, time.Now()); err != nil {
+		return err
+	}
+
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+	var parentID int64
+	row := tx.QueryRow(`SELECT parent_id FROM saved_searches WHERE id = ?`, -id)
+	if err := row.Scan(&parentID); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errNotFound
+		}
+		return err
+	}
+	if err := checkSearchName(tx, parentID, name, -id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`UPDATE saved_searches SET name = ?, sname = ?, query = ?, mdate = ? WHERE id = ?`,
+		name, sortKey(name), query, time.Now().Unix(), -id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// the parent directory tells the new name
+	_, err = tx.Exec(
+		"insert into changelog (parent_id, file_id, action) values ($1, $2, $3)",
+		parentID, id, actionAdd)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return m.refreshSavedSearch(-id)
+}
+
+// DeleteSavedSearch removes the directory of the saved search, items it found are left as they are
+func (m *filesDB) DeleteSavedSearch(id int64) error {
+	if !isVirtualID(id) {
+		return errNotFound
+	}
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+	var parentID int64
+	row := tx.QueryRow(`SELECT parent_id FROM saved_searches WHERE id = ?`, -id)
+	if err := row.Scan(&parentID); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errNotFound
+		}
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM saved_searches WHERE id = ?`, -id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM changelog WHERE parent_id = ?`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(
+		"insert into changelog (parent_id, file_id, action) values ($1, $2, $3)",
+		parentID, id, actionErase)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetSavedSearchParent returns the directory the saved search shows in
+func (m *filesDB) GetSavedSearchParent(id int64) (int64, error) {
+	var parentID int64
+	row := m.database.QueryRow(`SELECT parent_id FROM saved_searches WHERE id = ?`, -id)
+	if err := row.Scan(&parentID); err != nil {
+		return 0, err
+	}
+	return parentID, nil
+}
+
+const savedSearchColumns = `
+	saved_searches.id, saved_searches.parent_id, saved_searches.name, saved_searches.query,
+	saved_searches.mdate, saved_searches.cdate,
+	(SELECT count(*) FROM saved_search_items WHERE search_id = saved_searches.id)`
+
+func scanSavedSearchMeta(row scanner) (*savedSearchMeta, error) {
+	sm := &savedSearchMeta{fileMeta: &fileMeta{CType: contentTypeDirectory}}
+	if err := row.Scan(&sm.ID, &sm.ParentID, &sm.Name, &sm.Query, &sm.MDate, &sm.CDate, &sm.Size); err != nil {
+		return nil, err
+	}
+	sm.ID = -sm.ID
+	return sm, nil
+}
+
+func (m *filesDB) getSavedSearchMeta(id int64) (*savedSearchMeta, error) {
+	row := m.database.QueryRow(`SELECT `+savedSearchColumns+` FROM saved_searches WHERE id = ?`, -id)
+	return scanSavedSearchMeta(row)
+}
+
+// GetSavedSearches lists saved searches showing in the directory and the directories below it
+func (m *filesDB) GetSavedSearches(dirID int64) interface{} {
+	rows, err := m.database.Query(`
+		WITH RECURSIVE tree (id) AS (
+			SELECT ?
+			UNION ALL
+			SELECT files.id FROM files JOIN tree ON files.parent_id = tree.id
+		)
+		SELECT `+savedSearchColumns+` FROM saved_searches
+		WHERE parent_id IN tree
+		ORDER BY name, id`,
+		dirID)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	searches := make([]*savedSearchMeta, 0)
+	for rows.Next() {
+		sm, err := scanSavedSearchMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		searches = append(searches, sm)
+	}
+	return searches
+}
+
+// searchItem is what a saved search remembers of an item to tell when it changed
+type searchItem struct {
+	name  string
+	size  int64
+	mdate int64
+}
+
+// refreshSavedSearch runs the query of the saved search again and records items entering,
+// changing or leaving the result in the changelog
+func (m *filesDB) refreshSavedSearch(searchID int64) error {
+	m.searchLock.Lock()
+	defer m.searchLock.Unlock()
+
+	var parentID int64
+	var query string
+	row := m.database.QueryRow(`SELECT parent_id, query FROM saved_searches WHERE id = ?`, searchID)
+	if err := row.Scan(&parentID, &query); err != nil {
+		return err
+	}
+	// periods such as thisweek move, so the query is compiled on every refresh
+	cq, err := compileQuery(query, time.Now())
+	if err != nil {
+		return err
+	}
+
+	rows, err := m.database.Query(queryScope+`
+		SELECT files.id, files.name, coalesce(files.size, 0), coalesce(files.mdate, 0)
+		FROM files JOIN tree ON tree.id = files.id
+		WHERE files.ctype IS NOT NULL AND `+cq.where,
+		append([]interface{}{parentID}, cq.args...)...)
+	if err != nil {
+		return err
+	}
+	found := make(map[int64]searchItem)
+	for rows.Next() {
+		var id int64
+		var item searchItem
+		if err := rows.Scan(&id, &item.name, &item.size, &item.mdate); err != nil {
+			rows.Close()
+			return err
+		}
+		found[id] = item
+	}
+	rows.Close()
+
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+	rows, err = tx.Query(`SELECT file_id, name, size, mdate FROM saved_search_items WHERE search_id = ?`, searchID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	known := make(map[int64]searchItem)
+	for rows.Next() {
+		var id int64
+		var item searchItem
+		if err := rows.Scan(&id, &item.name, &item.size, &item.mdate); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		known[id] = item
+	}
+	rows.Close()
+
+	record := func(fileID int64, action int) error {
+		_, err := tx.Exec(
+			"insert into changelog (parent_id, file_id, action) values ($1, $2, $3)",
+			-searchID, fileID, action)
+		return err
+	}
+
+	changed := false
+	for id, item := range found {
+		if k, ok := known[id]; ok && k == item {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO saved_search_items (search_id, file_id, name, size, mdate) VALUES (?, ?, ?, ?, ?)`,
+			searchID, id, item.name, item.size, item.mdate)
+		if err == nil {
+			err = record(id, actionAdd)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		changed = true
+	}
+	for id := range known {
+		if _, ok := found[id]; ok {
+			continue
+		}
+		_, err := tx.Exec(`DELETE FROM saved_search_items WHERE search_id = ? AND file_id = ?`, searchID, id)
+		if err == nil {
+			err = record(id, actionErase)
+		}
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		changed = true
+	}
+	if changed {
+		if _, err := tx.Exec(`UPDATE saved_searches SET mdate = ? WHERE id = ?`, time.Now().Unix(), searchID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
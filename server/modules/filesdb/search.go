@@ -0,0 +1,156 @@
+package filesdb
+
+import (
+	"log"
+	"strings"
+)
+
+// foundMeta is a search result, the path saves clients walking up the parents
+type foundMeta struct {
+	*fileMeta
+	Path    string `json:"path"`
+	Snippet string `json:"snippet,omitempty"` // matching text escaped for HTML, matches in <mark> elements
+}
+
+type searchMeta struct {
+	Offset int          `json:"offset"`
+	Files  []*foundMeta `json:"files"`
+	Size   int64        `json:"size"`
+}
+
+// indexNames creates the name index and fills it with what earlier versions stored.
+// Triggers keep the index in step with the fname column.
+func (m *filesDB) indexNames() {
+	rows, err := m.database.Query(`SELECT id, name FROM files WHERE fname IS NULL AND parent_id IS NOT NULL`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	folded := make(map[int64]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			log.Fatal(err)
+		}
+		folded[id] = fold(name)
+	}
+	rows.Close()
+
+	_, err = m.database.Exec(`
+		CREATE VIRTUAL TABLE IF NOT EXISTS files_names USING fts4 (name);
+
+		CREATE TRIGGER IF NOT EXISTS t_files_names_insert AFTER INSERT ON files
+		WHEN new.fname IS NOT NULL
+		BEGIN
+			INSERT INTO files_names (docid, name) VALUES (new.id, new.fname);
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS t_files_names_update AFTER UPDATE OF fname ON files
+		BEGIN
+			DELETE FROM files_names WHERE docid = old.id;
+			INSERT INTO files_names (docid, name) SELECT new.id, new.fname WHERE new.fname IS NOT NULL;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS t_files_names_delete AFTER DELETE ON files
+		BEGIN
+			DELETE FROM files_names WHERE docid = old.id;
+		END;
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tx, err := m.database.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for id, name := range folded {
+		if _, err := tx.Exec(`UPDATE files SET fname = ? WHERE id = ?`, name, id); err != nil {
+			tx.Rollback()
+			log.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// nameQuery makes every word of the query match the beginning of a word of the name
+func nameQuery(query string) string {
+	terms := strings.Fields(fold(query))
+	for i, term := range terms {
+		terms[i] = `"` + strings.Replace(term, `"`, `""`, -1) + `*"`
+	}
+	return strings.Join(terms, " ")
+}
+
+// SearchNames finds items below the directory whose names match the query regardless of case
+// and diacritics. Words of the query match beginnings of words of the name, or with substring
+// set the query matches anywhere in the name.
+func (m *filesDB) SearchNames(dirID int64, query string, substring bool, offset int, count int) interface{} {
+	result := &searchMeta{
+		Offset: offset,
+		Files:  make([]*foundMeta, 0),
+	}
+	if strings.TrimSpace(query) == "" {
+		return result
+	}
+
+	var match string
+	var args []interface{}
+	if substring {
+		match = `instr(files.fname, ?) > 0`
+		args = append(args, fold(strings.TrimSpace(query)))
+	} else {
+		match = `files.id IN (SELECT docid FROM files_names WHERE name MATCH ?)`
+		args = append(args, nameQuery(query))
+	}
+
+	// the root directory has everything below it, no need to walk the tree
+	scope := ""
+	where := `files.parent_id IS NOT NULL`
+	if dirID != m.rootID {
+		scope = `
+			WITH RECURSIVE tree (id) AS (
+				SELECT id FROM files WHERE parent_id = ?
+				UNION ALL
+				SELECT files.id FROM files JOIN tree ON files.parent_id = tree.id
+			)`
+		where = `files.id IN tree`
+		args = append([]interface{}{dirID}, args...)
+	}
+	where += ` AND files.ctype IS NOT NULL AND ` + match
+
+	row := m.database.QueryRow(scope+` SELECT count(*) FROM files WHERE `+where, args...)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	rows, err := m.database.Query(scope+`
+		SELECT `+fileColumns+`
+		FROM files WHERE `+where+`
+		ORDER BY files.fname, files.id
+		LIMIT ? OFFSET ?`,
+		append(args, count, offset)...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		fm, err := scanFileMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Files = append(result.Files, &foundMeta{fileMeta: fm})
+	}
+	rows.Close()
+
+	for _, found := range result.Files {
+		found.Path, _ = m.GetPathForID(found.ID)
+	}
+	return result
+}
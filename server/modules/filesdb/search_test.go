@@ -0,0 +1,126 @@
+package filesdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"testing"
+)
+
+func TestFold(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+	}{
+		{"Crème Brûlée", "creme brulee"},
+		{"Cre\u0300me", "creme"}, // decomposed as macOS stores it
+		{"Straße", "strasse"},
+		{"ÆON", "aeon"},
+		{"Łódź", "lodz"},
+		{"Привет", "привет"},
+	}
+	for _, c := range cases {
+		if got := fold(c.name); got != c.want {
+			t.Errorf("%s: %s, want %s", c.name, got, c.want)
+		}
+	}
+
+	if got := nameQuery(` Crème  "Brû `); got != `"creme*" """bru*"` {
+		t.Errorf("query: %s", got)
+	}
+}
+
+func TestSearchNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, p := range []string{"files/Crème Brûlée.txt", "files/Crèpe Suzette.txt", "files/other/creme.md", "files/other/notes.txt"} {
+		os.MkdirAll(path.Dir(path.Join(dir, p)), 0755)
+		ioutil.WriteFile(path.Join(dir, p), []byte(p), 0644)
+	}
+
+	db := NewFilesDB(path.Join(dir, ".meta.db"))
+	db.ScanPath(path.Join(dir, "files"))
+	id := func(p string) int64 {
+		id, err := db.GetIDForPath(path.Join(dir, "files", p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	rootID := db.(*filesDB).rootID
+	search := func(dirID int64, query string, substring bool) string {
+		found, ok := db.SearchNames(dirID, query, substring, 0, 100).(*searchMeta)
+		if !ok {
+			t.Fatalf("%s: no result", query)
+		}
+		names := make([]string, 0)
+		for _, fm := range found.Files {
+			names = append(names, fm.Name)
+		}
+		sort.Strings(names)
+		if found.Size != int64(len(names)) {
+			t.Errorf("%s: size %d of %d", query, found.Size, len(names))
+		}
+		return fmt.Sprint(names)
+	}
+
+	cases := []struct {
+		dirID     int64
+		query     string
+		substring bool
+		want      string
+	}{
+		{rootID, "creme", false, "[Crème Brûlée.txt creme.md]"},
+		{rootID, "CRÊPE", false, "[Crèpe Suzette.txt]"},
+		{rootID, "brul cre", false, "[Crème Brûlée.txt]"},
+		// words match from their beginning unless searched as a substring
+		{rootID, "ulee", false, "[]"},
+		{rootID, "ulée", true, "[Crème Brûlée.txt]"},
+		{rootID, "e s", true, "[Crèpe Suzette.txt]"},
+		{rootID, `"`, false, "[]"},
+		{rootID, "  ", false, "[]"},
+		{id("other"), "creme", false, "[creme.md]"},
+		{id("other"), "other", false, "[]"},
+		{rootID, "other", false, "[other]"},
+	}
+	for _, c := range cases {
+		if got := search(c.dirID, c.query, c.substring); got != c.want {
+			t.Errorf("%q in %d: %s, want %s", c.query, c.dirID, got, c.want)
+		}
+	}
+
+	// the index follows renames and removals
+	if err := db.MoveItem(id("other/creme.md"), id("other"), "Tarte.md"); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(rootID, "creme", false); got != "[Crème Brûlée.txt]" {
+		t.Errorf("renamed: %s", got)
+	}
+	if got := search(rootID, "tarte", false); got != "[Tarte.md]" {
+		t.Errorf("renamed: %s", got)
+	}
+	if err := db.RemoveItem(id("other/Tarte.md")); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(rootID, "tarte", false); got != "[]" {
+		t.Errorf("removed: %s", got)
+	}
+
+	// names stored by earlier versions are indexed when the database is opened
+	m := db.(*filesDB)
+	if _, err := m.database.Exec(`DELETE FROM files_names; UPDATE files SET fname = NULL`); err != nil {
+		t.Fatal(err)
+	}
+	if got := search(rootID, "creme", false); got != "[]" {
+		t.Errorf("without an index: %s", got)
+	}
+	m.indexNames()
+	if got := search(rootID, "creme", false); got != "[Crème Brûlée.txt]" {
+		t.Errorf("indexed again: %s", got)
+	}
+}
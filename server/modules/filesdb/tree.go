@@ -0,0 +1,116 @@
+package filesdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+)
+
+// treeOrder puts items in the order of a walk of the tree, every item goes after its
+// directory and before the next sibling of the directory. The path is made of the sort keys
+// of the names, followed by the ID for names that sort the same.
+const treeOrder = `coalesce(files.sname, '') || char(2) || printf('%020d', files.id)`
+
+// WriteTree writes the item with the items below it down to the depth, or all of them for
+// depth 0, as JSON. Directories have their items in children, those at the depth have none.
+// Items are written as they are read, so the tree is never held in memory. Saved searches
+// are not part of the tree, what they find is in it already. A tree which fails half way
+// through is closed with the error in the error field of the item at the top.
+func (m *filesDB) WriteTree(dirID int64, depth int, w io.Writer) error {
+	if isVirtualID(dirID) {
+		return errBadRequest
+	}
+	root, err := scanFileMeta(m.database.QueryRow(`SELECT `+fileColumns+` FROM files WHERE id = ?`, dirID))
+	if err != nil {
+		return errNotFound
+	}
+
+	rows, err := m.database.Query(`
+		WITH RECURSIVE tree (id, depth, path) AS (
+			SELECT files.id, 1, `+treeOrder+`
+			FROM files WHERE files.parent_id = ? AND files.ctype IS NOT NULL
+			UNION ALL
+			SELECT files.id, tree.depth + 1, tree.path || char(1) || `+treeOrder+`
+			FROM files JOIN tree ON files.parent_id = tree.id
+			WHERE files.ctype IS NOT NULL AND (? = 0 OR tree.depth < ?)
+		)
+		SELECT tree.depth, `+fileColumns+`
+		FROM tree JOIN files ON files.id = tree.id
+		ORDER BY tree.path`,
+		dirID, depth, depth)
+	if err != nil {
+		log.Printf("%v", err)
+		return err
+	}
+	defer rows.Close()
+
+	b := bufio.NewWriter(w)
+	// written holds for every open directory whether it has items written already
+	written := make([]bool, 0)
+	writeNode := func(fm *fileMeta, level int) {
+		meta, _ := json.Marshal(fm)
+		if fm.CType != contentTypeDirectory || (depth > 0 && level >= depth) {
+			b.Write(meta)
+			return
+		}
+		b.Write(meta[:len(meta)-1])
+		b.WriteString(`,"children":[`)
+		written = append(written, false)
+	}
+
+	// fail closes the directories open below the top and marks the top with the error
+	fail := func(err error) error {
+		log.Printf("%v", err)
+		if len(written) == 0 {
+			return err
+		}
+		for range written[1:] {
+			b.WriteString("]}")
+		}
+		message, _ := json.Marshal(err.Error())
+		b.WriteString(`],"error":`)
+		b.Write(message)
+		b.WriteByte('}')
+		b.Flush()
+		return err
+	}
+
+	writeNode(root, 0)
+	for rows.Next() {
+		var level int
+		fm, err := scanFileMeta(rowPrefix{rows, []interface{}{&level}})
+		if err != nil {
+			return fail(err)
+		}
+		for len(written) > level {
+			b.WriteString("]}")
+			written = written[:len(written)-1]
+		}
+		if len(written) < level {
+			continue
+		}
+		if written[level-1] {
+			b.WriteByte(',')
+		}
+		written[level-1] = true
+		writeNode(fm, level)
+	}
+	if err := rows.Err(); err != nil {
+		return fail(err)
+	}
+	for range written {
+		b.WriteString("]}")
+	}
+	return b.Flush()
+}
+
+// rowPrefix scans columns selected before those scanFileMeta expects
+type rowPrefix struct {
+	row  scanner
+	dest []interface{}
+}
+
+func (r rowPrefix) Scan(dest ...interface{}) error {
+	return r.row.Scan(append(r.dest, dest...)...)
+}
@@ -0,0 +1,91 @@
+package filesdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+type treeNode struct {
+	Name     string      `json:"name"`
+	Children []*treeNode `json:"children"`
+	Error    string      `json:"error"`
+}
+
+func TestWriteTree(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, p := range []string{"a/x/y/1.txt", "a/x/2.txt", "a/3.txt", "b/4.txt"} {
+		os.MkdirAll(path.Dir(path.Join(dir, "files", p)), 0755)
+		ioutil.WriteFile(path.Join(dir, "files", p), []byte(p), 0644)
+	}
+
+	db := NewFilesDB(path.Join(dir, ".meta.db"))
+	db.ScanPath(path.Join(dir, "files"))
+	rootID, err := db.GetIDForPath(path.Join(dir, "files"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(depth int) (*treeNode, error) {
+		var out bytes.Buffer
+		err := db.WriteTree(rootID, depth, &out)
+		root := new(treeNode)
+		if jsonErr := json.Unmarshal(out.Bytes(), root); jsonErr != nil {
+			t.Fatalf("depth %d: %v in %s", depth, jsonErr, out.String())
+		}
+		return root, err
+	}
+	// names lists the tree as a walk would find it
+	var names func(n *treeNode) string
+	names = func(n *treeNode) string {
+		s := n.Name
+		if n.Children != nil {
+			s += "("
+			for i, c := range n.Children {
+				if i > 0 {
+					s += " "
+				}
+				s += names(c)
+			}
+			s += ")"
+		}
+		return s
+	}
+
+	cases := []struct {
+		depth int
+		want  string
+	}{
+		{0, "files(a(3.txt x(2.txt y(1.txt))) b(4.txt))"},
+		{1, "files(a b)"},
+		{2, "files(a(3.txt x) b(4.txt))"},
+	}
+	for _, c := range cases {
+		root, err := write(c.depth)
+		if err != nil || root.Error != "" {
+			t.Errorf("depth %d: %v %s", c.depth, err, root.Error)
+		}
+		if got := names(root); got != c.want {
+			t.Errorf("depth %d: %s, want %s", c.depth, got, c.want)
+		}
+	}
+
+	// an item which cannot be read stops the tree, what was written is closed and marked
+	y, _ := db.GetIDForPath(path.Join(dir, "files/a/x/y"))
+	if _, err := db.(*filesDB).database.Exec(`UPDATE files SET mdate = 'broken' WHERE id = ?`, y); err != nil {
+		t.Fatal(err)
+	}
+	root, err := write(0)
+	if err == nil || root.Error == "" {
+		t.Errorf("broken item: %v %q", err, root.Error)
+	}
+	if got := names(root); got != "files(a(3.txt x(2.txt)))" {
+		t.Errorf("broken item: %s", got)
+	}
+}
@@ -0,0 +1,186 @@
+package filesdb
+
+import (
+	"database/sql"
+	"log"
+)
+
+// Every directory keeps the bytes of the files below it in tree_size and the number of items
+// below it in tree_count. Imports, removals and moves add the difference they make to the
+// directories above the item, a scan counts its subtree again.
+
+// itemBytes is what a file adds to the directories above it, directories add what is in them
+func itemBytes(ctype sql.NullString, size sql.NullInt64) int64 {
+	if !ctype.Valid || ctype.String == contentTypeDirectory {
+		return 0
+	}
+	return size.Int64
+}
+
+// addToTree adds bytes and items to the directory and every directory above it
+func addToTree(tx *sql.Tx, dirID int64, bytes int64, count int64) error {
+	if bytes == 0 && count == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		WITH RECURSIVE up (id) AS (
+			SELECT ?
+			UNION ALL
+			SELECT files.parent_id FROM files JOIN up ON files.id = up.id WHERE files.parent_id IS NOT NULL
+		)
+		UPDATE files SET tree_size = tree_size + ?, tree_count = tree_count + ? WHERE id IN up`,
+		dirID, bytes, count)
+	return err
+}
+
+// itemTotals are the bytes and items the item adds to the directories above it
+func itemTotals(tx *sql.Tx, id int64) (parentID int64, bytes int64, count int64, err error) {
+	var ctype sql.NullString
+	var size sql.NullInt64
+	var treeSize, treeCount int64
+	row := tx.QueryRow(`SELECT parent_id, ctype, size, tree_size, tree_count FROM files WHERE id = ?`, id)
+	if err = row.Scan(&parentID, &ctype, &size, &treeSize, &treeCount); err != nil {
+		return
+	}
+	if !ctype.Valid {
+		// placeholders are not counted
+		return parentID, 0, 0, nil
+	}
+	return parentID, itemBytes(ctype, size) + treeSize, 1 + treeCount, nil
+}
+
+// countTree counts the totals of the directory and of every directory below it again,
+// then passes the difference on to the directories above it
+func countTree(tx *sql.Tx, dirID int64) error {
+	var parentID sql.NullInt64
+	var treeSize, treeCount int64
+	row := tx.QueryRow(`SELECT parent_id, tree_size, tree_count FROM files WHERE id = ?`, dirID)
+	if err := row.Scan(&parentID, &treeSize, &treeCount); err != nil {
+		return err
+	}
+
+	// every item below the directory is paired with each of its directories up to it
+	_, err := tx.Exec(`
+		WITH RECURSIVE sub (id) AS (
+			SELECT $1
+			UNION ALL
+			SELECT files.id FROM files JOIN sub ON files.parent_id = sub.id
+		),
+		pairs (dir_id, id) AS (
+			SELECT parent_id, id FROM files WHERE id IN sub AND id != $1
+			UNION ALL
+			SELECT files.parent_id, pairs.id FROM pairs JOIN files ON files.id = pairs.dir_id
+			WHERE pairs.dir_id != $1
+		),
+		totals (id, bytes, items) AS (
+			SELECT pairs.dir_id,
+				sum(CASE item.ctype WHEN $2 THEN 0 ELSE coalesce(item.size, 0) END),
+				count(*)
+			FROM pairs JOIN files AS item ON item.id = pairs.id
+			WHERE item.ctype IS NOT NULL
+			GROUP BY pairs.dir_id
+		)
+		UPDATE files SET
+			tree_size = coalesce((SELECT bytes FROM totals WHERE totals.id = files.id), 0),
+			tree_count = coalesce((SELECT items FROM totals WHERE totals.id = files.id), 0)
+		WHERE id IN sub`,
+		dirID, contentTypeDirectory)
+	if err != nil {
+		return err
+	}
+
+	if !parentID.Valid {
+		return nil
+	}
+	var newSize, newCount int64
+	row = tx.QueryRow(`SELECT tree_size, tree_count FROM files WHERE id = ?`, dirID)
+	if err := row.Scan(&newSize, &newCount); err != nil {
+		return err
+	}
+	return addToTree(tx, parentID.Int64, newSize-treeSize, newCount-treeCount)
+}
+
+// usageMeta tells where the bytes below a directory are
+type usageMeta struct {
+	Size    int64         `json:"size"`
+	Count   int64         `json:"count"`
+	Folders []*usageEntry `json:"folders"`
+	Types   []*usageEntry `json:"types"`
+}
+
+type usageEntry struct {
+	ID    int64  `json:"id,omitempty"`
+	Name  string `json:"name,omitempty"`
+	CType string `json:"ctype,omitempty"`
+	Size  int64  `json:"size"`
+	Count int64  `json:"count"`
+}
+
+// GetUsage breaks down the bytes below the directory by the directories in it and by the
+// content types of the files, the largest first
+func (m *filesDB) GetUsage(dirID int64) interface{} {
+	result := &usageMeta{
+		Folders: make([]*usageEntry, 0),
+		Types:   make([]*usageEntry, 0),
+	}
+	row := m.database.QueryRow(`SELECT tree_size, tree_count FROM files WHERE id = ? AND ctype = ?`, dirID, contentTypeDirectory)
+	if err := row.Scan(&result.Size, &result.Count); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	rows, err := m.database.Query(`
+		SELECT id, name, tree_size, tree_count FROM files
+		WHERE parent_id = ? AND ctype = ?
+		ORDER BY tree_size DESC, sname, id`,
+		dirID, contentTypeDirectory)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	for rows.Next() {
+		e := new(usageEntry)
+		if err := rows.Scan(&e.ID, &e.Name, &e.Size, &e.Count); err != nil {
+			rows.Close()
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Folders = append(result.Folders, e)
+	}
+	rows.Close()
+
+	// content types without parameters such as the charset
+	rows, err = m.database.Query(`
+		WITH RECURSIVE tree (id) AS (
+			SELECT id FROM files WHERE parent_id = ?
+			UNION ALL
+			SELECT files.id FROM files JOIN tree ON files.parent_id = tree.id
+		),
+		typed (ctype, size) AS (
+			SELECT lower(trim(CASE instr(files.ctype, ';')
+					WHEN 0 THEN files.ctype
+					ELSE substr(files.ctype, 1, instr(files.ctype, ';') - 1)
+				END)),
+				coalesce(files.size, 0)
+			FROM files JOIN tree ON tree.id = files.id
+			WHERE files.ctype IS NOT NULL AND files.ctype != ?
+		)
+		SELECT ctype, sum(size), count(*) FROM typed
+		GROUP BY ctype
+		ORDER BY sum(size) DESC, ctype`,
+		dirID, contentTypeDirectory)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+	for rows.Next() {
+		e := new(usageEntry)
+		if err := rows.Scan(&e.CType, &e.Size, &e.Count); err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Types = append(result.Types, e)
+	}
+	return result
+}
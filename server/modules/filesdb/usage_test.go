@@ -0,0 +1,160 @@
+package filesdb
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestTreeTotals(t *testing.T) {
+	dir, err := ioutil.TempDir("", "usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]int{
+		"a/1.txt":       5,
+		"a/x/2.txt":     10,
+		"a/x/y/3.txt":   20,
+		"a/x/y/z/4.txt": 40,
+		"b/5.txt":       7,
+		"b/c/6.txt":     3,
+	}
+	write := func(p string, size int) {
+		os.MkdirAll(path.Dir(path.Join(dir, "files", p)), 0755)
+		ioutil.WriteFile(path.Join(dir, "files", p), []byte(strings.Repeat("x", size)), 0644)
+	}
+	for p, size := range files {
+		write(p, size)
+	}
+	os.MkdirAll(path.Join(dir, "files/b/empty"), 0755)
+
+	db := NewFilesDB(path.Join(dir, ".meta.db"))
+	db.ScanPath(path.Join(dir, "files"))
+	id := func(p string) int64 {
+		id, err := db.GetIDForPath(path.Join(dir, "files", p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	// check compares the totals kept by every directory with those counted from scratch
+	check := func(context string) {
+		type item struct {
+			parentID sql.NullInt64
+			bytes    int64
+			counted  bool
+		}
+		items := make(map[int64]*item)
+		type totals struct{ size, count int64 }
+		kept := make(map[int64]totals)
+		rows, err := db.(*filesDB).database.Query(`SELECT id, parent_id, ctype, size, tree_size, tree_count FROM files`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var id int64
+			var ctype sql.NullString
+			var size sql.NullInt64
+			var tt totals
+			it := new(item)
+			if err := rows.Scan(&id, &it.parentID, &ctype, &size, &tt.size, &tt.count); err != nil {
+				t.Fatal(err)
+			}
+			it.counted = ctype.Valid
+			if ctype.String != contentTypeDirectory {
+				it.bytes = size.Int64
+			}
+			items[id] = it
+			if ctype.String == contentTypeDirectory {
+				kept[id] = tt
+			}
+		}
+		rows.Close()
+
+		recounted := make(map[int64]totals)
+		for _, it := range items {
+			if !it.counted {
+				continue
+			}
+			for up := it.parentID; up.Valid; up = items[up.Int64].parentID {
+				tt := recounted[up.Int64]
+				recounted[up.Int64] = totals{tt.size + it.bytes, tt.count + 1}
+			}
+		}
+		for id, tt := range kept {
+			if recounted[id] != tt {
+				t.Errorf("%s: directory %d keeps %+v, counts %+v", context, id, tt, recounted[id])
+			}
+		}
+	}
+	check("scan")
+	if usage := db.GetUsage(id("")).(*usageMeta); usage.Size != 85 || usage.Count != 13 {
+		t.Errorf("scan: %d bytes in %d items", usage.Size, usage.Count)
+	}
+
+	// directories tell their totals, empty ones too, files have none to tell
+	for p, want := range map[string]string{
+		"b":       `"size":3,`,
+		"b/empty": `"treeSize":0,"treeCount":0`,
+		"b/c":     `"treeSize":3,"treeCount":1`,
+		"b/5.txt": `"size":7,`,
+	} {
+		meta, err := json.Marshal(db.GetMetaDataForItemWithID(id(p)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(meta), want) {
+			t.Errorf("%s: %s", p, meta)
+		}
+		if isDir := !strings.HasSuffix(p, ".txt"); isDir != strings.Contains(string(meta), `"treeCount"`) {
+			t.Errorf("%s: %s", p, meta)
+		}
+	}
+
+	// a subtree moves with its totals
+	if err := db.MoveItem(id("a/x"), id("b/c"), "x"); err != nil {
+		t.Fatal(err)
+	}
+	check("move across subtrees")
+	if err := db.MoveItem(id("b/c/x/y"), id("a"), "y"); err != nil {
+		t.Fatal(err)
+	}
+	check("move back")
+
+	// and goes with them
+	if err := db.RemoveItem(id("b/c")); err != nil {
+		t.Fatal(err)
+	}
+	check("remove a subtree")
+
+	// files replaced take back what they added
+	write("a/1.txt", 50)
+	if err := db.ImportItem(id("a/1.txt"), path.Join(dir, "files/a/1.txt")); err != nil {
+		t.Fatal(err)
+	}
+	check("replace")
+
+	// a rescan drops what is gone and counts again what is there
+	os.RemoveAll(path.Join(dir, "files/a/y/z"))
+	os.Remove(path.Join(dir, "files/b/5.txt"))
+	write("b/empty/7.txt", 9)
+	write("a/y/8.txt", 11)
+	// orphans are what the server saw before it started
+	db.(*filesDB).startTime++
+	db.ScanPath(path.Join(dir, "files"))
+	check("rescan with orphans")
+	if _, err := db.GetIDForPath(path.Join(dir, "files/a/y/z/4.txt")); err == nil {
+		t.Errorf("rescan with orphans: 4.txt is left")
+	}
+
+	// a rescan of part of the tree passes the difference on above it
+	write("a/y/9.txt", 13)
+	db.ScanPath(path.Join(dir, "files/a/y"))
+	check("partial rescan")
+}
@@ -0,0 +1,874 @@
+package geocode
+
+// cities is the embedded dataset: capitals, large cities and popular destinations
+var cities = []city{
+	// Europe
+	{"Amsterdam", "North Holland", "Netherlands", 52.3676, 4.9041},
+	{"Rotterdam", "South Holland", "Netherlands", 51.9244, 4.4777},
+	{"The Hague", "South Holland", "Netherlands", 52.0705, 4.3007},
+	{"Utrecht", "Utrecht", "Netherlands", 52.0907, 5.1214},
+	{"Eindhoven", "North Brabant", "Netherlands", 51.4416, 5.4697},
+	{"Groningen", "Groningen", "Netherlands", 53.2194, 6.5665},
+	{"Brussels", "Brussels-Capital", "Belgium", 50.8503, 4.3517},
+	{"Antwerp", "Flanders", "Belgium", 51.2194, 4.4025},
+	{"Ghent", "Flanders", "Belgium", 51.0543, 3.7174},
+	{"Bruges", "Flanders", "Belgium", 51.2093, 3.2247},
+	{"Liège", "Wallonia", "Belgium", 50.6326, 5.5797},
+	{"Luxembourg", "Luxembourg", "Luxembourg", 49.6116, 6.1319},
+	{"Paris", "Île-de-France", "France", 48.8566, 2.3522},
+	{"Lyon", "Auvergne-Rhône-Alpes", "France", 45.7640, 4.8357},
+	{"Marseille", "Provence-Alpes-Côte d'Azur", "France", 43.2965, 5.3698},
+	{"Nice", "Provence-Alpes-Côte d'Azur", "France", 43.7102, 7.2620},
+	{"Toulouse", "Occitanie", "France", 43.6047, 1.4442},
+	{"Montpellier", "Occitanie", "France", 43.6108, 3.8767},
+	{"Bordeaux", "Nouvelle-Aquitaine", "France", 44.8378, -0.5792},
+	{"Nantes", "Pays de la Loire", "France", 47.2184, -1.5536},
+	{"Rennes", "Brittany", "France", 48.1173, -1.6778},
+	{"Brest", "Brittany", "France", 48.3904, -4.4861},
+	{"Strasbourg", "Grand Est", "France", 48.5734, 7.7521},
+	{"Lille", "Hauts-de-France", "France", 50.6292, 3.0573},
+	{"Rouen", "Normandy", "France", 49.4432, 1.0999},
+	{"Dijon", "Bourgogne-Franche-Comté", "France", 47.3220, 5.0415},
+	{"Chamonix", "Auvergne-Rhône-Alpes", "France", 45.9237, 6.8694},
+	{"Ajaccio", "Corsica", "France", 41.9192, 8.7386},
+	{"Monaco", "Monaco", "Monaco", 43.7384, 7.4246},
+	{"London", "England", "United Kingdom", 51.5074, -0.1278},
+	{"Birmingham", "England", "United Kingdom", 52.4862, -1.8904},
+	{"Manchester", "England", "United Kingdom", 53.4808, -2.2426},
+	{"Liverpool", "England", "United Kingdom", 53.4084, -2.9916},
+	{"Leeds", "England", "United Kingdom", 53.8008, -1.5491},
+	{"Newcastle upon Tyne", "England", "United Kingdom", 54.9783, -1.6178},
+	{"Bristol", "England", "United Kingdom", 51.4545, -2.5879},
+	{"Oxford", "England", "United Kingdom", 51.7520, -1.2577},
+	{"Cambridge", "England", "United Kingdom", 52.2053, 0.1218},
+	{"Brighton", "England", "United Kingdom", 50.8225, -0.1372},
+	{"Plymouth", "England", "United Kingdom", 50.3755, -4.1427},
+	{"Edinburgh", "Scotland", "United Kingdom", 55.9533, -3.1883},
+	{"Glasgow", "Scotland", "United Kingdom", 55.8642, -4.2518},
+	{"Aberdeen", "Scotland", "United Kingdom", 57.1497, -2.0943},
+	{"Inverness", "Scotland", "United Kingdom", 57.4778, -4.2247},
+	{"Cardiff", "Wales", "United Kingdom", 51.4816, -3.1791},
+	{"Belfast", "Northern Ireland", "United Kingdom", 54.5973, -5.9301},
+	{"Dublin", "Leinster", "Ireland", 53.3498, -6.2603},
+	{"Cork", "Munster", "Ireland", 51.8985, -8.4756},
+	{"Galway", "Connacht", "Ireland", 53.2707, -9.0568},
+	{"Berlin", "Berlin", "Germany", 52.5200, 13.4050},
+	{"Hamburg", "Hamburg", "Germany", 53.5511, 9.9937},
+	{"Munich", "Bavaria", "Germany", 48.1351, 11.5820},
+	{"Nuremberg", "Bavaria", "Germany", 49.4521, 11.0767},
+	{"Cologne", "North Rhine-Westphalia", "Germany", 50.9375, 6.9603},
+	{"Düsseldorf", "North Rhine-Westphalia", "Germany", 51.2277, 6.7735},
+	{"Dortmund", "North Rhine-Westphalia", "Germany", 51.5136, 7.4653},
+	{"Frankfurt", "Hesse", "Germany", 50.1109, 8.6821},
+	{"Stuttgart", "Baden-Württemberg", "Germany", 48.7758, 9.1829},
+	{"Freiburg", "Baden-Württemberg", "Germany", 47.9990, 7.8421},
+	{"Leipzig", "Saxony", "Germany", 51.3397, 12.3731},
+	{"Dresden", "Saxony", "Germany", 51.0504, 13.7373},
+	{"Hanover", "Lower Saxony", "Germany", 52.3759, 9.7320},
+	{"Bremen", "Bremen", "Germany", 53.0793, 8.8017},
+	{"Kiel", "Schleswig-Holstein", "Germany", 54.3233, 10.1228},
+	{"Rostock", "Mecklenburg-Vorpommern", "Germany", 54.0924, 12.0991},
+	{"Vienna", "Vienna", "Austria", 48.2082, 16.3738},
+	{"Salzburg", "Salzburg", "Austria", 47.8095, 13.0550},
+	{"Innsbruck", "Tyrol", "Austria", 47.2692, 11.4041},
+	{"Graz", "Styria", "Austria", 47.0707, 15.4395},
+	{"Zurich", "Zurich", "Switzerland", 47.3769, 8.5417},
+	{"Geneva", "Geneva", "Switzerland", 46.2044, 6.1432},
+	{"Bern", "Bern", "Switzerland", 46.9480, 7.4474},
+	{"Basel", "Basel-Stadt", "Switzerland", 47.5596, 7.5886},
+	{"Lucerne", "Lucerne", "Switzerland", 47.0502, 8.3093},
+	{"Zermatt", "Valais", "Switzerland", 46.0207, 7.7491},
+	{"Lugano", "Ticino", "Switzerland", 46.0037, 8.9511},
+	{"Vaduz", "Vaduz", "Liechtenstein", 47.1410, 9.5209},
+	{"Rome", "Lazio", "Italy", 41.9028, 12.4964},
+	{"Milan", "Lombardy", "Italy", 45.4642, 9.1900},
+	{"Bergamo", "Lombardy", "Italy", 45.6983, 9.6773},
+	{"Como", "Lombardy", "Italy", 45.8081, 9.0852},
+	{"Venice", "Veneto", "Italy", 45.4408, 12.3155},
+	{"Verona", "Veneto", "Italy", 45.4384, 10.9916},
+	{"Turin", "Piedmont", "Italy", 45.0703, 7.6869},
+	{"Genoa", "Liguria", "Italy", 44.4056, 8.9463},
+	{"Bologna", "Emilia-Romagna", "Italy", 44.4949, 11.3426},
+	{"Florence", "Tuscany", "Italy", 43.7696, 11.2558},
+	{"Pisa", "Tuscany", "Italy", 43.7228, 10.4017},
+	{"Siena", "Tuscany", "Italy", 43.3188, 11.3308},
+	{"Naples", "Campania", "Italy", 40.8518, 14.2681},
+	{"Amalfi", "Campania", "Italy", 40.6340, 14.6027},
+	{"Bari", "Apulia", "Italy", 41.1171, 16.8719},
+	{"Palermo", "Sicily", "Italy", 38.1157, 13.3615},
+	{"Catania", "Sicily", "Italy", 37.5079, 15.0830},
+	{"Cagliari", "Sardinia", "Italy", 39.2238, 9.1217},
+	{"Trento", "Trentino-Alto Adige", "Italy", 46.0748, 11.1217},
+	{"Bolzano", "Trentino-Alto Adige", "Italy", 46.4983, 11.3548},
+	{"Vatican City", "Vatican City", "Vatican City", 41.9029, 12.4534},
+	{"San Marino", "San Marino", "San Marino", 43.9424, 12.4578},
+	{"Valletta", "Malta", "Malta", 35.8989, 14.5146},
+	{"Madrid", "Community of Madrid", "Spain", 40.4168, -3.7038},
+	{"Barcelona", "Catalonia", "Spain", 41.3851, 2.1734},
+	{"Girona", "Catalonia", "Spain", 41.9794, 2.8214},
+	{"Valencia", "Valencian Community", "Spain", 39.4699, -0.3763},
+	{"Alicante", "Valencian Community", "Spain", 38.3452, -0.4810},
+	{"Seville", "Andalusia", "Spain", 37.3891, -5.9845},
+	{"Málaga", "Andalusia", "Spain", 36.7213, -4.4214},
+	{"Granada", "Andalusia", "Spain", 37.1773, -3.5986},
+	{"Córdoba", "Andalusia", "Spain", 37.8882, -4.7794},
+	{"Bilbao", "Basque Country", "Spain", 43.2630, -2.9350},
+	{"San Sebastián", "Basque Country", "Spain", 43.3183, -1.9812},
+	{"Zaragoza", "Aragon", "Spain", 41.6488, -0.8891},
+	{"Santiago de Compostela", "Galicia", "Spain", 42.8782, -8.5448},
+	{"Salamanca", "Castile and León", "Spain", 40.9701, -5.6635},
+	{"Palma", "Balearic Islands", "Spain", 39.5696, 2.6502},
+	{"Ibiza", "Balearic Islands", "Spain", 38.9067, 1.4206},
+	{"Las Palmas", "Canary Islands", "Spain", 28.1235, -15.4363},
+	{"Santa Cruz de Tenerife", "Canary Islands", "Spain", 28.4636, -16.2518},
+	{"Andorra la Vella", "Andorra la Vella", "Andorra", 42.5063, 1.5218},
+	{"Lisbon", "Lisbon", "Portugal", 38.7223, -9.1393},
+	{"Porto", "Porto", "Portugal", 41.1579, -8.6291},
+	{"Faro", "Algarve", "Portugal", 37.0194, -7.9322},
+	{"Funchal", "Madeira", "Portugal", 32.6669, -16.9241},
+	{"Ponta Delgada", "Azores", "Portugal", 37.7412, -25.6756},
+	{"Copenhagen", "Capital Region", "Denmark", 55.6761, 12.5683},
+	{"Aarhus", "Central Denmark", "Denmark", 56.1629, 10.2039},
+	{"Odense", "Southern Denmark", "Denmark", 55.4038, 10.4024},
+	{"Tórshavn", "Streymoy", "Faroe Islands", 62.0079, -6.7900},
+	{"Oslo", "Oslo", "Norway", 59.9139, 10.7522},
+	{"Bergen", "Vestland", "Norway", 60.3913, 5.3221},
+	{"Stavanger", "Rogaland", "Norway", 58.9700, 5.7331},
+	{"Trondheim", "Trøndelag", "Norway", 63.4305, 10.3951},
+	{"Tromsø", "Troms", "Norway", 69.6492, 18.9553},
+	{"Longyearbyen", "Svalbard", "Norway", 78.2232, 15.6267},
+	{"Stockholm", "Stockholm", "Sweden", 59.3293, 18.0686},
+	{"Gothenburg", "Västra Götaland", "Sweden", 57.7089, 11.9746},
+	{"Malmö", "Skåne", "Sweden", 55.6050, 13.0038},
+	{"Uppsala", "Uppsala", "Sweden", 59.8586, 17.6389},
+	{"Kiruna", "Norrbotten", "Sweden", 67.8558, 20.2253},
+	{"Helsinki", "Uusimaa", "Finland", 60.1699, 24.9384},
+	{"Tampere", "Pirkanmaa", "Finland", 61.4978, 23.7610},
+	{"Turku", "Southwest Finland", "Finland", 60.4518, 22.2666},
+	{"Rovaniemi", "Lapland", "Finland", 66.5039, 25.7294},
+	{"Reykjavík", "Capital Region", "Iceland", 64.1466, -21.9426},
+	{"Akureyri", "Northeastern Region", "Iceland", 65.6885, -18.1262},
+	{"Tallinn", "Harju", "Estonia", 59.4370, 24.7536},
+	{"Tartu", "Tartu", "Estonia", 58.3780, 26.7290},
+	{"Riga", "Riga", "Latvia", 56.9496, 24.1052},
+	{"Vilnius", "Vilnius", "Lithuania", 54.6872, 25.2797},
+	{"Kaunas", "Kaunas", "Lithuania", 54.8985, 23.9036},
+	{"Warsaw", "Masovia", "Poland", 52.2297, 21.0122},
+	{"Kraków", "Lesser Poland", "Poland", 50.0647, 19.9450},
+	{"Gdańsk", "Pomerania", "Poland", 54.3520, 18.6466},
+	{"Wrocław", "Lower Silesia", "Poland", 51.1079, 17.0385},
+	{"Poznań", "Greater Poland", "Poland", 52.4064, 16.9252},
+	{"Łódź", "Łódź", "Poland", 51.7592, 19.4560},
+	{"Zakopane", "Lesser Poland", "Poland", 49.2992, 19.9496},
+	{"Prague", "Prague", "Czech Republic", 50.0755, 14.4378},
+	{"Brno", "South Moravia", "Czech Republic", 49.1951, 16.6068},
+	{"Český Krumlov", "South Bohemia", "Czech Republic", 48.8127, 14.3175},
+	{"Bratislava", "Bratislava", "Slovakia", 48.1486, 17.1077},
+	{"Košice", "Košice", "Slovakia", 48.7164, 21.2611},
+	{"Budapest", "Budapest", "Hungary", 47.4979, 19.0402},
+	{"Debrecen", "Hajdú-Bihar", "Hungary", 47.5316, 21.6273},
+	{"Ljubljana", "Central Slovenia", "Slovenia", 46.0569, 14.5058},
+	{"Bled", "Upper Carniola", "Slovenia", 46.3683, 14.1146},
+	{"Zagreb", "Zagreb", "Croatia", 45.8150, 15.9819},
+	{"Split", "Split-Dalmatia", "Croatia", 43.5081, 16.4402},
+	{"Dubrovnik", "Dubrovnik-Neretva", "Croatia", 42.6507, 18.0944},
+	{"Zadar", "Zadar", "Croatia", 44.1194, 15.2314},
+	{"Rijeka", "Primorje-Gorski Kotar", "Croatia", 45.3271, 14.4422},
+	{"Sarajevo", "Federation of Bosnia and Herzegovina", "Bosnia and Herzegovina", 43.8563, 18.4131},
+	{"Mostar", "Federation of Bosnia and Herzegovina", "Bosnia and Herzegovina", 43.3438, 17.8078},
+	{"Belgrade", "Belgrade", "Serbia", 44.7866, 20.4489},
+	{"Novi Sad", "Vojvodina", "Serbia", 45.2671, 19.8335},
+	{"Podgorica", "Podgorica", "Montenegro", 42.4304, 19.2594},
+	{"Kotor", "Kotor", "Montenegro", 42.4247, 18.7712},
+	{"Pristina", "Pristina", "Kosovo", 42.6629, 21.1655},
+	{"Skopje", "Skopje", "North Macedonia", 41.9981, 21.4254},
+	{"Ohrid", "Southwestern", "North Macedonia", 41.1231, 20.8016},
+	{"Tirana", "Tirana", "Albania", 41.3275, 19.8187},
+	{"Sofia", "Sofia City", "Bulgaria", 42.6977, 23.3219},
+	{"Plovdiv", "Plovdiv", "Bulgaria", 42.1354, 24.7453},
+	{"Varna", "Varna", "Bulgaria", 43.2141, 27.9147},
+	{"Bucharest", "Bucharest", "Romania", 44.4268, 26.1025},
+	{"Cluj-Napoca", "Cluj", "Romania", 46.7712, 23.6236},
+	{"Brașov", "Brașov", "Romania", 45.6427, 25.5887},
+	{"Constanța", "Constanța", "Romania", 44.1598, 28.6348},
+	{"Chișinău", "Chișinău", "Moldova", 47.0105, 28.8638},
+	{"Athens", "Attica", "Greece", 37.9838, 23.7275},
+	{"Thessaloniki", "Central Macedonia", "Greece", 40.6401, 22.9444},
+	{"Heraklion", "Crete", "Greece", 35.3387, 25.1442},
+	{"Chania", "Crete", "Greece", 35.5138, 24.0180},
+	{"Rhodes", "South Aegean", "Greece", 36.4341, 28.2176},
+	{"Fira", "South Aegean", "Greece", 36.4167, 25.4316},
+	{"Mykonos", "South Aegean", "Greece", 37.4467, 25.3289},
+	{"Corfu", "Ionian Islands", "Greece", 39.6243, 19.9217},
+	{"Nicosia", "Nicosia", "Cyprus", 35.1856, 33.3823},
+	{"Limassol", "Limassol", "Cyprus", 34.7071, 33.0226},
+	{"Kyiv", "Kyiv", "Ukraine", 50.4501, 30.5234},
+	{"Lviv", "Lviv", "Ukraine", 49.8397, 24.0297},
+	{"Odesa", "Odesa", "Ukraine", 46.4825, 30.7233},
+	{"Kharkiv", "Kharkiv", "Ukraine", 49.9935, 36.2304},
+	{"Dnipro", "Dnipropetrovsk", "Ukraine", 48.4647, 35.0462},
+	{"Minsk", "Minsk", "Belarus", 53.9006, 27.5590},
+	{"Moscow", "Moscow", "Russia", 55.7558, 37.6173},
+	{"Saint Petersburg", "Saint Petersburg", "Russia", 59.9311, 30.3609},
+	{"Kaliningrad", "Kaliningrad", "Russia", 54.7104, 20.4522},
+	{"Murmansk", "Murmansk", "Russia", 68.9585, 33.0827},
+	{"Kazan", "Tatarstan", "Russia", 55.7887, 49.1221},
+	{"Nizhny Novgorod", "Nizhny Novgorod", "Russia", 56.2965, 43.9361},
+	{"Samara", "Samara", "Russia", 53.1959, 50.1002},
+	{"Volgograd", "Volgograd", "Russia", 48.7080, 44.5133},
+	{"Rostov-on-Don", "Rostov", "Russia", 47.2357, 39.7015},
+	{"Sochi", "Krasnodar", "Russia", 43.6028, 39.7342},
+	{"Yekaterinburg", "Sverdlovsk", "Russia", 56.8389, 60.6057},
+	{"Perm", "Perm", "Russia", 58.0105, 56.2502},
+	{"Ufa", "Bashkortostan", "Russia", 54.7388, 55.9721},
+	{"Omsk", "Omsk", "Russia", 54.9885, 73.3242},
+	{"Novosibirsk", "Novosibirsk", "Russia", 55.0084, 82.9357},
+	{"Krasnoyarsk", "Krasnoyarsk", "Russia", 56.0153, 92.8932},
+	{"Irkutsk", "Irkutsk", "Russia", 52.2870, 104.3050},
+	{"Yakutsk", "Sakha", "Russia", 62.0355, 129.6755},
+	{"Khabarovsk", "Khabarovsk", "Russia", 48.4802, 135.0719},
+	{"Vladivostok", "Primorsky", "Russia", 43.1198, 131.8869},
+	{"Petropavlovsk-Kamchatsky", "Kamchatka", "Russia", 53.0452, 158.6483},
+	{"Magadan", "Magadan", "Russia", 59.5638, 150.8035},
+
+	// Middle East and Caucasus
+	{"Istanbul", "Istanbul", "Turkey", 41.0082, 28.9784},
+	{"Ankara", "Ankara", "Turkey", 39.9334, 32.8597},
+	{"Izmir", "Izmir", "Turkey", 38.4237, 27.1428},
+	{"Antalya", "Antalya", "Turkey", 36.8969, 30.7133},
+	{"Göreme", "Nevşehir", "Turkey", 38.6431, 34.8289},
+	{"Trabzon", "Trabzon", "Turkey", 41.0027, 39.7168},
+	{"Diyarbakır", "Diyarbakır", "Turkey", 37.9144, 40.2306},
+	{"Tbilisi", "Tbilisi", "Georgia", 41.7151, 44.8271},
+	{"Batumi", "Adjara", "Georgia", 41.6168, 41.6367},
+	{"Yerevan", "Yerevan", "Armenia", 40.1792, 44.4991},
+	{"Baku", "Baku", "Azerbaijan", 40.4093, 49.8671},
+	{"Tehran", "Tehran", "Iran", 35.6892, 51.3890},
+	{"Isfahan", "Isfahan", "Iran", 32.6546, 51.6680},
+	{"Shiraz", "Fars", "Iran", 29.5918, 52.5837},
+	{"Mashhad", "Razavi Khorasan", "Iran", 36.2605, 59.6168},
+	{"Tabriz", "East Azerbaijan", "Iran", 38.0962, 46.2738},
+	{"Baghdad", "Baghdad", "Iraq", 33.3152, 44.3661},
+	{"Erbil", "Kurdistan Region", "Iraq", 36.1901, 44.0091},
+	{"Basra", "Basra", "Iraq", 30.5085, 47.7804},
+	{"Damascus", "Damascus", "Syria", 33.5138, 36.2765},
+	{"Aleppo", "Aleppo", "Syria", 36.2021, 37.1343},
+	{"Beirut", "Beirut", "Lebanon", 33.8938, 35.5018},
+	{"Jerusalem", "Jerusalem", "Israel", 31.7683, 35.2137},
+	{"Tel Aviv", "Tel Aviv", "Israel", 32.0853, 34.7818},
+	{"Haifa", "Haifa", "Israel", 32.7940, 34.9896},
+	{"Eilat", "Southern", "Israel", 29.5577, 34.9519},
+	{"Amman", "Amman", "Jordan", 31.9454, 35.9284},
+	{"Petra", "Ma'an", "Jordan", 30.3285, 35.4444},
+	{"Aqaba", "Aqaba", "Jordan", 29.5320, 35.0063},
+	{"Riyadh", "Riyadh", "Saudi Arabia", 24.7136, 46.6753},
+	{"Jeddah", "Makkah", "Saudi Arabia", 21.4858, 39.1925},
+	{"Mecca", "Makkah", "Saudi Arabia", 21.3891, 39.8579},
+	{"Medina", "Medina", "Saudi Arabia", 24.5247, 39.5692},
+	{"Dammam", "Eastern Province", "Saudi Arabia", 26.4207, 50.0888},
+	{"Kuwait City", "Capital", "Kuwait", 29.3759, 47.9774},
+	{"Manama", "Capital", "Bahrain", 26.2285, 50.5860},
+	{"Doha", "Doha", "Qatar", 25.2854, 51.5310},
+	{"Abu Dhabi", "Abu Dhabi", "United Arab Emirates", 24.4539, 54.3773},
+	{"Dubai", "Dubai", "United Arab Emirates", 25.2048, 55.2708},
+	{"Muscat", "Muscat", "Oman", 23.5880, 58.3829},
+	{"Salalah", "Dhofar", "Oman", 17.0151, 54.0924},
+	{"Sana'a", "Sana'a", "Yemen", 15.3694, 44.1910},
+	{"Aden", "Aden", "Yemen", 12.7855, 45.0187},
+
+	// Africa
+	{"Cairo", "Cairo", "Egypt", 30.0444, 31.2357},
+	{"Giza", "Giza", "Egypt", 30.0131, 31.2089},
+	{"Alexandria", "Alexandria", "Egypt", 31.2001, 29.9187},
+	{"Luxor", "Luxor", "Egypt", 25.6872, 32.6396},
+	{"Aswan", "Aswan", "Egypt", 24.0889, 32.8998},
+	{"Hurghada", "Red Sea", "Egypt", 27.2579, 33.8116},
+	{"Sharm El Sheikh", "South Sinai", "Egypt", 27.9158, 34.3300},
+	{"Tripoli", "Tripoli", "Libya", 32.8872, 13.1913},
+	{"Benghazi", "Benghazi", "Libya", 32.1167, 20.0667},
+	{"Tunis", "Tunis", "Tunisia", 36.8065, 10.1815},
+	{"Sousse", "Sousse", "Tunisia", 35.8256, 10.6084},
+	{"Djerba", "Medenine", "Tunisia", 33.8076, 10.8451},
+	{"Algiers", "Algiers", "Algeria", 36.7538, 3.0588},
+	{"Oran", "Oran", "Algeria", 35.6971, -0.6308},
+	{"Tamanrasset", "Tamanrasset", "Algeria", 22.7850, 5.5228},
+	{"Rabat", "Rabat-Salé-Kénitra", "Morocco", 34.0209, -6.8416},
+	{"Casablanca", "Casablanca-Settat", "Morocco", 33.5731, -7.5898},
+	{"Marrakesh", "Marrakesh-Safi", "Morocco", 31.6295, -7.9811},
+	{"Fez", "Fès-Meknès", "Morocco", 34.0181, -5.0078},
+	{"Tangier", "Tanger-Tetouan-Al Hoceima", "Morocco", 35.7595, -5.8340},
+	{"Agadir", "Souss-Massa", "Morocco", 30.4278, -9.5981},
+	{"Merzouga", "Drâa-Tafilalet", "Morocco", 31.0802, -4.0134},
+	{"Nouakchott", "Nouakchott", "Mauritania", 18.0735, -15.9582},
+	{"Dakar", "Dakar", "Senegal", 14.7167, -17.4677},
+	{"Banjul", "Banjul", "Gambia", 13.4549, -16.5790},
+	{"Bamako", "Bamako", "Mali", 12.6392, -8.0029},
+	{"Timbuktu", "Tombouctou", "Mali", 16.7666, -3.0026},
+	{"Niamey", "Niamey", "Niger", 13.5116, 2.1254},
+	{"Ouagadougou", "Centre", "Burkina Faso", 12.3714, -1.5197},
+	{"Conakry", "Conakry", "Guinea", 9.6412, -13.5784},
+	{"Freetown", "Western Area", "Sierra Leone", 8.4657, -13.2317},
+	{"Monrovia", "Montserrado", "Liberia", 6.3156, -10.8074},
+	{"Abidjan", "Abidjan", "Ivory Coast", 5.3600, -4.0083},
+	{"Accra", "Greater Accra", "Ghana", 5.6037, -0.1870},
+	{"Kumasi", "Ashanti", "Ghana", 6.6885, -1.6244},
+	{"Lomé", "Maritime", "Togo", 6.1725, 1.2314},
+	{"Cotonou", "Littoral", "Benin", 6.3703, 2.3912},
+	{"Lagos", "Lagos", "Nigeria", 6.5244, 3.3792},
+	{"Abuja", "Federal Capital Territory", "Nigeria", 9.0765, 7.3986},
+	{"Kano", "Kano", "Nigeria", 12.0022, 8.5920},
+	{"Port Harcourt", "Rivers", "Nigeria", 4.8156, 7.0498},
+	{"N'Djamena", "N'Djamena", "Chad", 12.1348, 15.0557},
+	{"Douala", "Littoral", "Cameroon", 4.0511, 9.7679},
+	{"Yaoundé", "Centre", "Cameroon", 3.8480, 11.5021},
+	{"Bangui", "Bangui", "Central African Republic", 4.3947, 18.5582},
+	{"Libreville", "Estuaire", "Gabon", 0.4162, 9.4673},
+	{"Brazzaville", "Brazzaville", "Republic of the Congo", -4.2634, 15.2429},
+	{"Kinshasa", "Kinshasa", "DR Congo", -4.4419, 15.2663},
+	{"Lubumbashi", "Haut-Katanga", "DR Congo", -11.6647, 27.4794},
+	{"Goma", "North Kivu", "DR Congo", -1.6585, 29.2205},
+	{"Luanda", "Luanda", "Angola", -8.8390, 13.2894},
+	{"Khartoum", "Khartoum", "Sudan", 15.5007, 32.5599},
+	{"Juba", "Central Equatoria", "South Sudan", 4.8594, 31.5713},
+	{"Asmara", "Maekel", "Eritrea", 15.3229, 38.9251},
+	{"Addis Ababa", "Addis Ababa", "Ethiopia", 9.0300, 38.7400},
+	{"Lalibela", "Amhara", "Ethiopia", 12.0317, 39.0476},
+	{"Djibouti", "Djibouti", "Djibouti", 11.5721, 43.1456},
+	{"Mogadishu", "Banaadir", "Somalia", 2.0469, 45.3182},
+	{"Hargeisa", "Woqooyi Galbeed", "Somalia", 9.5600, 44.0650},
+	{"Nairobi", "Nairobi", "Kenya", -1.2921, 36.8219},
+	{"Mombasa", "Mombasa", "Kenya", -4.0435, 39.6682},
+	{"Narok", "Narok", "Kenya", -1.0833, 35.8667},
+	{"Kampala", "Central", "Uganda", 0.3476, 32.5825},
+	{"Kigali", "Kigali", "Rwanda", -1.9441, 30.0619},
+	{"Bujumbura", "Bujumbura Mairie", "Burundi", -3.3614, 29.3599},
+	{"Dar es Salaam", "Dar es Salaam", "Tanzania", -6.7924, 39.2083},
+	{"Arusha", "Arusha", "Tanzania", -3.3869, 36.6830},
+	{"Zanzibar City", "Zanzibar", "Tanzania", -6.1659, 39.2026},
+	{"Dodoma", "Dodoma", "Tanzania", -6.1630, 35.7516},
+	{"Lilongwe", "Central", "Malawi", -13.9626, 33.7741},
+	{"Lusaka", "Lusaka", "Zambia", -15.3875, 28.3228},
+	{"Livingstone", "Southern", "Zambia", -17.8419, 25.8544},
+	{"Harare", "Harare", "Zimbabwe", -17.8252, 31.0335},
+	{"Victoria Falls", "Matabeleland North", "Zimbabwe", -17.9318, 25.8307},
+	{"Maputo", "Maputo", "Mozambique", -25.9692, 32.5732},
+	{"Antananarivo", "Analamanga", "Madagascar", -18.8792, 47.5079},
+	{"Port Louis", "Port Louis", "Mauritius", -20.1609, 57.5012},
+	{"Victoria", "Mahé", "Seychelles", -4.6191, 55.4513},
+	{"Windhoek", "Khomas", "Namibia", -22.5609, 17.0658},
+	{"Swakopmund", "Erongo", "Namibia", -22.6792, 14.5272},
+	{"Gaborone", "South-East", "Botswana", -24.6282, 25.9231},
+	{"Maun", "North-West", "Botswana", -19.9833, 23.4167},
+	{"Johannesburg", "Gauteng", "South Africa", -26.2041, 28.0473},
+	{"Pretoria", "Gauteng", "South Africa", -25.7479, 28.2293},
+	{"Cape Town", "Western Cape", "South Africa", -33.9249, 18.4241},
+	{"Durban", "KwaZulu-Natal", "South Africa", -29.8587, 31.0218},
+	{"Port Elizabeth", "Eastern Cape", "South Africa", -33.9608, 25.6022},
+	{"Skukuza", "Mpumalanga", "South Africa", -24.9964, 31.5921},
+	{"Maseru", "Maseru", "Lesotho", -29.3151, 27.4869},
+	{"Mbabane", "Hhohho", "Eswatini", -26.3054, 31.1367},
+	{"Praia", "Santiago", "Cape Verde", 14.9330, -23.5133},
+
+	// Asia
+	{"Kabul", "Kabul", "Afghanistan", 34.5553, 69.2075},
+	{"Islamabad", "Islamabad Capital Territory", "Pakistan", 33.6844, 73.0479},
+	{"Karachi", "Sindh", "Pakistan", 24.8607, 67.0011},
+	{"Lahore", "Punjab", "Pakistan", 31.5204, 74.3587},
+	{"Peshawar", "Khyber Pakhtunkhwa", "Pakistan", 34.0151, 71.5249},
+	{"Gilgit", "Gilgit-Baltistan", "Pakistan", 35.9208, 74.3144},
+	{"New Delhi", "Delhi", "India", 28.6139, 77.2090},
+	{"Mumbai", "Maharashtra", "India", 19.0760, 72.8777},
+	{"Pune", "Maharashtra", "India", 18.5204, 73.8567},
+	{"Bangalore", "Karnataka", "India", 12.9716, 77.5946},
+	{"Chennai", "Tamil Nadu", "India", 13.0827, 80.2707},
+	{"Hyderabad", "Telangana", "India", 17.3850, 78.4867},
+	{"Kolkata", "West Bengal", "India", 22.5726, 88.3639},
+	{"Ahmedabad", "Gujarat", "India", 23.0225, 72.5714},
+	{"Jaipur", "Rajasthan", "India", 26.9124, 75.7873},
+	{"Udaipur", "Rajasthan", "India", 24.5854, 73.7125},
+	{"Jaisalmer", "Rajasthan", "India", 26.9157, 70.9083},
+	{"Agra", "Uttar Pradesh", "India", 27.1767, 78.0081},
+	{"Varanasi", "Uttar Pradesh", "India", 25.3176, 82.9739},
+	{"Lucknow", "Uttar Pradesh", "India", 26.8467, 80.9462},
+	{"Amritsar", "Punjab", "India", 31.6340, 74.8723},
+	{"Shimla", "Himachal Pradesh", "India", 31.1048, 77.1734},
+	{"Srinagar", "Jammu and Kashmir", "India", 34.0837, 74.7973},
+	{"Leh", "Ladakh", "India", 34.1526, 77.5771},
+	{"Rishikesh", "Uttarakhand", "India", 30.0869, 78.2676},
+	{"Panaji", "Goa", "India", 15.4909, 73.8278},
+	{"Kochi", "Kerala", "India", 9.9312, 76.2673},
+	{"Thiruvananthapuram", "Kerala", "India", 8.5241, 76.9366},
+	{"Madurai", "Tamil Nadu", "India", 9.9252, 78.1198},
+	{"Bhubaneswar", "Odisha", "India", 20.2961, 85.8245},
+	{"Patna", "Bihar", "India", 25.5941, 85.1376},
+	{"Guwahati", "Assam", "India", 26.1445, 91.7362},
+	{"Darjeeling", "West Bengal", "India", 27.0410, 88.2663},
+	{"Bhopal", "Madhya Pradesh", "India", 23.2599, 77.4126},
+	{"Nagpur", "Maharashtra", "India", 21.1458, 79.0882},
+	{"Port Blair", "Andaman and Nicobar Islands", "India", 11.6234, 92.7265},
+	{"Kathmandu", "Bagmati", "Nepal", 27.7172, 85.3240},
+	{"Pokhara", "Gandaki", "Nepal", 28.2096, 83.9856},
+	{"Namche Bazaar", "Koshi", "Nepal", 27.8069, 86.7140},
+	{"Thimphu", "Thimphu", "Bhutan", 27.4728, 89.6390},
+	{"Dhaka", "Dhaka", "Bangladesh", 23.8103, 90.4125},
+	{"Chittagong", "Chittagong", "Bangladesh", 22.3569, 91.7832},
+	{"Colombo", "Western", "Sri Lanka", 6.9271, 79.8612},
+	{"Kandy", "Central", "Sri Lanka", 7.2906, 80.6337},
+	{"Galle", "Southern", "Sri Lanka", 6.0535, 80.2210},
+	{"Malé", "Malé", "Maldives", 4.1755, 73.5093},
+	{"Tashkent", "Tashkent", "Uzbekistan", 41.2995, 69.2401},
+	{"Samarkand", "Samarqand", "Uzbekistan", 39.6270, 66.9750},
+	{"Bukhara", "Bukhara", "Uzbekistan", 39.7681, 64.4556},
+	{"Khiva", "Xorazm", "Uzbekistan", 41.3783, 60.3639},
+	{"Almaty", "Almaty", "Kazakhstan", 43.2220, 76.8512},
+	{"Astana", "Astana", "Kazakhstan", 51.1694, 71.4491},
+	{"Shymkent", "Shymkent", "Kazakhstan", 42.3417, 69.5901},
+	{"Aktobe", "Aktobe", "Kazakhstan", 50.2839, 57.1669},
+	{"Bishkek", "Bishkek", "Kyrgyzstan", 42.8746, 74.5698},
+	{"Karakol", "Issyk-Kul", "Kyrgyzstan", 42.4907, 78.3936},
+	{"Dushanbe", "Dushanbe", "Tajikistan", 38.5598, 68.7870},
+	{"Khorog", "Gorno-Badakhshan", "Tajikistan", 37.4897, 71.5531},
+	{"Ashgabat", "Ashgabat", "Turkmenistan", 37.9601, 58.3261},
+	{"Ulaanbaatar", "Ulaanbaatar", "Mongolia", 47.8864, 106.9057},
+	{"Dalanzadgad", "Ömnögovi", "Mongolia", 43.5708, 104.4250},
+	{"Beijing", "Beijing", "China", 39.9042, 116.4074},
+	{"Shanghai", "Shanghai", "China", 31.2304, 121.4737},
+	{"Hangzhou", "Zhejiang", "China", 30.2741, 120.1551},
+	{"Suzhou", "Jiangsu", "China", 31.2990, 120.5853},
+	{"Nanjing", "Jiangsu", "China", 32.0603, 118.7969},
+	{"Guangzhou", "Guangdong", "China", 23.1291, 113.2644},
+	{"Shenzhen", "Guangdong", "China", 22.5431, 114.0579},
+	{"Xiamen", "Fujian", "China", 24.4798, 118.0894},
+	{"Chengdu", "Sichuan", "China", 30.5728, 104.0668},
+	{"Chongqing", "Chongqing", "China", 29.4316, 106.9123},
+	{"Xi'an", "Shaanxi", "China", 34.3416, 108.9398},
+	{"Wuhan", "Hubei", "China", 30.5928, 114.3055},
+	{"Changsha", "Hunan", "China", 28.2282, 112.9388},
+	{"Zhangjiajie", "Hunan", "China", 29.1170, 110.4792},
+	{"Guilin", "Guangxi", "China", 25.2736, 110.2900},
+	{"Kunming", "Yunnan", "China", 25.0389, 102.7183},
+	{"Lijiang", "Yunnan", "China", 26.8721, 100.2299},
+	{"Lhasa", "Tibet", "China", 29.6520, 91.1721},
+	{"Xining", "Qinghai", "China", 36.6171, 101.7782},
+	{"Lanzhou", "Gansu", "China", 36.0611, 103.8343},
+	{"Dunhuang", "Gansu", "China", 40.1421, 94.6619},
+	{"Ürümqi", "Xinjiang", "China", 43.8256, 87.6168},
+	{"Kashgar", "Xinjiang", "China", 39.4704, 75.9898},
+	{"Hohhot", "Inner Mongolia", "China", 40.8424, 111.7490},
+	{"Harbin", "Heilongjiang", "China", 45.8038, 126.5350},
+	{"Shenyang", "Liaoning", "China", 41.8057, 123.4315},
+	{"Dalian", "Liaoning", "China", 38.9140, 121.6147},
+	{"Qingdao", "Shandong", "China", 36.0671, 120.3826},
+	{"Tianjin", "Tianjin", "China", 39.3434, 117.3616},
+	{"Zhengzhou", "Henan", "China", 34.7466, 113.6254},
+	{"Sanya", "Hainan", "China", 18.2528, 109.5119},
+	{"Hong Kong", "Hong Kong", "China", 22.3193, 114.1694},
+	{"Macau", "Macau", "China", 22.1987, 113.5439},
+	{"Taipei", "Taipei", "Taiwan", 25.0330, 121.5654},
+	{"Taichung", "Taichung", "Taiwan", 24.1477, 120.6736},
+	{"Kaohsiung", "Kaohsiung", "Taiwan", 22.6273, 120.3014},
+	{"Hualien", "Hualien", "Taiwan", 23.9872, 121.6015},
+	{"Seoul", "Seoul", "South Korea", 37.5665, 126.9780},
+	{"Incheon", "Incheon", "South Korea", 37.4563, 126.7052},
+	{"Busan", "Busan", "South Korea", 35.1796, 129.0756},
+	{"Gyeongju", "North Gyeongsang", "South Korea", 35.8562, 129.2247},
+	{"Jeju", "Jeju", "South Korea", 33.4996, 126.5312},
+	{"Pyongyang", "Pyongyang", "North Korea", 39.0392, 125.7625},
+	{"Tokyo", "Tokyo", "Japan", 35.6762, 139.6503},
+	{"Yokohama", "Kanagawa", "Japan", 35.4437, 139.6380},
+	{"Kamakura", "Kanagawa", "Japan", 35.3192, 139.5467},
+	{"Hakone", "Kanagawa", "Japan", 35.2324, 139.1069},
+	{"Nikko", "Tochigi", "Japan", 36.7199, 139.6982},
+	{"Osaka", "Osaka", "Japan", 34.6937, 135.5023},
+	{"Kyoto", "Kyoto", "Japan", 35.0116, 135.7681},
+	{"Nara", "Nara", "Japan", 34.6851, 135.8048},
+	{"Kobe", "Hyogo", "Japan", 34.6901, 135.1955},
+	{"Nagoya", "Aichi", "Japan", 35.1815, 136.9066},
+	{"Kanazawa", "Ishikawa", "Japan", 36.5613, 136.6562},
+	{"Takayama", "Gifu", "Japan", 36.1461, 137.2522},
+	{"Matsumoto", "Nagano", "Japan", 36.2380, 137.9720},
+	{"Hiroshima", "Hiroshima", "Japan", 34.3853, 132.4553},
+	{"Fukuoka", "Fukuoka", "Japan", 33.5904, 130.4017},
+	{"Nagasaki", "Nagasaki", "Japan", 32.7503, 129.8779},
+	{"Kagoshima", "Kagoshima", "Japan", 31.5966, 130.5571},
+	{"Sendai", "Miyagi", "Japan", 38.2682, 140.8694},
+	{"Sapporo", "Hokkaido", "Japan", 43.0618, 141.3545},
+	{"Hakodate", "Hokkaido", "Japan", 41.7687, 140.7288},
+	{"Naha", "Okinawa", "Japan", 26.2124, 127.6809},
+	{"Hanoi", "Hanoi", "Vietnam", 21.0278, 105.8342},
+	{"Ha Long", "Quảng Ninh", "Vietnam", 20.9599, 107.0425},
+	{"Sa Pa", "Lào Cai", "Vietnam", 22.3364, 103.8438},
+	{"Hue", "Thừa Thiên Huế", "Vietnam", 16.4637, 107.5909},
+	{"Da Nang", "Da Nang", "Vietnam", 16.0544, 108.2022},
+	{"Hoi An", "Quảng Nam", "Vietnam", 15.8801, 108.3380},
+	{"Nha Trang", "Khánh Hòa", "Vietnam", 12.2388, 109.1967},
+	{"Da Lat", "Lâm Đồng", "Vietnam", 11.9404, 108.4583},
+	{"Ho Chi Minh City", "Ho Chi Minh City", "Vietnam", 10.8231, 106.6297},
+	{"Phu Quoc", "Kiên Giang", "Vietnam", 10.2899, 103.9840},
+	{"Vientiane", "Vientiane Prefecture", "Laos", 17.9757, 102.6331},
+	{"Luang Prabang", "Luang Prabang", "Laos", 19.8856, 102.1347},
+	{"Phnom Penh", "Phnom Penh", "Cambodia", 11.5564, 104.9282},
+	{"Siem Reap", "Siem Reap", "Cambodia", 13.3671, 103.8448},
+	{"Bangkok", "Bangkok", "Thailand", 13.7563, 100.5018},
+	{"Ayutthaya", "Phra Nakhon Si Ayutthaya", "Thailand", 14.3532, 100.5689},
+	{"Chiang Mai", "Chiang Mai", "Thailand", 18.7883, 98.9853},
+	{"Chiang Rai", "Chiang Rai", "Thailand", 19.9105, 99.8406},
+	{"Pattaya", "Chonburi", "Thailand", 12.9236, 100.8825},
+	{"Phuket", "Phuket", "Thailand", 7.8804, 98.3923},
+	{"Krabi", "Krabi", "Thailand", 8.0863, 98.9063},
+	{"Ko Samui", "Surat Thani", "Thailand", 9.5120, 100.0136},
+	{"Udon Thani", "Udon Thani", "Thailand", 17.4138, 102.7872},
+	{"Yangon", "Yangon", "Myanmar", 16.8409, 96.1735},
+	{"Mandalay", "Mandalay", "Myanmar", 21.9588, 96.0891},
+	{"Bagan", "Mandalay", "Myanmar", 21.1717, 94.8585},
+	{"Naypyidaw", "Naypyidaw", "Myanmar", 19.7633, 96.0785},
+	{"Kuala Lumpur", "Kuala Lumpur", "Malaysia", 3.1390, 101.6869},
+	{"George Town", "Penang", "Malaysia", 5.4141, 100.3288},
+	{"Malacca", "Malacca", "Malaysia", 2.1896, 102.2501},
+	{"Langkawi", "Kedah", "Malaysia", 6.3500, 99.8000},
+	{"Kota Kinabalu", "Sabah", "Malaysia", 5.9804, 116.0735},
+	{"Kuching", "Sarawak", "Malaysia", 1.5535, 110.3593},
+	{"Singapore", "Singapore", "Singapore", 1.3521, 103.8198},
+	{"Bandar Seri Begawan", "Brunei-Muara", "Brunei", 4.9031, 114.9398},
+	{"Jakarta", "Jakarta", "Indonesia", -6.2088, 106.8456},
+	{"Bandung", "West Java", "Indonesia", -6.9175, 107.6191},
+	{"Yogyakarta", "Yogyakarta", "Indonesia", -7.7956, 110.3695},
+	{"Surabaya", "East Java", "Indonesia", -7.2575, 112.7521},
+	{"Denpasar", "Bali", "Indonesia", -8.6705, 115.2126},
+	{"Ubud", "Bali", "Indonesia", -8.5069, 115.2625},
+	{"Mataram", "West Nusa Tenggara", "Indonesia", -8.5833, 116.1167},
+	{"Labuan Bajo", "East Nusa Tenggara", "Indonesia", -8.4964, 119.8877},
+	{"Medan", "North Sumatra", "Indonesia", 3.5952, 98.6722},
+	{"Padang", "West Sumatra", "Indonesia", -0.9471, 100.4172},
+	{"Palembang", "South Sumatra", "Indonesia", -2.9761, 104.7754},
+	{"Makassar", "South Sulawesi", "Indonesia", -5.1477, 119.4327},
+	{"Manado", "North Sulawesi", "Indonesia", 1.4748, 124.8421},
+	{"Balikpapan", "East Kalimantan", "Indonesia", -1.2379, 116.8529},
+	{"Jayapura", "Papua", "Indonesia", -2.5337, 140.7181},
+	{"Dili", "Dili", "East Timor", -8.5569, 125.5603},
+	{"Manila", "Metro Manila", "Philippines", 14.5995, 120.9842},
+	{"Baguio", "Benguet", "Philippines", 16.4023, 120.5960},
+	{"Cebu City", "Cebu", "Philippines", 10.3157, 123.8854},
+	{"Boracay", "Aklan", "Philippines", 11.9674, 121.9248},
+	{"Puerto Princesa", "Palawan", "Philippines", 9.7392, 118.7353},
+	{"El Nido", "Palawan", "Philippines", 11.1956, 119.4075},
+	{"Davao City", "Davao del Sur", "Philippines", 7.1907, 125.4553},
+
+	// Oceania
+	{"Sydney", "New South Wales", "Australia", -33.8688, 151.2093},
+	{"Newcastle", "New South Wales", "Australia", -32.9283, 151.7817},
+	{"Byron Bay", "New South Wales", "Australia", -28.6474, 153.6020},
+	{"Canberra", "Australian Capital Territory", "Australia", -35.2809, 149.1300},
+	{"Melbourne", "Victoria", "Australia", -37.8136, 144.9631},
+	{"Brisbane", "Queensland", "Australia", -27.4698, 153.0251},
+	{"Gold Coast", "Queensland", "Australia", -28.0167, 153.4000},
+	{"Cairns", "Queensland", "Australia", -16.9186, 145.7781},
+	{"Townsville", "Queensland", "Australia", -19.2590, 146.8169},
+	{"Adelaide", "South Australia", "Australia", -34.9285, 138.6007},
+	{"Perth", "Western Australia", "Australia", -31.9505, 115.8605},
+	{"Broome", "Western Australia", "Australia", -17.9614, 122.2359},
+	{"Darwin", "Northern Territory", "Australia", -12.4634, 130.8456},
+	{"Alice Springs", "Northern Territory", "Australia", -23.6980, 133.8807},
+	{"Yulara", "Northern Territory", "Australia", -25.2406, 130.9889},
+	{"Hobart", "Tasmania", "Australia", -42.8821, 147.3272},
+	{"Launceston", "Tasmania", "Australia", -41.4332, 147.1441},
+	{"Auckland", "Auckland", "New Zealand", -36.8485, 174.7633},
+	{"Rotorua", "Bay of Plenty", "New Zealand", -38.1368, 176.2497},
+	{"Wellington", "Wellington", "New Zealand", -41.2865, 174.7762},
+	{"Christchurch", "Canterbury", "New Zealand", -43.5321, 172.6362},
+	{"Queenstown", "Otago", "New Zealand", -45.0312, 168.6626},
+	{"Dunedin", "Otago", "New Zealand", -45.8788, 170.5028},
+	{"Port Moresby", "National Capital District", "Papua New Guinea", -9.4438, 147.1803},
+	{"Suva", "Central", "Fiji", -18.1248, 178.4501},
+	{"Nadi", "Western", "Fiji", -17.7765, 177.4356},
+	{"Nouméa", "South Province", "New Caledonia", -22.2758, 166.4580},
+	{"Port Vila", "Shefa", "Vanuatu", -17.7334, 168.3273},
+	{"Honiara", "Guadalcanal", "Solomon Islands", -9.4456, 159.9729},
+	{"Apia", "Tuamasaga", "Samoa", -13.8507, -171.7514},
+	{"Nuku'alofa", "Tongatapu", "Tonga", -21.1394, -175.2046},
+	{"Papeete", "Windward Islands", "French Polynesia", -17.5516, -149.5585},
+	{"Bora Bora", "Leeward Islands", "French Polynesia", -16.5004, -151.7415},
+	{"Rarotonga", "Rarotonga", "Cook Islands", -21.2292, -159.7763},
+	{"Hagåtña", "Guam", "Guam", 13.4443, 144.7937},
+
+	// North America
+	{"New York", "New York", "United States", 40.7128, -74.0060},
+	{"Buffalo", "New York", "United States", 42.8864, -78.8784},
+	{"Boston", "Massachusetts", "United States", 42.3601, -71.0589},
+	{"Portland", "Maine", "United States", 43.6591, -70.2568},
+	{"Burlington", "Vermont", "United States", 44.4759, -73.2121},
+	{"Providence", "Rhode Island", "United States", 41.8240, -71.4128},
+	{"Hartford", "Connecticut", "United States", 41.7658, -72.6734},
+	{"Newark", "New Jersey", "United States", 40.7357, -74.1724},
+	{"Philadelphia", "Pennsylvania", "United States", 39.9526, -75.1652},
+	{"Pittsburgh", "Pennsylvania", "United States", 40.4406, -79.9959},
+	{"Baltimore", "Maryland", "United States", 39.2904, -76.6122},
+	{"Washington", "District of Columbia", "United States", 38.9072, -77.0369},
+	{"Richmond", "Virginia", "United States", 37.5407, -77.4360},
+	{"Virginia Beach", "Virginia", "United States", 36.8529, -75.9780},
+	{"Charlotte", "North Carolina", "United States", 35.2271, -80.8431},
+	{"Raleigh", "North Carolina", "United States", 35.7796, -78.6382},
+	{"Asheville", "North Carolina", "United States", 35.5951, -82.5515},
+	{"Charleston", "South Carolina", "United States", 32.7765, -79.9311},
+	{"Atlanta", "Georgia", "United States", 33.7490, -84.3880},
+	{"Savannah", "Georgia", "United States", 32.0809, -81.0912},
+	{"Miami", "Florida", "United States", 25.7617, -80.1918},
+	{"Orlando", "Florida", "United States", 28.5383, -81.3792},
+	{"Tampa", "Florida", "United States", 27.9506, -82.4572},
+	{"Jacksonville", "Florida", "United States", 30.3322, -81.6557},
+	{"Key West", "Florida", "United States", 24.5551, -81.7800},
+	{"Tallahassee", "Florida", "United States", 30.4383, -84.2807},
+	{"Birmingham", "Alabama", "United States", 33.5186, -86.8104},
+	{"Nashville", "Tennessee", "United States", 36.1627, -86.7816},
+	{"Memphis", "Tennessee", "United States", 35.1495, -90.0490},
+	{"Gatlinburg", "Tennessee", "United States", 35.7143, -83.5102},
+	{"Louisville", "Kentucky", "United States", 38.2527, -85.7585},
+	{"New Orleans", "Louisiana", "United States", 29.9511, -90.0715},
+	{"Jackson", "Mississippi", "United States", 32.2988, -90.1848},
+	{"Little Rock", "Arkansas", "United States", 34.7465, -92.2896},
+	{"Columbus", "Ohio", "United States", 39.9612, -82.9988},
+	{"Cleveland", "Ohio", "United States", 41.4993, -81.6944},
+	{"Cincinnati", "Ohio", "United States", 39.1031, -84.5120},
+	{"Detroit", "Michigan", "United States", 42.3314, -83.0458},
+	{"Grand Rapids", "Michigan", "United States", 42.9634, -85.6681},
+	{"Indianapolis", "Indiana", "United States", 39.7684, -86.1581},
+	{"Chicago", "Illinois", "United States", 41.8781, -87.6298},
+	{"Milwaukee", "Wisconsin", "United States", 43.0389, -87.9065},
+	{"Minneapolis", "Minnesota", "United States", 44.9778, -93.2650},
+	{"Duluth", "Minnesota", "United States", 46.7867, -92.1005},
+	{"Des Moines", "Iowa", "United States", 41.5868, -93.6250},
+	{"St. Louis", "Missouri", "United States", 38.6270, -90.1994},
+	{"Kansas City", "Missouri", "United States", 39.0997, -94.5786},
+	{"Omaha", "Nebraska", "United States", 41.2565, -95.9345},
+	{"Wichita", "Kansas", "United States", 37.6872, -97.3301},
+	{"Oklahoma City", "Oklahoma", "United States", 35.4676, -97.5164},
+	{"Dallas", "Texas", "United States", 32.7767, -96.7970},
+	{"Houston", "Texas", "United States", 29.7604, -95.3698},
+	{"Austin", "Texas", "United States", 30.2672, -97.7431},
+	{"San Antonio", "Texas", "United States", 29.4241, -98.4936},
+	{"El Paso", "Texas", "United States", 31.7619, -106.4850},
+	{"Amarillo", "Texas", "United States", 35.2220, -101.8313},
+	{"Fargo", "North Dakota", "United States", 46.8772, -96.7898},
+	{"Sioux Falls", "South Dakota", "United States", 43.5446, -96.7311},
+	{"Rapid City", "South Dakota", "United States", 44.0805, -103.2310},
+	{"Billings", "Montana", "United States", 45.7833, -108.5007},
+	{"Missoula", "Montana", "United States", 46.8721, -113.9940},
+	{"West Glacier", "Montana", "United States", 48.4950, -113.9820},
+	{"Jackson", "Wyoming", "United States", 43.4799, -110.7624},
+	{"Cheyenne", "Wyoming", "United States", 41.1400, -104.8202},
+	{"Denver", "Colorado", "United States", 39.7392, -104.9903},
+	{"Colorado Springs", "Colorado", "United States", 38.8339, -104.8214},
+	{"Aspen", "Colorado", "United States", 39.1911, -106.8175},
+	{"Salt Lake City", "Utah", "United States", 40.7608, -111.8910},
+	{"Moab", "Utah", "United States", 38.5733, -109.5498},
+	{"Springdale", "Utah", "United States", 37.1889, -112.9986},
+	{"Albuquerque", "New Mexico", "United States", 35.0844, -106.6504},
+	{"Santa Fe", "New Mexico", "United States", 35.6870, -105.9378},
+	{"Phoenix", "Arizona", "United States", 33.4484, -112.0740},
+	{"Tucson", "Arizona", "United States", 32.2226, -110.9747},
+	{"Flagstaff", "Arizona", "United States", 35.1983, -111.6513},
+	{"Grand Canyon Village", "Arizona", "United States", 36.0544, -112.1401},
+	{"Page", "Arizona", "United States", 36.9147, -111.4558},
+	{"Las Vegas", "Nevada", "United States", 36.1699, -115.1398},
+	{"Reno", "Nevada", "United States", 39.5296, -119.8138},
+	{"Boise", "Idaho", "United States", 43.6150, -116.2023},
+	{"Los Angeles", "California", "United States", 34.0522, -118.2437},
+	{"San Diego", "California", "United States", 32.7157, -117.1611},
+	{"Palm Springs", "California", "United States", 33.8303, -116.5453},
+	{"Santa Barbara", "California", "United States", 34.4208, -119.6982},
+	{"Fresno", "California", "United States", 36.7378, -119.7871},
+	{"Yosemite Valley", "California", "United States", 37.7456, -119.5936},
+	{"Monterey", "California", "United States", 36.6002, -121.8947},
+	{"San Jose", "California", "United States", 37.3382, -121.8863},
+	{"San Francisco", "California", "United States", 37.7749, -122.4194},
+	{"Sacramento", "California", "United States", 38.5816, -121.4944},
+	{"Lake Tahoe", "California", "United States", 38.9399, -119.9772},
+	{"Eureka", "California", "United States", 40.8021, -124.1637},
+	{"Portland", "Oregon", "United States", 45.5152, -122.6784},
+	{"Eugene", "Oregon", "United States", 44.0521, -123.0868},
+	{"Bend", "Oregon", "United States", 44.0582, -121.3153},
+	{"Seattle", "Washington", "United States", 47.6062, -122.3321},
+	{"Spokane", "Washington", "United States", 47.6588, -117.4260},
+	{"Anchorage", "Alaska", "United States", 61.2181, -149.9003},
+	{"Fairbanks", "Alaska", "United States", 64.8378, -147.7164},
+	{"Juneau", "Alaska", "United States", 58.3019, -134.4197},
+	{"Honolulu", "Hawaii", "United States", 21.3069, -157.8583},
+	{"Kahului", "Hawaii", "United States", 20.8893, -156.4729},
+	{"Hilo", "Hawaii", "United States", 19.7071, -155.0885},
+	{"Kona", "Hawaii", "United States", 19.6400, -155.9969},
+	{"Lihue", "Hawaii", "United States", 21.9811, -159.3711},
+	{"San Juan", "Puerto Rico", "Puerto Rico", 18.4655, -66.1057},
+	{"Toronto", "Ontario", "Canada", 43.6532, -79.3832},
+	{"Ottawa", "Ontario", "Canada", 45.4215, -75.6972},
+	{"Niagara Falls", "Ontario", "Canada", 43.0896, -79.0849},
+	{"Thunder Bay", "Ontario", "Canada", 48.3809, -89.2477},
+	{"Montreal", "Quebec", "Canada", 45.5017, -73.5673},
+	{"Quebec City", "Quebec", "Canada", 46.8139, -71.2080},
+	{"Halifax", "Nova Scotia", "Canada", 44.6488, -63.5752},
+	{"Moncton", "New Brunswick", "Canada", 46.0878, -64.7782},
+	{"Charlottetown", "Prince Edward Island", "Canada", 46.2382, -63.1311},
+	{"St. John's", "Newfoundland and Labrador", "Canada", 47.5615, -52.7126},
+	{"Winnipeg", "Manitoba", "Canada", 49.8951, -97.1384},
+	{"Churchill", "Manitoba", "Canada", 58.7684, -94.1650},
+	{"Regina", "Saskatchewan", "Canada", 50.4452, -104.6189},
+	{"Saskatoon", "Saskatchewan", "Canada", 52.1332, -106.6700},
+	{"Calgary", "Alberta", "Canada", 51.0447, -114.0719},
+	{"Edmonton", "Alberta", "Canada", 53.5461, -113.4938},
+	{"Banff", "Alberta", "Canada", 51.1784, -115.5708},
+	{"Jasper", "Alberta", "Canada", 52.8737, -118.0814},
+	{"Vancouver", "British Columbia", "Canada", 49.2827, -123.1207},
+	{"Victoria", "British Columbia", "Canada", 48.4284, -123.3656},
+	{"Whistler", "British Columbia", "Canada", 50.1163, -122.9574},
+	{"Kelowna", "British Columbia", "Canada", 49.8880, -119.4960},
+	{"Prince George", "British Columbia", "Canada", 53.9171, -122.7497},
+	{"Whitehorse", "Yukon", "Canada", 60.7212, -135.0568},
+	{"Yellowknife", "Northwest Territories", "Canada", 62.4540, -114.3718},
+	{"Iqaluit", "Nunavut", "Canada", 63.7467, -68.5170},
+	{"Nuuk", "Sermersooq", "Greenland", 64.1814, -51.6941},
+	{"Ilulissat", "Avannaata", "Greenland", 69.2198, -51.0986},
+	{"Mexico City", "Mexico City", "Mexico", 19.4326, -99.1332},
+	{"Guadalajara", "Jalisco", "Mexico", 20.6597, -103.3496},
+	{"Puerto Vallarta", "Jalisco", "Mexico", 20.6534, -105.2253},
+	{"Monterrey", "Nuevo León", "Mexico", 25.6866, -100.3161},
+	{"Tijuana", "Baja California", "Mexico", 32.5149, -117.0382},
+	{"La Paz", "Baja California Sur", "Mexico", 24.1426, -110.3128},
+	{"Cabo San Lucas", "Baja California Sur", "Mexico", 22.8905, -109.9167},
+	{"Chihuahua", "Chihuahua", "Mexico", 28.6329, -106.0691},
+	{"Mazatlán", "Sinaloa", "Mexico", 23.2494, -106.4111},
+	{"Guanajuato", "Guanajuato", "Mexico", 21.0190, -101.2574},
+	{"San Miguel de Allende", "Guanajuato", "Mexico", 20.9144, -100.7452},
+	{"Puebla", "Puebla", "Mexico", 19.0414, -98.2063},
+	{"Oaxaca", "Oaxaca", "Mexico", 17.0732, -96.7266},
+	{"Acapulco", "Guerrero", "Mexico", 16.8531, -99.8237},
+	{"Veracruz", "Veracruz", "Mexico", 19.1738, -96.1342},
+	{"San Cristóbal de las Casas", "Chiapas", "Mexico", 16.7370, -92.6376},
+	{"Mérida", "Yucatán", "Mexico", 20.9674, -89.5926},
+	{"Cancún", "Quintana Roo", "Mexico", 21.1619, -86.8515},
+	{"Tulum", "Quintana Roo", "Mexico", 20.2114, -87.4654},
+	{"Guatemala City", "Guatemala", "Guatemala", 14.6349, -90.5069},
+	{"Antigua Guatemala", "Sacatepéquez", "Guatemala", 14.5586, -90.7295},
+	{"Flores", "Petén", "Guatemala", 16.9300, -89.8917},
+	{"Belize City", "Belize", "Belize", 17.5046, -88.1962},
+	{"San Salvador", "San Salvador", "El Salvador", 13.6929, -89.2182},
+	{"Tegucigalpa", "Francisco Morazán", "Honduras", 14.0723, -87.1921},
+	{"Roatán", "Bay Islands", "Honduras", 16.3298, -86.5300},
+	{"Managua", "Managua", "Nicaragua", 12.1150, -86.2362},
+	{"Granada", "Granada", "Nicaragua", 11.9344, -85.9560},
+	{"San José", "San José", "Costa Rica", 9.9281, -84.0907},
+	{"La Fortuna", "Alajuela", "Costa Rica", 10.4678, -84.6427},
+	{"Liberia", "Guanacaste", "Costa Rica", 10.6346, -85.4407},
+	{"Panama City", "Panamá", "Panama", 8.9824, -79.5199},
+	{"Bocas del Toro", "Bocas del Toro", "Panama", 9.3403, -82.2420},
+
+	// Caribbean
+	{"Havana", "Havana", "Cuba", 23.1136, -82.3666},
+	{"Varadero", "Matanzas", "Cuba", 23.1394, -81.2861},
+	{"Trinidad", "Sancti Spíritus", "Cuba", 21.8045, -79.9847},
+	{"Santiago de Cuba", "Santiago de Cuba", "Cuba", 20.0247, -75.8219},
+	{"Nassau", "New Providence", "Bahamas", 25.0443, -77.3504},
+	{"Kingston", "Kingston", "Jamaica", 17.9712, -76.7936},
+	{"Montego Bay", "Saint James", "Jamaica", 18.4762, -77.8939},
+	{"Port-au-Prince", "Ouest", "Haiti", 18.5944, -72.3074},
+	{"Santo Domingo", "Distrito Nacional", "Dominican Republic", 18.4861, -69.9312},
+	{"Punta Cana", "La Altagracia", "Dominican Republic", 18.5601, -68.3725},
+	{"Oranjestad", "Aruba", "Aruba", 12.5092, -70.0086},
+	{"Willemstad", "Curaçao", "Curaçao", 12.1091, -68.9316},
+	{"Charlotte Amalie", "Saint Thomas", "U.S. Virgin Islands", 18.3419, -64.9307},
+	{"Philipsburg", "Sint Maarten", "Sint Maarten", 18.0260, -63.0458},
+	{"St. John's", "Saint John", "Antigua and Barbuda", 17.1274, -61.8468},
+	{"Pointe-à-Pitre", "Guadeloupe", "Guadeloupe", 16.2411, -61.5331},
+	{"Fort-de-France", "Martinique", "Martinique", 14.6161, -61.0588},
+	{"Castries", "Castries", "Saint Lucia", 14.0101, -60.9875},
+	{"Bridgetown", "Saint Michael", "Barbados", 13.1132, -59.5988},
+	{"St. George's", "Saint George", "Grenada", 12.0561, -61.7488},
+	{"Port of Spain", "Port of Spain", "Trinidad and Tobago", 10.6549, -61.5019},
+	{"Hamilton", "Pembroke", "Bermuda", 32.2949, -64.7814},
+
+	// South America
+	{"Caracas", "Capital District", "Venezuela", 10.4806, -66.9036},
+	{"Maracaibo", "Zulia", "Venezuela", 10.6427, -71.6125},
+	{"Canaima", "Bolívar", "Venezuela", 6.2390, -62.8549},
+	{"Bogotá", "Bogotá", "Colombia", 4.7110, -74.0721},
+	{"Medellín", "Antioquia", "Colombia", 6.2442, -75.5812},
+	{"Cali", "Valle del Cauca", "Colombia", 3.4516, -76.5320},
+	{"Cartagena", "Bolívar", "Colombia", 10.3910, -75.4794},
+	{"Santa Marta", "Magdalena", "Colombia", 11.2408, -74.1990},
+	{"Leticia", "Amazonas", "Colombia", -4.2153, -69.9406},
+	{"Quito", "Pichincha", "Ecuador", -0.1807, -78.4678},
+	{"Guayaquil", "Guayas", "Ecuador", -2.1710, -79.9224},
+	{"Cuenca", "Azuay", "Ecuador", -2.9001, -79.0059},
+	{"Puerto Ayora", "Galápagos", "Ecuador", -0.7432, -90.3168},
+	{"Georgetown", "Demerara-Mahaica", "Guyana", 6.8013, -58.1551},
+	{"Paramaribo", "Paramaribo", "Suriname", 5.8520, -55.2038},
+	{"Cayenne", "French Guiana", "French Guiana", 4.9224, -52.3135},
+	{"Lima", "Lima", "Peru", -12.0464, -77.0428},
+	{"Arequipa", "Arequipa", "Peru", -16.4090, -71.5375},
+	{"Cusco", "Cusco", "Peru", -13.5319, -71.9675},
+	{"Aguas Calientes", "Cusco", "Peru", -13.1547, -72.5254},
+	{"Puno", "Puno", "Peru", -15.8402, -70.0219},
+	{"Iquitos", "Loreto", "Peru", -3.7437, -73.2516},
+	{"Trujillo", "La Libertad", "Peru", -8.1091, -79.0215},
+	{"Huaraz", "Áncash", "Peru", -9.5278, -77.5278},
+	{"La Paz", "La Paz", "Bolivia", -16.4897, -68.1193},
+	{"Santa Cruz de la Sierra", "Santa Cruz", "Bolivia", -17.8146, -63.1561},
+	{"Sucre", "Chuquisaca", "Bolivia", -19.0196, -65.2619},
+	{"Uyuni", "Potosí", "Bolivia", -20.4605, -66.8255},
+	{"Brasília", "Federal District", "Brazil", -15.8267, -47.9218},
+	{"São Paulo", "São Paulo", "Brazil", -23.5505, -46.6333},
+	{"Rio de Janeiro", "Rio de Janeiro", "Brazil", -22.9068, -43.1729},
+	{"Paraty", "Rio de Janeiro", "Brazil", -23.2178, -44.7131},
+	{"Belo Horizonte", "Minas Gerais", "Brazil", -19.9167, -43.9345},
+	{"Curitiba", "Paraná", "Brazil", -25.4284, -49.2733},
+	{"Foz do Iguaçu", "Paraná", "Brazil", -25.5469, -54.5882},
+	{"Florianópolis", "Santa Catarina", "Brazil", -27.5954, -48.5480},
+	{"Porto Alegre", "Rio Grande do Sul", "Brazil", -30.0346, -51.2177},
+	{"Salvador", "Bahia", "Brazil", -12.9777, -38.5016},
+	{"Recife", "Pernambuco", "Brazil", -8.0476, -34.8770},
+	{"Fernando de Noronha", "Pernambuco", "Brazil", -3.8540, -32.4237},
+	{"Fortaleza", "Ceará", "Brazil", -3.7319, -38.5267},
+	{"Natal", "Rio Grande do Norte", "Brazil", -5.7945, -35.2110},
+	{"São Luís", "Maranhão", "Brazil", -2.5307, -44.3068},
+	{"Belém", "Pará", "Brazil", -1.4558, -48.4902},
+	{"Manaus", "Amazonas", "Brazil", -3.1190, -60.0217},
+	{"Porto Velho", "Rondônia", "Brazil", -8.7612, -63.9004},
+	{"Cuiabá", "Mato Grosso", "Brazil", -15.6014, -56.0979},
+	{"Campo Grande", "Mato Grosso do Sul", "Brazil", -20.4697, -54.6201},
+	{"Bonito", "Mato Grosso do Sul", "Brazil", -21.1261, -56.4836},
+	{"Goiânia", "Goiás", "Brazil", -16.6869, -49.2648},
+	{"Palmas", "Tocantins", "Brazil", -10.1844, -48.3336},
+	{"Asunción", "Asunción", "Paraguay", -25.2637, -57.5759},
+	{"Montevideo", "Montevideo", "Uruguay", -34.9011, -56.1645},
+	{"Punta del Este", "Maldonado", "Uruguay", -34.9475, -54.9338},
+	{"Buenos Aires", "Buenos Aires", "Argentina", -34.6037, -58.3816},
+	{"Mar del Plata", "Buenos Aires Province", "Argentina", -38.0055, -57.5426},
+	{"Córdoba", "Córdoba", "Argentina", -31.4201, -64.1888},
+	{"Rosario", "Santa Fe", "Argentina", -32.9442, -60.6505},
+	{"Mendoza", "Mendoza", "Argentina", -32.8895, -68.8458},
+	{"Salta", "Salta", "Argentina", -24.7821, -65.4232},
+	{"Puerto Iguazú", "Misiones", "Argentina", -25.5972, -54.5786},
+	{"San Carlos de Bariloche", "Río Negro", "Argentina", -41.1335, -71.3103},
+	{"Puerto Madryn", "Chubut", "Argentina", -42.7692, -65.0385},
+	{"El Calafate", "Santa Cruz", "Argentina", -50.3379, -72.2648},
+	{"El Chaltén", "Santa Cruz", "Argentina", -49.3315, -72.8863},
+	{"Ushuaia", "Tierra del Fuego", "Argentina", -54.8019, -68.3030},
+	{"Stanley", "Falkland Islands", "Falkland Islands", -51.6977, -57.8513},
+	{"Santiago", "Santiago Metropolitan", "Chile", -33.4489, -70.6693},
+	{"Valparaíso", "Valparaíso", "Chile", -33.0472, -71.6127},
+	{"San Pedro de Atacama", "Antofagasta", "Chile", -22.9087, -68.1997},
+	{"Antofagasta", "Antofagasta", "Chile", -23.6509, -70.3975},
+	{"Arica", "Arica y Parinacota", "Chile", -18.4783, -70.3126},
+	{"La Serena", "Coquimbo", "Chile", -29.9027, -71.2519},
+	{"Concepción", "Biobío", "Chile", -36.8201, -73.0444},
+	{"Pucón", "Araucanía", "Chile", -39.2823, -71.9545},
+	{"Puerto Montt", "Los Lagos", "Chile", -41.4689, -72.9411},
+	{"Coyhaique", "Aysén", "Chile", -45.5712, -72.0685},
+	{"Puerto Natales", "Magallanes", "Chile", -51.7236, -72.4875},
+	{"Punta Arenas", "Magallanes", "Chile", -53.1638, -70.9171},
+	{"Hanga Roa", "Valparaíso", "Chile", -27.1500, -109.4333},
+
+	// Atlantic and Antarctica
+	{"Jamestown", "Saint Helena", "Saint Helena", -15.9244, -5.7181},
+	{"King Edward Point", "South Georgia", "South Georgia", -54.2833, -36.5000},
+	{"McMurdo Station", "Ross Dependency", "Antarctica", -77.8419, 166.6863},
+	{"Esperanza Base", "Antarctic Peninsula", "Antarctica", -63.3972, -56.9975},
+}
@@ -0,0 +1,69 @@
+// Package geocode resolves coordinates to place names without talking to any external service.
+// It knows a few hundred cities, which is coarse, but enough to tell where a photo was taken.
+package geocode
+
+import (
+	"math"
+)
+
+const (
+	earthRadiusKm = 6371.0
+
+	// photos that far from the nearest known city are placed in the city
+	cityRadiusKm = 40.0
+	// further away only the region and the country of the nearest city are trusted
+	regionRadiusKm = 400.0
+)
+
+// Place is a location a photo was taken at. City or both City and Region may be empty
+// when the coordinates are far from any city of the dataset.
+type Place struct {
+	Country string
+	Region  string
+	City    string
+}
+
+type city struct {
+	name      string
+	region    string
+	country   string
+	latitude  float64
+	longitude float64
+}
+
+func radians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+// Distance is the great circle distance in kilometers
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	dLat := radians(lat2 - lat1)
+	dLon := radians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(lat1))*math.Cos(radians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Lookup returns the place of the coordinates, or nil if there is nothing known around
+func Lookup(latitude float64, longitude float64) *Place {
+	if math.IsNaN(latitude) || math.IsNaN(longitude) || math.Abs(latitude) > 90 || math.Abs(longitude) > 180 {
+		return nil
+	}
+
+	var nearest *city
+	nearestDistance := math.MaxFloat64
+	for i := range cities {
+		c := &cities[i]
+		if d := Distance(latitude, longitude, c.latitude, c.longitude); d < nearestDistance {
+			nearest, nearestDistance = c, d
+		}
+	}
+
+	switch {
+	case nearest == nil || nearestDistance > regionRadiusKm:
+		return nil
+	case nearestDistance > cityRadiusKm:
+		return &Place{Country: nearest.country, Region: nearest.region}
+	}
+	return &Place{Country: nearest.country, Region: nearest.region, City: nearest.name}
+}
@@ -0,0 +1,39 @@
+package geocode
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	cases := []struct {
+		latitude, longitude float64
+		place               *Place
+	}{
+		{48.8584, 2.2945, &Place{"France", "Île-de-France", "Paris"}},
+		{52.3717, 4.8900, &Place{"Netherlands", "North Holland", "Amsterdam"}},
+		{-33.8568, 151.2153, &Place{"Australia", "New South Wales", "Sydney"}},
+		{-24.5, 133.0, &Place{"Australia", "Northern Territory", ""}}, // outback, no city around
+		{0, -30, nil}, // middle of the Atlantic
+		{math.NaN(), 0, nil},
+	}
+
+	for _, c := range cases {
+		place := Lookup(c.latitude, c.longitude)
+		switch {
+		case c.place == nil && place != nil:
+			t.Errorf("%f,%f resolved to %+v", c.latitude, c.longitude, *place)
+		case c.place != nil && place == nil:
+			t.Errorf("%f,%f did not resolve", c.latitude, c.longitude)
+		case c.place != nil && *place != *c.place:
+			t.Errorf("%f,%f resolved to %+v instead of %+v", c.latitude, c.longitude, *place, *c.place)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// Paris to London is about 344 km
+	if d := Distance(48.8566, 2.3522, 51.5074, -0.1278); math.Abs(d-344) > 2 {
+		t.Errorf("distance is %f", d)
+	}
+}
@@ -0,0 +1,211 @@
+// Package imaging renders non-destructive edits of photos: orientation, rotation, flips,
+// crop and exposure. The original image is never modified, edits are a Recipe applied on demand.
+package imaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+
+	// decoders image.Decode knows about
+	_ "image/gif"
+)
+
+const (
+	maxExposure = 4.0
+	jpegQuality = 90
+)
+
+// ErrBadRecipe tells the recipe can not be applied
+var ErrBadRecipe = errors.New("bad edit recipe")
+
+// Crop is a rectangle relative to the image size, so it holds for any resolution of the image
+type Crop struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// Recipe lists the edits in the order they are applied to the image as the camera oriented it
+type Recipe struct {
+	Rotate   int     `json:"rotate,omitempty"` // clockwise, multiple of 90
+	FlipH    bool    `json:"fliph,omitempty"`
+	FlipV    bool    `json:"flipv,omitempty"`
+	Crop     *Crop   `json:"crop,omitempty"`
+	Exposure float64 `json:"exposure,omitempty"` // in stops
+}
+
+// ParseRecipe decodes and validates a JSON encoded recipe
+func ParseRecipe(data []byte) (*Recipe, error) {
+	r := new(Recipe)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(r); err != nil {
+		return nil, ErrBadRecipe
+	}
+
+	if r.Rotate%90 != 0 {
+		return nil, ErrBadRecipe
+	}
+	r.Rotate = (r.Rotate%360 + 360) % 360
+
+	if c := r.Crop; c != nil {
+		if c.X < 0 || c.Y < 0 || c.Width <= 0 || c.Height <= 0 || c.X+c.Width > 1 || c.Y+c.Height > 1 {
+			return nil, ErrBadRecipe
+		}
+	}
+	if math.IsNaN(r.Exposure) || math.Abs(r.Exposure) > maxExposure {
+		return nil, ErrBadRecipe
+	}
+	return r, nil
+}
+
+// IsIdentity tells the recipe changes nothing
+func (r *Recipe) IsIdentity() bool {
+	return r.Rotate == 0 && !r.FlipH && !r.FlipV && r.Crop == nil && r.Exposure == 0
+}
+
+// transform maps destination pixel coordinates to the source ones
+type transform func(x, y int) (int, int)
+
+func remap(src image.Image, width int, height int, t transform) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			sx, sy := t(x, y)
+			dst.Set(x, y, src.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+	return dst
+}
+
+// Orient turns the image as EXIF orientation 1-8 says it should be displayed
+func Orient(src image.Image, orientation int) image.Image {
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	switch orientation {
+	case 2:
+		return remap(src, w, h, func(x, y int) (int, int) { return w - 1 - x, y })
+	case 3:
+		return remap(src, w, h, func(x, y int) (int, int) { return w - 1 - x, h - 1 - y })
+	case 4:
+		return remap(src, w, h, func(x, y int) (int, int) { return x, h - 1 - y })
+	case 5:
+		return remap(src, h, w, func(x, y int) (int, int) { return y, x })
+	case 6:
+		return remap(src, h, w, func(x, y int) (int, int) { return y, h - 1 - x })
+	case 7:
+		return remap(src, h, w, func(x, y int) (int, int) { return w - 1 - y, h - 1 - x })
+	case 8:
+		return remap(src, h, w, func(x, y int) (int, int) { return w - 1 - y, x })
+	}
+	return src
+}
+
+func rotate(src image.Image, degrees int) image.Image {
+	switch degrees {
+	case 90:
+		return Orient(src, 6)
+	case 180:
+		return Orient(src, 3)
+	case 270:
+		return Orient(src, 8)
+	}
+	return src
+}
+
+func crop(src image.Image, c *Crop) image.Image {
+	b := src.Bounds()
+	rect := image.Rect(
+		b.Min.X+int(math.Round(c.X*float64(b.Dx()))),
+		b.Min.Y+int(math.Round(c.Y*float64(b.Dy()))),
+		b.Min.X+int(math.Round((c.X+c.Width)*float64(b.Dx()))),
+		b.Min.Y+int(math.Round((c.Y+c.Height)*float64(b.Dy()))))
+	if rect.Empty() {
+		rect.Max = rect.Min.Add(image.Pt(1, 1))
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, rect.Min, draw.Src)
+	return dst
+}
+
+// exposure scales light in linear space, 1 stop doubles it
+func exposure(src image.Image, stops float64) image.Image {
+	gain := math.Pow(2, stops)
+	var lut [256]uint8
+	for i := range lut {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		v = math.Min(1, v*gain)
+		if v <= 0.0031308 {
+			v *= 12.92
+		} else {
+			v = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+		lut[i] = uint8(math.Round(v * 255))
+	}
+
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := color.NRGBAModel.Convert(src.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+			dst.SetNRGBA(x, y, color.NRGBA{lut[c.R], lut[c.G], lut[c.B], c.A})
+		}
+	}
+	return dst
+}
+
+// Apply renders the recipe over the image, which must be oriented already
+func Apply(src image.Image, r *Recipe) image.Image {
+	img := rotate(src, r.Rotate)
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	switch {
+	case r.FlipH && r.FlipV:
+		img = Orient(img, 3)
+	case r.FlipH:
+		img = remap(img, w, h, func(x, y int) (int, int) { return w - 1 - x, y })
+	case r.FlipV:
+		img = remap(img, w, h, func(x, y int) (int, int) { return x, h - 1 - y })
+	}
+	if r.Crop != nil {
+		img = crop(img, r.Crop)
+	}
+	if r.Exposure != 0 {
+		img = exposure(img, r.Exposure)
+	}
+	return img
+}
+
+// CanDecode tells whether the content is in a format Render understands
+func CanDecode(in io.Reader) bool {
+	_, _, err := image.DecodeConfig(in)
+	return err == nil
+}
+
+// Render decodes the image, applies orientation and the recipe and encodes the result in
+// the format of the original, PNG for anything but JPEG. It returns the format name.
+func Render(in io.Reader, orientation int, r *Recipe, out io.Writer) (string, error) {
+	src, format, err := image.Decode(in)
+	if err != nil {
+		return "", err
+	}
+	img := Apply(Orient(src, orientation), r)
+
+	if format == "jpeg" {
+		return format, jpeg.Encode(out, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	return "png", png.Encode(out, img)
+}
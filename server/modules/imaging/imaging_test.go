@@ -0,0 +1,140 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"testing"
+)
+
+// testImage is 4x2 with a red top left pixel, everything else black
+func testImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 0xff
+	}
+	img.SetNRGBA(0, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	return img
+}
+
+func isRed(img image.Image, x, y int) bool {
+	c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+	return c.R == 0xff && c.G == 0 && c.B == 0
+}
+
+func TestParseRecipe(t *testing.T) {
+	cases := []struct {
+		recipe string
+		ok     bool
+	}{
+		{`{}`, true},
+		{`{"rotate": -90, "fliph": true, "exposure": 1.5}`, true},
+		{`{"crop": {"x": 0.1, "y": 0.1, "width": 0.5, "height": 0.9}}`, true},
+		{`{"rotate": 45}`, false},
+		{`{"crop": {"x": 0.6, "y": 0, "width": 0.5, "height": 1}}`, false},
+		{`{"exposure": 10}`, false},
+		{`{"saturation": 1}`, false},
+		{`[]`, false},
+	}
+	for _, c := range cases {
+		if _, err := ParseRecipe([]byte(c.recipe)); (err == nil) != c.ok {
+			t.Errorf("%s: %v", c.recipe, err)
+		}
+	}
+
+	r, _ := ParseRecipe([]byte(`{"rotate": -90}`))
+	if r.Rotate != 270 {
+		t.Errorf("rotate is %d", r.Rotate)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// orientation 6 is displayed turned 90° clockwise, the top left corner goes top right
+	img := Orient(testImage(), 6)
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 4 {
+		t.Fatalf("bounds %v", b)
+	}
+	if !isRed(img, 1, 0) {
+		t.Errorf("red pixel is not at the top right")
+	}
+}
+
+func TestApply(t *testing.T) {
+	r := &Recipe{Rotate: 180, FlipH: true, Crop: &Crop{X: 0, Y: 0.5, Width: 0.5, Height: 0.5}}
+	img := Apply(testImage(), r)
+	if b := img.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Fatalf("bounds %v", b)
+	}
+	// rotation moves the red pixel to the bottom right, the flip to the bottom left
+	if !isRed(img, 0, 0) {
+		t.Errorf("red pixel is not at the bottom left")
+	}
+
+	bright := Apply(testImage(), &Recipe{Exposure: 1})
+	if c := color.NRGBAModel.Convert(bright.At(1, 0)).(color.NRGBA); c.R != 0 || c.A != 0xff {
+		t.Errorf("black turned into %v", c)
+	}
+	dim := Apply(testImage(), &Recipe{Exposure: -1})
+	if c := color.NRGBAModel.Convert(dim.At(0, 0)).(color.NRGBA); c.R >= 0xff || c.R < 0xb0 {
+		t.Errorf("red dimmed by a stop is %v", c)
+	}
+}
+
+func TestRender(t *testing.T) {
+	var in, out bytes.Buffer
+	png.Encode(&in, testImage())
+
+	format, err := Render(&in, 6, &Recipe{Rotate: 90}, &out)
+	if err != nil || format != "png" {
+		t.Fatalf("format %s, %v", format, err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// orientation and rotation turn the image upside down
+	if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+		t.Fatalf("bounds %v", b)
+	}
+	if !isRed(img, 3, 1) {
+		t.Errorf("red pixel is not at the bottom right")
+	}
+}
+
+// blobs draws smooth shapes, seed changes them
+func blobs(width, height, seed int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	s := float64(seed)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			fx, fy := float64(x)/float64(width), float64(y)/float64(height)
+			v := 128 + 60*math.Sin(fx*(3+s)+s)*math.Cos(fy*(2+s*0.7)) + 50*math.Sin((fx+fy)*(5+s))
+			img.SetNRGBA(x, y, color.NRGBA{uint8(v), uint8(v * 0.8), uint8(255 - v), 0xff})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	original := PerceptualHash(blobs(320, 240, 1))
+
+	// a smaller recompressed copy is still the same picture
+	var encoded bytes.Buffer
+	jpeg.Encode(&encoded, blobs(160, 120, 1), &jpeg.Options{Quality: 60})
+	copied, err := jpeg.Decode(&encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := HashDistance(original, PerceptualHash(copied)); d > 6 {
+		t.Errorf("copy is %d bits away", d)
+	}
+
+	for seed := 2; seed < 5; seed++ {
+		if d := HashDistance(original, PerceptualHash(blobs(320, 240, seed))); d < 16 {
+			t.Errorf("picture %d is %d bits away", seed, d)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package imaging
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	phashSize = 32 // side of the downscaled image the DCT runs over
+	phashBits = 8  // side of the block of lowest frequencies which makes the hash
+)
+
+// phashCos holds cos((2x+1)uπ/2N) for the frequencies the hash takes
+var phashCos = func() [phashBits][phashSize]float64 {
+	var table [phashBits][phashSize]float64
+	for u := 0; u < phashBits; u++ {
+		for x := 0; x < phashSize; x++ {
+			table[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * phashSize))
+		}
+	}
+	return table
+}()
+
+// luminance downscales the image to phashSize squared by averaging the pixels each cell covers
+func luminance(img image.Image) [phashSize][phashSize]float64 {
+	var sums, counts [phashSize][phashSize]float64
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	for y := 0; y < h; y++ {
+		cy := y * phashSize / h
+		for x := 0; x < w; x++ {
+			cx := x * phashSize / w
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			sums[cy][cx] += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+			counts[cy][cx]++
+		}
+	}
+	for y := range sums {
+		for x := range sums[y] {
+			if counts[y][x] > 0 {
+				sums[y][x] /= counts[y][x]
+			}
+		}
+	}
+	return sums
+}
+
+// PerceptualHash summarizes the structure of the image in 64 bits: each bit tells whether
+// a low frequency of the DCT is above the median. Resized, recompressed or slightly
+// adjusted copies of an image have hashes a few bits apart.
+func PerceptualHash(img image.Image) uint64 {
+	if img.Bounds().Empty() {
+		return 0
+	}
+	pixels := luminance(img)
+
+	coefficients := make([]float64, 0, phashBits*phashBits)
+	for v := 0; v < phashBits; v++ {
+		for u := 0; u < phashBits; u++ {
+			var sum float64
+			for y := 0; y < phashSize; y++ {
+				for x := 0; x < phashSize; x++ {
+					sum += pixels[y][x] * phashCos[u][x] * phashCos[v][y]
+				}
+			}
+			coefficients = append(coefficients, sum)
+		}
+	}
+
+	// the DC term is the average brightness, it would skew the median
+	sorted := append([]float64{}, coefficients[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, c := range coefficients {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// HashDistance is the number of bits two perceptual hashes differ in, 0 to 64
+func HashDistance(a uint64, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
@@ -0,0 +1,349 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	tagImageWidth       = 0x0100
+	tagImageLength      = 0x0101
+	tagMake             = 0x010F
+	tagModel            = 0x0110
+	tagOrientation      = 0x0112
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagGPSIFD           = 0x8825
+	tagDateTimeOriginal = 0x9003
+	tagDateTimeDigitize = 0x9004
+	tagMakerNote        = 0x927C
+	tagPixelXDimension  = 0xA002
+	tagPixelYDimension  = 0xA003
+
+	tagGPSLatitudeRef  = 0x0001
+	tagGPSLatitude     = 0x0002
+	tagGPSLongitudeRef = 0x0003
+	tagGPSLongitude    = 0x0004
+
+	tagAppleContentIdentifier = 0x0011
+
+	typeByte      = 1
+	typeASCII     = 2
+	typeShort     = 3
+	typeLong      = 4
+	typeRational  = 5
+	typeUndefined = 7
+	typeSLong     = 9
+	typeSRational = 10
+
+	maxIFDEntries = 1024
+	maxValueSize  = 1 << 20
+
+	exifTimeLayout = "2006:01:02 15:04:05"
+)
+
+var errMalformed = errors.New("malformed media file")
+
+var typeSize = map[uint16]uint32{
+	typeByte:      1,
+	typeASCII:     1,
+	typeShort:     2,
+	typeLong:      4,
+	typeRational:  8,
+	typeUndefined: 1,
+	typeSLong:     4,
+	typeSRational: 8,
+}
+
+type tiffEntry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+type tiff struct {
+	r     io.ReaderAt
+	base  int64
+	order binary.ByteOrder
+}
+
+type ifd map[uint16]*tiffEntry
+
+func (t *tiff) readIFD(offset uint32) (ifd, error) {
+	buf := make([]byte, 2)
+	if _, err := t.r.ReadAt(buf, t.base+int64(offset)); err != nil {
+		return nil, err
+	}
+	count := int(t.order.Uint16(buf))
+	if count > maxIFDEntries {
+		return nil, errMalformed
+	}
+
+	buf = make([]byte, 12*count)
+	if _, err := t.r.ReadAt(buf, t.base+int64(offset)+2); err != nil {
+		return nil, err
+	}
+
+	entries := make(ifd, count)
+	for i := 0; i < count; i++ {
+		e := buf[i*12 : i*12+12]
+		entry := &tiffEntry{
+			typ:   t.order.Uint16(e[2:4]),
+			count: t.order.Uint32(e[4:8]),
+		}
+		size, ok := typeSize[entry.typ]
+		if !ok || entry.count > maxValueSize/size {
+			continue
+		}
+		size *= entry.count
+		if size <= 4 {
+			entry.value = e[8 : 8+size]
+		} else {
+			entry.value = make([]byte, size)
+			if _, err := t.r.ReadAt(entry.value, t.base+int64(t.order.Uint32(e[8:12]))); err != nil {
+				continue
+			}
+		}
+		entries[t.order.Uint16(e[0:2])] = entry
+	}
+
+	return entries, nil
+}
+
+func (t *tiff) uint(e *tiffEntry) (uint32, bool) {
+	if e == nil || e.count == 0 {
+		return 0, false
+	}
+	switch e.typ {
+	case typeByte, typeUndefined:
+		return uint32(e.value[0]), true
+	case typeShort:
+		return uint32(t.order.Uint16(e.value)), true
+	case typeLong, typeSLong:
+		return t.order.Uint32(e.value), true
+	}
+	return 0, false
+}
+
+func (t *tiff) rationals(e *tiffEntry) []float64 {
+	if e == nil || (e.typ != typeRational && e.typ != typeSRational) {
+		return nil
+	}
+	values := make([]float64, 0, e.count)
+	for i := uint32(0); i < e.count; i++ {
+		num := t.order.Uint32(e.value[i*8:])
+		den := t.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			values = append(values, 0)
+		} else if e.typ == typeSRational {
+			values = append(values, float64(int32(num))/float64(int32(den)))
+		} else {
+			values = append(values, float64(num)/float64(den))
+		}
+	}
+	return values
+}
+
+func ascii(e *tiffEntry) string {
+	if e == nil || (e.typ != typeASCII && e.typ != typeUndefined) {
+		return ""
+	}
+	if i := bytes.IndexByte(e.value, 0); i >= 0 {
+		return strings.TrimSpace(string(e.value[:i]))
+	}
+	return strings.TrimSpace(string(e.value))
+}
+
+func parseExifTime(e *tiffEntry) time.Time {
+	t, err := time.Parse(exifTimeLayout, ascii(e))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func degrees(dms []float64, ref string) (float64, bool) {
+	if len(dms) != 3 {
+		return 0, false
+	}
+	v := dms[0] + dms[1]/60 + dms[2]/3600
+	if ref == "S" || ref == "W" {
+		v = -v
+	}
+	return v, true
+}
+
+// readTIFF parses a TIFF structure (EXIF payload or a TIFF based RAW file) located at base
+func readTIFF(r io.ReaderAt, base int64, m *Metadata) error {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, base); err != nil {
+		return err
+	}
+
+	t := &tiff{r: r, base: base}
+	switch string(header[0:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return errMalformed
+	}
+	if t.order.Uint16(header[2:4]) != 42 {
+		return errMalformed
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(header[4:8]))
+	if err != nil {
+		return err
+	}
+
+	m.Make = ascii(ifd0[tagMake])
+	m.Model = ascii(ifd0[tagModel])
+	if v, ok := t.uint(ifd0[tagOrientation]); ok {
+		m.Orientation = int(v)
+	}
+	if v, ok := t.uint(ifd0[tagImageWidth]); ok {
+		m.Width = int(v)
+	}
+	if v, ok := t.uint(ifd0[tagImageLength]); ok {
+		m.Height = int(v)
+	}
+	m.CaptureTime = parseExifTime(ifd0[tagDateTime])
+
+	if offset, ok := t.uint(ifd0[tagExifIFD]); ok {
+		if exif, err := t.readIFD(offset); err == nil {
+			if ct := parseExifTime(exif[tagDateTimeOriginal]); !ct.IsZero() {
+				m.CaptureTime = ct
+			} else if ct := parseExifTime(exif[tagDateTimeDigitize]); !ct.IsZero() {
+				m.CaptureTime = ct
+			}
+			if v, ok := t.uint(exif[tagPixelXDimension]); ok && v > 0 {
+				m.Width = int(v)
+			}
+			if v, ok := t.uint(exif[tagPixelYDimension]); ok && v > 0 {
+				m.Height = int(v)
+			}
+			if note := exif[tagMakerNote]; note != nil {
+				m.ContentID = appleContentID(note.value)
+			}
+		}
+	}
+
+	if offset, ok := t.uint(ifd0[tagGPSIFD]); ok {
+		if gps, err := t.readIFD(offset); err == nil {
+			lat, latOK := degrees(t.rationals(gps[tagGPSLatitude]), ascii(gps[tagGPSLatitudeRef]))
+			lon, lonOK := degrees(t.rationals(gps[tagGPSLongitude]), ascii(gps[tagGPSLongitudeRef]))
+			if latOK && lonOK && !(lat == 0 && lon == 0) {
+				m.HasGPS = true
+				m.Latitude = lat
+				m.Longitude = lon
+			}
+		}
+	}
+
+	return nil
+}
+
+// appleContentID reads the identifier shared by both halves of a Live Photo from an Apple maker note:
+// a signature, a version and a byte order mark precede an IFD whose offsets count from the note start
+func appleContentID(note []byte) string {
+	if len(note) < 16 || !bytes.HasPrefix(note, []byte("Apple iOS\x00")) || string(note[12:14]) != "MM" {
+		return ""
+	}
+	t := &tiff{r: bytes.NewReader(note), order: binary.BigEndian}
+	entries, err := t.readIFD(14)
+	if err != nil {
+		return ""
+	}
+	return ascii(entries[tagAppleContentIdentifier])
+}
+
+// readJPEG walks JPEG segments looking for the EXIF and XMP blocks and the frame header
+func readJPEG(r io.ReaderAt, m *Metadata) error {
+	offset := int64(2)
+	marker := make([]byte, 4)
+	for {
+		if _, err := r.ReadAt(marker, offset); err != nil {
+			return err
+		}
+		if marker[0] != 0xFF {
+			return errMalformed
+		}
+		if marker[1] == 0xFF { // fill byte
+			offset++
+			continue
+		}
+		if marker[1] == 0xD8 || marker[1] == 0x01 || (marker[1] >= 0xD0 && marker[1] <= 0xD7) {
+			offset += 2
+			continue
+		}
+		if marker[1] == 0xDA || marker[1] == 0xD9 { // image data or end of image
+			return nil
+		}
+
+		length := int64(binary.BigEndian.Uint16(marker[2:4]))
+		if length < 2 {
+			return errMalformed
+		}
+		data := offset + 4
+
+		switch {
+		case marker[1] == 0xE1:
+			header := make([]byte, 6)
+			if _, err := r.ReadAt(header, data); err == nil && string(header) == "Exif\x00\x00" {
+				if err := readTIFF(r, data+6, m); err != nil {
+					return err
+				}
+			} else if err == nil {
+				readJPEGXMP(r, data, length-2, m)
+			}
+		case marker[1] >= 0xC0 && marker[1] <= 0xCF && marker[1] != 0xC4 && marker[1] != 0xC8 && marker[1] != 0xCC:
+			frame := make([]byte, 5)
+			if _, err := r.ReadAt(frame, data); err != nil {
+				return err
+			}
+			m.Height = int(binary.BigEndian.Uint16(frame[1:3]))
+			m.Width = int(binary.BigEndian.Uint16(frame[3:5]))
+		}
+
+		offset += 2 + length
+	}
+}
+
+// readPNG takes dimensions from IHDR and EXIF from the eXIf chunk, if any
+func readPNG(r io.ReaderAt, m *Metadata) error {
+	offset := int64(8)
+	header := make([]byte, 8)
+	for {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return err
+		}
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		data := offset + 8
+
+		switch string(header[4:8]) {
+		case "IHDR":
+			dims := make([]byte, 8)
+			if _, err := r.ReadAt(dims, data); err != nil {
+				return err
+			}
+			m.Width = int(binary.BigEndian.Uint32(dims[0:4]))
+			m.Height = int(binary.BigEndian.Uint32(dims[4:8]))
+		case "eXIf":
+			width, height := m.Width, m.Height
+			if err := readTIFF(r, data, m); err != nil {
+				return err
+			}
+			m.Width, m.Height = width, height
+		case "IEND":
+			return nil
+		}
+
+		offset = data + length + 4
+	}
+}
@@ -0,0 +1,163 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+type testTag struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func asciiTag(tag uint16, s string) testTag {
+	return testTag{tag, typeASCII, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func longTag(tag uint16, v uint32) testTag {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return testTag{tag, typeLong, 1, b}
+}
+
+func rationalTag(tag uint16, values ...uint32) testTag {
+	b := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(b[i*4:], v)
+	}
+	return testTag{tag, typeRational, uint32(len(values) / 2), b}
+}
+
+// buildIFD lays out a little endian IFD at offset, followed by its out of line values
+func buildIFD(offset uint32, tags []testTag) []byte {
+	out := make([]byte, 2+12*len(tags)+4)
+	binary.LittleEndian.PutUint16(out, uint16(len(tags)))
+	extra := offset + uint32(len(out))
+	var data []byte
+	for i, t := range tags {
+		e := out[2+i*12:]
+		binary.LittleEndian.PutUint16(e[0:], t.tag)
+		binary.LittleEndian.PutUint16(e[2:], t.typ)
+		binary.LittleEndian.PutUint32(e[4:], t.count)
+		if len(t.value) <= 4 {
+			copy(e[8:12], t.value)
+		} else {
+			binary.LittleEndian.PutUint32(e[8:], extra+uint32(len(data)))
+			data = append(data, t.value...)
+		}
+	}
+	return append(out, data...)
+}
+
+func buildExif() []byte {
+	gps := []testTag{
+		asciiTag(tagGPSLatitudeRef, "N"),
+		rationalTag(tagGPSLatitude, 52, 1, 22, 1, 1800, 100),
+		asciiTag(tagGPSLongitudeRef, "E"),
+		rationalTag(tagGPSLongitude, 4, 1, 53, 1, 2400, 100),
+	}
+	exif := []testTag{
+		asciiTag(tagDateTimeOriginal, "2019:07:14 10:20:30"),
+	}
+
+	ifd0Tags := func(exifOffset, gpsOffset uint32) []testTag {
+		return []testTag{
+			asciiTag(tagMake, "Apple"),
+			asciiTag(tagModel, "iPhone XS"),
+			longTag(tagExifIFD, exifOffset),
+			longTag(tagGPSIFD, gpsOffset),
+		}
+	}
+
+	// lay out once to learn the sizes, then once more with real offsets
+	ifd0 := buildIFD(8, ifd0Tags(0, 0))
+	exifOffset := uint32(8 + len(ifd0))
+	exifIFD := buildIFD(exifOffset, exif)
+	gpsOffset := exifOffset + uint32(len(exifIFD))
+	ifd0 = buildIFD(8, ifd0Tags(exifOffset, gpsOffset))
+
+	out := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	out = append(out, ifd0...)
+	out = append(out, exifIFD...)
+	return append(out, buildIFD(gpsOffset, gps)...)
+}
+
+func writeTestJPEG(t *testing.T, dir string) string {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 64, 48)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	payload := append([]byte("Exif\x00\x00"), buildExif()...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(payload)+2))
+
+	content := append([]byte{0xFF, 0xD8}, app1...)
+	content = append(content, payload...)
+	content = append(content, encoded.Bytes()[2:]...)
+
+	p := path.Join(dir, "IMG_0001.JPG")
+	if err := ioutil.WriteFile(p, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestReadJPEGMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := ReadMetadata(writeTestJPEG(t, dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Kind != KindPhoto {
+		t.Errorf("kind is '%s'", m.Kind)
+	}
+	if m.Make != "Apple" || m.Model != "iPhone XS" {
+		t.Errorf("camera is '%s %s'", m.Make, m.Model)
+	}
+	if m.CaptureTime.Format(exifTimeLayout) != "2019:07:14 10:20:30" {
+		t.Errorf("capture time is '%v'", m.CaptureTime)
+	}
+	if m.Width != 64 || m.Height != 48 {
+		t.Errorf("dimensions are %dx%d", m.Width, m.Height)
+	}
+	if !m.HasGPS || math.Abs(m.Latitude-52.3717) > 0.001 || math.Abs(m.Longitude-4.89) > 0.001 {
+		t.Errorf("location is %v %f,%f", m.HasGPS, m.Latitude, m.Longitude)
+	}
+}
+
+func TestReadMetadataOfUnknownFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := path.Join(dir, "clip.mov")
+	if err := ioutil.WriteFile(p, []byte("not really a movie"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadMetadata(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Kind != KindVideo || !m.CaptureTime.IsZero() {
+		t.Errorf("unexpected metadata %+v", m)
+	}
+}
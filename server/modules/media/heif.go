@@ -0,0 +1,222 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+var heifBrands = map[string]bool{
+	"heic": true,
+	"heix": true,
+	"heim": true,
+	"heis": true,
+	"mif1": true,
+	"msf1": true,
+	"avif": true,
+}
+
+// isHEIF checks the major and compatible brands of the ftyp box at the start of the file
+func isHEIF(r io.ReaderAt) bool {
+	header := make([]byte, 8)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return false
+	}
+	size := int64(binary.BigEndian.Uint32(header[0:4]))
+	if size < 16 || size > 1024 {
+		return false
+	}
+	brands, err := readBoxPayload(r, mp4Box{typ: "ftyp", offset: 8, size: size - 8}, size)
+	if err != nil {
+		return false
+	}
+	for i := 0; i+4 <= len(brands); i += 4 {
+		if i != 4 && heifBrands[string(brands[i:i+4])] { // skip minor version
+			return true
+		}
+	}
+	return false
+}
+
+type heifExtent struct {
+	offset int64
+	length int64
+}
+
+// readHEIF finds the Exif item of a HEIF image through the item info and item location boxes,
+// dimensions come from the image spatial extents property.
+func readHEIF(r io.ReaderAt, size int64, m *Metadata) error {
+	var exifItem uint32
+	var hasExif bool
+	locations := make(map[uint32][]heifExtent)
+
+	err := readBoxes(r, 0, size, func(b mp4Box) error {
+		if b.typ != "meta" {
+			return nil
+		}
+		// meta is a full box, skip version and flags
+		return readBoxes(r, b.offset+4, b.offset+b.size, func(b mp4Box) error {
+			switch b.typ {
+			case "iinf":
+				exifItem, hasExif = heifFindItem(r, b, "Exif")
+			case "iloc":
+				heifReadLocations(r, b, locations)
+			case "iprp":
+				return readBoxes(r, b.offset, b.offset+b.size, func(b mp4Box) error {
+					if b.typ == "ipco" {
+						return readBoxes(r, b.offset, b.offset+b.size, func(b mp4Box) error {
+							if b.typ == "ispe" {
+								heifSpatialExtent(r, b, m)
+							}
+							return nil
+						})
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	extents := locations[exifItem]
+	if !hasExif || len(extents) == 0 {
+		return nil
+	}
+
+	// the item starts with an offset to the TIFF header, which usually follows "Exif\0\0"
+	header := make([]byte, 4)
+	if _, err := r.ReadAt(header, extents[0].offset); err != nil {
+		return err
+	}
+	width, height := m.Width, m.Height
+	if err := readTIFF(r, extents[0].offset+4+int64(binary.BigEndian.Uint32(header)), m); err != nil {
+		return err
+	}
+	if width > 0 && height > 0 {
+		m.Width, m.Height = width, height
+	}
+	return nil
+}
+
+func heifFindItem(r io.ReaderAt, b mp4Box, itemType string) (uint32, bool) {
+	head, err := readBoxPayload(r, b, 8)
+	if err != nil || len(head) < 6 {
+		return 0, false
+	}
+	offset := b.offset + 6
+	if head[0] != 0 {
+		offset += 2
+	}
+
+	var itemID uint32
+	var found bool
+	readBoxes(r, offset, b.offset+b.size, func(b mp4Box) error {
+		if b.typ != "infe" || found {
+			return nil
+		}
+		payload, err := readBoxPayload(r, b, 16)
+		if err != nil || len(payload) < 12 || payload[0] < 2 {
+			return nil
+		}
+		id, typ := uint32(binary.BigEndian.Uint16(payload[4:6])), string(payload[8:12])
+		if payload[0] >= 3 {
+			if len(payload) < 14 {
+				return nil
+			}
+			id, typ = binary.BigEndian.Uint32(payload[4:8]), string(payload[10:14])
+		}
+		if typ == itemType {
+			itemID, found = id, true
+		}
+		return nil
+	})
+	return itemID, found
+}
+
+func heifReadLocations(r io.ReaderAt, b mp4Box, locations map[uint32][]heifExtent) {
+	payload, err := readBoxPayload(r, b, 1<<20)
+	if err != nil || len(payload) < 8 {
+		return
+	}
+	version := payload[0]
+	offsetSize := int(payload[4] >> 4)
+	lengthSize := int(payload[4] & 0xF)
+	baseOffsetSize := int(payload[5] >> 4)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(payload[5] & 0xF)
+	}
+
+	pos := 6
+	read := func(n int) (uint64, bool) {
+		if n == 0 {
+			return 0, true
+		}
+		if pos+n > len(payload) {
+			return 0, false
+		}
+		var v uint64
+		for _, c := range payload[pos : pos+n] {
+			v = v<<8 | uint64(c)
+		}
+		pos += n
+		return v, true
+	}
+
+	itemCountSize := 2
+	if version == 2 {
+		itemCountSize = 4
+	}
+	itemCount, ok := read(itemCountSize)
+	for i := uint64(0); ok && i < itemCount; i++ {
+		var itemID, baseOffset, extentCount uint64
+		if itemID, ok = read(itemCountSize); !ok {
+			return
+		}
+		if version == 1 || version == 2 {
+			if _, ok = read(2); !ok { // construction method
+				return
+			}
+		}
+		if _, ok = read(2); !ok { // data reference index
+			return
+		}
+		if baseOffset, ok = read(baseOffsetSize); !ok {
+			return
+		}
+		if extentCount, ok = read(2); !ok {
+			return
+		}
+		for e := uint64(0); e < extentCount; e++ {
+			var extentOffset, extentLength uint64
+			if _, ok = read(indexSize); !ok {
+				return
+			}
+			if extentOffset, ok = read(offsetSize); !ok {
+				return
+			}
+			if extentLength, ok = read(lengthSize); !ok {
+				return
+			}
+			locations[uint32(itemID)] = append(locations[uint32(itemID)], heifExtent{
+				offset: int64(baseOffset + extentOffset),
+				length: int64(extentLength),
+			})
+		}
+	}
+}
+
+// heifSpatialExtent keeps the largest extent, smaller ones belong to tiles and thumbnails
+func heifSpatialExtent(r io.ReaderAt, b mp4Box, m *Metadata) {
+	payload, err := readBoxPayload(r, b, 12)
+	if err != nil || len(payload) < 12 {
+		return
+	}
+	width := int(binary.BigEndian.Uint32(payload[4:8]))
+	height := int(binary.BigEndian.Uint32(payload[8:12]))
+	if width*height > m.Width*m.Height {
+		m.Width, m.Height = width, height
+	}
+}
@@ -0,0 +1,85 @@
+package media
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func buildAppleMakerNote(contentID string) []byte {
+	note := append([]byte("Apple iOS\x00"), 0, 1, 'M', 'M')
+	ifd := make([]byte, 2+12+4)
+	binary.BigEndian.PutUint16(ifd[0:], 1)
+	binary.BigEndian.PutUint16(ifd[2:], tagAppleContentIdentifier)
+	binary.BigEndian.PutUint16(ifd[4:], typeASCII)
+	binary.BigEndian.PutUint32(ifd[6:], uint32(len(contentID)+1))
+	binary.BigEndian.PutUint32(ifd[10:], uint32(len(note)+len(ifd)))
+	note = append(note, ifd...)
+	return append(note, append([]byte(contentID), 0)...)
+}
+
+func buildHEIC() []byte {
+	note := buildAppleMakerNote("4A3F1C2B-6E5D-4F7A-9B8C-0D1E2F3A4B5C")
+	ifd0 := buildIFD(8, []testTag{asciiTag(tagMake, "Apple"), longTag(tagExifIFD, 0)})
+	exif := buildIFD(uint32(8+len(ifd0)), []testTag{
+		asciiTag(tagDateTimeOriginal, "2021:03:04 05:06:07"),
+		{tagMakerNote, typeUndefined, uint32(len(note)), note},
+	})
+	ifd0 = buildIFD(8, []testTag{asciiTag(tagMake, "Apple"), longTag(tagExifIFD, uint32(8+len(ifd0)))})
+	tiff := append([]byte{'I', 'I', 42, 0, 8, 0, 0, 0}, ifd0...)
+	tiff = append(tiff, exif...)
+	item := append(u32(6), append([]byte("Exif\x00\x00"), tiff...)...)
+
+	ftyp := box("ftyp", []byte("heic"), u32(0), []byte("mif1heic"))
+	infe := box("infe", u32(2<<24), []byte{0, 1, 0, 0}, []byte("Exif"), []byte{0})
+	ispe := func(width, height uint32) []byte {
+		return box("ispe", u32(0, width, height))
+	}
+	iloc := func(offset uint32) []byte {
+		return box("iloc", u32(0), []byte{0x44, 0x00, 0, 1, 0, 1, 0, 0, 0, 1}, u32(offset, uint32(len(item))))
+	}
+	meta := func(offset uint32) []byte {
+		return box("meta", u32(0),
+			box("hdlr", u32(0, 0), []byte("pict"), make([]byte, 13)),
+			box("iinf", u32(0), []byte{0, 1}, infe),
+			iloc(offset),
+			box("iprp", box("ipco", ispe(4032, 3024), ispe(512, 384))))
+	}
+
+	head := append(ftyp, meta(0)...)
+	head = append(ftyp, meta(uint32(len(head)+8))...)
+	return append(head, box("mdat", item)...)
+}
+
+func TestReadHEICMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := path.Join(dir, "IMG_0003.HEIC")
+	if err := ioutil.WriteFile(p, buildHEIC(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadMetadata(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Kind != KindPhoto || m.Make != "Apple" {
+		t.Errorf("kind is '%s', make is '%s'", m.Kind, m.Make)
+	}
+	if m.CaptureTime.Format(exifTimeLayout) != "2021:03:04 05:06:07" {
+		t.Errorf("capture time is '%v'", m.CaptureTime)
+	}
+	if m.Width != 4032 || m.Height != 3024 {
+		t.Errorf("dimensions are %dx%d", m.Width, m.Height)
+	}
+	if m.ContentID != "4A3F1C2B-6E5D-4F7A-9B8C-0D1E2F3A4B5C" {
+		t.Errorf("content identifier is '%s'", m.ContentID)
+	}
+}
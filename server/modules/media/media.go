@@ -0,0 +1,129 @@
+package media
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// KindPhoto is a still image
+	KindPhoto = "photo"
+	// KindVideo is a movie clip
+	KindVideo = "video"
+)
+
+var kindByExtension = map[string]string{
+	".jpg":  KindPhoto,
+	".jpeg": KindPhoto,
+	".png":  KindPhoto,
+	".gif":  KindPhoto,
+	".webp": KindPhoto,
+	".heic": KindPhoto,
+	".heif": KindPhoto,
+	".tif":  KindPhoto,
+	".tiff": KindPhoto,
+	".dng":  KindPhoto,
+	".cr2":  KindPhoto,
+	".nef":  KindPhoto,
+	".arw":  KindPhoto,
+	".orf":  KindPhoto,
+	".rw2":  KindPhoto,
+	".pef":  KindPhoto,
+	".srw":  KindPhoto,
+	".mp4":  KindVideo,
+	".m4v":  KindVideo,
+	".mov":  KindVideo,
+	".3gp":  KindVideo,
+}
+
+// Metadata is what could be learned about a photo or a video from its content
+type Metadata struct {
+	Kind        string
+	CaptureTime time.Time
+	Make        string
+	Model       string
+	Width       int
+	Height      int
+	Orientation int
+	HasGPS      bool
+	Latitude    float64
+	Longitude   float64
+	Duration    float64 // seconds, videos only
+	Codec       string  // sample entry type of the video track, e.g. avc1 or hvc1
+	ContentID   string  // identifier shared by the still and the video of a Live Photo
+	XMP         *XMP    // from the sidecar if there is one, otherwise embedded in the file
+}
+
+// KindForName returns KindPhoto or KindVideo for the file name, or an empty string
+// if the name does not look like a media file
+func KindForName(name string) string {
+	return kindByExtension[strings.ToLower(filepath.Ext(name))]
+}
+
+// isQuickTimeAtom recognizes the first box of MP4 files, and of old QuickTime files which have no ftyp
+func isQuickTimeAtom(typ []byte) bool {
+	switch string(typ) {
+	case "ftyp", "moov", "mdat", "wide", "free", "skip":
+		return true
+	}
+	return false
+}
+
+// IsRAW tells whether the file name has the extension of a camera RAW format
+func IsRAW(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".dng", ".cr2", ".nef", ".arw", ".orf", ".rw2", ".pef", ".srw":
+		return true
+	}
+	return false
+}
+
+// ReadMetadata extracts capture date, camera, dimensions, location and XMP from the file.
+// Formats it does not understand yield Metadata with only Kind set, and XMP of a sidecar.
+func ReadMetadata(p string) (*Metadata, error) {
+	m := &Metadata{
+		Kind: KindForName(p),
+	}
+
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, 8)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return m, nil
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0xFF, 0xD8}):
+		err = readJPEG(f, m)
+	case bytes.HasPrefix(magic, []byte("II*\x00")), bytes.HasPrefix(magic, []byte("MM\x00*")):
+		err = readTIFF(f, 0, m)
+	case bytes.Equal(magic, []byte("\x89PNG\r\n\x1a\n")):
+		err = readPNG(f, m)
+	case string(magic[4:8]) == "ftyp" && isHEIF(f):
+		err = readHEIF(f, fi.Size(), m)
+	case isQuickTimeAtom(magic[4:8]):
+		err = readMP4(f, fi.Size(), m)
+	}
+
+	// desktop tools keep the sidecar up to date, the embedded copy is what the camera wrote
+	if x, sidecarErr := ReadSidecar(p); sidecarErr == nil && x != nil {
+		m.XMP = x
+	}
+	if err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
@@ -0,0 +1,338 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// seconds between 1904-01-01, the epoch of ISO base media files, and 1970-01-01
+const mp4EpochOffset = 2082844800
+
+var iso6709 = regexp.MustCompile(`^([+-]\d+(?:\.\d+)?)([+-]\d+(?:\.\d+)?)`)
+
+type mp4Box struct {
+	typ    string
+	offset int64 // payload offset
+	size   int64 // payload size
+}
+
+// readBoxes calls fn for every box in [offset, end) of an ISO base media file (MP4, MOV, HEIC)
+func readBoxes(r io.ReaderAt, offset int64, end int64, fn func(b mp4Box) error) error {
+	header := make([]byte, 16)
+	for offset+8 <= end {
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		b := mp4Box{
+			typ:    string(header[4:8]),
+			offset: offset + 8,
+		}
+		switch size {
+		case 0: // up to the end of the file
+			size = end - offset
+		case 1:
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			b.offset += 8
+		}
+		if size < b.offset-offset || offset+size > end {
+			return errMalformed
+		}
+		b.size = offset + size - b.offset
+
+		if err := fn(b); err != nil {
+			return err
+		}
+		offset += size
+	}
+	return nil
+}
+
+func readBoxPayload(r io.ReaderAt, b mp4Box, limit int64) ([]byte, error) {
+	size := b.size
+	if size > limit {
+		size = limit
+	}
+	payload := make([]byte, size)
+	if _, err := r.ReadAt(payload, b.offset); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func parseISO6709(s string, m *Metadata) {
+	match := iso6709.FindStringSubmatch(s)
+	if match == nil {
+		return
+	}
+	lat, errLat := strconv.ParseFloat(match[1], 64)
+	lon, errLon := strconv.ParseFloat(match[2], 64)
+	if errLat == nil && errLon == nil && !(lat == 0 && lon == 0) {
+		m.HasGPS = true
+		m.Latitude = lat
+		m.Longitude = lon
+	}
+}
+
+// parseQuickTimeDate keeps the wall clock of the place the clip was shot, as EXIF dates do
+func parseQuickTimeDate(s string) time.Time {
+	for _, layout := range []string{"2006-01-02T15:04:05-0700", "2006-01-02T15:04:05Z07:00", "2006-01-02T15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		}
+	}
+	return time.Time{}
+}
+
+type mp4Reader struct {
+	r          io.ReaderAt
+	m          *Metadata
+	quickTime  map[string]string // com.apple.quicktime.* keys of the moov/meta box
+	isVideoTrk bool
+}
+
+// readMP4 extracts movie header, video track and location data of MP4 and QuickTime files
+func readMP4(r io.ReaderAt, size int64, m *Metadata) error {
+	mr := &mp4Reader{
+		r:         r,
+		m:         m,
+		quickTime: make(map[string]string),
+	}
+
+	err := readBoxes(r, 0, size, func(b mp4Box) error {
+		if b.typ == "moov" {
+			return readBoxes(r, b.offset, b.offset+b.size, mr.moov)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if v, ok := mr.quickTime["com.apple.quicktime.creationdate"]; ok {
+		if t := parseQuickTimeDate(v); !t.IsZero() {
+			m.CaptureTime = t
+		}
+	}
+	if v, ok := mr.quickTime["com.apple.quicktime.location.ISO6709"]; ok {
+		parseISO6709(v, m)
+	}
+	if v, ok := mr.quickTime["com.apple.quicktime.make"]; ok {
+		m.Make = v
+	}
+	if v, ok := mr.quickTime["com.apple.quicktime.model"]; ok {
+		m.Model = v
+	}
+	if v, ok := mr.quickTime["com.apple.quicktime.content.identifier"]; ok {
+		m.ContentID = v
+	}
+	return nil
+}
+
+func (mr *mp4Reader) moov(b mp4Box) error {
+	switch b.typ {
+	case "mvhd":
+		mr.mvhd(b)
+	case "trak":
+		mr.isVideoTrk = false
+		return readBoxes(mr.r, b.offset, b.offset+b.size, mr.trak)
+	case "udta":
+		return readBoxes(mr.r, b.offset, b.offset+b.size, mr.udta)
+	case "meta":
+		return mr.meta(b)
+	}
+	return nil
+}
+
+func (mr *mp4Reader) mvhd(b mp4Box) {
+	payload, err := readBoxPayload(mr.r, b, 32)
+	if err != nil || len(payload) < 20 {
+		return
+	}
+
+	var created, timescale, duration uint64
+	if payload[0] == 1 {
+		if len(payload) < 32 {
+			return
+		}
+		created = binary.BigEndian.Uint64(payload[4:12])
+		timescale = uint64(binary.BigEndian.Uint32(payload[20:24]))
+		duration = binary.BigEndian.Uint64(payload[24:32])
+	} else {
+		created = uint64(binary.BigEndian.Uint32(payload[4:8]))
+		timescale = uint64(binary.BigEndian.Uint32(payload[12:16]))
+		duration = uint64(binary.BigEndian.Uint32(payload[16:20]))
+	}
+
+	if created > mp4EpochOffset {
+		mr.m.CaptureTime = time.Unix(int64(created-mp4EpochOffset), 0).UTC()
+	}
+	if timescale > 0 {
+		mr.m.Duration = float64(duration) / float64(timescale)
+	}
+}
+
+func (mr *mp4Reader) trak(b mp4Box) error {
+	switch b.typ {
+	case "mdia":
+		if err := readBoxes(mr.r, b.offset, b.offset+b.size, mr.mdia); err != nil {
+			return err
+		}
+	case "tkhd":
+		mr.tkhd(b)
+	}
+	return nil
+}
+
+// tkhd keeps the dimensions until mdia tells whether this is a video track
+func (mr *mp4Reader) tkhd(b mp4Box) {
+	payload, err := readBoxPayload(mr.r, b, 96)
+	if err != nil || len(payload) < 84 {
+		return
+	}
+
+	fields := payload[24:] // skip version 0 times, ids and duration
+	if payload[0] == 1 {
+		if len(payload) < 96 {
+			return
+		}
+		fields = payload[36:]
+	}
+	// reserved(8) layer(2) group(2) volume(2) reserved(2) matrix(36) width(4) height(4)
+	if len(fields) < 60 {
+		return
+	}
+	matrix := fields[16:52]
+	width := int(binary.BigEndian.Uint32(fields[52:56]) >> 16)
+	height := int(binary.BigEndian.Uint32(fields[56:60]) >> 16)
+	if width == 0 || height == 0 || mr.m.Width != 0 {
+		return
+	}
+
+	mr.m.Width, mr.m.Height = width, height
+	m00 := int32(binary.BigEndian.Uint32(matrix[0:4]))
+	m01 := int32(binary.BigEndian.Uint32(matrix[4:8]))
+	switch {
+	case m00 == 0 && m01 > 0:
+		mr.m.Orientation = 6 // rotated 90 CW, as EXIF puts it
+	case m00 == 0 && m01 < 0:
+		mr.m.Orientation = 8
+	case m00 < 0:
+		mr.m.Orientation = 3
+	}
+	mr.isVideoTrk = true
+}
+
+func (mr *mp4Reader) mdia(b mp4Box) error {
+	switch b.typ {
+	case "hdlr":
+		payload, err := readBoxPayload(mr.r, b, 12)
+		if err == nil && len(payload) == 12 && string(payload[8:12]) != "vide" && mr.isVideoTrk {
+			// dimensions came from a track which is not a video, e.g. timed metadata
+			mr.m.Width, mr.m.Height, mr.m.Orientation = 0, 0, 0
+			mr.isVideoTrk = false
+		}
+	case "minf":
+		return readBoxes(mr.r, b.offset, b.offset+b.size, func(b mp4Box) error {
+			if b.typ == "stbl" {
+				return readBoxes(mr.r, b.offset, b.offset+b.size, mr.stbl)
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+func (mr *mp4Reader) stbl(b mp4Box) error {
+	if b.typ != "stsd" || !mr.isVideoTrk || mr.m.Codec != "" {
+		return nil
+	}
+	payload, err := readBoxPayload(mr.r, b, 16)
+	if err == nil && len(payload) == 16 && binary.BigEndian.Uint32(payload[4:8]) > 0 {
+		mr.m.Codec = string(bytes.TrimRight(payload[12:16], " \x00"))
+	}
+	return nil
+}
+
+// udta holds QuickTime user data atoms, the ones with a copyright sign prefix are strings
+func (mr *mp4Reader) udta(b mp4Box) error {
+	switch b.typ {
+	case "\xa9xyz", "\xa9mak", "\xa9mod":
+		payload, err := readBoxPayload(mr.r, b, 256)
+		if err != nil || len(payload) < 4 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(payload[0:2]))
+		if 4+length > len(payload) {
+			length = len(payload) - 4
+		}
+		value := string(payload[4 : 4+length])
+		switch b.typ {
+		case "\xa9xyz":
+			if !mr.m.HasGPS {
+				parseISO6709(value, mr.m)
+			}
+		case "\xa9mak":
+			mr.m.Make = value
+		case "\xa9mod":
+			mr.m.Model = value
+		}
+	case "meta":
+		return mr.meta(b)
+	}
+	return nil
+}
+
+// meta reads QuickTime metadata: a keys table and an item list indexed by key number
+func (mr *mp4Reader) meta(b mp4Box) error {
+	offset := b.offset
+	if head, err := readBoxPayload(mr.r, b, 8); err == nil && len(head) == 8 && string(head[4:8]) != "hdlr" {
+		offset += 4 // ISO flavour of the box has version and flags
+	}
+
+	keys := make(map[uint32]string)
+	return readBoxes(mr.r, offset, b.offset+b.size, func(b mp4Box) error {
+		switch b.typ {
+		case "keys":
+			payload, err := readBoxPayload(mr.r, b, 64*1024)
+			if err != nil || len(payload) < 8 {
+				return nil
+			}
+			count := binary.BigEndian.Uint32(payload[4:8])
+			pos := 8
+			for i := uint32(1); i <= count && pos+8 <= len(payload); i++ {
+				size := int(binary.BigEndian.Uint32(payload[pos : pos+4]))
+				if size < 8 || pos+size > len(payload) {
+					break
+				}
+				keys[i] = string(payload[pos+8 : pos+size])
+				pos += size
+			}
+		case "ilst":
+			return readBoxes(mr.r, b.offset, b.offset+b.size, func(item mp4Box) error {
+				key, ok := keys[binary.BigEndian.Uint32([]byte(item.typ))]
+				if !ok {
+					return nil
+				}
+				return readBoxes(mr.r, item.offset, item.offset+item.size, func(data mp4Box) error {
+					if data.typ != "data" {
+						return nil
+					}
+					payload, err := readBoxPayload(mr.r, data, 1024)
+					if err == nil && len(payload) > 8 {
+						mr.quickTime[key] = string(payload[8:])
+					}
+					return nil
+				})
+			})
+		}
+		return nil
+	})
+}
@@ -0,0 +1,100 @@
+package media
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+)
+
+func box(typ string, payload ...[]byte) []byte {
+	size := 8
+	for _, p := range payload {
+		size += len(p)
+	}
+	out := make([]byte, 8, size)
+	binary.BigEndian.PutUint32(out, uint32(size))
+	copy(out[4:], typ)
+	for _, p := range payload {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func u32(values ...uint32) []byte {
+	out := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.BigEndian.PutUint32(out[i*4:], v)
+	}
+	return out
+}
+
+func buildMOV() []byte {
+	mvhd := box("mvhd", u32(0, 3650000000, 3650000000, 600, 6300), make([]byte, 80))
+
+	tkhd := box("tkhd",
+		u32(0, 0, 0, 1, 0, 6300), // version, times, track id, reserved, duration
+		make([]byte, 16),         // reserved, layer, group, volume, reserved
+		u32(0, 0x10000, 0, 0xFFFF0000, 0, 0, 0, 0, 0x40000000), // rotated by 90
+		u32(1920<<16, 1080<<16))
+	hdlr := box("hdlr", u32(0, 0), []byte("vide"), make([]byte, 12))
+	stsd := box("stsd", u32(0, 1), box("hvc1", make([]byte, 78)))
+	trak := box("trak", tkhd, box("mdia", hdlr, box("minf", box("stbl", stsd))))
+
+	key := func(name string) []byte {
+		return box("mdta", []byte(name))
+	}
+	item := func(index uint32, value string) []byte {
+		return box(string(u32(index)), box("data", u32(1, 0), []byte(value)))
+	}
+	meta := box("meta",
+		box("hdlr", u32(0, 0), []byte("mdta"), make([]byte, 12)),
+		box("keys", u32(0, 2),
+			key("com.apple.quicktime.location.ISO6709"),
+			key("com.apple.quicktime.creationdate")),
+		box("ilst",
+			item(1, "+48.8584+002.2945+035.000/"),
+			item(2, "2020-05-17T18:01:02+0200")))
+
+	ftyp := box("ftyp", []byte("qt  "), u32(0), []byte("qt  "))
+	return append(append(ftyp, box("moov", mvhd, trak, meta)...), box("mdat")...)
+}
+
+func TestReadMOVMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := path.Join(dir, "IMG_0002.MOV")
+	if err := ioutil.WriteFile(p, buildMOV(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := ReadMetadata(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if m.Kind != KindVideo {
+		t.Errorf("kind is '%s'", m.Kind)
+	}
+	if m.CaptureTime.Format("2006-01-02 15:04:05") != "2020-05-17 18:01:02" {
+		t.Errorf("capture time is '%v'", m.CaptureTime)
+	}
+	if m.Duration != 10.5 {
+		t.Errorf("duration is %f", m.Duration)
+	}
+	if m.Width != 1920 || m.Height != 1080 || m.Orientation != 6 {
+		t.Errorf("dimensions are %dx%d, orientation %d", m.Width, m.Height, m.Orientation)
+	}
+	if m.Codec != "hvc1" {
+		t.Errorf("codec is '%s'", m.Codec)
+	}
+	if !m.HasGPS || math.Abs(m.Latitude-48.8584) > 0.0001 || math.Abs(m.Longitude-2.2945) > 0.0001 {
+		t.Errorf("location is %v %f,%f", m.HasGPS, m.Latitude, m.Longitude)
+	}
+}
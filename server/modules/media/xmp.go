@@ -0,0 +1,370 @@
+package media
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	nsRDF = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	nsXMP = "http://ns.adobe.com/xap/1.0/"
+	nsDC  = "http://purl.org/dc/elements/1.1/"
+	nsXML = "http://www.w3.org/XML/1998/namespace"
+
+	// APP1 segments carrying XMP start with the namespace
+	xmpJPEGHeader = nsXMP + "\x00"
+
+	// RatingRejected is what desktop tools write for photos marked as rejected
+	RatingRejected = -1
+	// RatingMax is five stars
+	RatingMax = 5
+)
+
+var (
+	rdfDescription = xml.Name{Space: nsRDF, Local: "Description"}
+	rdfRDF         = xml.Name{Space: nsRDF, Local: "RDF"}
+	rdfLi          = xml.Name{Space: nsRDF, Local: "li"}
+	xmpRating      = xml.Name{Space: nsXMP, Local: "Rating"}
+	dcTitle        = xml.Name{Space: nsDC, Local: "title"}
+	dcSubject      = xml.Name{Space: nsDC, Local: "subject"}
+)
+
+// XMP is the part of XMP metadata the library keeps: what photographers set in desktop tools
+type XMP struct {
+	Rating   int // 1-5 stars, 0 if not rated, RatingRejected
+	Title    string
+	Keywords []string
+}
+
+func parseRating(s string) int {
+	rating, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || math.IsNaN(rating) {
+		return 0
+	}
+	return int(math.Max(RatingRejected, math.Min(RatingMax, rating)))
+}
+
+// ParseXMP reads rating, title and keywords from an XMP packet. Properties may be
+// written as elements or, for simple values, as attributes of rdf:Description.
+func ParseXMP(packet []byte) (*XMP, error) {
+	x := new(XMP)
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	var stack []xml.Name
+	var text strings.Builder
+	lang, titleDefault := "", false
+
+	within := func(name xml.Name) bool {
+		for _, n := range stack {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errMalformed
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name == rdfDescription {
+				for _, attr := range t.Attr {
+					if attr.Name == xmpRating {
+						x.Rating = parseRating(attr.Value)
+					}
+				}
+			}
+			if t.Name == rdfLi && within(dcTitle) {
+				lang = ""
+				for _, attr := range t.Attr {
+					if attr.Name.Space == nsXML && attr.Name.Local == "lang" {
+						lang = attr.Value
+					}
+				}
+			}
+			stack = append(stack, t.Name)
+			text.Reset()
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, errMalformed
+			}
+			stack = stack[:len(stack)-1]
+			value := strings.TrimSpace(text.String())
+			switch {
+			case t.Name == xmpRating:
+				x.Rating = parseRating(value)
+			case t.Name == rdfLi && within(dcSubject) && value != "":
+				x.Keywords = append(x.Keywords, value)
+			case t.Name == rdfLi && within(dcTitle):
+				// the default language wins over any other title
+				if x.Title == "" || (lang == "x-default" && !titleDefault) {
+					x.Title = value
+					titleDefault = lang == "x-default"
+				}
+			}
+			text.Reset()
+		}
+	}
+	return x, nil
+}
+
+// readJPEGXMP parses the XMP segment, extended XMP split over several segments is not supported
+func readJPEGXMP(r io.ReaderAt, data int64, length int64, m *Metadata) {
+	segment := make([]byte, length)
+	if _, err := r.ReadAt(segment, data); err != nil || !bytes.HasPrefix(segment, []byte(xmpJPEGHeader)) {
+		return
+	}
+	if x, err := ParseXMP(segment[len(xmpJPEGHeader):]); err == nil {
+		m.XMP = x
+	}
+}
+
+// sidecarCandidates are the names desktop tools give sidecars: IMG_0001.xmp by Lightroom
+// and most others, IMG_0001.CR2.xmp by darktable
+func sidecarCandidates(mediaPath string) []string {
+	base := strings.TrimSuffix(mediaPath, filepath.Ext(mediaPath))
+	return []string{base + ".xmp", base + ".XMP", mediaPath + ".xmp", mediaPath + ".XMP"}
+}
+
+// FindSidecar returns the path of the XMP sidecar of the media file, or an empty string
+func FindSidecar(mediaPath string) string {
+	for _, candidate := range sidecarCandidates(mediaPath) {
+		if fi, err := os.Stat(candidate); err == nil && fi.Mode().IsRegular() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// IsSidecar tells whether the file name looks like an XMP sidecar
+func IsSidecar(name string) bool {
+	return strings.EqualFold(filepath.Ext(name), ".xmp")
+}
+
+// SidecarOwners lists media files in the directory of the sidecar it may belong to
+func SidecarOwners(sidecarPath string) []string {
+	base := strings.TrimSuffix(sidecarPath, filepath.Ext(sidecarPath))
+	if KindForName(base) != "" {
+		return []string{base}
+	}
+	matches, _ := filepath.Glob(globEscape(base) + ".*")
+	owners := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if KindForName(match) != "" && strings.TrimSuffix(match, filepath.Ext(match)) == base {
+			owners = append(owners, match)
+		}
+	}
+	return owners
+}
+
+func globEscape(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ReadSidecar parses the sidecar of the media file, nil if there is none
+func ReadSidecar(mediaPath string) (*XMP, error) {
+	sidecarPath := FindSidecar(mediaPath)
+	if sidecarPath == "" {
+		return nil, nil
+	}
+	packet, err := ioutil.ReadFile(sidecarPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseXMP(packet)
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// description renders the properties as an rdf:Description declaring every namespace it uses,
+// so that it is valid whatever prefixes the rest of the packet has
+func (x *XMP) description() string {
+	var b strings.Builder
+	b.WriteString(`  <rdf:Description rdf:about="" xmlns:rdf="` + nsRDF + `" xmlns:xmp="` + nsXMP + `" xmlns:dc="` + nsDC + "\">\n")
+	if x.Rating != 0 {
+		b.WriteString("   <xmp:Rating>" + strconv.Itoa(x.Rating) + "</xmp:Rating>\n")
+	}
+	if x.Title != "" {
+		b.WriteString(`   <dc:title><rdf:Alt><rdf:li xml:lang="x-default">` + escapeXML(x.Title) + "</rdf:li></rdf:Alt></dc:title>\n")
+	}
+	if len(x.Keywords) > 0 {
+		b.WriteString("   <dc:subject><rdf:Bag>")
+		for _, keyword := range x.Keywords {
+			b.WriteString("<rdf:li>" + escapeXML(keyword) + "</rdf:li>")
+		}
+		b.WriteString("</rdf:Bag></dc:subject>\n")
+	}
+	b.WriteString("  </rdf:Description>\n")
+	return b.String()
+}
+
+func (x *XMP) packet() []byte {
+	return []byte("<?xpacket begin=\"\ufeff\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n" + `<x:xmpmeta xmlns:x="adobe:ns:meta/">
+ <rdf:RDF xmlns:rdf="` + nsRDF + `">
+` + x.description() + ` </rdf:RDF>
+</x:xmpmeta>
+<?xpacket end="w"?>
+`)
+}
+
+// updatePacket replaces the properties of x in an existing packet and keeps everything
+// else desktop tools stored there, e.g. develop settings, byte for byte
+func (x *XMP) updatePacket(packet []byte) ([]byte, error) {
+	type span struct{ start, end int64 }
+	var drop []span
+	var attrEdits []span // start tags of rdf:Description with properties as attributes
+	prefixes := make(map[string]bool)
+	insertAt := int64(-1)
+
+	decoder := xml.NewDecoder(bytes.NewReader(packet))
+	var stack []xml.Name
+	var propertyStart int64
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errMalformed
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" && attr.Value == nsXMP {
+					prefixes[attr.Name.Local] = true
+				}
+			}
+			if t.Name == rdfDescription {
+				for _, attr := range t.Attr {
+					if attr.Name == xmpRating {
+						attrEdits = append(attrEdits, span{start, decoder.InputOffset()})
+					}
+				}
+			}
+			if len(stack) > 0 && stack[len(stack)-1] == rdfDescription {
+				propertyStart = start
+			}
+			stack = append(stack, t.Name)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 && stack[len(stack)-1] == rdfDescription {
+				if t.Name == xmpRating || t.Name == dcTitle || t.Name == dcSubject {
+					drop = append(drop, span{propertyStart, decoder.InputOffset()})
+				}
+			}
+			if t.Name == rdfRDF && insertAt < 0 {
+				insertAt = start
+			}
+		}
+	}
+	if insertAt < 0 {
+		return nil, errMalformed
+	}
+
+	prefixList := make([]string, 0, len(prefixes))
+	for prefix := range prefixes {
+		prefixList = append(prefixList, regexp.QuoteMeta(prefix))
+	}
+	ratingAttr := regexp.MustCompile(`\s+(` + strings.Join(prefixList, "|") + `):Rating\s*=\s*("[^"]*"|'[^']*')`)
+
+	var out bytes.Buffer
+	offset := int64(0)
+	copyTo := func(end int64) {
+		out.Write(packet[offset:end])
+		offset = end
+	}
+	// spans do not overlap: attributes are in start tags of descriptions, dropped spans are inside
+	for len(drop) > 0 || len(attrEdits) > 0 {
+		switch {
+		case len(attrEdits) > 0 && (len(drop) == 0 || attrEdits[0].start < drop[0].start):
+			e := attrEdits[0]
+			attrEdits = attrEdits[1:]
+			copyTo(e.start)
+			out.Write(ratingAttr.ReplaceAll(packet[e.start:e.end], nil))
+			offset = e.end
+		default:
+			d := drop[0]
+			drop = drop[1:]
+			copyTo(d.start)
+			offset = d.end
+		}
+		if offset > insertAt {
+			return nil, errMalformed
+		}
+	}
+	copyTo(insertAt)
+	out.WriteString(x.description())
+	copyTo(int64(len(packet)))
+	return out.Bytes(), nil
+}
+
+// WriteSidecar stores the properties in the sidecar of the media file. An existing sidecar
+// is updated in place, otherwise a new one is created next to the file.
+func WriteSidecar(mediaPath string, x *XMP) error {
+	sidecarPath := FindSidecar(mediaPath)
+	var packet []byte
+	mode := os.FileMode(0644)
+	if sidecarPath != "" {
+		existing, err := ioutil.ReadFile(sidecarPath)
+		if err != nil {
+			return err
+		}
+		if fi, err := os.Stat(sidecarPath); err == nil {
+			mode = fi.Mode().Perm()
+		}
+		if packet, err = x.updatePacket(existing); err != nil {
+			return err
+		}
+	} else {
+		sidecarPath = sidecarCandidates(mediaPath)[0]
+		packet = x.packet()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(sidecarPath), ".xmp-")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(packet)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), sidecarPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// lightroomXMP is shaped after what Lightroom writes: simple properties as attributes,
+// develop settings which have to survive an update
+const lightroomXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/" x:xmptk="Adobe XMP Core 5.6-c140">
+ <rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+  <rdf:Description rdf:about=""
+    xmlns:xap="http://ns.adobe.com/xap/1.0/"
+    xmlns:dc="http://purl.org/dc/elements/1.1/"
+    xmlns:crs="http://ns.adobe.com/camera-raw-settings/1.0/"
+   xap:Rating="4"
+   crs:Exposure2012="+0.35">
+   <dc:title>
+    <rdf:Alt>
+     <rdf:li xml:lang="de">Sonnenuntergang</rdf:li>
+     <rdf:li xml:lang="x-default">Sunset</rdf:li>
+    </rdf:Alt>
+   </dc:title>
+   <dc:subject>
+    <rdf:Bag>
+     <rdf:li>beach</rdf:li>
+     <rdf:li>family &amp; friends</rdf:li>
+    </rdf:Bag>
+   </dc:subject>
+  </rdf:Description>
+ </rdf:RDF>
+</x:xmpmeta>
+`
+
+func TestParseXMP(t *testing.T) {
+	x, err := ParseXMP([]byte(lightroomXMP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &XMP{Rating: 4, Title: "Sunset", Keywords: []string{"beach", "family & friends"}}
+	if !reflect.DeepEqual(x, expected) {
+		t.Errorf("parsed %+v", x)
+	}
+
+	if _, err := ParseXMP([]byte("<x:xmpmeta><rdf:RDF>")); err == nil {
+		t.Errorf("truncated packet parsed")
+	}
+}
+
+func TestUpdateXMP(t *testing.T) {
+	x := &XMP{Rating: RatingRejected, Title: "<Dusk>", Keywords: []string{"sea"}}
+	packet, err := x.updatePacket([]byte(lightroomXMP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(packet), `crs:Exposure2012="+0.35"`) {
+		t.Errorf("develop settings are lost:\n%s", packet)
+	}
+	parsed, err := ParseXMP(packet)
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, packet)
+	}
+	if !reflect.DeepEqual(parsed, x) {
+		t.Errorf("parsed back %+v:\n%s", parsed, packet)
+	}
+
+	parsed, err = ParseXMP((&XMP{}).packet())
+	if err != nil || !reflect.DeepEqual(parsed, &XMP{}) {
+		t.Errorf("empty packet parsed into %+v, %v", parsed, err)
+	}
+}
+
+func TestSidecar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	raw := path.Join(dir, "IMG_0001.CR2")
+	ioutil.WriteFile(raw, []byte("raw"), 0644)
+	if x, err := ReadSidecar(raw); x != nil || err != nil {
+		t.Errorf("missing sidecar read as %+v, %v", x, err)
+	}
+
+	x := &XMP{Rating: 2, Keywords: []string{"a", "b"}}
+	if err := WriteSidecar(raw, x); err != nil {
+		t.Fatal(err)
+	}
+	if FindSidecar(raw) != path.Join(dir, "IMG_0001.xmp") {
+		t.Errorf("sidecar is '%s'", FindSidecar(raw))
+	}
+	x.Title = "Updated"
+	if err := WriteSidecar(raw, x); err != nil {
+		t.Fatal(err)
+	}
+	if parsed, err := ReadSidecar(raw); err != nil || !reflect.DeepEqual(parsed, x) {
+		t.Errorf("sidecar read as %+v, %v", parsed, err)
+	}
+
+	if owners := SidecarOwners(FindSidecar(raw)); !reflect.DeepEqual(owners, []string{raw}) {
+		t.Errorf("owners %v", owners)
+	}
+}
+
+func TestReadJPEGXMP(t *testing.T) {
+	var encoded bytes.Buffer
+	if err := jpeg.Encode(&encoded, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+	payload := append([]byte(xmpJPEGHeader), lightroomXMP...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	content := append(append(append([]byte{}, encoded.Bytes()[:2]...), append(segment, payload...)...), encoded.Bytes()[2:]...)
+
+	dir, err := ioutil.TempDir("", "xmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	p := path.Join(dir, "photo.jpg")
+	ioutil.WriteFile(p, content, 0644)
+
+	m, err := ReadMetadata(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Width != 8 || m.XMP == nil || m.XMP.Rating != 4 {
+		t.Fatalf("metadata %+v, xmp %+v", m, m.XMP)
+	}
+
+	// the sidecar takes precedence over the embedded packet
+	WriteSidecar(p, &XMP{Rating: 1})
+	if m, _ := ReadMetadata(p); m.XMP == nil || m.XMP.Rating != 1 || m.XMP.Title != "" {
+		t.Errorf("sidecar ignored, xmp %+v", m.XMP)
+	}
+}
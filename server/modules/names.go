@@ -0,0 +1,9 @@
+package modules
+
+import "strings"
+
+// ValidItemName tells whether a file or directory can be given the name, which has to stay
+// a single component of a path so that nothing is made outside of the parent directory
+func ValidItemName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, "/\x00")
+}
@@ -0,0 +1,69 @@
+package modules
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Permissions a directory grants to users and groups on itself and on what is below it
+const (
+	PermRead   = 1 << iota // see, list, search and download
+	PermWrite              // upload, make directories, move items in
+	PermDelete             // delete, move or rename
+	PermShare              // see and change who may do what
+
+	PermNone = 0
+	PermAll  = PermRead | PermWrite | PermDelete | PermShare
+)
+
+var permissionNames = []string{"read", "write", "delete", "share"}
+
+// ParsePermissions reads a comma separated list of permission names, an empty list is none
+func ParsePermissions(s string) (int, bool) {
+	perms := PermNone
+	if s == "" {
+		return perms, true
+	}
+	for _, name := range strings.Split(s, ",") {
+		found := false
+		for i, n := range permissionNames {
+			if strings.TrimSpace(name) == n {
+				perms |= 1 << uint(i)
+				found = true
+			}
+		}
+		if !found {
+			return 0, false
+		}
+	}
+	return perms, true
+}
+
+// PermissionNames lists the names of the permissions
+func PermissionNames(perms int) []string {
+	names := make([]string, 0, len(permissionNames))
+	for i, n := range permissionNames {
+		if perms&(1<<uint(i)) != 0 {
+			names = append(names, n)
+		}
+	}
+	return names
+}
+
+// principals are who permissions are granted to, "user:<name>" or "group:<name>"
+var validPrincipal = regexp.MustCompile(`^(user|group):[A-Za-z0-9_][A-Za-z0-9_.-]{0,63}$`)
+
+// ValidPrincipal tells whether permissions can be granted to the principal
+func ValidPrincipal(principal string) bool {
+	return validPrincipal.MatchString(principal)
+}
+
+// UserPrincipal is the principal of the user
+func UserPrincipal(name string) string {
+	return "user:" + name
+}
+
+// GroupPrincipal is the principal of the group
+func GroupPrincipal(name string) string {
+	return "group:" + name
+}
@@ -0,0 +1,133 @@
+package photos
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+const (
+	optCmdAlbums          = "albums"
+	optCmdAlbum           = "album"
+	optCmdCreateAlbum     = "createAlbum"
+	optCmdRenameAlbum     = "renameAlbum"
+	optCmdAddToAlbum      = "addToAlbum"
+	optCmdRemoveFromAlbum = "removeFromAlbum"
+	optCmdReorderAlbum    = "reorderAlbum"
+	optCmdSetAlbumCover   = "setAlbumCover"
+	optPhotoID            = "photoId"
+)
+
+// writeError answers with the code carried by modules.HandlerError, or 500 for anything else
+func writeError(w http.ResponseWriter, err error) {
+	if handlerErr, ok := err.(modules.HandlerError); ok {
+		w.WriteHeader(handlerErr.Code())
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// readPhotoIDs decodes a JSON array of photo IDs from the request body
+func readPhotoIDs(r *http.Request) ([]int64, bool) {
+	var photoIDs []int64
+	if err := json.NewDecoder(r.Body).Decode(&photoIDs); err != nil {
+		return nil, false
+	}
+	return photoIDs, true
+}
+
+func (p *photos) getAlbums(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+	writeJSON(w, http.StatusOK, p.photosDB.GetAlbums(requestUser(r), offset, count))
+}
+
+func (p *photos) getAlbum(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !owns(r, p.photosDB.GetAlbumOwner(id)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+	writeJSON(w, http.StatusOK, p.photosDB.GetAlbumPhotos(id, offset, count))
+}
+
+func (p *photos) createAlbum(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, err := p.photosDB.CreateAlbum(requestUser(r), opts.Get(optName))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, p.photosDB.GetAlbumWithID(id))
+}
+
+func (p *photos) updateAlbum(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !owns(r, p.photosDB.GetAlbumOwner(id)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var err error
+	switch opts.Get(optCmd) {
+	case optCmdRenameAlbum:
+		err = p.photosDB.RenameAlbum(id, opts.Get(optName))
+	case optCmdSetAlbumCover:
+		photoID, convErr := strconv.ParseInt(opts.Get(optPhotoID), 10, 64)
+		if convErr != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		err = p.photosDB.SetAlbumCover(id, photoID)
+	default:
+		photoIDs, ok := readPhotoIDs(r)
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch opts.Get(optCmd) {
+		case optCmdAddToAlbum:
+			err = p.photosDB.AddPhotosToAlbum(id, photoIDs)
+		case optCmdRemoveFromAlbum:
+			err = p.photosDB.RemovePhotosFromAlbum(id, photoIDs)
+		case optCmdReorderAlbum:
+			err = p.photosDB.ReorderAlbum(id, photoIDs)
+		}
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.photosDB.GetAlbumWithID(id))
+}
+
+func (p *photos) deleteAlbum(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !owns(r, p.photosDB.GetAlbumOwner(id)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err := p.photosDB.DeleteAlbum(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,176 @@
+package photos
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/akokshar/storage/server/modules/imaging"
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+const (
+	optCmdDuplicates        = "duplicates"
+	optCmdResolveDuplicates = "resolveDuplicates"
+	optDistance             = "distance"
+	optAction               = "action"
+	actionMerge             = "merge"
+	actionTrash             = "trash"
+	// bits two hashes may differ in to be listed as duplicates, beyond the max
+	// different pictures of the same scene group together
+	duplicatesDefaultDistance = 10
+	duplicatesMaxDistance     = 20
+	phashBatchSize            = 100
+	// trashed duplicates, indexBaseDir skips dot directories
+	trashDir = ".trash"
+)
+
+// perceptualHash decodes the photo as it is displayed, an empty hash marks
+// photos which cannot be decoded so that they are not tried again
+func perceptualHash(photoPath string) string {
+	f, err := os.Open(photoPath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return ""
+	}
+	if meta, err := media.ReadMetadata(photoPath); err == nil && meta != nil {
+		img = imaging.Orient(img, meta.Orientation)
+	}
+	return fmt.Sprintf("%016x", imaging.PerceptualHash(img))
+}
+
+// updatePerceptualHashes hashes photos added since the last listing
+func (p *photos) updatePerceptualHashes() {
+	p.phashLock.Lock()
+	defer p.phashLock.Unlock()
+
+	for {
+		ids := p.photosDB.GetPhotosWithoutPerceptualHash(phashBatchSize)
+		if len(ids) == 0 {
+			return
+		}
+		for _, id := range ids {
+			hash := ""
+			if photoPath, err := p.filesDB.GetPathForID(id); err == nil {
+				hash = perceptualHash(photoPath)
+			}
+			if err := p.photosDB.SetPhotoPerceptualHash(id, hash); err != nil {
+				log.Printf("Failed to store perceptual hash of %d: %v", id, err)
+				return
+			}
+		}
+	}
+}
+
+func (p *photos) getDuplicates(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	distance := intOpt(opts, optDistance, duplicatesDefaultDistance)
+	if distance > duplicatesMaxDistance {
+		distance = duplicatesMaxDistance
+	}
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+
+	p.updatePerceptualHashes()
+	writeJSON(w, http.StatusOK, p.photosDB.GetDuplicateGroups(requestUser(r), distance, offset, count))
+}
+
+// trashTo moves files into the trash directory of the user, where they can be recovered
+// from by hand
+func trashTo(root *userRoot) func(string) error {
+	return func(filePath string) error {
+		return trashFile(filepath.Join(root.path, trashDir), filePath)
+	}
+}
+
+func trashFile(dir string, filePath string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	name := filepath.Base(filePath)
+	trashPath := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(trashPath); os.IsNotExist(err) {
+			break
+		}
+		ext := filepath.Ext(name)
+		trashPath = filepath.Join(dir, fmt.Sprintf("%s-%d%s", name[:len(name)-len(ext)], i, ext))
+	}
+	return os.Rename(filePath, trashPath)
+}
+
+// resolveDuplicates keeps the asset given by id. Assets listed in the body either become
+// its resources or go to the trash, their album memberships move to the kept asset in both cases.
+func (p *photos) resolveDuplicates(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	keepID, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	action := opts.Get(optAction)
+	if action != actionMerge && action != actionTrash {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ids, ok := readPhotoIDs(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.ownsPhoto(r, keepID) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	root, err := p.userRoot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	keep := make(map[int64]bool)
+	for _, resourceID := range p.photosDB.GetAssetResources(keepID) {
+		keep[resourceID] = true
+	}
+	trashed := make([]int64, 0)
+	for _, id := range ids {
+		if !p.ownsPhoto(r, id) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if action != actionTrash || keep[id] {
+			continue
+		}
+		for _, resourceID := range p.photosDB.GetAssetResources(id) {
+			// files indexed from sources are owned by the files module
+			if p.photosDB.GetSourceOfPhoto(resourceID) != 0 {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			if !keep[resourceID] {
+				keep[resourceID] = true
+				trashed = append(trashed, resourceID)
+			}
+		}
+	}
+
+	if err := p.photosDB.MergePhotos(keepID, ids); err != nil {
+		writeError(w, err)
+		return
+	}
+	for i := len(trashed) - 1; i >= 0; i-- {
+		if err := p.removeResource(trashed[i], trashTo(root)); err != nil {
+			log.Printf("Failed to trash %d: %v", trashed[i], err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, p.photosDB.GetPhotoWithID(keepID))
+}
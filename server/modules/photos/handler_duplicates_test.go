@@ -0,0 +1,163 @@
+package photos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestResolveDuplicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// picture is white with the right or the bottom half black
+	picture := func(side int, vertical bool) []byte {
+		img := image.NewGray(image.Rect(0, 0, side, side))
+		for y := 0; y < side; y++ {
+			for x := 0; x < side; x++ {
+				if (vertical && y < side/2) || (!vertical && x < side/2) {
+					img.SetGray(x, y, color.Gray{Y: 255})
+				}
+			}
+		}
+		var b bytes.Buffer
+		png.Encode(&b, img)
+		return b.Bytes()
+	}
+	os.MkdirAll(path.Join(dir, "files/alice/pics"), 0755)
+	ioutil.WriteFile(path.Join(dir, "files/alice/pics/s.png"), picture(32, false), 0644)
+
+	p, request := newTestPhotos(dir)
+	p.filesDB.ScanPath(path.Join(dir, "files"))
+	aliceFiles, _ := p.filesDB.GetIDForPath(path.Join(dir, "files/alice"))
+	pics, _ := p.filesDB.GetIDForPath(path.Join(dir, "files/alice/pics"))
+	if err := p.filesDB.SetOwner(aliceFiles, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if w := request("alice", "POST", fmt.Sprintf("/photos?cmd=addSource&id=%d", pics), nil); w.Code != http.StatusCreated {
+		t.Fatalf("alice indexes their files: %d", w.Code)
+	}
+	source, _ := p.filesDB.GetIDForPath(path.Join(dir, "files/alice/pics/s.png"))
+
+	ids := make(map[string]int64)
+	for name, content := range map[string][]byte{
+		"x.png": picture(64, false),
+		"y.png": picture(32, false),
+		"z.png": picture(48, false),
+		"v.png": picture(64, true),
+	} {
+		var photo struct {
+			ID int64 `json:"id"`
+		}
+		w := request("alice", "POST", "/photos?name="+name, bytes.NewReader(content))
+		if err := json.Unmarshal(w.Body.Bytes(), &photo); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("alice uploads %s: %d", name, w.Code)
+		}
+		ids[name] = photo.ID
+	}
+	x, y, z, v := ids["x.png"], ids["y.png"], ids["z.png"], ids["v.png"]
+
+	// the largest copy is suggested to keep, other pictures are not copies
+	var duplicates struct {
+		Groups []struct {
+			Keep   int64 `json:"keep"`
+			Photos []struct {
+				ID int64 `json:"id"`
+			} `json:"photos"`
+		} `json:"groups"`
+	}
+	w := request("alice", "GET", "/photos?cmd=duplicates", nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &duplicates); err != nil {
+		t.Fatalf("%d %s", w.Code, w.Body.String())
+	}
+	if len(duplicates.Groups) != 1 {
+		t.Fatalf("duplicates: %+v", duplicates.Groups)
+	}
+	found := make(map[int64]bool)
+	for _, pm := range duplicates.Groups[0].Photos {
+		found[pm.ID] = true
+	}
+	if g := duplicates.Groups[0]; g.Keep != x || g.Photos[0].ID != x || g.Photos[1].ID != z || len(found) != 4 || !found[y] || !found[source] {
+		t.Fatalf("duplicates: %+v", g)
+	}
+
+	var album struct {
+		ID int64 `json:"id"`
+	}
+	json.Unmarshal(request("alice", "POST", "/photos?cmd=createAlbum&name=trip", nil).Body.Bytes(), &album)
+	request("alice", "POST", fmt.Sprintf("/photos?cmd=addToAlbum&id=%d", album.ID), strings.NewReader(fmt.Sprintf("[%d, %d]", y, v)))
+
+	resolve := func(action string, keep int64, ids ...int64) int {
+		body, _ := json.Marshal(ids)
+		return request("alice", "POST", fmt.Sprintf("/photos?cmd=resolveDuplicates&action=%s&id=%d", action, keep), bytes.NewReader(body)).Code
+	}
+	status := func(id int64) int {
+		return request("alice", "GET", fmt.Sprintf("/photos?id=%d&cmd=info", id), nil).Code
+	}
+
+	// files of sources are left to the files module
+	if code := resolve("trash", x, source); code != http.StatusForbidden {
+		t.Errorf("trash from a source: %d", code)
+	}
+	if code := resolve("delete", x, y); code != http.StatusBadRequest {
+		t.Errorf("unknown action: %d", code)
+	}
+	if code := resolve("trash", x, 999999); code != http.StatusNotFound {
+		t.Errorf("unknown photo: %d", code)
+	}
+	if _, err := os.Stat(path.Join(dir, "files/alice/pics/s.png")); err != nil {
+		t.Errorf("source file: %v", err)
+	}
+
+	// trashed copies are kept in the trash of the user, their albums go to the photo kept
+	if code := resolve("trash", x, y); code != http.StatusOK {
+		t.Fatalf("trash: %d", code)
+	}
+	if code := status(y); code != http.StatusNotFound {
+		t.Errorf("trashed photo: %d", code)
+	}
+	if _, err := os.Stat(path.Join(dir, "photos/alice/y.png")); err == nil {
+		t.Errorf("trashed file is left")
+	}
+	if content, err := ioutil.ReadFile(path.Join(dir, "photos/alice", trashDir, "y.png")); err != nil || !bytes.Equal(content, picture(32, false)) {
+		t.Errorf("trash: %v", err)
+	}
+	if code := status(x); code != http.StatusOK {
+		t.Errorf("kept photo: %d", code)
+	}
+	var content struct {
+		Photos []struct {
+			ID int64 `json:"id"`
+		} `json:"photos"`
+	}
+	json.Unmarshal(request("alice", "GET", fmt.Sprintf("/photos?cmd=album&id=%d", album.ID), nil).Body.Bytes(), &content)
+	if len(content.Photos) != 2 || content.Photos[0].ID != x || content.Photos[1].ID != v {
+		t.Errorf("album: %+v", content.Photos)
+	}
+
+	// merged copies stay as files of the photo kept
+	if code := resolve("merge", x, z, source); code != http.StatusOK {
+		t.Fatalf("merge: %d", code)
+	}
+	var info struct {
+		Asset int64 `json:"asset"`
+	}
+	json.Unmarshal(request("alice", "GET", fmt.Sprintf("/photos?id=%d&cmd=info", z), nil).Body.Bytes(), &info)
+	if info.Asset != x {
+		t.Errorf("asset of the merged photo: %d", info.Asset)
+	}
+	if _, err := os.Stat(path.Join(dir, "photos/alice/z.png")); err != nil {
+		t.Errorf("merged file: %v", err)
+	}
+}
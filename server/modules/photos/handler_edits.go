@@ -0,0 +1,179 @@
+package photos
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/akokshar/storage/server/modules/imaging"
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+const (
+	optCmdEdit         = "edit"
+	optRendition       = "rendition"
+	renditionOriginal  = "original"
+	renditionEdited    = "edited"
+	editRecipeMaxBytes = 16 * 1024
+	// rendered edits, indexBaseDir skips dot directories
+	editsCacheDir = ".cache/edits"
+)
+
+// servePhoto sends the original file unless the edited rendition is asked for.
+// Assets without an edit have the original as their edited rendition.
+func (p *photos) servePhoto(w http.ResponseWriter, r *http.Request, id int64, rendition string) {
+	idPath, err := p.filesDB.GetPathForID(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch rendition {
+	case "", renditionOriginal:
+	case renditionEdited:
+		if recipe := p.photosDB.GetPhotoEdit(id); recipe != nil {
+			renderedPath, err := p.renderEdit(id, idPath, recipe)
+			if err != nil {
+				log.Printf("Failed to render edit of '%s': %v", idPath, err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			idPath = renderedPath
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	http.ServeFile(w, r, idPath)
+}
+
+// editCachePath names the rendition after the recipe and the state of the original,
+// so that a changed original or recipe never hits a stale rendition
+func (p *photos) editCachePath(id int64, idPath string, recipe []byte) (string, error) {
+	fi, err := os.Stat(idPath)
+	if err != nil {
+		return "", err
+	}
+	hash := sha1.New()
+	fmt.Fprintf(hash, "%s\n%d\n%d", recipe, fi.Size(), fi.ModTime().UnixNano())
+	return filepath.Join(p.basedir, editsCacheDir, fmt.Sprintf("%d-%x", id, hash.Sum(nil)[:8])), nil
+}
+
+func (p *photos) renderEdit(id int64, idPath string, recipe []byte) (string, error) {
+	cachePath, err := p.editCachePath(id, idPath, recipe)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(cachePath); err == nil {
+		return cachePath, nil
+	}
+
+	r, err := imaging.ParseRecipe(recipe)
+	if err != nil {
+		return "", err
+	}
+	orientation := 0
+	if meta, err := media.ReadMetadata(idPath); err == nil && meta != nil {
+		orientation = meta.Orientation
+	}
+
+	src, err := os.Open(idPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(cachePath), os.ModePerm); err != nil {
+		return "", err
+	}
+	// concurrent requests render into their own files, whichever is renamed last wins
+	tmp, err := ioutil.TempFile(filepath.Dir(cachePath), ".render-")
+	if err != nil {
+		return "", err
+	}
+	_, err = imaging.Render(src, orientation, r, tmp)
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), cachePath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return cachePath, nil
+}
+
+// dropEditCache removes renditions of the asset, they are rendered again on demand
+func (p *photos) dropEditCache(id int64) {
+	cached, _ := filepath.Glob(filepath.Join(p.basedir, editsCacheDir, fmt.Sprintf("%d-*", id)))
+	for _, cachePath := range cached {
+		if err := os.Remove(cachePath); err != nil {
+			log.Printf("Failed to delete '%s' due to '%s'", cachePath, err.Error())
+		}
+	}
+}
+
+func (p *photos) editPhoto(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.ownsPhoto(r, id) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	recipe, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, editRecipeMaxBytes))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	idPath, err := p.filesDB.GetPathForID(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	f, err := os.Open(idPath)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	decodable := imaging.CanDecode(f)
+	f.Close()
+	if !decodable {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err := p.photosDB.SetPhotoEdit(id, recipe); err != nil {
+		writeError(w, err)
+		return
+	}
+	p.dropEditCache(id)
+	writeJSON(w, http.StatusOK, p.photosDB.GetPhotoWithID(id))
+}
+
+func (p *photos) revertPhoto(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.ownsPhoto(r, id) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err := p.photosDB.RemovePhotoEdit(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	p.dropEditCache(id)
+	writeJSON(w, http.StatusOK, p.photosDB.GetPhotoWithID(id))
+}
@@ -0,0 +1,143 @@
+package photos
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEdits(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	_, request := newTestPhotos(dir)
+
+	var original bytes.Buffer
+	png.Encode(&original, image.NewNRGBA(image.Rect(0, 0, 4, 2)))
+	var photo, broken struct {
+		ID int64 `json:"id"`
+	}
+	for name, v := range map[string]interface{}{"a.png": &photo, "b.jpg": &broken} {
+		content := original.String()
+		if name == "b.jpg" {
+			content = "not an image"
+		}
+		w := request("alice", "POST", "/photos?name="+name, strings.NewReader(content))
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil || w.Code != http.StatusCreated {
+			t.Fatalf("alice uploads %s: %d", name, w.Code)
+		}
+	}
+
+	// edit tells the recipe of the photo as the library keeps it
+	edit := func(method string, id int64, recipe string) (int, string) {
+		w := request("alice", method, fmt.Sprintf("/photos?cmd=edit&id=%d", id), strings.NewReader(recipe))
+		var info struct {
+			Edit json.RawMessage `json:"edit"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &info)
+		var compact bytes.Buffer
+		json.Compact(&compact, info.Edit)
+		return w.Code, compact.String()
+	}
+	// rendition tells the size of the image served
+	rendition := func(name string) string {
+		w := request("alice", "GET", fmt.Sprintf("/photos?id=%d&rendition=%s", photo.ID, name), nil)
+		config, _, err := image.DecodeConfig(w.Body)
+		if err != nil {
+			return fmt.Sprintf("%d", w.Code)
+		}
+		return fmt.Sprintf("%dx%d", config.Width, config.Height)
+	}
+	cached := func() int {
+		found, _ := filepath.Glob(path.Join(dir, "photos", editsCacheDir, fmt.Sprintf("%d-*", photo.ID)))
+		return len(found)
+	}
+
+	// an edit leaves the original as it is and is rendered on demand
+	if status, recipe := edit("POST", photo.ID, `{"rotate": -270}`); status != http.StatusOK || recipe != `{"rotate":90}` {
+		t.Errorf("rotated: %d %s", status, recipe)
+	}
+	if size := rendition("edited"); size != "2x4" {
+		t.Errorf("edited: %s", size)
+	}
+	if size := rendition("original"); size != "4x2" {
+		t.Errorf("original: %s", size)
+	}
+	if n := cached(); n != 1 {
+		t.Errorf("rendered: %d", n)
+	}
+	if content, _ := ioutil.ReadFile(path.Join(dir, "photos/alice/a.png")); !bytes.Equal(content, original.Bytes()) {
+		t.Errorf("original changed")
+	}
+
+	// another edit is rendered again
+	if status, recipe := edit("POST", photo.ID, `{"crop": {"x": 0, "y": 0, "width": 0.5, "height": 1}}`); status != http.StatusOK || recipe != `{"crop":{"x":0,"y":0,"width":0.5,"height":1}}` {
+		t.Errorf("cropped: %d %s", status, recipe)
+	}
+	if size := rendition("edited"); size != "2x2" {
+		t.Errorf("edited again: %s", size)
+	}
+
+	// edits which can not be applied leave the one there is
+	cases := []struct {
+		context string
+		user    string
+		target  string
+		recipe  string
+		status  int
+	}{
+		{"rotated by less than a right angle", "alice", fmt.Sprintf("/photos?cmd=edit&id=%d", photo.ID), `{"rotate": 45}`, http.StatusBadRequest},
+		{"cropped outside", "alice", fmt.Sprintf("/photos?cmd=edit&id=%d", photo.ID), `{"crop": {"x": 0.5, "y": 0, "width": 1, "height": 1}}`, http.StatusBadRequest},
+		{"unknown adjustment", "alice", fmt.Sprintf("/photos?cmd=edit&id=%d", photo.ID), `{"sharpen": 1}`, http.StatusBadRequest},
+		{"not a recipe", "alice", fmt.Sprintf("/photos?cmd=edit&id=%d", photo.ID), `rotate`, http.StatusBadRequest},
+		{"too long", "alice", fmt.Sprintf("/photos?cmd=edit&id=%d", photo.ID), `{"rotate": 90` + strings.Repeat(" ", editRecipeMaxBytes) + `}`, http.StatusBadRequest},
+		{"not decodable", "alice", fmt.Sprintf("/photos?cmd=edit&id=%d", broken.ID), `{"rotate": 90}`, http.StatusUnsupportedMediaType},
+		{"no photo", "alice", "/photos?cmd=edit", `{"rotate": 90}`, http.StatusBadRequest},
+		{"of others", "bob", fmt.Sprintf("/photos?cmd=edit&id=%d", photo.ID), `{"rotate": 90}`, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if w := request(c.user, "POST", c.target, strings.NewReader(c.recipe)); w.Code != c.status {
+			t.Errorf("%s: %d", c.context, w.Code)
+		}
+	}
+	if w := request("bob", "DELETE", fmt.Sprintf("/photos?cmd=edit&id=%d", photo.ID), nil); w.Code != http.StatusNotFound {
+		t.Errorf("bob reverts: %d", w.Code)
+	}
+	if size := rendition("edited"); size != "2x2" {
+		t.Errorf("edited after rejected edits: %s", size)
+	}
+	if size := rendition("thumbnail"); size != "400" {
+		t.Errorf("unknown rendition: %s", size)
+	}
+
+	// reverting goes back to the original and drops what was rendered
+	if status, recipe := edit("DELETE", photo.ID, ""); status != http.StatusOK || recipe != "" {
+		t.Errorf("reverted: %d %s", status, recipe)
+	}
+	if n := cached(); n != 0 {
+		t.Errorf("rendered after reverting: %d", n)
+	}
+	if size := rendition("edited"); size != "4x2" {
+		t.Errorf("edited after reverting: %s", size)
+	}
+	if status, _ := edit("DELETE", photo.ID, ""); status != http.StatusOK {
+		t.Errorf("reverted again: %d", status)
+	}
+
+	// an edit which changes nothing is no edit
+	edit("POST", photo.ID, `{"fliph": true}`)
+	if status, recipe := edit("POST", photo.ID, `{"rotate": 360}`); status != http.StatusOK || recipe != "" {
+		t.Errorf("rotated all around: %d %s", status, recipe)
+	}
+}
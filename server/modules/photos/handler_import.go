@@ -0,0 +1,394 @@
+package photos
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akokshar/storage/server/modules"
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+const (
+	optCmdImport        = "import"
+	optPath             = "path"
+	optLayout           = "layout"
+	importDefaultLayout = "YYYY/MM/DD"
+	// files are received there first, indexBaseDir skips dot directories
+	importTmpDir = ".cache/import"
+
+	importStatusImported = "imported"
+	importStatusSkipped  = "skipped"
+	importStatusFailed   = "failed"
+)
+
+var (
+	errNotADirectory = errors.New("not a directory")
+	errBadName       = errors.New("not a name of a file")
+)
+
+type importItem struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	ID     int64  `json:"id,omitempty"` // the new photo, or the one a skipped file duplicates
+	Reason string `json:"reason,omitempty"`
+}
+
+type importReport struct {
+	Imported int           `json:"imported"`
+	Skipped  int           `json:"skipped"`
+	Failed   int           `json:"failed"`
+	Items    []*importItem `json:"items"`
+}
+
+func (r *importReport) add(item *importItem) {
+	switch item.Status {
+	case importStatusImported:
+		r.Imported++
+	case importStatusSkipped:
+		r.Skipped++
+	default:
+		r.Failed++
+	}
+	r.Items = append(r.Items, item)
+}
+
+// parseLayout splits a layout like YYYY/MM/DD into directory names, YYYY, MM and DD are
+// replaced with the capture date, anything else is taken literally
+func parseLayout(layout string) ([]string, bool) {
+	if layout == "" {
+		layout = importDefaultLayout
+	}
+	components := strings.Split(strings.Trim(layout, "/"), "/")
+	for _, c := range components {
+		if c == "" || strings.HasPrefix(c, ".") || strings.ContainsAny(c, `\`) {
+			return nil, false
+		}
+	}
+	return components, true
+}
+
+func layoutDir(components []string, t time.Time) string {
+	r := strings.NewReplacer("YYYY", t.Format("2006"), "MM", t.Format("01"), "DD", t.Format("02"))
+	dirs := make([]string, len(components))
+	for i, c := range components {
+		dirs[i] = r.Replace(c)
+	}
+	return path.Join(dirs...)
+}
+
+func hashFile(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findDuplicate returns the photo of the owner with the same content, hashes of photos which
+// may match are computed on the way and kept for the next imports
+func (p *photos) findDuplicate(owner string, size int64, hash string, exclude int64) int64 {
+	for _, id := range p.photosDB.GetPhotoIDsWithSize(owner, size) {
+		if id == exclude {
+			continue
+		}
+		photoHash := p.photosDB.GetPhotoHash(id)
+		if photoHash == "" {
+			photoPath, err := p.filesDB.GetPathForID(id)
+			if err != nil {
+				continue
+			}
+			if photoHash, err = hashFile(photoPath); err != nil {
+				continue
+			}
+			p.photosDB.SetPhotoHash(id, photoHash)
+		}
+		if photoHash == hash {
+			return id
+		}
+	}
+	return 0
+}
+
+// ensureDir returns the ID of the directory relative to the root, creating what is missing
+func (p *photos) ensureDir(root *userRoot, relPath string) (int64, error) {
+	parentID := root.id
+	dirPath := root.path
+	for _, name := range strings.Split(relPath, "/") {
+		dirPath = filepath.Join(dirPath, name)
+		if id, err := p.filesDB.GetIDForPath(dirPath); err == nil {
+			parentID = id
+			continue
+		}
+
+		id, err := p.makeDir(parentID, dirPath)
+		if err != nil {
+			return 0, err
+		}
+		parentID = id
+	}
+	return parentID, nil
+}
+
+// makeDir makes the directory in the parent known to FilesDB, a directory which is there
+// on disk already is taken as it is
+func (p *photos) makeDir(parentID int64, dirPath string) (int64, error) {
+	if err := os.Mkdir(dirPath, 0755); err != nil && !os.IsExist(err) {
+		return 0, err
+	}
+	if fi, err := os.Stat(dirPath); err != nil || !fi.IsDir() {
+		return 0, errNotADirectory
+	}
+	id, err := p.filesDB.CreateItemPlaceholder(parentID, filepath.Base(dirPath))
+	if err != nil {
+		return 0, err
+	}
+	if createdPath, err := p.filesDB.GetPathForID(id); err != nil || createdPath != dirPath {
+		p.filesDB.DeleteItemPlaceholder(id)
+		return 0, errNotADirectory
+	}
+	if err := p.filesDB.ImportItem(id, dirPath); err != nil {
+		p.filesDB.DeleteItemPlaceholder(id)
+		return 0, err
+	}
+	return id, nil
+}
+
+// importFile files the content into the layout below the root unless the library of the user
+// already has it. replaces is the ID of the file the content comes from when it is in the
+// basedir already.
+func (p *photos) importFile(root *userRoot, name string, content io.Reader, mtime time.Time, layout []string, replaces int64) *importItem {
+	item := &importItem{Name: name}
+	fail := func(reason string, err error) *importItem {
+		log.Printf("Failed to import '%s': %v", name, err)
+		item.Status, item.Reason = importStatusFailed, reason
+		return item
+	}
+	// names come from archives as they were written, the file has to stay in its directory
+	if !modules.ValidItemName(name) {
+		return fail("name", errBadName)
+	}
+
+	tmpDir := filepath.Join(root.path, importTmpDir)
+	if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
+		return fail("storage", err)
+	}
+	// the extension tells readMetadata the kind of the file
+	tmp, err := ioutil.TempFile(tmpDir, ".import-*"+strings.ToLower(filepath.Ext(name)))
+	if err != nil {
+		return fail("storage", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), content)
+	tmp.Close()
+	if err != nil {
+		return fail("read", err)
+	}
+	// archives made without times have them at the epoch, capture time is better guessed as now
+	if mtime.Unix() > 0 {
+		os.Chtimes(tmpPath, mtime, mtime)
+	}
+
+	if id := p.findDuplicate(root.user, size, hex.EncodeToString(h.Sum(nil)), replaces); id != 0 {
+		item.Status, item.ID, item.Reason = importStatusSkipped, id, "duplicate"
+		return item
+	}
+
+	fi, err := os.Stat(tmpPath)
+	if err != nil {
+		return fail("storage", err)
+	}
+	meta := readMetadata(tmpPath, fi)
+	dirID, err := p.ensureDir(root, layoutDir(layout, meta.CaptureTime))
+	if err != nil {
+		return fail("storage", err)
+	}
+
+	id, err := p.filesDB.CreateItemPlaceholder(dirID, name)
+	if err != nil {
+		return fail("storage", err)
+	}
+	filePath, err := p.filesDB.GetPathForID(id)
+	if err == nil {
+		err = os.Rename(tmpPath, filePath)
+	}
+	if err == nil {
+		err = p.filesDB.ImportItem(id, filePath)
+	}
+	if err == nil {
+		err = p.photosDB.AddPhoto(id, 0, meta)
+	}
+	if err != nil {
+		p.filesDB.DeleteItemPlaceholder(id)
+		if filePath != "" {
+			os.Remove(filePath)
+		}
+		return fail("storage", err)
+	}
+	p.photosDB.SetPhotoHash(id, hex.EncodeToString(h.Sum(nil)))
+
+	item.Status, item.ID = importStatusImported, id
+	return item
+}
+
+// importArchive imports media files of a tar archive, compressed with gzip or not,
+// whatever directories they are in
+func (p *photos) importArchive(root *userRoot, body io.Reader, layout []string) (*importReport, error) {
+	br := bufio.NewReader(body)
+	var archive *tar.Reader
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		archive = tar.NewReader(gz)
+	} else {
+		archive = tar.NewReader(br)
+	}
+
+	report := &importReport{Items: make([]*importItem, 0)}
+	for entries := 0; ; entries++ {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if entries == 0 {
+				return nil, err
+			}
+			report.add(&importItem{Status: importStatusFailed, Reason: "truncated archive"})
+			break
+		}
+
+		name := path.Base(header.Name)
+		if header.Typeflag != tar.TypeReg || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if media.KindForName(name) == "" {
+			report.add(&importItem{Name: name, Status: importStatusSkipped, Reason: "unsupported"})
+			continue
+		}
+		report.add(p.importFile(root, name, archive, header.ModTime, layout, 0))
+	}
+	return report, nil
+}
+
+// importDir imports media files found below a directory of the root. Imported files move
+// into the layout, skipped ones stay where they are.
+func (p *photos) importDir(root *userRoot, dirPath string, layout []string) *importReport {
+	files := make([]string, 0)
+	filepath.Walk(dirPath, func(walkPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(fi.Name(), ".") && walkPath != dirPath {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if fi.Mode().IsRegular() {
+			files = append(files, walkPath)
+		}
+		return nil
+	})
+
+	report := &importReport{Items: make([]*importItem, 0)}
+	for _, filePath := range files {
+		name := filepath.Base(filePath)
+		if media.KindForName(name) == "" {
+			report.add(&importItem{Name: name, Status: importStatusSkipped, Reason: "unsupported"})
+			continue
+		}
+
+		fi, err := os.Stat(filePath)
+		if err != nil {
+			report.add(&importItem{Name: name, Status: importStatusFailed, Reason: "read"})
+			continue
+		}
+		f, err := os.Open(filePath)
+		if err != nil {
+			report.add(&importItem{Name: name, Status: importStatusFailed, Reason: "read"})
+			continue
+		}
+		id, err := p.filesDB.GetIDForPath(filePath)
+		if err != nil {
+			id = 0
+		}
+		item := p.importFile(root, name, f, fi.ModTime(), layout, id)
+		f.Close()
+		report.add(item)
+
+		if item.Status != importStatusImported {
+			continue
+		}
+		if id != 0 {
+			if err := p.deleteResource(id); err != nil {
+				log.Printf("Failed to remove imported '%s': %v", filePath, err)
+			}
+		} else if err := os.Remove(filePath); err != nil {
+			log.Printf("Failed to remove imported '%s': %v", filePath, err)
+		}
+	}
+	return report
+}
+
+func (p *photos) importPhotos(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	layout, ok := parseLayout(opts.Get(optLayout))
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	root, err := p.userRoot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	p.importLock.Lock()
+	defer p.importLock.Unlock()
+
+	if opts.Get(optPath) == "" {
+		report, err := p.importArchive(root, r.Body, layout)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	// users import what is in their own directory only, the path is relative to it
+	dirPath := filepath.Join(root.path, opts.Get(optPath))
+	if !isUnder(dirPath, root.path) || dirPath == root.path {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if fi, err := os.Stat(dirPath); err != nil || !fi.IsDir() {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, p.importDir(root, dirPath, layout))
+}
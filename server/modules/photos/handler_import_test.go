@@ -0,0 +1,121 @@
+package photos
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, request := newTestPhotos(dir)
+	rootOf := func(user string) *userRoot {
+		r := httptest.NewRequest("POST", "/photos", nil)
+		r.Header.Set("X-Authenticated-User", user)
+		root, err := p.userRoot(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+	alice, bob := rootOf("alice"), rootOf("bob")
+	layout, _ := parseLayout("")
+	mtime := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	// ensureDir makes what is missing once
+	dayID, err := p.ensureDir(alice, "2020/05/01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := p.ensureDir(alice, "2020/05/01"); err != nil || again != dayID {
+		t.Errorf("ensureDir again: %d %v, want %d", again, err, dayID)
+	}
+	if dayPath, _ := p.filesDB.GetPathForID(dayID); dayPath != path.Join(alice.path, "2020/05/01") {
+		t.Errorf("ensureDir: %s", dayPath)
+	}
+	ioutil.WriteFile(path.Join(alice.path, "2021"), []byte("x"), 0644)
+	if _, err := p.ensureDir(alice, "2021/01/01"); err != errNotADirectory {
+		t.Errorf("ensureDir over a file: %v", err)
+	}
+
+	item := p.importFile(alice, "a.jpg", strings.NewReader("a"), mtime, layout, 0)
+	if item.Status != importStatusImported {
+		t.Fatalf("import: %+v", item)
+	}
+	if itemPath, _ := p.filesDB.GetPathForID(item.ID); itemPath != path.Join(alice.path, "2020/05/01/a.jpg") {
+		t.Errorf("import: %s", itemPath)
+	}
+	// the same content is skipped whatever its name, in the library of the user only
+	if dup := p.importFile(alice, "b.jpg", strings.NewReader("a"), mtime, layout, 0); dup.Status != importStatusSkipped || dup.ID != item.ID {
+		t.Errorf("duplicate: %+v", dup)
+	}
+	if other := p.importFile(bob, "a.jpg", strings.NewReader("a"), mtime, layout, 0); other.Status != importStatusImported {
+		t.Errorf("import of bob: %+v", other)
+	}
+
+	// a directory in the way of the file fails the import, nothing is left behind
+	os.MkdirAll(path.Join(alice.path, "2020/05/01/c.jpg/x"), 0755)
+	if failed := p.importFile(alice, "c.jpg", strings.NewReader("c"), mtime, layout, 0); failed.Status != importStatusFailed {
+		t.Errorf("import into a directory: %+v", failed)
+	}
+	if id, err := p.filesDB.GetIDForPath(path.Join(alice.path, "2020/05/01/c.jpg")); err == nil {
+		t.Errorf("placeholder %d is left", id)
+	}
+	if tmp, _ := ioutil.ReadDir(path.Join(alice.path, importTmpDir)); len(tmp) != 0 {
+		t.Errorf("%d temporary files are left", len(tmp))
+	}
+	os.RemoveAll(path.Join(alice.path, "2020/05/01/c.jpg"))
+	// names of archive entries stay in the directory of the layout
+	for _, name := range []string{"..", "../../bob/d.jpg", "d\x00.jpg"} {
+		if failed := p.importFile(alice, name, strings.NewReader("d"), mtime, layout, 0); failed.Status != importStatusFailed || failed.Reason != "name" {
+			t.Errorf("import of %q: %+v", name, failed)
+		}
+	}
+
+	// importDir moves what it imports into the layout
+	inbox := path.Join(alice.path, "inbox")
+	os.MkdirAll(inbox, 0755)
+	for name, content := range map[string]string{"d.jpg": "d", "dup.jpg": "a", "notes.txt": "n", ".e.jpg": "e"} {
+		ioutil.WriteFile(path.Join(inbox, name), []byte(content), 0644)
+		os.Chtimes(path.Join(inbox, name), mtime, mtime)
+	}
+	report := p.importDir(alice, inbox, layout)
+	if report.Imported != 1 || report.Skipped != 2 || report.Failed != 0 {
+		t.Errorf("importDir: %+v", report)
+	}
+	left, _ := ioutil.ReadDir(inbox)
+	if len(left) != 3 {
+		t.Errorf("importDir left %d files", len(left))
+	}
+	if _, err := os.Stat(path.Join(alice.path, "2020/05/01/d.jpg")); err != nil {
+		t.Errorf("importDir: %v", err)
+	}
+
+	// users import from their own directory only
+	cases := []struct {
+		user   string
+		path   string
+		status int
+	}{
+		{"alice", "inbox", http.StatusOK},
+		{"alice", ".", http.StatusForbidden},
+		{"alice", "../bob", http.StatusForbidden},
+		{"bob", "../alice/inbox", http.StatusForbidden},
+		{"bob", "/alice/inbox", http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if w := request(c.user, "POST", "/photos?cmd=import&path="+c.path, nil); w.Code != c.status {
+			t.Errorf("%s imports %s: %d", c.user, c.path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package photos
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	optCmdOnThisDay = "onThisDay"
+	optDate         = "date"
+	optWindow       = "window"
+	// days around the date, windows of neighbouring years must not overlap
+	onThisDayMaxWindow = 30
+)
+
+// getOnThisDay lists photos of the same calendar day in previous years. The date is
+// YYYY-MM-DD, or MM-DD in the current year, and defaults to today on the server clock.
+func (p *photos) getOnThisDay(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if date := opts.Get(optDate); date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			// 2000 is a leap year, so that 02-29 parses
+			if parsed, err = time.Parse("2006-01-02", "2000-"+date); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			// a leap day falls on February 28 in common years
+			parsed = time.Date(day.Year(), parsed.Month(), parsed.Day(), 0, 0, 0, 0, time.UTC)
+			if parsed.Month() == time.March && date == "02-29" {
+				parsed = parsed.AddDate(0, 0, -1)
+			}
+		}
+		day = parsed
+	}
+
+	window := 0
+	if value := opts.Get(optWindow); value != "" {
+		var err error
+		if window, err = strconv.Atoi(value); err != nil || window < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	if window > onThisDayMaxWindow {
+		window = onThisDayMaxWindow
+	}
+	count := intOpt(opts, optCount, optCountDefaultValue)
+	writeJSON(w, http.StatusOK, p.photosDB.GetPhotosOnThisDay(requestUser(r), day, window, count))
+}
@@ -0,0 +1,34 @@
+package photos
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestOnThisDayOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	_, request := newTestPhotos(dir)
+	cases := []struct {
+		query  string
+		status int
+	}{
+		{"", http.StatusOK},
+		{"&date=02-29&window=3", http.StatusOK},
+		{"&window=365", http.StatusOK},
+		{"&window=-1", http.StatusBadRequest},
+		{"&window=week", http.StatusBadRequest},
+		{"&date=02-30", http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if w := request("alice", "GET", "/photos?cmd=onThisDay"+c.query, nil); w.Code != c.status {
+			t.Errorf("%q: %d", c.query, w.Code)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package photos
+
+import (
+	"net/http"
+	"net/url"
+)
+
+const (
+	optCmdMoments = "moments"
+	optCmdMoment  = "moment"
+)
+
+func (p *photos) getMoments(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+	writeJSON(w, http.StatusOK, p.photosDB.GetMoments(requestUser(r), offset, count))
+}
+
+func (p *photos) getMoment(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !owns(r, p.photosDB.GetMomentOwner(id)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+	writeJSON(w, http.StatusOK, p.photosDB.GetMomentPhotos(id, offset, count))
+}
@@ -1,22 +1,81 @@
 package photos
 
 import (
+	"encoding/json"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/akokshar/storage/server/modules"
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+const (
+	optCmd                = "cmd"
+	optCmdTimeline        = "timeline"
+	optCmdListChanges     = "list"
+	optCmdInfo            = "info"
+	optID                 = "id"
+	optName               = "name"
+	optAnchor             = "anchor"
+	optAnchorDefaultValue = 0
+	optOffset             = "offset"
+	optOffsetDefaultValue = 0
+	optCount              = "count"
+	optCountDefaultValue  = 50
+)
+
+var (
+	errForbidden = modules.NewHandlerErrorWithCode(http.StatusForbidden).(error)
+	// the directory named like the user was not made for them
+	errRootTaken = modules.NewHandlerErrorWithCode(http.StatusConflict).(error)
 )
 
 type photos struct {
-	routePrefix string
-	basedir     string
+	routePrefix    string
+	basedir        string
+	sourcesBaseDir string
+	filesDB        modules.FilesDB
+	photosDB       modules.PhotosDB
+	rootID         int64
+	sourcesLock    sync.Mutex
+	importLock     sync.Mutex
+	phashLock      sync.Mutex
+	rootsLock      sync.Mutex
 }
 
-// New initializes backend to server files
-func New(prefix string, basedir string) modules.HTTPHandler {
+// userRoot is the directory of the basedir the photos of a user go to
+type userRoot struct {
+	id   int64
+	path string
+	user string
+}
+
+// New initializes backend to serve the photo library. Directories under sourcesBaseDir
+// can be added to the library as sources, their media is indexed in place.
+func New(filesDB modules.FilesDB, photosDB modules.PhotosDB, prefix string, basedir string, sourcesBaseDir string) modules.HTTPHandler {
+	if err := os.MkdirAll(basedir, os.ModePerm); err != nil {
+		log.Printf("Failed to create '%s': %v", basedir, err)
+	}
+
 	p := &photos{
-		routePrefix: prefix,
-		basedir:     basedir,
+		routePrefix:    prefix,
+		basedir:        basedir,
+		sourcesBaseDir: sourcesBaseDir,
+		filesDB:        filesDB,
+		photosDB:       photosDB,
+		rootID:         filesDB.ScanPath(basedir),
 	}
+	p.indexBaseDir()
+	p.indexSources()
+	go p.syncSourcesForever()
 
 	return p
 }
@@ -30,5 +89,412 @@ func (p *photos) GetBaseDir() string {
 }
 
 func (p *photos) ServeHTTPRequest(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusNotImplemented)
+	// the library of every user is their own, nobody sees photos nobody owns
+	if requestUser(r) == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		p.getPhoto(w, r)
+	case http.MethodPost:
+		p.postPhotos(w, r)
+	case http.MethodDelete:
+		p.deletePhotos(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func requestUser(r *http.Request) string {
+	return r.Header.Get("X-Authenticated-User")
+}
+
+// owns tells whether the user of the request owns what belongs to owner. Unknown photos,
+// albums and sources have no owner.
+func owns(r *http.Request, owner string) bool {
+	return owner != "" && strings.EqualFold(owner, requestUser(r))
+}
+
+func requestGroups(r *http.Request) []string {
+	if groups := r.Header.Get("X-Authenticated-Groups"); groups != "" {
+		return strings.Split(groups, ",")
+	}
+	return nil
+}
+
+// readable tells whether the user of the request may see the photo. Its file decides as it
+// does in the files module: the user owns it or the directories above it grant them read.
+func (p *photos) readable(r *http.Request, id int64) bool {
+	if !p.photosDB.HasPhoto(id) {
+		return false
+	}
+	if owner, _ := p.filesDB.GetOwner(id); owns(r, owner) {
+		return true
+	}
+	return p.filesDB.GetPermissions(id, requestUser(r), requestGroups(r))&modules.PermRead != 0
+}
+
+// ownsPhoto tells whether the photo is in the library of the user of the request and its
+// file is still readable to them
+func (p *photos) ownsPhoto(r *http.Request, id int64) bool {
+	return owns(r, p.photosDB.GetPhotoOwner(id)) && p.readable(r, id)
+}
+
+// userRoot finds the directory of the user of the request, making it on first use. A directory
+// named like the user which is there already is not taken over, the administrator hands it over.
+func (p *photos) userRoot(r *http.Request) (*userRoot, error) {
+	name := requestUser(r)
+	if name == "" {
+		return nil, errForbidden
+	}
+	root := &userRoot{path: path.Join(p.basedir, name), user: name}
+	var err error
+	if root.id, err = p.filesDB.GetIDForPath(root.path); err == nil && p.isRootOf(root.id, name) {
+		return root, nil
+	}
+
+	p.rootsLock.Lock()
+	defer p.rootsLock.Unlock()
+	if root.id, err = p.filesDB.GetIDForPath(root.path); err == nil {
+		if !p.isRootOf(root.id, name) {
+			return nil, errRootTaken
+		}
+		return root, nil
+	}
+	if _, err := os.Lstat(root.path); err == nil {
+		return nil, errRootTaken
+	}
+	if root.id, err = p.makeDir(p.rootID, root.path); err != nil {
+		return nil, err
+	}
+	if err := p.filesDB.SetOwner(root.id, name); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (p *photos) isRootOf(id int64, name string) bool {
+	owner, rootID := p.filesDB.GetOwner(id)
+	return rootID == id && strings.EqualFold(owner, name)
+}
+
+// indexBaseDir adds files that are in the basedir but not yet in the library, e.g. copied there by hand
+func (p *photos) indexBaseDir() {
+	filepath.Walk(p.basedir, func(itemPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(fi.Name(), ".") && itemPath != p.basedir {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() || media.KindForName(fi.Name()) == "" {
+			return nil
+		}
+
+		id, err := p.filesDB.GetIDForPath(itemPath)
+		if err != nil || p.photosDB.HasPhoto(id) {
+			return nil
+		}
+		if err := p.photosDB.AddPhoto(id, 0, readMetadata(itemPath, fi)); err != nil {
+			log.Printf("Failed to index '%s': %v", itemPath, err)
+		}
+		return nil
+	})
+}
+
+// readMetadata never fails, whatever is missing in the content is taken from the file system
+func readMetadata(itemPath string, fi os.FileInfo) *media.Metadata {
+	meta, err := media.ReadMetadata(itemPath)
+	if err != nil {
+		log.Printf("Incomplete metadata for '%s': %v", itemPath, err)
+	}
+	if meta == nil {
+		meta = &media.Metadata{Kind: media.KindForName(itemPath)}
+	}
+	if meta.CaptureTime.IsZero() {
+		meta.CaptureTime = fi.ModTime().UTC()
+	}
+	return meta
+}
+
+func parseID(opts url.Values) (int64, bool) {
+	rawID, err := strconv.Atoi(opts.Get(optID))
+	if err != nil {
+		return 0, false
+	}
+	return int64(rawID), true
+}
+
+func intOpt(opts url.Values, name string, defaultValue int) int {
+	value, err := strconv.Atoi(opts.Get(name))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	if data == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dataJSON, _ := json.MarshalIndent(data, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(dataJSON)
+}
+
+func (p *photos) getPhoto(w http.ResponseWriter, r *http.Request) {
+	opts, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch opts.Get(optCmd) {
+	case optCmdTimeline:
+		offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+		count := intOpt(opts, optCount, optCountDefaultValue)
+		writeJSON(w, http.StatusOK, p.photosDB.GetTimeline(requestUser(r), offset, count))
+		return
+	case optCmdListChanges:
+		anchor := intOpt(opts, optAnchor, optAnchorDefaultValue)
+		count := intOpt(opts, optCount, optCountDefaultValue)
+		writeJSON(w, http.StatusOK, p.photosDB.GetChangesSince(requestUser(r), int64(anchor), count))
+		return
+	case optCmdAlbums:
+		p.getAlbums(w, r, opts)
+		return
+	case optCmdAlbum:
+		p.getAlbum(w, r, opts)
+		return
+	case optCmdSources:
+		p.getSources(w, r)
+		return
+	case optCmdSmartAlbums:
+		p.getSmartAlbums(w, r, opts)
+		return
+	case optCmdSmartAlbum:
+		p.getSmartAlbum(w, r, opts)
+		return
+	case optCmdPlaces:
+		p.getPlaces(w, r, opts)
+		return
+	case optCmdPlace:
+		p.getPlace(w, r, opts)
+		return
+	case optCmdMoments:
+		p.getMoments(w, r, opts)
+		return
+	case optCmdMoment:
+		p.getMoment(w, r, opts)
+		return
+	case optCmdDuplicates:
+		p.getDuplicates(w, r, opts)
+		return
+	case optCmdOnThisDay:
+		p.getOnThisDay(w, r, opts)
+		return
+	}
+
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	// photos shared through the files module can be seen by ID
+	if !p.readable(r, id) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch opts.Get(optCmd) {
+	case optCmdInfo:
+		writeJSON(w, http.StatusOK, p.photosDB.GetPhotoWithID(id))
+	default:
+		p.servePhoto(w, r, id, opts.Get(optRendition))
+	}
+}
+
+func (p *photos) postPhotos(w http.ResponseWriter, r *http.Request) {
+	opts, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch opts.Get(optCmd) {
+	case "":
+		p.createPhoto(w, r, opts)
+	case optCmdCreateAlbum:
+		p.createAlbum(w, r, opts)
+	case optCmdAddSource:
+		p.addSource(w, r, opts)
+	case optCmdCreateSmartAlbum:
+		p.createSmartAlbum(w, r, opts)
+	case optCmdEdit:
+		p.editPhoto(w, r, opts)
+	case optCmdSetInfo:
+		p.setInfo(w, r, opts)
+	case optCmdImport:
+		p.importPhotos(w, r, opts)
+	case optCmdResolveDuplicates:
+		p.resolveDuplicates(w, r, opts)
+	case optCmdUpdateSmartAlbum:
+		p.updateSmartAlbum(w, r, opts)
+	case optCmdRenameAlbum, optCmdSetAlbumCover, optCmdAddToAlbum, optCmdRemoveFromAlbum, optCmdReorderAlbum:
+		p.updateAlbum(w, r, opts)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (p *photos) deletePhotos(w http.ResponseWriter, r *http.Request) {
+	opts, err := url.ParseQuery(r.URL.RawQuery)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch opts.Get(optCmd) {
+	case "":
+		p.deletePhoto(w, r, opts)
+	case optCmdAlbum:
+		p.deleteAlbum(w, r, opts)
+	case optCmdSource:
+		p.deleteSource(w, r, opts)
+	case optCmdSmartAlbum:
+		p.deleteSmartAlbum(w, r, opts)
+	case optCmdEdit:
+		p.revertPhoto(w, r, opts)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (p *photos) createPhoto(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	name := filepath.Base(opts.Get(optName))
+	if name == "." || name == "/" || strings.HasPrefix(name, ".") {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if media.KindForName(name) == "" {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	root, err := p.userRoot(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	id, err := p.filesDB.CreateItemPlaceholder(root.id, name)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	filePath, err := p.filesDB.GetPathForID(id)
+	if err != nil {
+		p.filesDB.DeleteItemPlaceholder(id)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := p.storeUpload(id, filePath, r.Body); err != nil {
+		log.Printf("Failed to store '%s': %v", filePath, err)
+		p.filesDB.DeleteItemPlaceholder(id)
+		os.Remove(filePath)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, p.photosDB.GetPhotoWithID(id))
+}
+
+func (p *photos) storeUpload(id int64, filePath string, body io.Reader) error {
+	nf, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(nf, body)
+	nf.Close()
+	if err != nil {
+		return err
+	}
+
+	if err := p.filesDB.ImportItem(id, filePath); err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+	return p.photosDB.AddPhoto(id, 0, readMetadata(filePath, fi))
+}
+
+func (p *photos) deletePhoto(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.ownsPhoto(r, id) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// files indexed from sources are owned by the files module
+	if p.photosDB.GetSourceOfPhoto(id) != 0 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	// resources of an asset go together, companions first so they do not show up on their own
+	resources := p.photosDB.GetAssetResources(id)
+	for i := len(resources) - 1; i >= 0; i-- {
+		if err := p.deleteResource(resources[i]); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (p *photos) deleteResource(id int64) error {
+	return p.removeResource(id, os.Remove)
+}
+
+// removeResource takes the file out of the library, dispose gets rid of the file itself
+// and of its sidecar once nothing else refers to it
+func (p *photos) removeResource(id int64, dispose func(string) error) error {
+	idPath, err := p.filesDB.GetPathForID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := p.photosDB.RemovePhoto(id); err != nil {
+		return err
+	}
+	if err := p.filesDB.RemoveItem(id); err != nil {
+		return err
+	}
+
+	if err := dispose(idPath); err != nil {
+		log.Printf("Failed to delete '%s' due to '%s'", idPath, err.Error())
+	}
+	// a sidecar goes with the last file it describes
+	if sidecarPath := media.FindSidecar(idPath); sidecarPath != "" && len(media.SidecarOwners(sidecarPath)) == 0 {
+		if err := dispose(sidecarPath); err != nil {
+			log.Printf("Failed to delete '%s' due to '%s'", sidecarPath, err.Error())
+		}
+	}
+	p.dropEditCache(id)
+	return nil
 }
@@ -0,0 +1,234 @@
+package photos
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/akokshar/storage/server/modules"
+	"github.com/akokshar/storage/server/modules/filesdb"
+	"github.com/akokshar/storage/server/modules/photosdb"
+)
+
+// newTestPhotos serves the library in dir/photos, requests are sent as the server would
+func newTestPhotos(dir string) (*photos, func(user, method, target string, body io.Reader) *httptest.ResponseRecorder) {
+	dbFile := path.Join(dir, ".meta.db")
+	filesDB := filesdb.NewFilesDB(dbFile)
+	p := New(filesDB, photosdb.NewPhotosDB(dbFile, filesDB), "/photos", path.Join(dir, "photos"), path.Join(dir, "files")).(*photos)
+	request := func(user, method, target string, body io.Reader) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, body)
+		r.Header.Set("X-Authenticated-User", user)
+		w := httptest.NewRecorder()
+		p.ServeHTTPRequest(w, r)
+		return w
+	}
+	return p, request
+}
+
+func TestLibrariesApart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// stored before there were users
+	os.MkdirAll(path.Join(dir, "photos/carol"), 0755)
+	ioutil.WriteFile(path.Join(dir, "photos/old.jpg"), []byte("old"), 0644)
+	os.MkdirAll(path.Join(dir, "files/alice/pics"), 0755)
+
+	p, request := newTestPhotos(dir)
+	p.filesDB.ScanPath(path.Join(dir, "files"))
+	aliceFiles, _ := p.filesDB.GetIDForPath(path.Join(dir, "files/alice"))
+	pics, _ := p.filesDB.GetIDForPath(path.Join(dir, "files/alice/pics"))
+	if err := p.filesDB.SetOwner(aliceFiles, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	decode := func(w *httptest.ResponseRecorder, v interface{}) {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%d %s", w.Code, w.Body.String())
+		}
+	}
+	var photo, album struct {
+		ID int64 `json:"id"`
+	}
+
+	w := request("alice", "POST", "/photos?name=a.jpg", strings.NewReader("a"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("alice uploads: %d", w.Code)
+	}
+	decode(w, &photo)
+	w = request("alice", "POST", "/photos?cmd=createAlbum&name=trip", nil)
+	decode(w, &album)
+	if w := request("alice", "POST", fmt.Sprintf("/photos?cmd=addToAlbum&id=%d", album.ID), strings.NewReader(fmt.Sprintf("[%d]", photo.ID))); w.Code != http.StatusOK {
+		t.Fatalf("alice fills the album: %d", w.Code)
+	}
+	var moments struct {
+		Moments []struct {
+			ID int64 `json:"id"`
+		} `json:"moments"`
+		Size int64 `json:"size"`
+	}
+	decode(request("alice", "GET", "/photos?cmd=moments", nil), &moments)
+	if moments.Size != 1 {
+		t.Fatalf("moments of alice: %d", moments.Size)
+	}
+	if w := request("bob", "POST", fmt.Sprintf("/photos?cmd=addSource&id=%d", pics), nil); w.Code != http.StatusNotFound {
+		t.Errorf("bob indexes the files of alice: %d", w.Code)
+	}
+	if w := request("alice", "POST", fmt.Sprintf("/photos?cmd=addSource&id=%d", pics), nil); w.Code != http.StatusCreated {
+		t.Fatalf("alice indexes their files: %d", w.Code)
+	}
+
+	cases := []struct {
+		user   string
+		method string
+		target string
+		status int
+	}{
+		{"bob", "GET", fmt.Sprintf("/photos?id=%d", photo.ID), http.StatusNotFound},
+		{"bob", "GET", fmt.Sprintf("/photos?id=%d&cmd=info", photo.ID), http.StatusNotFound},
+		{"bob", "DELETE", fmt.Sprintf("/photos?id=%d", photo.ID), http.StatusNotFound},
+		{"bob", "GET", fmt.Sprintf("/photos?cmd=album&id=%d", album.ID), http.StatusNotFound},
+		{"bob", "DELETE", fmt.Sprintf("/photos?cmd=album&id=%d", album.ID), http.StatusNotFound},
+		{"bob", "POST", fmt.Sprintf("/photos?cmd=renameAlbum&id=%d&name=mine", album.ID), http.StatusNotFound},
+		{"bob", "GET", fmt.Sprintf("/photos?cmd=moment&id=%d", moments.Moments[0].ID), http.StatusNotFound},
+		{"bob", "POST", fmt.Sprintf("/photos?cmd=resolveDuplicates&action=trash&id=%d", photo.ID), http.StatusNotFound},
+		{"bob", "DELETE", fmt.Sprintf("/photos?cmd=source&id=%d", pics), http.StatusNotFound},
+		{"", "GET", "/photos?cmd=timeline", http.StatusForbidden},
+		{"alice", "GET", fmt.Sprintf("/photos?id=%d", photo.ID), http.StatusOK},
+		// a directory which is there already is not taken
+		{"carol", "POST", "/photos?name=c.jpg", http.StatusConflict},
+	}
+	for _, c := range cases {
+		if w := request(c.user, c.method, c.target, strings.NewReader("[]")); w.Code != c.status {
+			t.Errorf("%s %s %s: %d", c.user, c.method, c.target, w.Code)
+		}
+	}
+
+	// listings of bob are empty, the photo nobody owns is in none
+	for _, target := range []string{
+		"/photos?cmd=timeline", "/photos?cmd=albums", "/photos?cmd=moments", "/photos?cmd=places",
+		"/photos?cmd=duplicates", "/photos?cmd=list",
+	} {
+		var listing struct {
+			Size int64 `json:"size"`
+		}
+		decode(request("bob", "GET", target, nil), &listing)
+		if listing.Size != 0 {
+			t.Errorf("bob %s: %d", target, listing.Size)
+		}
+	}
+	var sources []interface{}
+	decode(request("bob", "GET", "/photos?cmd=sources", nil), &sources)
+	if len(sources) != 0 {
+		t.Errorf("sources of bob: %d", len(sources))
+	}
+	var timeline struct {
+		Size int64 `json:"size"`
+	}
+	decode(request("alice", "GET", "/photos?cmd=timeline", nil), &timeline)
+	if timeline.Size != 1 {
+		t.Errorf("timeline of alice: %d", timeline.Size)
+	}
+
+	// photos of others do not get into albums
+	w = request("bob", "POST", "/photos?cmd=createAlbum&name=loot", nil)
+	decode(w, &album)
+	w = request("bob", "POST", fmt.Sprintf("/photos?cmd=addToAlbum&id=%d", album.ID), strings.NewReader(fmt.Sprintf("[%d]", photo.ID)))
+	var count struct {
+		Count int64 `json:"count"`
+	}
+	decode(w, &count)
+	if count.Count != 0 {
+		t.Errorf("album of bob: %d", count.Count)
+	}
+
+	if _, err := os.Stat(path.Join(dir, "photos/alice")); err != nil {
+		t.Errorf("directory of alice: %v", err)
+	}
+}
+
+func TestSharedPhotos(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p, request := newTestPhotos(dir)
+	w := request("alice", "POST", "/photos?name=a.jpg", strings.NewReader("a"))
+	var photo struct {
+		ID int64 `json:"id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &photo); err != nil {
+		t.Fatalf("alice uploads: %d", w.Code)
+	}
+	aliceRoot, err := p.filesDB.GetIDForPath(path.Join(dir, "photos/alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared := func(user string, groups string, status int) {
+		r := httptest.NewRequest("GET", fmt.Sprintf("/photos?id=%d", photo.ID), nil)
+		r.Header.Set("X-Authenticated-User", user)
+		r.Header.Set("X-Authenticated-Groups", groups)
+		w := httptest.NewRecorder()
+		p.ServeHTTPRequest(w, r)
+		if w.Code != status {
+			t.Errorf("%s of %q reads the photo: %d", user, groups, w.Code)
+		}
+	}
+	shared("bob", "", http.StatusNotFound)
+	shared("carol", "family", http.StatusNotFound)
+
+	// what the directory of alice grants goes for their photos
+	if err := p.filesDB.SetACL(aliceRoot, "user:bob", modules.PermRead); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.filesDB.SetACL(aliceRoot, "group:family", modules.PermRead); err != nil {
+		t.Fatal(err)
+	}
+	shared("bob", "", http.StatusOK)
+	shared("carol", "family", http.StatusOK)
+	shared("dave", "", http.StatusNotFound)
+	// a grant to the user goes before those to their groups
+	if err := p.filesDB.SetACL(aliceRoot, "user:carol", modules.PermNone); err != nil {
+		t.Fatal(err)
+	}
+	shared("carol", "family", http.StatusNotFound)
+
+	// reading is all a grant gives, the photo stays out of the library of bob
+	cases := []struct {
+		method string
+		target string
+		status int
+	}{
+		{"GET", fmt.Sprintf("/photos?id=%d&cmd=info", photo.ID), http.StatusOK},
+		{"DELETE", fmt.Sprintf("/photos?id=%d", photo.ID), http.StatusNotFound},
+		{"POST", fmt.Sprintf("/photos?cmd=setInfo&id=%d", photo.ID), http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if w := request("bob", c.method, c.target, strings.NewReader("{}")); w.Code != c.status {
+			t.Errorf("bob %s %s: %d", c.method, c.target, w.Code)
+		}
+	}
+	var timeline struct {
+		Size int64 `json:"size"`
+	}
+	json.Unmarshal(request("bob", "GET", "/photos?cmd=timeline", nil).Body.Bytes(), &timeline)
+	if timeline.Size != 0 {
+		t.Errorf("timeline of bob: %d", timeline.Size)
+	}
+
+	if err := p.filesDB.ResetACL(aliceRoot, "user:bob"); err != nil {
+		t.Fatal(err)
+	}
+	shared("bob", "", http.StatusNotFound)
+}
@@ -0,0 +1,74 @@
+package photos
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+const (
+	optCmdPlaces         = "places"
+	optCmdPlace          = "place"
+	optLevel             = "level"
+	optLevelDefaultValue = "city"
+	optLevelPhoto        = "photo"
+	optCountry           = "country"
+	optRegion            = "region"
+	optCity              = "city"
+	optFormat            = "format"
+	optFormatGeoJSON     = "geojson"
+	geoJSONContentType   = "application/geo+json"
+)
+
+var placeLevels = map[string]bool{
+	"country": true,
+	"region":  true,
+	"city":    true,
+}
+
+func levelOpt(opts url.Values) string {
+	if level := opts.Get(optLevel); level != "" {
+		return level
+	}
+	return optLevelDefaultValue
+}
+
+// getPlaces lists places photos were taken at with counts, or exports them as GeoJSON for map views
+func (p *photos) getPlaces(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	level := levelOpt(opts)
+	geoJSON := opts.Get(optFormat) == optFormatGeoJSON
+	if !placeLevels[level] && !(geoJSON && level == optLevelPhoto) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if !geoJSON {
+		offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+		count := intOpt(opts, optCount, optCountDefaultValue)
+		writeJSON(w, http.StatusOK, p.photosDB.GetPlaces(requestUser(r), level, opts.Get(optCountry), opts.Get(optRegion), offset, count))
+		return
+	}
+
+	collection := p.photosDB.GetPlacesGeoJSON(requestUser(r), level, opts.Get(optCountry), opts.Get(optRegion))
+	if collection == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dataJSON, _ := json.Marshal(collection)
+	w.Header().Set("Content-Type", geoJSONContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(dataJSON)
+}
+
+func (p *photos) getPlace(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	level := levelOpt(opts)
+	if !placeLevels[level] || opts.Get(optCountry) == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+	writeJSON(w, http.StatusOK, p.photosDB.GetPlacePhotos(
+		requestUser(r), level, opts.Get(optCountry), opts.Get(optRegion), opts.Get(optCity), offset, count))
+}
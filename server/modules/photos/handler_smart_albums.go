@@ -0,0 +1,100 @@
+package photos
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+const (
+	optCmdSmartAlbums       = "smartAlbums"
+	optCmdSmartAlbum        = "smartAlbum"
+	optCmdCreateSmartAlbum  = "createSmartAlbum"
+	optCmdUpdateSmartAlbum  = "updateSmartAlbum"
+	smartAlbumRulesMaxBytes = 64 * 1024
+)
+
+func readRules(r *http.Request) ([]byte, bool) {
+	rules, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, smartAlbumRulesMaxBytes))
+	if err != nil {
+		return nil, false
+	}
+	return rules, true
+}
+
+func (p *photos) getSmartAlbums(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+	writeJSON(w, http.StatusOK, p.photosDB.GetSmartAlbums(requestUser(r), offset, count))
+}
+
+func (p *photos) getSmartAlbum(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !owns(r, p.photosDB.GetSmartAlbumOwner(id)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	offset := intOpt(opts, optOffset, optOffsetDefaultValue)
+	count := intOpt(opts, optCount, optCountDefaultValue)
+	writeJSON(w, http.StatusOK, p.photosDB.GetSmartAlbumPhotos(id, offset, count))
+}
+
+func (p *photos) createSmartAlbum(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	rules, ok := readRules(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	id, err := p.photosDB.CreateSmartAlbum(requestUser(r), opts.Get(optName), rules)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, p.photosDB.GetSmartAlbumWithID(id))
+}
+
+func (p *photos) updateSmartAlbum(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !owns(r, p.photosDB.GetSmartAlbumOwner(id)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rules, ok := readRules(r)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := p.photosDB.UpdateSmartAlbum(id, opts.Get(optName), rules); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p.photosDB.GetSmartAlbumWithID(id))
+}
+
+func (p *photos) deleteSmartAlbum(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !owns(r, p.photosDB.GetSmartAlbumOwner(id)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err := p.photosDB.DeleteSmartAlbum(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,226 @@
+package photos
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+const (
+	optCmdSources   = "sources"
+	optCmdAddSource = "addSource"
+	optCmdSource    = "source"
+
+	sourcesSyncInterval  = 30 * time.Second
+	sourcesSyncBatchSize = 500
+)
+
+// isUnder tells if itemPath is dir itself or somewhere below it
+func isUnder(itemPath string, dir string) bool {
+	return itemPath == dir || strings.HasPrefix(itemPath, dir+"/")
+}
+
+// indexSources picks up files which FilesDB learnt about by scanning, these are not in the change feed
+func (p *photos) indexSources() {
+	p.sourcesLock.Lock()
+	defer p.sourcesLock.Unlock()
+
+	for sourceID := range p.photosDB.GetSourceSyncAnchors() {
+		if sourcePath, err := p.filesDB.GetPathForID(sourceID); err == nil {
+			p.indexSourceItem(sourceID, sourceID, sourcePath)
+		}
+	}
+
+	if err := p.photosDB.PruneOrphans(); err != nil {
+		log.Printf("Failed to prune photo library: %v", err)
+	}
+}
+
+// syncSourcesForever keeps sources up to date with the files they point to
+func (p *photos) syncSourcesForever() {
+	for range time.Tick(sourcesSyncInterval) {
+		p.syncSources()
+	}
+}
+
+// syncSources follows FilesDB change feed from the last seen anchor of every source
+func (p *photos) syncSources() {
+	p.sourcesLock.Lock()
+	defer p.sourcesLock.Unlock()
+
+	anchors := p.photosDB.GetSourceSyncAnchors()
+	sourcePaths := make([]string, 0, len(anchors))
+	for sourceID := range anchors {
+		if sourcePath, err := p.filesDB.GetPathForID(sourceID); err == nil {
+			sourcePaths = append(sourcePaths, sourcePath)
+		}
+	}
+	underSource := func(itemPath string) bool {
+		for _, sourcePath := range sourcePaths {
+			if isUnder(itemPath, sourcePath) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for sourceID, anchor := range anchors {
+		sourcePath, err := p.filesDB.GetPathForID(sourceID)
+		if err != nil {
+			continue
+		}
+
+		for {
+			changes, err := p.filesDB.GetChangesSince(anchor, sourcesSyncBatchSize)
+			if err != nil {
+				log.Printf("Failed to sync source '%s': %v", sourcePath, err)
+				break
+			}
+			if len(changes) == 0 {
+				break
+			}
+
+			for _, c := range changes {
+				anchor = c.Anchor
+				if c.Erased {
+					if p.photosDB.GetSourceOfPhoto(c.ID) == sourceID {
+						p.photosDB.RemovePhoto(c.ID)
+					}
+					continue
+				}
+
+				itemPath, err := p.filesDB.GetPathForID(c.ID)
+				if err != nil {
+					continue
+				}
+				if !isUnder(itemPath, sourcePath) {
+					// moved out of the library, photos moved to another source stay
+					if p.photosDB.GetSourceOfPhoto(c.ID) == sourceID && !underSource(itemPath) {
+						p.photosDB.RemovePhoto(c.ID)
+					}
+					continue
+				}
+				if media.IsSidecar(itemPath) {
+					p.applySidecar(itemPath)
+					continue
+				}
+				p.indexSourceItem(sourceID, c.ID, itemPath)
+			}
+
+			if err := p.photosDB.SetSourceSyncAnchor(sourceID, anchor); err != nil {
+				log.Printf("Failed to sync source '%s': %v", sourcePath, err)
+				break
+			}
+		}
+	}
+
+	if err := p.photosDB.PruneOrphans(); err != nil {
+		log.Printf("Failed to prune photo library: %v", err)
+	}
+}
+
+// indexSourceItem adds a media file or, for a directory, everything media below it which is
+// not in the library as it is now
+func (p *photos) indexSourceItem(sourceID int64, id int64, itemPath string) {
+	filepath.Walk(itemPath, func(walkPath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(fi.Name(), ".") && walkPath != itemPath {
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() || media.KindForName(fi.Name()) == "" {
+			return nil
+		}
+
+		walkID := id
+		if walkPath != itemPath {
+			if walkID, err = p.filesDB.GetIDForPath(walkPath); err != nil {
+				return nil
+			}
+		}
+		// files written again since they were read have their metadata read again
+		if p.photosDB.HasCurrentPhoto(walkID) {
+			return nil
+		}
+		if err := p.photosDB.AddPhoto(walkID, sourceID, readMetadata(walkPath, fi)); err != nil {
+			log.Printf("Failed to index '%s': %v", walkPath, err)
+		}
+		return nil
+	})
+}
+
+func (p *photos) getSources(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, p.photosDB.GetSources(requestUser(r)))
+}
+
+func (p *photos) addSource(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	sourcePath, err := p.filesDB.GetPathForID(id)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	// users index their own files only
+	if owner, _ := p.filesDB.GetOwner(id); !owns(r, owner) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if !isUnder(sourcePath, p.sourcesBaseDir) || isUnder(sourcePath, p.basedir) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if fi, err := os.Stat(sourcePath); err != nil || !fi.IsDir() {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	p.sourcesLock.Lock()
+	err = p.photosDB.AddSource(id, p.filesDB.GetSyncAnchor())
+	if err == nil {
+		p.indexSourceItem(id, id, sourcePath)
+	}
+	p.sourcesLock.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, p.photosDB.GetSources(requestUser(r)))
+}
+
+func (p *photos) deleteSource(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !owns(r, p.photosDB.GetSourceOwner(id)) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	p.sourcesLock.Lock()
+	err := p.photosDB.RemoveSource(id)
+	p.sourcesLock.Unlock()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,72 @@
+package photos
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestSourceRewritten(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.MkdirAll(path.Join(dir, "files/alice/pics"), 0755)
+	photoPath := path.Join(dir, "files/alice/pics/a.jpg")
+	write := func(content string, mtime time.Time) {
+		ioutil.WriteFile(photoPath, []byte(content), 0644)
+		os.Chtimes(photoPath, mtime, mtime)
+	}
+	before, after := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC), time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	write("a", before)
+
+	p, request := newTestPhotos(dir)
+	p.filesDB.ScanPath(path.Join(dir, "files"))
+	aliceFiles, _ := p.filesDB.GetIDForPath(path.Join(dir, "files/alice"))
+	pics, _ := p.filesDB.GetIDForPath(path.Join(dir, "files/alice/pics"))
+	photo, _ := p.filesDB.GetIDForPath(photoPath)
+	if err := p.filesDB.SetOwner(aliceFiles, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if w := request("alice", "POST", fmt.Sprintf("/photos?cmd=addSource&id=%d", pics), nil); w.Code != http.StatusCreated {
+		t.Fatalf("alice indexes their files: %d", w.Code)
+	}
+	captureDate := func() int64 {
+		var meta struct {
+			CaptureDate int64 `json:"capturedate"`
+		}
+		w := request("alice", "GET", fmt.Sprintf("/photos?id=%d&cmd=info", photo), nil)
+		if err := json.Unmarshal(w.Body.Bytes(), &meta); err != nil {
+			t.Fatalf("%d %s", w.Code, w.Body.String())
+		}
+		return meta.CaptureDate
+	}
+	if got := captureDate(); got != before.Unix() {
+		t.Errorf("indexed: %d", got)
+	}
+	if !p.photosDB.HasCurrentPhoto(photo) {
+		t.Errorf("the photo is not current")
+	}
+
+	// a file written again in place keeps its ID, its metadata is read again
+	write("ab", after)
+	if err := p.filesDB.ImportItem(photo, photoPath); err != nil {
+		t.Fatal(err)
+	}
+	if p.photosDB.HasCurrentPhoto(photo) {
+		t.Errorf("the photo of the rewritten file is current")
+	}
+	p.syncSources()
+	if got := captureDate(); got != after.Unix() {
+		t.Errorf("rewritten: %d, want %d", got, after.Unix())
+	}
+	if !p.photosDB.HasCurrentPhoto(photo) {
+		t.Errorf("the photo read again is not current")
+	}
+}
@@ -0,0 +1,121 @@
+package photos
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+const (
+	optCmdSetInfo   = "setInfo"
+	infoMaxBytes    = 64 * 1024
+	infoMaxKeywords = 256
+)
+
+// infoUpdate is the body of setInfo, properties which are not set stay as they are
+type infoUpdate struct {
+	Rating   *int      `json:"rating"`
+	Title    *string   `json:"title"`
+	Keywords *[]string `json:"keywords"`
+}
+
+// writeSidecars stores the properties where desktop tools look for them: next to the RAW
+// files of the asset, or next to the asset itself if it has no RAW files
+func (p *photos) writeSidecars(id int64, x *media.XMP) error {
+	resources := p.photosDB.GetAssetResources(id)
+	paths := make([]string, 0, len(resources))
+	for _, resourceID := range resources {
+		resourcePath, err := p.filesDB.GetPathForID(resourceID)
+		if err != nil {
+			return err
+		}
+		if media.IsRAW(resourcePath) {
+			paths = append(paths, resourcePath)
+		}
+	}
+	if len(paths) == 0 && len(resources) > 0 {
+		assetPath, err := p.filesDB.GetPathForID(resources[0])
+		if err != nil {
+			return err
+		}
+		paths = append(paths, assetPath)
+	}
+
+	for _, resourcePath := range paths {
+		if err := media.WriteSidecar(resourcePath, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applySidecar updates the library with a sidecar changed by a desktop tool
+func (p *photos) applySidecar(sidecarPath string) {
+	for _, ownerPath := range media.SidecarOwners(sidecarPath) {
+		id, err := p.filesDB.GetIDForPath(ownerPath)
+		if err != nil || !p.photosDB.HasPhoto(id) {
+			continue
+		}
+		x, err := media.ReadSidecar(ownerPath)
+		if err != nil || x == nil {
+			log.Printf("Failed to read sidecar of '%s': %v", ownerPath, err)
+			continue
+		}
+		if err := p.photosDB.SetPhotoXMP(id, x); err != nil {
+			log.Printf("Failed to update '%s' from sidecar: %v", ownerPath, err)
+		}
+	}
+}
+
+func (p *photos) setInfo(w http.ResponseWriter, r *http.Request, opts url.Values) {
+	id, ok := parseID(opts)
+	if !ok {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !p.ownsPhoto(r, id) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var update infoUpdate
+	if err := json.NewDecoder(http.MaxBytesReader(nil, r.Body, infoMaxBytes)).Decode(&update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if update.Keywords != nil && len(*update.Keywords) > infoMaxKeywords {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	x := p.photosDB.GetPhotoXMP(id)
+	if x == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if update.Rating != nil {
+		x.Rating = *update.Rating
+	}
+	if update.Title != nil {
+		x.Title = *update.Title
+	}
+	if update.Keywords != nil {
+		x.Keywords = *update.Keywords
+	}
+
+	if err := p.photosDB.SetPhotoXMP(id, x); err != nil {
+		writeError(w, err)
+		return
+	}
+	// sidecars get what the library stored, e.g. with blank keywords dropped
+	if err := p.writeSidecars(id, p.photosDB.GetPhotoXMP(id)); err != nil {
+		log.Printf("Failed to write sidecar of %d: %v", id, err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p.photosDB.GetPhotoWithID(id))
+}
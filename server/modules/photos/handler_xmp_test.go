@@ -0,0 +1,149 @@
+package photos
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+func TestSidecars(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photos")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	pics := path.Join(dir, "files/alice/pics")
+	os.MkdirAll(pics, 0755)
+	shot := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"b.jpg", "IMG_1.JPG", "IMG_1.CR2"} {
+		ioutil.WriteFile(path.Join(pics, name), []byte("not an image of "+name), 0644)
+		os.Chtimes(path.Join(pics, name), shot, shot)
+	}
+	// what a desktop tool wrote before
+	if err := media.WriteSidecar(path.Join(pics, "b.jpg"), &media.XMP{Rating: 2, Title: "Old", Keywords: []string{"sea"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, request := newTestPhotos(dir)
+	p.filesDB.ScanPath(path.Join(dir, "files"))
+	id := func(name string) int64 {
+		id, err := p.filesDB.GetIDForPath(path.Join(pics, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	aliceFiles, _ := p.filesDB.GetIDForPath(path.Join(dir, "files/alice"))
+	if err := p.filesDB.SetOwner(aliceFiles, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if w := request("alice", "POST", fmt.Sprintf("/photos?cmd=addSource&id=%d", id("")), nil); w.Code != http.StatusCreated {
+		t.Fatalf("alice indexes their files: %d", w.Code)
+	}
+
+	// info tells what the library keeps of the photo as rating:title:keywords
+	info := func(w *httptest.ResponseRecorder) string {
+		var meta struct {
+			Rating   int      `json:"rating"`
+			Title    string   `json:"title"`
+			Keywords []string `json:"keywords"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &meta); err != nil {
+			t.Fatalf("%d %s", w.Code, w.Body.String())
+		}
+		return fmt.Sprintf("%d:%s:%v", meta.Rating, meta.Title, meta.Keywords)
+	}
+	stored := func(name string) string {
+		w := request("alice", "GET", fmt.Sprintf("/photos?id=%d&cmd=info", id(name)), nil)
+		return info(w)
+	}
+	setInfo := func(name string, update string) string {
+		w := request("alice", "POST", fmt.Sprintf("/photos?cmd=setInfo&id=%d", id(name)), strings.NewReader(update))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: %d", name, update, w.Code)
+		}
+		return info(w)
+	}
+	sidecar := func(name string) string {
+		x, err := media.ReadSidecar(path.Join(pics, name))
+		if err != nil || x == nil {
+			return fmt.Sprintf("none %v", err)
+		}
+		return fmt.Sprintf("%d:%s:%v", x.Rating, x.Title, x.Keywords)
+	}
+
+	// sidecars are read with the photo
+	if got := stored("b.jpg"); got != "2:Old:[sea]" {
+		t.Errorf("indexed: %s", got)
+	}
+
+	// changes go to the library and to the sidecar there is, what is not set stays
+	if got := setInfo("b.jpg", `{"rating": 4, "keywords": ["sea", " ", "boat "]}`); got != "4:Old:[sea boat]" {
+		t.Errorf("set: %s", got)
+	}
+	if got := stored("b.jpg"); got != "4:Old:[sea boat]" {
+		t.Errorf("stored: %s", got)
+	}
+	if got := sidecar("b.jpg"); got != "4:Old:[sea boat]" {
+		t.Errorf("written: %s", got)
+	}
+	if _, err := os.Stat(path.Join(pics, "b.jpg.xmp")); err == nil {
+		t.Errorf("another sidecar is made")
+	}
+
+	// assets with RAW files have the sidecar next to them, all resources take the change
+	if got := setInfo("IMG_1.JPG", `{"title": "Pair"}`); got != "0:Pair:[]" {
+		t.Errorf("set on the asset: %s", got)
+	}
+	if got := sidecar("IMG_1.CR2"); got != "0:Pair:[]" {
+		t.Errorf("written for the RAW file: %s", got)
+	}
+	if _, err := os.Stat(path.Join(pics, "IMG_1.JPG.xmp")); err == nil {
+		t.Errorf("sidecar next to the still")
+	}
+	if got := stored("IMG_1.CR2"); got != "0:Pair:[]" {
+		t.Errorf("stored for the RAW file: %s", got)
+	}
+
+	// changes which can not be stored leave what there is
+	for _, c := range []struct {
+		context string
+		user    string
+		update  string
+		status  int
+	}{
+		{"too good", "alice", `{"rating": 6}`, http.StatusBadRequest},
+		{"worse than rejected", "alice", `{"rating": -2}`, http.StatusBadRequest},
+		{"not an update", "alice", `rating`, http.StatusBadRequest},
+		{"too many keywords", "alice", `{"keywords": ["k` + strings.Repeat(`", "k`, infoMaxKeywords) + `"]}`, http.StatusBadRequest},
+		{"of others", "bob", `{"rating": 1}`, http.StatusNotFound},
+	} {
+		if w := request(c.user, "POST", fmt.Sprintf("/photos?cmd=setInfo&id=%d", id("b.jpg")), strings.NewReader(c.update)); w.Code != c.status {
+			t.Errorf("%s: %d", c.context, w.Code)
+		}
+	}
+	if got, written := stored("b.jpg"), sidecar("b.jpg"); got != "4:Old:[sea boat]" || written != got {
+		t.Errorf("after rejected changes: %s, written %s", got, written)
+	}
+
+	// sidecars changed by desktop tools and uploaded again come back to the library
+	if err := media.WriteSidecar(path.Join(pics, "b.jpg"), &media.XMP{Rating: media.RatingRejected, Title: "New"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.filesDB.ImportItem(id("b.xmp"), path.Join(pics, "b.xmp")); err != nil {
+		t.Fatal(err)
+	}
+	p.syncSources()
+	if got := stored("b.jpg"); got != "-1:New:[]" {
+		t.Errorf("changed by a desktop tool: %s", got)
+	}
+}
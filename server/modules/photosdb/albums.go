@@ -0,0 +1,354 @@
+package photosdb
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+type albumMeta struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Cover int64  `json:"cover"`
+	Count int64  `json:"count"`
+	CDate int64  `json:"cdate"`
+}
+
+type albumListMeta struct {
+	Offset int          `json:"offset"`
+	Albums []*albumMeta `json:"albums"`
+	Size   int64        `json:"size"`
+}
+
+type albumContentMeta struct {
+	Album  *albumMeta   `json:"album"`
+	Offset int          `json:"offset"`
+	Photos []*photoMeta `json:"photos"`
+	Size   int64        `json:"size"`
+}
+
+// albumColumns falls back to the first photo of the album when no cover is set explicitly,
+// or the cover was taken out of the album. Photos handed over to someone else since they
+// were added stay out of the album.
+const albumColumns = `
+	a.id, a.name,
+	coalesce(
+		(SELECT ap.photo_id FROM album_photos AS ap JOIN photos AS p ON p.id = ap.photo_id AND p.owner = a.owner
+			WHERE ap.album_id = a.id AND ap.photo_id = a.cover_id),
+		(SELECT ap.photo_id FROM album_photos AS ap JOIN photos AS p ON p.id = ap.photo_id AND p.owner = a.owner
+			WHERE ap.album_id = a.id ORDER BY ap.position LIMIT 1),
+		0),
+	(SELECT count(*) FROM album_photos AS ap JOIN photos AS p ON p.id = ap.photo_id AND p.owner = a.owner
+		WHERE ap.album_id = a.id),
+	a.ctime`
+
+func scanAlbumMeta(row scanner) (*albumMeta, error) {
+	am := new(albumMeta)
+	if err := row.Scan(&am.ID, &am.Name, &am.Cover, &am.Count, &am.CDate); err != nil {
+		return nil, err
+	}
+	return am, nil
+}
+
+// requireAlbum maps a missing album to errNotFound so handlers can answer 404
+func requireAlbum(tx *sql.Tx, id int64) error {
+	var count int
+	if err := tx.QueryRow(`SELECT count(*) FROM albums WHERE id = ?`, id).Scan(&count); err != nil {
+		return err
+	}
+	if count == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (m *photosDB) CreateAlbum(owner string, name string) (id int64, err error) {
+	if name == "" {
+		return 0, errBadRequest
+	}
+
+	res, err := m.database.Exec(
+		"insert into albums (name, ctime, owner) values ($1, $2, $3)",
+		name, time.Now().Unix(), owner)
+	if err != nil {
+		return
+	}
+	return res.LastInsertId()
+}
+
+func (m *photosDB) RenameAlbum(id int64, name string) error {
+	if name == "" {
+		return errBadRequest
+	}
+
+	res, err := m.database.Exec("update albums set name = $1 where id = $2", name, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (m *photosDB) DeleteAlbum(id int64) error {
+	res, err := m.database.Exec("delete from albums where id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (m *photosDB) AddPhotosToAlbum(id int64, photoIDs []int64) (err error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	if err = requireAlbum(tx, id); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	var position int64
+	row := tx.QueryRow(`SELECT coalesce(max(position) + 1, 0) FROM album_photos WHERE album_id = ?`, id)
+	if err = row.Scan(&position); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	for _, photoID := range photoIDs {
+		var res sql.Result
+		res, err = tx.Exec(`
+			insert into album_photos (album_id, photo_id, position)
+			select $1, coalesce(asset_id, id), $2 from photos
+			where id = $3 and owner = (select owner from albums where id = $1)`,
+			id, position, photoID)
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			position++
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+func (m *photosDB) RemovePhotosFromAlbum(id int64, photoIDs []int64) (err error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	if err = requireAlbum(tx, id); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	for _, photoID := range photoIDs {
+		_, err = tx.Exec("delete from album_photos where album_id = $1 and photo_id = $2", id, photoID)
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// ReorderAlbum moves the listed photos to the beginning of the album in the given order.
+// Photos which are not listed keep their relative order after them.
+func (m *photosDB) ReorderAlbum(id int64, photoIDs []int64) (err error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	if err = requireAlbum(tx, id); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	rows, err := tx.Query(`SELECT photo_id FROM album_photos WHERE album_id = ? ORDER BY position`, id)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+	current := make([]int64, 0)
+	for rows.Next() {
+		var photoID int64
+		if err = rows.Scan(&photoID); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return
+		}
+		current = append(current, photoID)
+	}
+	rows.Close()
+
+	listed := make(map[int64]bool, len(photoIDs))
+	order := make([]int64, 0, len(current))
+	for _, photoID := range photoIDs {
+		if !listed[photoID] {
+			listed[photoID] = true
+			order = append(order, photoID)
+		}
+	}
+	for _, photoID := range current {
+		if !listed[photoID] {
+			order = append(order, photoID)
+		}
+	}
+
+	for position, photoID := range order {
+		_, err = tx.Exec(
+			"update album_photos set position = $1 where album_id = $2 and photo_id = $3",
+			position, id, photoID)
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+func (m *photosDB) SetAlbumCover(id int64, photoID int64) (err error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	if err = requireAlbum(tx, id); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	var count int
+	row := tx.QueryRow(`SELECT count(*) FROM album_photos WHERE album_id = $1 AND photo_id = $2`, id, photoID)
+	if err = row.Scan(&count); err != nil {
+		tx.Rollback()
+		return
+	}
+	if count == 0 {
+		tx.Rollback()
+		return errBadRequest
+	}
+
+	if _, err = tx.Exec("update albums set cover_id = $1 where id = $2", photoID, id); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// GetAlbumOwner is empty for albums which do not exist
+func (m *photosDB) GetAlbumOwner(id int64) string {
+	var owner string
+	if err := m.database.QueryRow(`SELECT owner FROM albums WHERE id = ?`, id).Scan(&owner); err != nil {
+		return ""
+	}
+	return owner
+}
+
+func (m *photosDB) GetAlbumWithID(id int64) interface{} {
+	row := m.database.QueryRow(`SELECT `+albumColumns+` FROM albums AS a WHERE a.id = ?`, id)
+	am, err := scanAlbumMeta(row)
+	if err != nil {
+		return nil
+	}
+	return am
+}
+
+func (m *photosDB) GetAlbums(owner string, offset int, count int) interface{} {
+	rows, err := m.database.Query(`
+		SELECT `+albumColumns+`
+		FROM albums AS a
+		WHERE a.owner = $1
+		ORDER BY a.name COLLATE NOCASE, a.id
+		LIMIT $2 OFFSET $3`,
+		owner, count, offset)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	result := &albumListMeta{
+		Offset: offset,
+		Albums: make([]*albumMeta, 0, count),
+	}
+
+	for rows.Next() {
+		am, err := scanAlbumMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Albums = append(result.Albums, am)
+	}
+
+	row := m.database.QueryRow(`SELECT count(*) FROM albums WHERE owner = ?`, owner)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
+
+func (m *photosDB) GetAlbumPhotos(id int64, offset int, count int) interface{} {
+	row := m.database.QueryRow(`SELECT `+albumColumns+` FROM albums AS a WHERE a.id = ?`, id)
+	am, err := scanAlbumMeta(row)
+	if err != nil {
+		return nil
+	}
+
+	rows, err := m.database.Query(`
+		SELECT `+photoColumns+`
+		FROM album_photos AS ap
+		JOIN albums AS a ON a.id = ap.album_id
+		JOIN photos AS p ON p.id = ap.photo_id AND p.owner = a.owner
+		LEFT JOIN files AS f ON f.id = p.id
+		WHERE ap.album_id = $1
+		ORDER BY ap.position
+		LIMIT $2 OFFSET $3`,
+		id, count, offset)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	result := &albumContentMeta{
+		Album:  am,
+		Offset: offset,
+		Photos: make([]*photoMeta, 0, count),
+		Size:   am.Count,
+	}
+
+	for rows.Next() {
+		pm, err := scanPhotoMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Photos = append(result.Photos, pm)
+	}
+	if err := m.addDetails(result.Photos); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
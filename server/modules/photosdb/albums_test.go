@@ -0,0 +1,148 @@
+package photosdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules"
+	"github.com/akokshar/storage/server/modules/filesdb"
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+// newTestLibrary opens the library of the files below dir, which are owned by the users
+// named like the directories they are in
+func newTestLibrary(t *testing.T, dir string, users ...string) (*photosDB, modules.FilesDB) {
+	dbFile := path.Join(dir, ".meta.db")
+	filesDB := filesdb.NewFilesDB(dbFile)
+	filesDB.ScanPath(dir)
+	for _, user := range users {
+		id, err := filesDB.GetIDForPath(path.Join(dir, user))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := filesDB.SetOwner(id, user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewPhotosDB(dbFile, filesDB).(*photosDB), filesDB
+}
+
+func TestAlbums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	names := map[string][]string{"alice": {"1.jpg", "2.jpg", "3.jpg", "4.jpg", "5.jpg"}, "bob": {"1.jpg"}}
+	for user, files := range names {
+		os.MkdirAll(path.Join(dir, user), 0755)
+		for _, name := range files {
+			ioutil.WriteFile(path.Join(dir, user, name), []byte(user+name), 0644)
+		}
+	}
+	db, filesDB := newTestLibrary(t, dir, "alice", "bob")
+	id := func(p string) int64 {
+		id, err := filesDB.GetIDForPath(path.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	for user, files := range names {
+		for i, name := range files {
+			meta := &media.Metadata{Kind: media.KindPhoto, CaptureTime: time.Date(2020, time.May, i+1, 12, 0, 0, 0, time.UTC)}
+			if err := db.AddPhoto(id(path.Join(user, name)), 0, meta); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	p1, p2, p3, p4, p5 := id("alice/1.jpg"), id("alice/2.jpg"), id("alice/3.jpg"), id("alice/4.jpg"), id("alice/5.jpg")
+	ofBob := id("bob/1.jpg")
+
+	album, err := db.CreateAlbum("alice", "trip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.CreateAlbum("alice", ""); err != errBadRequest {
+		t.Errorf("album without a name: %v", err)
+	}
+	// check compares the photos of the album in their order and its cover
+	check := func(context string, cover int64, want ...int64) {
+		content, ok := db.GetAlbumPhotos(album, 0, 100).(*albumContentMeta)
+		if !ok {
+			t.Fatalf("%s: no album", context)
+		}
+		got := make([]int64, 0)
+		for _, pm := range content.Photos {
+			got = append(got, pm.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) || content.Size != int64(len(want)) {
+			t.Errorf("%s: %v of %d, want %v", context, got, content.Size, want)
+		}
+		if content.Album.Cover != cover {
+			t.Errorf("%s: cover %d, want %d", context, content.Album.Cover, cover)
+		}
+	}
+
+	// photos are in an album once, photos of others and unknown ones are left out
+	if err := db.AddPhotosToAlbum(album, []int64{p1, p2, p3, p1, ofBob, 999999}); err != nil {
+		t.Fatal(err)
+	}
+	check("added", p1, p1, p2, p3)
+	if err := db.AddPhotosToAlbum(album, []int64{p2, p4}); err != nil {
+		t.Fatal(err)
+	}
+	check("added again", p1, p1, p2, p3, p4)
+	if err := db.AddPhotosToAlbum(999999, []int64{p5}); err != errNotFound {
+		t.Errorf("unknown album: %v", err)
+	}
+
+	// listed photos go first, the others keep their order after them
+	if err := db.ReorderAlbum(album, []int64{p3, p1, p3, p5}); err != nil {
+		t.Fatal(err)
+	}
+	check("reordered", p3, p3, p1, p2, p4)
+
+	// the cover has to be in the album and is the first photo again once it is taken out
+	if err := db.SetAlbumCover(album, p5); err != errBadRequest {
+		t.Errorf("cover out of the album: %v", err)
+	}
+	if err := db.SetAlbumCover(album, p2); err != nil {
+		t.Fatal(err)
+	}
+	check("cover", p2, p3, p1, p2, p4)
+	if err := db.RemovePhotosFromAlbum(album, []int64{p2, p5}); err != nil {
+		t.Fatal(err)
+	}
+	check("cover taken out", p3, p3, p1, p4)
+	if err := db.AddPhotosToAlbum(album, []int64{p2}); err != nil {
+		t.Fatal(err)
+	}
+	check("added at the end", p2, p3, p1, p4, p2)
+
+	// photos which are gone leave the album
+	if err := db.RemovePhoto(p3); err != nil {
+		t.Fatal(err)
+	}
+	check("removed", p2, p1, p4, p2)
+
+	// so do duplicates merged into another photo, which takes their place
+	if err := db.MergePhotos(p5, []int64{p2}); err != nil {
+		t.Fatal(err)
+	}
+	check("merged", p5, p1, p4, p5)
+
+	if err := db.DeleteAlbum(album); err != nil {
+		t.Fatal(err)
+	}
+	if db.GetAlbumWithID(album) != nil {
+		t.Errorf("deleted album is there")
+	}
+	if err := db.DeleteAlbum(album); err != errNotFound {
+		t.Errorf("deleted again: %v", err)
+	}
+}
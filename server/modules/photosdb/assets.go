@@ -0,0 +1,311 @@
+package photosdb
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+const (
+	roleStill = "photo"
+	roleRAW   = "raw"
+	roleVideo = "video"
+
+	// companions are written by the camera at the same moment, clocks of a Live Photo
+	// still and its clip may differ by a second
+	companionMaxTimeDelta = 2
+)
+
+// resourceMeta is one of the files an asset consists of, e.g. the movie of a Live Photo
+type resourceMeta struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+	CType string `json:"ctype"`
+	Kind  string `json:"kind"`
+	Role  string `json:"role"`
+}
+
+func resourceRole(kind string, name string) string {
+	switch {
+	case kind == media.KindVideo:
+		return roleVideo
+	case media.IsRAW(name):
+		return roleRAW
+	}
+	return roleStill
+}
+
+// rolePriority tells which resource represents the asset: stills render everywhere, RAW files
+// need a decoder and clips are only the motion part of a Live Photo
+func rolePriority(role string) int {
+	switch role {
+	case roleStill:
+		return 0
+	case roleRAW:
+		return 1
+	}
+	return 2
+}
+
+func baseName(name string) string {
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+type companion struct {
+	id        int64
+	name      string
+	kind      string
+	ctime     int64
+	contentID string
+	assetID   int64
+}
+
+func scanCompanion(row scanner) (*companion, error) {
+	c := new(companion)
+	var name sql.NullString
+	if err := row.Scan(&c.id, &name, &c.kind, &c.ctime, &c.contentID, &c.assetID); err != nil {
+		return nil, err
+	}
+	c.name = name.String
+	return c, nil
+}
+
+const companionColumns = `p.id, f.name, p.kind, p.ctime, coalesce(p.content_id, ''), coalesce(p.asset_id, 0)`
+
+func (c *companion) role() string {
+	return resourceRole(c.kind, c.name)
+}
+
+// matches tells whether two files are parts of one asset: the same base name in the same
+// directory shot at the same time, or the same content identifier written by the camera
+func (c *companion) matches(other *companion, sameDir bool) bool {
+	if c.role() == other.role() {
+		return false
+	}
+	if c.contentID != "" && c.contentID == other.contentID {
+		return true
+	}
+	delta := c.ctime - other.ctime
+	if delta < 0 {
+		delta = -delta
+	}
+	return sameDir && strings.EqualFold(baseName(c.name), baseName(other.name)) && delta <= companionMaxTimeDelta
+}
+
+// pairCompanions groups the photo with its companions. The resource with the highest role
+// priority becomes the asset, the others point to it with asset_id and are not listed on
+// their own. Triggers on asset_id tell feed clients about the change.
+func (m *photosDB) pairCompanions(tx *sql.Tx, id int64) error {
+	var parentID sql.NullInt64
+	row := tx.QueryRow(`
+		SELECT `+companionColumns+`, f.parent_id
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE p.id = ?`,
+		id)
+	self, err := scanCompanion(rowSuffix{row, []interface{}{&parentID}})
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`
+		SELECT `+companionColumns+`, coalesce(f.parent_id = ?, 0)
+		FROM photos AS p JOIN files AS f ON f.id = p.id
+		WHERE p.id != ? AND p.owner = (SELECT owner FROM photos WHERE id = ?) AND (
+			(f.parent_id = ? AND f.name LIKE ? ESCAPE '\') OR
+			(p.content_id = ? AND p.content_id != ''))`,
+		parentID, id, id, parentID, escapeLike(baseName(self.name))+".%", self.contentID)
+	if err != nil {
+		return err
+	}
+
+	assets := make([]int64, 0)
+	for rows.Next() {
+		var sameDir bool
+		c, err := scanCompanion(rowSuffix{rows, []interface{}{&sameDir}})
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if !self.matches(c, sameDir) {
+			continue
+		}
+		if c.assetID != 0 {
+			assets = append(assets, c.assetID)
+		} else {
+			assets = append(assets, c.id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return nil
+	}
+
+	// everything that already belongs to the matched assets joins the group as well
+	members := []*companion{self}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(assets)), ", ")
+	args := make([]interface{}, 0, 2*len(assets)+1)
+	args = append(args, id)
+	for i := 0; i < 2; i++ {
+		for _, assetID := range assets {
+			args = append(args, assetID)
+		}
+	}
+	rows, err = tx.Query(`
+		SELECT `+companionColumns+`
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE p.id != ? AND (p.id IN (`+placeholders+`) OR p.asset_id IN (`+placeholders+`))`,
+		args...)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		c, err := scanCompanion(rows)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		members = append(members, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	primary := members[0]
+	for _, c := range members[1:] {
+		p, q := rolePriority(c.role()), rolePriority(primary.role())
+		if p < q || (p == q && c.id < primary.id) {
+			primary = c
+		}
+	}
+
+	if _, err := tx.Exec("update photos set asset_id = NULL where id = ?", primary.id); err != nil {
+		return err
+	}
+	for _, c := range members {
+		if c.id == primary.id {
+			continue
+		}
+		if err := moveToAsset(tx, primary.id, c.id); err != nil {
+			return err
+		}
+	}
+
+	if err := unifyXMP(tx, primary.id, members); err != nil {
+		return err
+	}
+
+	// the asset has got new resources
+	_, err = tx.Exec(
+		"insert into photos_changelog (photo_id, action) values (?, ?)",
+		primary.id, actionAdd)
+	return err
+}
+
+// moveToAsset makes the photo a resource of the asset
+func moveToAsset(tx *sql.Tx, assetID int64, id int64) error {
+	if _, err := tx.Exec("update photos set asset_id = ? where id = ?", assetID, id); err != nil {
+		return err
+	}
+	// albums hold assets, memberships of a resource move over to its asset
+	if _, err := tx.Exec("update or ignore album_photos set photo_id = ? where photo_id = ?", assetID, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("delete from album_photos where photo_id = ?", id); err != nil {
+		return err
+	}
+	_, err := tx.Exec("update albums set cover_id = ? where cover_id = ?", assetID, id)
+	return err
+}
+
+// addResources lists the resources of the assets which have companions
+func (m *photosDB) addResources(photos []*photoMeta) error {
+	if len(photos) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*photoMeta, len(photos))
+	args := make([]interface{}, 0, len(photos))
+	for _, pm := range photos {
+		byID[pm.ID] = pm
+		args = append(args, pm.ID)
+	}
+
+	rows, err := m.database.Query(`
+		SELECT p.asset_id, p.id, f.name, f.size, f.ctype, p.kind
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE p.asset_id IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")+`)
+		ORDER BY p.id`,
+		args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var assetID int64
+		var name, ctype sql.NullString
+		var size sql.NullInt64
+		rm := new(resourceMeta)
+		if err := rows.Scan(&assetID, &rm.ID, &name, &size, &ctype, &rm.Kind); err != nil {
+			return err
+		}
+		rm.Name = name.String
+		rm.Size = size.Int64
+		rm.CType = ctype.String
+		rm.Role = resourceRole(rm.Kind, rm.Name)
+
+		pm := byID[assetID]
+		if pm.Resources == nil {
+			pm.Resources = []*resourceMeta{{
+				ID:    pm.ID,
+				Name:  pm.Name,
+				Size:  pm.Size,
+				CType: pm.CType,
+				Kind:  pm.Kind,
+				Role:  resourceRole(pm.Kind, pm.Name),
+			}}
+		}
+		pm.Resources = append(pm.Resources, rm)
+	}
+	return rows.Err()
+}
+
+// GetAssetResources returns IDs of all the files of the asset the photo belongs to, the asset first
+func (m *photosDB) GetAssetResources(id int64) []int64 {
+	var assetID int64
+	row := m.database.QueryRow(`SELECT coalesce(asset_id, id) FROM photos WHERE id = ?`, id)
+	if err := row.Scan(&assetID); err != nil {
+		return nil
+	}
+
+	result := []int64{assetID}
+	rows, err := m.database.Query(`SELECT id FROM photos WHERE asset_id = ? ORDER BY id`, assetID)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var resourceID int64
+		if err := rows.Scan(&resourceID); err == nil {
+			result = append(result, resourceID)
+		}
+	}
+	return result
+}
+
+// rowSuffix lets scanners read a row that has extra trailing columns
+type rowSuffix struct {
+	row    scanner
+	suffix []interface{}
+}
+
+func (r rowSuffix) Scan(dest ...interface{}) error {
+	return r.row.Scan(append(dest, r.suffix...)...)
+}
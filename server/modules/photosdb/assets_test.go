@@ -0,0 +1,153 @@
+package photosdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+func TestCompanions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	shot := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+	photos := []struct {
+		path      string
+		kind      string
+		ctime     time.Time
+		contentID string
+	}{
+		// the RAW file comes first, the still shot with it takes over the asset
+		{"alice/IMG_1.CR2", media.KindPhoto, shot, ""},
+		{"alice/IMG_1.JPG", media.KindPhoto, shot.Add(time.Second), ""},
+		// two stills are two photos
+		{"alice/IMG_2.JPG", media.KindPhoto, shot, ""},
+		{"alice/IMG_2.jpeg", media.KindPhoto, shot, ""},
+		// the clip of a Live Photo goes by its content identifier wherever it is
+		{"alice/clips/IMG_3.MOV", media.KindVideo, shot, "live"},
+		{"alice/IMG_3.HEIC", media.KindPhoto, shot, "live"},
+		// shot at other times
+		{"alice/IMG_4.JPG", media.KindPhoto, shot, ""},
+		{"alice/IMG_4.DNG", media.KindPhoto, shot.Add(time.Hour), ""},
+		// photos of others are theirs
+		{"bob/IMG_3.MOV", media.KindVideo, shot, "live"},
+	}
+	for _, p := range photos {
+		os.MkdirAll(path.Dir(path.Join(dir, p.path)), 0755)
+		ioutil.WriteFile(path.Join(dir, p.path), []byte(p.path), 0644)
+	}
+	db, filesDB := newTestLibrary(t, dir, "alice", "bob")
+	id := func(p string) int64 {
+		id, err := filesDB.GetIDForPath(path.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	album, err := db.CreateAlbum("alice", "trip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range photos {
+		meta := &media.Metadata{Kind: p.kind, CaptureTime: p.ctime, ContentID: p.contentID}
+		if err := db.AddPhoto(id(p.path), 0, meta); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if err := db.AddPhotosToAlbum(album, []int64{id(p.path)}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// timeline lists the assets of the user by name, each with the names of its resources
+	timeline := func(user string) map[string]string {
+		result := db.GetTimeline(user, 0, 100).(*timelineMeta)
+		assets := make(map[string]string)
+		for _, pm := range result.Photos {
+			resources := make([]string, 0)
+			for _, rm := range pm.Resources {
+				resources = append(resources, rm.Name+":"+rm.Role)
+			}
+			sort.Strings(resources)
+			assets[pm.Name] = fmt.Sprint(resources)
+		}
+		if result.Size != int64(len(result.Photos)) {
+			t.Errorf("%s: %d photos of %d", user, len(result.Photos), result.Size)
+		}
+		return assets
+	}
+
+	want := map[string]string{
+		"IMG_1.JPG":  "[IMG_1.CR2:raw IMG_1.JPG:photo]",
+		"IMG_2.JPG":  "[]",
+		"IMG_2.jpeg": "[]",
+		"IMG_3.HEIC": "[IMG_3.HEIC:photo IMG_3.MOV:video]",
+		"IMG_4.JPG":  "[]",
+		"IMG_4.DNG":  "[]",
+	}
+	if got := timeline("alice"); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("alice: %v", got)
+	}
+	if got := timeline("bob"); fmt.Sprint(got) != "map[IMG_3.MOV:[]]" {
+		t.Errorf("bob: %v", got)
+	}
+	if got := db.GetAssetResources(id("alice/IMG_1.CR2")); fmt.Sprint(got) != fmt.Sprint([]int64{id("alice/IMG_1.JPG"), id("alice/IMG_1.CR2")}) {
+		t.Errorf("resources of IMG_1.CR2: %v", got)
+	}
+	if pm := db.GetPhotoWithID(id("alice/clips/IMG_3.MOV")).(*photoMeta); pm.Asset != id("alice/IMG_3.HEIC") {
+		t.Errorf("asset of IMG_3.MOV: %d", pm.Asset)
+	}
+
+	// albums hold the asset a resource went into
+	content := db.GetAlbumPhotos(album, 0, 100).(*albumContentMeta)
+	if len(content.Photos) != 1 || content.Photos[0].ID != id("alice/IMG_1.JPG") {
+		t.Errorf("album: %+v", content.Photos)
+	}
+
+	// resources whose asset is gone are on their own again and feed clients hear about them
+	var changes struct {
+		New    []*photoMeta `json:"new"`
+		Erase  []int64      `json:"erase"`
+		Anchor int64        `json:"anchor"`
+	}
+	data, _ := json.Marshal(db.GetChangesSince("alice", 0, 1000))
+	json.Unmarshal(data, &changes)
+	if err := db.RemovePhoto(id("alice/IMG_1.JPG")); err != nil {
+		t.Fatal(err)
+	}
+	delete(want, "IMG_1.JPG")
+	want["IMG_1.CR2"] = "[]"
+	if got := timeline("alice"); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("alice after the removal: %v", got)
+	}
+	if got := db.GetAssetResources(id("alice/IMG_1.CR2")); fmt.Sprint(got) != fmt.Sprint([]int64{id("alice/IMG_1.CR2")}) {
+		t.Errorf("resources of IMG_1.CR2 on its own: %v", got)
+	}
+	changes.New, changes.Erase = nil, nil
+	data, _ = json.Marshal(db.GetChangesSince("alice", changes.Anchor, 1000))
+	if err := json.Unmarshal(data, &changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.New) != 1 || changes.New[0].ID != id("alice/IMG_1.CR2") || len(changes.Erase) != 1 || changes.Erase[0] != id("alice/IMG_1.JPG") {
+		t.Errorf("changes after the removal: %d new, %v erased", len(changes.New), changes.Erase)
+	}
+
+	// the still comes back and takes the RAW file in again
+	if err := db.AddPhoto(id("alice/IMG_1.JPG"), 0, &media.Metadata{Kind: media.KindPhoto, CaptureTime: shot}); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.GetAssetResources(id("alice/IMG_1.CR2")); fmt.Sprint(got) != fmt.Sprint([]int64{id("alice/IMG_1.JPG"), id("alice/IMG_1.CR2")}) {
+		t.Errorf("resources of IMG_1.CR2 paired again: %v", got)
+	}
+}
@@ -0,0 +1,303 @@
+package photosdb
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/akokshar/storage/server/modules/imaging"
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+// duplicateMeta is a photo of a group with its similarity to the photo suggested to keep, 0 to 1
+type duplicateMeta struct {
+	*photoMeta
+	Similarity float64 `json:"similarity"`
+}
+
+type duplicateGroupMeta struct {
+	Keep   int64            `json:"keep"`
+	Photos []*duplicateMeta `json:"photos"`
+}
+
+type duplicateListMeta struct {
+	Distance int                   `json:"distance"`
+	Offset   int                   `json:"offset"`
+	Groups   []*duplicateGroupMeta `json:"groups"`
+	Size     int64                 `json:"size"`
+}
+
+// hashedPhoto is what grouping needs to know about a photo
+type hashedPhoto struct {
+	id     int64
+	hash   uint64
+	pixels int64
+	size   int64
+	ctime  int64
+}
+
+// better tells which photo to keep: the largest, then the earliest
+func (h *hashedPhoto) better(other *hashedPhoto) bool {
+	switch {
+	case h.pixels != other.pixels:
+		return h.pixels > other.pixels
+	case h.size != other.size:
+		return h.size > other.size
+	case h.ctime != other.ctime:
+		return h.ctime < other.ctime
+	}
+	return h.id < other.id
+}
+
+// GetPhotosWithoutPerceptualHash lists still photos the perceptual hash is not computed for yet
+func (m *photosDB) GetPhotosWithoutPerceptualHash(count int) []int64 {
+	rows, err := m.database.Query(`
+		SELECT id FROM photos WHERE phash IS NULL AND kind = ? ORDER BY id LIMIT ?`,
+		media.KindPhoto, count)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, count)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// SetPhotoPerceptualHash stores the hash, an empty one marks a photo which can not be hashed
+func (m *photosDB) SetPhotoPerceptualHash(id int64, hash string) error {
+	_, err := m.database.Exec("update photos set phash = ? where id = ?", hash, id)
+	return err
+}
+
+// groupDuplicates puts photos into one group when they are within maxDistance of any member.
+// Every pair is compared, which is fine for libraries of tens of thousands of photos.
+func groupDuplicates(photos []*hashedPhoto, maxDistance int) [][]*hashedPhoto {
+	parent := make([]int, len(photos))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range photos {
+		for j := i + 1; j < len(photos); j++ {
+			if imaging.HashDistance(photos[i].hash, photos[j].hash) <= maxDistance {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	byRoot := make(map[int][]*hashedPhoto)
+	for i, p := range photos {
+		root := find(i)
+		byRoot[root] = append(byRoot[root], p)
+	}
+	groups := make([][]*hashedPhoto, 0)
+	for _, group := range byRoot {
+		if len(group) < 2 {
+			continue
+		}
+		// the photo to keep goes first
+		sort.Slice(group, func(i, j int) bool { return group[i].better(group[j]) })
+		groups = append(groups, group)
+	}
+	// groups of the latest photos first, like the timeline
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i][0].ctime != groups[j][0].ctime {
+			return groups[i][0].ctime > groups[j][0].ctime
+		}
+		return groups[i][0].id < groups[j][0].id
+	})
+	return groups
+}
+
+// GetDuplicateGroups lists groups of assets of the owner whose perceptual hashes are at most
+// maxDistance bits apart
+func (m *photosDB) GetDuplicateGroups(owner string, maxDistance int, offset int, count int) interface{} {
+	rows, err := m.database.Query(`
+		SELECT p.id, p.phash, p.width * p.height, coalesce(f.size, 0), p.ctime
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE p.asset_id IS NULL AND p.owner = ? AND coalesce(p.phash, '') != ''`,
+		owner)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	photos := make([]*hashedPhoto, 0)
+	for rows.Next() {
+		h := new(hashedPhoto)
+		var hash string
+		if err := rows.Scan(&h.id, &hash, &h.pixels, &h.size, &h.ctime); err != nil {
+			rows.Close()
+			log.Printf("%v", err)
+			return nil
+		}
+		if h.hash, err = strconv.ParseUint(hash, 16, 64); err != nil {
+			continue
+		}
+		photos = append(photos, h)
+	}
+	rows.Close()
+
+	groups := groupDuplicates(photos, maxDistance)
+	result := &duplicateListMeta{
+		Distance: maxDistance,
+		Offset:   offset,
+		Groups:   make([]*duplicateGroupMeta, 0),
+		Size:     int64(len(groups)),
+	}
+	if offset >= len(groups) {
+		return result
+	}
+	groups = groups[offset:]
+	if count >= 0 && count < len(groups) {
+		groups = groups[:count]
+	}
+
+	args := make([]interface{}, 0)
+	for _, group := range groups {
+		for _, h := range group {
+			args = append(args, h.id)
+		}
+	}
+	rows, err = m.database.Query(`
+		SELECT `+photoColumns+`
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE p.id IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")+`)`,
+		args...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	byID := make(map[int64]*photoMeta, len(args))
+	listed := make([]*photoMeta, 0, len(args))
+	for rows.Next() {
+		pm, err := scanPhotoMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		byID[pm.ID] = pm
+		listed = append(listed, pm)
+	}
+	if err := m.addDetails(listed); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	for _, group := range groups {
+		gm := &duplicateGroupMeta{
+			Keep:   group[0].id,
+			Photos: make([]*duplicateMeta, 0, len(group)),
+		}
+		for _, h := range group {
+			if pm, ok := byID[h.id]; ok {
+				distance := imaging.HashDistance(group[0].hash, h.hash)
+				gm.Photos = append(gm.Photos, &duplicateMeta{pm, 1 - float64(distance)/64})
+			}
+		}
+		result.Groups = append(result.Groups, gm)
+	}
+
+	return result
+}
+
+// MergePhotos makes the assets the photos belong to resources of the asset to keep,
+// their album memberships and album covers move over to it. They take the rating, title and
+// keywords of the asset to keep, or give theirs to it if it has none.
+func (m *photosDB) MergePhotos(keepID int64, ids []int64) error {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+
+	var isAsset bool
+	row := tx.QueryRow(`SELECT asset_id IS NULL FROM photos WHERE id = ?`, keepID)
+	if err := row.Scan(&isAsset); err != nil || !isAsset {
+		tx.Rollback()
+		return errNotFound
+	}
+
+	members := []*companion{{id: keepID}}
+	for _, id := range ids {
+		var assetID int64
+		row := tx.QueryRow(`SELECT coalesce(asset_id, id) FROM photos WHERE id = ?`, id)
+		if err := row.Scan(&assetID); err != nil {
+			tx.Rollback()
+			return errNotFound
+		}
+		if assetID == keepID {
+			continue
+		}
+
+		rows, err := tx.Query(`SELECT id FROM photos WHERE id = ? OR asset_id = ?`, assetID, assetID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		resources := make([]int64, 0)
+		for rows.Next() {
+			var resourceID int64
+			if err := rows.Scan(&resourceID); err != nil {
+				rows.Close()
+				tx.Rollback()
+				return err
+			}
+			resources = append(resources, resourceID)
+		}
+		rows.Close()
+
+		for _, resourceID := range resources {
+			if err := moveToAsset(tx, keepID, resourceID); err != nil {
+				tx.Rollback()
+				return err
+			}
+			members = append(members, &companion{id: resourceID})
+		}
+		// the merged asset is gone from the timeline
+		_, err = tx.Exec(
+			"insert into photos_changelog (photo_id, action) values (?, ?)",
+			assetID, actionErase)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := unifyXMP(tx, keepID, members); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"insert into photos_changelog (photo_id, action) values (?, ?)",
+		keepID, actionAdd)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := m.refreshMoments(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,140 @@
+package photosdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+func TestDuplicates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	photos := []struct {
+		path  string
+		side  int
+		day   int
+		phash string
+	}{
+		{"alice/a.jpg", 100, 2, "0000000000000000"},
+		// the largest is kept
+		{"alice/b.jpg", 200, 3, "0000000000000001"},
+		// the earliest of the same size goes before the others
+		{"alice/c.jpg", 100, 1, "0000000000000003"},
+		{"alice/d.jpg", 100, 4, "ffffffffffff0000"},
+		// not decodable
+		{"alice/e.jpg", 100, 5, ""},
+		{"bob/f.jpg", 100, 2, "0000000000000000"},
+	}
+	for _, p := range photos {
+		os.MkdirAll(path.Dir(path.Join(dir, p.path)), 0755)
+		ioutil.WriteFile(path.Join(dir, p.path), []byte(p.path), 0644)
+	}
+	db, filesDB := newTestLibrary(t, dir, "alice", "bob")
+	id := func(p string) int64 {
+		id, err := filesDB.GetIDForPath(path.Join(dir, "alice", p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	for _, p := range photos {
+		photoID, _ := filesDB.GetIDForPath(path.Join(dir, p.path))
+		meta := &media.Metadata{Kind: media.KindPhoto, Width: p.side, Height: p.side, CaptureTime: time.Date(2020, time.May, p.day, 12, 0, 0, 0, time.UTC)}
+		if err := db.AddPhoto(photoID, 0, meta); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetPhotoPerceptualHash(photoID, p.phash); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b, c, d, e := id("a.jpg"), id("b.jpg"), id("c.jpg"), id("d.jpg"), id("e.jpg")
+
+	// groups tells the groups of alice as the photo to keep and the photos with their similarity
+	groups := func(distance int) string {
+		list := db.GetDuplicateGroups("alice", distance, 0, 100).(*duplicateListMeta)
+		found := make([]string, 0)
+		for _, g := range list.Groups {
+			group := fmt.Sprintf("keep %d:", g.Keep)
+			for _, dm := range g.Photos {
+				group += fmt.Sprintf(" %d=%.4f", dm.ID, dm.Similarity)
+			}
+			found = append(found, group)
+		}
+		if list.Size != int64(len(found)) {
+			t.Errorf("distance %d: %d groups of %d", distance, len(found), list.Size)
+		}
+		return fmt.Sprint(found)
+	}
+	if got, want := groups(10), fmt.Sprintf("[keep %d: %d=1.0000 %d=0.9844 %d=0.9844]", b, b, c, a); got != want {
+		t.Errorf("groups: %s, want %s", got, want)
+	}
+	if got := groups(0); got != "[]" {
+		t.Errorf("identical: %s", got)
+	}
+
+	trip, _ := db.CreateAlbum("alice", "trip")
+	other, _ := db.CreateAlbum("alice", "other")
+	db.AddPhotosToAlbum(trip, []int64{a, d})
+	db.AddPhotosToAlbum(other, []int64{c, b})
+	db.SetPhotoXMP(a, &media.XMP{Rating: 5, Keywords: []string{"sea"}})
+	db.SetPhotoXMP(d, &media.XMP{Rating: 2})
+
+	// the copies become resources of the kept photo, which takes their properties as it has none
+	if err := db.MergePhotos(b, []int64{a, c}); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.GetAssetResources(a); fmt.Sprint(got) != fmt.Sprint([]int64{b, a, c}) {
+		t.Errorf("resources: %v", got)
+	}
+	// album tells the photos of the album and its cover
+	album := func(id int64) string {
+		content := db.GetAlbumPhotos(id, 0, 100).(*albumContentMeta)
+		ids := make([]int64, 0)
+		for _, pm := range content.Photos {
+			ids = append(ids, pm.ID)
+		}
+		return fmt.Sprintf("%v cover %d", ids, content.Album.Cover)
+	}
+	if got, want := album(trip), fmt.Sprintf("[%d %d] cover %d", b, d, b); got != want {
+		t.Errorf("trip: %s, want %s", got, want)
+	}
+	if got, want := album(other), fmt.Sprintf("[%d] cover %d", b, b); got != want {
+		t.Errorf("other: %s, want %s", got, want)
+	}
+	for _, resource := range []int64{a, b, c} {
+		if x := db.GetPhotoXMP(resource); x == nil || x.Rating != 5 || fmt.Sprint(x.Keywords) != "[sea]" {
+			t.Errorf("properties of %d: %+v", resource, x)
+		}
+	}
+	timeline := db.GetTimeline("alice", 0, 100).(*timelineMeta)
+	if timeline.Size != 3 {
+		t.Errorf("timeline: %d", timeline.Size)
+	}
+	if got := groups(10); got != "[]" {
+		t.Errorf("groups after the merge: %s", got)
+	}
+
+	// properties of the kept photo win
+	if err := db.MergePhotos(b, []int64{d}); err != nil {
+		t.Fatal(err)
+	}
+	if x := db.GetPhotoXMP(d); x == nil || x.Rating != 5 {
+		t.Errorf("properties of the merged photo: %+v", x)
+	}
+
+	// only assets are kept
+	if err := db.MergePhotos(a, []int64{e}); err != errNotFound {
+		t.Errorf("kept a resource: %v", err)
+	}
+	if err := db.MergePhotos(e, []int64{999999}); err != errNotFound {
+		t.Errorf("merged an unknown photo: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package photosdb
+
+import (
+	"encoding/json"
+
+	"github.com/akokshar/storage/server/modules/imaging"
+)
+
+// SetPhotoEdit stores the edit recipe of an asset, the original file is left as it is.
+// A recipe which changes nothing reverts the asset to the original.
+func (m *photosDB) SetPhotoEdit(id int64, recipe []byte) error {
+	r, err := imaging.ParseRecipe(recipe)
+	if err != nil {
+		return errBadRequest
+	}
+	if r.IsIdentity() {
+		return m.setEdit(id, nil)
+	}
+	normalized, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return m.setEdit(id, normalized)
+}
+
+// RemovePhotoEdit reverts the asset to the original
+func (m *photosDB) RemovePhotoEdit(id int64) error {
+	return m.setEdit(id, nil)
+}
+
+// GetPhotoEdit returns the JSON encoded recipe, nil if the asset is not edited
+func (m *photosDB) GetPhotoEdit(id int64) []byte {
+	var recipe []byte
+	row := m.database.QueryRow(`SELECT edit FROM photos WHERE id = ? AND edit IS NOT NULL`, id)
+	if err := row.Scan(&recipe); err != nil {
+		return nil
+	}
+	return recipe
+}
+
+// setEdit records the change in the changelog so that clients refetch the rendition
+func (m *photosDB) setEdit(id int64, recipe []byte) error {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+
+	var edit interface{}
+	if recipe != nil {
+		edit = string(recipe)
+	}
+	var exists bool
+	row := tx.QueryRow(`SELECT count(*) > 0 FROM photos WHERE id = ? AND asset_id IS NULL`, id)
+	if err := row.Scan(&exists); err != nil || !exists {
+		tx.Rollback()
+		return errNotFound
+	}
+
+	res, err := tx.Exec(`update photos set edit = ? where id = ? and edit is not ?`, edit, id, edit)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		// nothing changed, e.g. reverting an asset which is not edited
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(
+		"insert into photos_changelog (photo_id, action) values (?, ?)",
+		id, actionAdd)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,51 @@
+package photosdb
+
+import (
+	"log"
+)
+
+// GetPhotoIDsWithSize lists photos of the owner of the size, only those can have the same content
+func (m *photosDB) GetPhotoIDsWithSize(owner string, size int64) []int64 {
+	rows, err := m.database.Query(`
+		SELECT p.id FROM photos AS p JOIN files AS f ON f.id = p.id
+		WHERE p.owner = ? AND f.size = ?
+		ORDER BY p.id`,
+		owner, size)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// GetPhotoHash returns the content hash, or an empty string if it is not computed yet
+func (m *photosDB) GetPhotoHash(id int64) string {
+	var hash string
+	row := m.database.QueryRow(`SELECT coalesce(hash, '') FROM photos WHERE id = ?`, id)
+	if err := row.Scan(&hash); err != nil {
+		return ""
+	}
+	return hash
+}
+
+func (m *photosDB) SetPhotoHash(id int64, hash string) error {
+	res, err := m.database.Exec("update photos set hash = ? where id = ?", hash, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+		return errNotFound
+	}
+	return nil
+}
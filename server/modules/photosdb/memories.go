@@ -0,0 +1,121 @@
+package photosdb
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// memories reach back that many years at most, capture times of cameras with their clock
+// unset lie further
+const memoriesMaxYears = 100
+
+type memoryYearMeta struct {
+	Year   int          `json:"year"`
+	Photos []*photoMeta `json:"photos"`
+	Size   int64        `json:"size"`
+}
+
+type memoriesMeta struct {
+	Date   string            `json:"date"`
+	Window int               `json:"window"`
+	Years  []*memoryYearMeta `json:"years"`
+}
+
+// sameDayIn is the day of the year in another year, February 29 falls on February 28 in common years
+func sameDayIn(year int, day time.Time) time.Time {
+	d := time.Date(year, day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	if d.Month() != day.Month() {
+		d = d.AddDate(0, 0, -d.Day())
+	}
+	return d
+}
+
+// GetPhotosOnThisDay lists photos captured within window days around the day in the years before it,
+// the latest year first, with up to count photos per year. The window must be shorter than half a year.
+// Capture times are the wall clock of the camera stored as UTC, so days are compared in UTC.
+func (m *photosDB) GetPhotosOnThisDay(owner string, day time.Time, window int, count int) interface{} {
+	result := &memoriesMeta{
+		Date:   day.Format("2006-01-02"),
+		Window: window,
+		Years:  make([]*memoryYearMeta, 0),
+	}
+
+	var first int64
+	row := m.database.QueryRow(`SELECT coalesce(min(ctime), 0) FROM photos WHERE asset_id IS NULL AND owner = ?`, owner)
+	if err := row.Scan(&first); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	if first == 0 {
+		return result
+	}
+
+	leapDay := time.Date(2000, time.February, 29, 0, 0, 0, 0, time.UTC)
+	leapDayMissing := day.Month() == time.February && day.Day() == 28 && sameDayIn(day.Year(), leapDay).Day() == 28
+
+	// a window reaching over new year takes days of the following year, so start a year early
+	conditions := make([]string, 0)
+	args := []interface{}{owner}
+	ranges := make([]*memoryYearMeta, 0)
+	starts := make([]int64, 0)
+	from := time.Unix(first, 0).UTC().Year() - 1
+	if from < day.Year()-memoriesMaxYears {
+		from = day.Year() - memoriesMaxYears
+	}
+	for year := from; year < day.Year(); year++ {
+		d := sameDayIn(year, day)
+		start, end := d.AddDate(0, 0, -window), d.AddDate(0, 0, window+1)
+		// leap days are remembered on February 28 of common years
+		if leapDayMissing && sameDayIn(year, leapDay).Day() == 29 {
+			end = end.AddDate(0, 0, 1)
+		}
+		conditions = append(conditions, "(p.ctime >= ? AND p.ctime < ?)")
+		args = append(args, start.Unix(), end.Unix())
+		ranges = append(ranges, &memoryYearMeta{Year: year, Photos: make([]*photoMeta, 0)})
+		starts = append(starts, start.Unix())
+	}
+	if len(ranges) == 0 {
+		return result
+	}
+
+	rows, err := m.database.Query(`
+		SELECT `+photoColumns+`
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE p.asset_id IS NULL AND p.owner = ? AND (`+strings.Join(conditions, " OR ")+`)
+		ORDER BY p.ctime DESC, p.id DESC`,
+		args...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	listed := make([]*photoMeta, 0)
+	var current *memoryYearMeta
+	for rows.Next() {
+		pm, err := scanPhotoMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		// windows do not overlap, the photo is in the last one starting before it
+		i := sort.Search(len(starts), func(i int) bool { return starts[i] > pm.CaptureDate }) - 1
+		if ranges[i] != current {
+			current = ranges[i]
+			result.Years = append(result.Years, current)
+		}
+		current.Size++
+		if count < 0 || len(current.Photos) < count {
+			current.Photos = append(current.Photos, pm)
+			listed = append(listed, pm)
+		}
+	}
+	if err := m.addDetails(listed); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
@@ -0,0 +1,88 @@
+package photosdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+func TestPhotosOnThisDay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	taken := map[string]time.Time{
+		"leap.jpg":    time.Date(2016, time.February, 29, 12, 0, 0, 0, time.UTC),
+		"eve.jpg":     time.Date(2018, time.December, 31, 23, 0, 0, 0, time.UTC),
+		"newyear.jpg": time.Date(2019, time.January, 2, 1, 0, 0, 0, time.UTC),
+		// a camera with its clock unset
+		"unset.jpg": time.Date(1915, time.February, 28, 12, 0, 0, 0, time.UTC),
+	}
+	os.MkdirAll(path.Join(dir, "alice"), 0755)
+	for name := range taken {
+		ioutil.WriteFile(path.Join(dir, "alice", name), []byte(name), 0644)
+	}
+	db, filesDB := newTestLibrary(t, dir, "alice")
+	ids := make(map[int64]string)
+	for name, ctime := range taken {
+		id, err := filesDB.GetIDForPath(path.Join(dir, "alice", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.AddPhoto(id, 0, &media.Metadata{Kind: media.KindPhoto, CaptureTime: ctime}); err != nil {
+			t.Fatal(err)
+		}
+		ids[id] = name
+	}
+
+	cases := []struct {
+		context string
+		day     time.Time
+		window  int
+		want    map[int][]string
+	}{
+		// February 28 of a common year remembers leap days, that of a leap year does not
+		{"common year", time.Date(2021, time.February, 28, 0, 0, 0, 0, time.UTC), 0, map[int][]string{2016: {"leap.jpg"}}},
+		{"leap year", time.Date(2020, time.February, 28, 0, 0, 0, 0, time.UTC), 0, map[int][]string{}},
+		{"leap day", time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC), 0, map[int][]string{2016: {"leap.jpg"}}},
+		// a window over new year goes with the year of its day
+		{"new year", time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC), 1, map[int][]string{2019: {"newyear.jpg", "eve.jpg"}}},
+		{"new year eve", time.Date(2020, time.December, 31, 0, 0, 0, 0, time.UTC), 1, map[int][]string{2018: {"eve.jpg"}}},
+		{"old year", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC), 0, map[int][]string{}},
+	}
+	for _, c := range cases {
+		memories, ok := db.GetPhotosOnThisDay("alice", c.day, c.window, 10).(*memoriesMeta)
+		if !ok {
+			t.Fatalf("%s: no memories", c.context)
+		}
+		if len(memories.Years) != len(c.want) {
+			t.Errorf("%s: %d years, want %d", c.context, len(memories.Years), len(c.want))
+			continue
+		}
+		for _, year := range memories.Years {
+			want := c.want[year.Year]
+			if len(year.Photos) != len(want) {
+				t.Errorf("%s: %d photos in %d, want %d", c.context, len(year.Photos), year.Year, len(want))
+				continue
+			}
+			for i, pm := range year.Photos {
+				if ids[pm.ID] != want[i] {
+					t.Errorf("%s: %s in %d, want %s", c.context, ids[pm.ID], year.Year, want[i])
+				}
+			}
+		}
+	}
+
+	// the clock unset reaches no further than memoriesMaxYears
+	memories := db.GetPhotosOnThisDay("alice", time.Date(2021, time.February, 28, 0, 0, 0, 0, time.UTC), 0, 10).(*memoriesMeta)
+	for _, year := range memories.Years {
+		if year.Year < 2021-memoriesMaxYears {
+			t.Errorf("memories of %d", year.Year)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package photosdb
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/geocode"
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+const (
+	// a pause that long in shooting starts a new moment
+	momentMaxGap = 3 * 60 * 60
+	// so does moving that far, in kilometers, from where the last photo with GPS was taken
+	momentMaxDistance = 50.0
+)
+
+type momentMeta struct {
+	ID       int64      `json:"id"`
+	Title    string     `json:"title"`
+	Start    int64      `json:"start"`
+	End      int64      `json:"end"`
+	Count    int64      `json:"count"`
+	Cover    int64      `json:"cover"`
+	Place    *placeMeta `json:"place,omitempty"`
+	Location *location  `json:"location,omitempty"` // center of the photos with GPS
+}
+
+type momentListMeta struct {
+	Offset  int           `json:"offset"`
+	Moments []*momentMeta `json:"moments"`
+	Size    int64         `json:"size"`
+}
+
+type momentContentMeta struct {
+	Moment *momentMeta  `json:"moment"`
+	Offset int          `json:"offset"`
+	Photos []*photoMeta `json:"photos"`
+	Size   int64        `json:"size"`
+}
+
+const momentColumns = `
+	m.id, coalesce(m.title, ''), m.stime, m.etime, m.count, coalesce(m.cover_id, 0),
+	coalesce(m.country, ''), coalesce(m.region, ''), coalesce(m.city, ''),
+	m.has_gps, m.latitude, m.longitude`
+
+func scanMomentMeta(row scanner) (*momentMeta, error) {
+	mm := new(momentMeta)
+	place := new(placeMeta)
+	var hasGPS bool
+	var lat, lon float64
+	err := row.Scan(&mm.ID, &mm.Title, &mm.Start, &mm.End, &mm.Count, &mm.Cover,
+		&place.Country, &place.Region, &place.City, &hasGPS, &lat, &lon)
+	if err != nil {
+		return nil, err
+	}
+	if place.Country != "" {
+		mm.Place = place
+	}
+	if hasGPS {
+		mm.Location = &location{
+			Latitude:  lat,
+			Longitude: lon,
+		}
+	}
+	return mm, nil
+}
+
+// momentPhoto is what clustering needs to know about a photo
+type momentPhoto struct {
+	id     int64
+	ctime  int64
+	kind   string
+	pixels int
+	hasGPS bool
+	lat    float64
+	lon    float64
+	place  placeMeta
+}
+
+// cluster splits photos sorted by capture time into moments
+func cluster(photos []*momentPhoto) [][]*momentPhoto {
+	moments := make([][]*momentPhoto, 0)
+	var current []*momentPhoto
+	var lastGPS *momentPhoto
+	for _, p := range photos {
+		if len(current) > 0 {
+			split := p.ctime-current[len(current)-1].ctime > momentMaxGap
+			if p.hasGPS && lastGPS != nil && geocode.Distance(lastGPS.lat, lastGPS.lon, p.lat, p.lon) > momentMaxDistance {
+				split = true
+			}
+			if split {
+				moments = append(moments, current)
+				current, lastGPS = nil, nil
+			}
+		}
+		current = append(current, p)
+		if p.hasGPS {
+			lastGPS = p
+		}
+	}
+	if len(current) > 0 {
+		moments = append(moments, current)
+	}
+	return moments
+}
+
+// momentPlace is the place most photos of the moment were taken at
+func momentPlace(photos []*momentPhoto) placeMeta {
+	counts := make(map[placeMeta]int)
+	var best placeMeta
+	for _, p := range photos {
+		if p.place.Country == "" {
+			continue
+		}
+		counts[p.place]++
+		if counts[p.place] > counts[best] {
+			best = p.place
+		}
+	}
+	return best
+}
+
+// momentCover prefers the largest still image, of equal ones the closest to the middle of the moment
+func momentCover(photos []*momentPhoto) int64 {
+	middle := (photos[0].ctime + photos[len(photos)-1].ctime) / 2
+	cover := photos[0]
+	score := func(p *momentPhoto) (bool, int, int64) {
+		offset := p.ctime - middle
+		if offset < 0 {
+			offset = -offset
+		}
+		return p.kind == media.KindPhoto, p.pixels, -offset
+	}
+	for _, p := range photos[1:] {
+		still, pixels, closeness := score(p)
+		coverStill, coverPixels, coverCloseness := score(cover)
+		switch {
+		case still != coverStill:
+			if still {
+				cover = p
+			}
+		case pixels != coverPixels:
+			if pixels > coverPixels {
+				cover = p
+			}
+		case closeness > coverCloseness:
+			cover = p
+		}
+	}
+	return cover.id
+}
+
+// momentDates formats a date range as compact as the range allows: "17 May 2020",
+// "17–19 May 2020", "30 May – 2 Jun 2020", "30 Dec 2019 – 2 Jan 2020"
+func momentDates(start time.Time, end time.Time) string {
+	switch {
+	case start.Year() != end.Year():
+		return fmt.Sprintf("%s – %s", start.Format("2 Jan 2006"), end.Format("2 Jan 2006"))
+	case start.Month() != end.Month():
+		return fmt.Sprintf("%s – %s", start.Format("2 Jan"), end.Format("2 Jan 2006"))
+	case start.Day() != end.Day():
+		return fmt.Sprintf("%d–%s", start.Day(), end.Format("2 Jan 2006"))
+	}
+	return start.Format("2 Jan 2006")
+}
+
+func momentTitle(start int64, end int64, place placeMeta) string {
+	dates := momentDates(time.Unix(start, 0).UTC(), time.Unix(end, 0).UTC())
+	for _, name := range []string{place.City, place.Region, place.Country} {
+		if name != "" {
+			return name + ", " + dates
+		}
+	}
+	return dates
+}
+
+type timeWindow struct {
+	start int64
+	end   int64
+}
+
+// refreshMoments recomputes moments around photos which are not in a moment yet and moments
+// which have changed. Moments further away than momentMaxGap cannot be affected and stay as they are.
+// Every user has moments of their own photos. Recomputed moments keep the ID of the moment most of
+// their photos were in, so that clients holding on to a moment keep seeing it.
+func (m *photosDB) refreshMoments(tx *sql.Tx) error {
+	rows, err := tx.Query(`
+		SELECT owner, ctime, ctime FROM photos WHERE asset_id IS NULL AND moment_id IS NULL
+		UNION ALL
+		SELECT owner, stime, etime FROM moments WHERE dirty`)
+	if err != nil {
+		return err
+	}
+	windows := make(map[string][]timeWindow)
+	for rows.Next() {
+		var owner string
+		var w timeWindow
+		if err := rows.Scan(&owner, &w.start, &w.end); err != nil {
+			rows.Close()
+			return err
+		}
+		windows[owner] = append(windows[owner], w)
+	}
+	rows.Close()
+	if len(windows) == 0 {
+		return nil
+	}
+
+	// moments the windows reach are taken apart
+	affected := make([]int64, 0)
+	for owner, ownerWindows := range windows {
+		// merge windows which are close enough to end up in one moment
+		sort.Slice(ownerWindows, func(i, j int) bool { return ownerWindows[i].start < ownerWindows[j].start })
+		merged := ownerWindows[:1]
+		for _, w := range ownerWindows[1:] {
+			last := &merged[len(merged)-1]
+			if w.start-last.end <= momentMaxGap {
+				if w.end > last.end {
+					last.end = w.end
+				}
+			} else {
+				merged = append(merged, w)
+			}
+		}
+
+		for _, w := range merged {
+			ids, err := queryIDs(tx,
+				"SELECT id FROM moments WHERE owner = ? AND stime <= ? AND etime >= ?",
+				owner, w.end+momentMaxGap, w.start-momentMaxGap)
+			if err != nil {
+				return err
+			}
+			affected = append(affected, ids...)
+		}
+	}
+
+	// photos remember the moment they were in
+	previous := make(map[int64]int64)
+	unused := make(map[int64]bool, len(affected))
+	for _, id := range affected {
+		unused[id] = true
+		photoIDs, err := queryIDs(tx, "SELECT id FROM photos WHERE moment_id = ?", id)
+		if err != nil {
+			return err
+		}
+		for _, photoID := range photoIDs {
+			previous[photoID] = id
+		}
+		if _, err := tx.Exec("update photos set moment_id = NULL where moment_id = ?", id); err != nil {
+			return err
+		}
+	}
+
+	rows, err = tx.Query(`
+		SELECT owner, id, ctime, kind, width * height, has_gps, latitude, longitude,
+			coalesce(country, ''), coalesce(region, ''), coalesce(city, '')
+		FROM photos
+		WHERE asset_id IS NULL AND moment_id IS NULL
+		ORDER BY owner, ctime, id`)
+	if err != nil {
+		return err
+	}
+	photos := make(map[string][]*momentPhoto)
+	for rows.Next() {
+		var owner string
+		p := new(momentPhoto)
+		err := rows.Scan(&owner, &p.id, &p.ctime, &p.kind, &p.pixels, &p.hasGPS, &p.lat, &p.lon,
+			&p.place.Country, &p.place.Region, &p.place.City)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		photos[owner] = append(photos[owner], p)
+	}
+	rows.Close()
+
+	for owner, ownerPhotos := range photos {
+		for _, moment := range cluster(ownerPhotos) {
+			id := reuseMoment(moment, previous, unused)
+			if err := m.saveMoment(tx, id, owner, moment); err != nil {
+				return err
+			}
+		}
+	}
+
+	for id := range unused {
+		if _, err := tx.Exec("delete from moments where id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryIDs(tx *sql.Tx, query string, args ...interface{}) ([]int64, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// reuseMoment picks the unused moment most photos of the cluster were in, the earliest of
+// equal ones, or 0 for a new moment
+func reuseMoment(photos []*momentPhoto, previous map[int64]int64, unused map[int64]bool) int64 {
+	votes := make(map[int64]int)
+	var best int64
+	for _, p := range photos {
+		id, ok := previous[p.id]
+		if !ok || !unused[id] {
+			continue
+		}
+		votes[id]++
+		if best == 0 || votes[id] > votes[best] || votes[id] == votes[best] && id < best {
+			best = id
+		}
+	}
+	delete(unused, best)
+	return best
+}
+
+// saveMoment updates the moment in place, or makes a new one when id is 0
+func (m *photosDB) saveMoment(tx *sql.Tx, id int64, owner string, photos []*momentPhoto) error {
+	start, end := photos[0].ctime, photos[len(photos)-1].ctime
+	place := momentPlace(photos)
+
+	var gpsCount int
+	var lat, lon float64
+	for _, p := range photos {
+		if p.hasGPS {
+			gpsCount++
+			lat += p.lat
+			lon += p.lon
+		}
+	}
+	if gpsCount > 0 {
+		lat, lon = lat/float64(gpsCount), lon/float64(gpsCount)
+	}
+
+	values := []interface{}{
+		owner, start, end, len(photos), momentCover(photos), momentTitle(start, end, place),
+		place.Country, place.Region, place.City, gpsCount > 0, lat, lon,
+	}
+	if id != 0 {
+		_, err := tx.Exec(`
+			update moments
+			set owner = ?, stime = ?, etime = ?, count = ?, cover_id = ?, title = ?,
+				country = ?, region = ?, city = ?, has_gps = ?, latitude = ?, longitude = ?, dirty = 0
+			where id = ?`,
+			append(values, id)...)
+		if err != nil {
+			return err
+		}
+	} else {
+		res, err := tx.Exec(`
+			insert into moments
+				(owner, stime, etime, count, cover_id, title, country, region, city, has_gps, latitude, longitude)
+				values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			values...)
+		if err != nil {
+			return err
+		}
+		if id, err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range photos {
+		if _, err := tx.Exec("update photos set moment_id = ? where id = ?", id, p.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateMoments brings moments up to date with photos removed while the library was closed
+func (m *photosDB) updateMoments() error {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+	if err := m.refreshMoments(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetMomentWithID reads the moment as the last change left it, moments are kept up to date
+// by the changes of the library
+func (m *photosDB) GetMomentWithID(id int64) interface{} {
+	row := m.database.QueryRow(`SELECT `+momentColumns+` FROM moments AS m WHERE m.id = ?`, id)
+	mm, err := scanMomentMeta(row)
+	if err != nil {
+		return nil
+	}
+	return mm
+}
+
+// GetMomentOwner is empty for moments which do not exist
+func (m *photosDB) GetMomentOwner(id int64) string {
+	var owner string
+	if err := m.database.QueryRow(`SELECT owner FROM moments WHERE id = ?`, id).Scan(&owner); err != nil {
+		return ""
+	}
+	return owner
+}
+
+func (m *photosDB) GetMoments(owner string, offset int, count int) interface{} {
+	rows, err := m.database.Query(`
+		SELECT `+momentColumns+`
+		FROM moments AS m
+		WHERE m.owner = ?
+		ORDER BY m.stime DESC, m.id DESC
+		LIMIT ? OFFSET ?`,
+		owner, count, offset)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	result := &momentListMeta{
+		Offset:  offset,
+		Moments: make([]*momentMeta, 0, count),
+	}
+
+	for rows.Next() {
+		mm, err := scanMomentMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Moments = append(result.Moments, mm)
+	}
+
+	row := m.database.QueryRow(`SELECT count(*) FROM moments WHERE owner = ?`, owner)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
+
+func (m *photosDB) GetMomentPhotos(id int64, offset int, count int) interface{} {
+	mm, ok := m.GetMomentWithID(id).(*momentMeta)
+	if !ok {
+		return nil
+	}
+
+	rows, err := m.database.Query(`
+		SELECT `+photoColumns+`
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE p.moment_id = ?
+		ORDER BY p.ctime, p.id
+		LIMIT ? OFFSET ?`,
+		id, count, offset)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	result := &momentContentMeta{
+		Moment: mm,
+		Offset: offset,
+		Photos: make([]*photoMeta, 0, count),
+		Size:   mm.Count,
+	}
+
+	for rows.Next() {
+		pm, err := scanPhotoMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Photos = append(result.Photos, pm)
+	}
+	if err := m.addDetails(result.Photos); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
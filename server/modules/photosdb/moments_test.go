@@ -0,0 +1,113 @@
+package photosdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+func TestMomentsKeepTheirIDs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// photos taken that many hours after noon of the first of May
+	hours := []int{0, 1, 2, 5, 8, 9}
+	for _, user := range []string{"alice", "bob"} {
+		os.MkdirAll(path.Join(dir, user), 0755)
+		for _, h := range hours {
+			ioutil.WriteFile(path.Join(dir, user, fmt.Sprintf("%02d.jpg", h)), []byte{byte(h)}, 0644)
+		}
+	}
+	db, filesDB := newTestLibrary(t, dir, "alice", "bob")
+
+	noon := time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)
+	id := func(user string, h int) int64 {
+		id, err := filesDB.GetIDForPath(path.Join(dir, user, fmt.Sprintf("%02d.jpg", h)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	add := func(user string, h int) {
+		meta := &media.Metadata{Kind: media.KindPhoto, CaptureTime: noon.Add(time.Duration(h) * time.Hour)}
+		if err := db.AddPhoto(id(user, h), 0, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	moments := func(user string) []*momentMeta {
+		list, ok := db.GetMoments(user, 0, 100).(*momentListMeta)
+		if !ok {
+			t.Fatalf("moments of %s", user)
+		}
+		// the earliest first
+		for i, j := 0, len(list.Moments)-1; i < j; i, j = i+1, j-1 {
+			list.Moments[i], list.Moments[j] = list.Moments[j], list.Moments[i]
+		}
+		return list.Moments
+	}
+	check := func(context string, user string, want ...int64) []*momentMeta {
+		got := moments(user)
+		if len(got) != len(want) {
+			t.Fatalf("%s: %d moments, want %d", context, len(got), len(want))
+		}
+		for i, mm := range got {
+			if mm.Count != want[i] {
+				t.Errorf("%s: moment %d has %d photos, want %d", context, i, mm.Count, want[i])
+			}
+		}
+		return got
+	}
+
+	for _, h := range []int{0, 1, 2, 8, 9} {
+		add("alice", h)
+	}
+	add("bob", 0)
+	first := check("two moments", "alice", 3, 2)
+	ofBob := check("moments of bob", "bob", 1)
+
+	// a photo in between joins the moments, the bigger one keeps its ID
+	add("alice", 5)
+	joined := check("joined", "alice", 6)
+	if joined[0].ID != first[0].ID {
+		t.Errorf("joined: moment %d, want %d", joined[0].ID, first[0].ID)
+	}
+	if db.GetMomentWithID(first[1].ID) != nil {
+		t.Errorf("joined: moment %d is still there", first[1].ID)
+	}
+
+	// and leaves them apart when it goes
+	if err := db.RemovePhoto(id("alice", 5)); err != nil {
+		t.Fatal(err)
+	}
+	split := check("split", "alice", 3, 2)
+	if split[0].ID != first[0].ID {
+		t.Errorf("split: moment %d, want %d", split[0].ID, first[0].ID)
+	}
+
+	// a photo added to a moment leaves it where it is
+	add("bob", 1)
+	if grown := check("grown", "bob", 2); grown[0].ID != ofBob[0].ID {
+		t.Errorf("grown: moment %d, want %d", grown[0].ID, ofBob[0].ID)
+	}
+
+	// photos the files module erases leave their moment as it is until the library changes,
+	// reading does not write
+	if _, err := db.database.Exec("delete from photos where id = ?", id("alice", 9)); err != nil {
+		t.Fatal(err)
+	}
+	check("read", "alice", 3, 2)
+	if err := db.PruneOrphans(); err != nil {
+		t.Fatal(err)
+	}
+	pruned := check("pruned", "alice", 3, 1)
+	if pruned[1].ID != split[1].ID {
+		t.Errorf("pruned: moment %d, want %d", pruned[1].ID, split[1].ID)
+	}
+}
@@ -0,0 +1,103 @@
+package photosdb
+
+import "log"
+
+// Photos and sources belong to the user owning their files, albums and smart albums to the
+// user who made them. Users see what they own only, moments are made of the photos of one
+// user and the changelog of a user tells about their photos.
+
+// resolveOwners gives photos and sources added before there were users, or in directories
+// handed over to a user since, to the owner of their files. Photos leave the moments of
+// their former owner and are announced to the new one.
+func (m *photosDB) resolveOwners() {
+	for _, table := range []string{"photos", "photo_sources"} {
+		rows, err := m.database.Query(`SELECT id FROM ` + table + ` WHERE owner = ''`)
+		if err != nil {
+			log.Fatal(err)
+		}
+		ids := make([]int64, 0)
+		for rows.Next() {
+			var id int64
+			if err := rows.Scan(&id); err != nil {
+				log.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			owner, _ := m.filesDB.GetOwner(id)
+			if owner == "" {
+				continue
+			}
+			var err error
+			if table == "photo_sources" {
+				_, err = m.database.Exec("update photo_sources set owner = ? where id = ?", owner, id)
+			} else {
+				err = m.setPhotoOwner(id, owner)
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	// changes recorded before there were users go to whoever owns the photo now
+	_, err := m.database.Exec(`
+		update photos_changelog set owner = coalesce((select owner from photos where id = photo_id), '')
+		where owner is null`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (m *photosDB) setPhotoOwner(id int64, owner string) (err error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	if _, err = tx.Exec("update moments set dirty = 1 where id = (select moment_id from photos where id = ?)", id); err != nil {
+		tx.Rollback()
+		return
+	}
+	if _, err = tx.Exec("update photos set owner = ?, moment_id = NULL where id = ?", owner, id); err != nil {
+		tx.Rollback()
+		return
+	}
+	if _, err = tx.Exec("insert into photos_changelog (photo_id, action) values (?, ?)", id, actionAdd); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// AdoptAlbums hands albums and smart albums made before there were users over to the user
+func (m *photosDB) AdoptAlbums(owner string) (err error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	for _, table := range []string{"albums", "smart_albums"} {
+		if _, err = tx.Exec("update "+table+" set owner = ? where owner = ''", owner); err != nil {
+			tx.Rollback()
+			return
+		}
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// GetPhotoOwner is empty for photos which are not in the library or nobody owns
+func (m *photosDB) GetPhotoOwner(id int64) string {
+	var owner string
+	row := m.database.QueryRow(`SELECT owner FROM photos WHERE id = ?`, id)
+	if err := row.Scan(&owner); err != nil {
+		return ""
+	}
+	return owner
+}
@@ -0,0 +1,118 @@
+package photosdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+func TestHandedOverPhotos(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, user := range []string{"alice", "bob"} {
+		os.MkdirAll(path.Join(dir, user), 0755)
+	}
+	ioutil.WriteFile(path.Join(dir, "alice/a.jpg"), []byte("a"), 0644)
+	db, filesDB := newTestLibrary(t, dir, "alice", "bob")
+	photo, err := filesDB.GetIDForPath(path.Join(dir, "alice/a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := &media.Metadata{Kind: media.KindPhoto, CaptureTime: time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)}
+	if err := db.AddPhoto(photo, 0, meta); err != nil {
+		t.Fatal(err)
+	}
+
+	anchors := make(map[string]int64)
+	// changes tells what the change feed of the user holds since the last call
+	changes := func(user string) (added int, erased []int64) {
+		var result struct {
+			New    []*photoMeta `json:"new"`
+			Erase  []int64      `json:"erase"`
+			Anchor int64        `json:"anchor"`
+		}
+		data, _ := json.Marshal(db.GetChangesSince(user, anchors[user], 100))
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatal(err)
+		}
+		anchors[user] = result.Anchor
+		return len(result.New), result.Erase
+	}
+	if added, _ := changes("alice"); added != 1 {
+		t.Errorf("alice: %d new", added)
+	}
+
+	// a file moved to another user takes the photo along, its former owner is told it is gone
+	bob, _ := filesDB.GetIDForPath(path.Join(dir, "bob"))
+	if err := filesDB.MoveItem(photo, bob, "a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddPhoto(photo, 0, meta); err != nil {
+		t.Fatal(err)
+	}
+	if owner := db.GetPhotoOwner(photo); owner != "bob" {
+		t.Errorf("owner: %q", owner)
+	}
+	if added, erased := changes("alice"); added != 0 || len(erased) != 1 || erased[0] != photo {
+		t.Errorf("alice after the handover: %d new, %v erased", added, erased)
+	}
+	if added, erased := changes("bob"); added != 1 || len(erased) != 0 {
+		t.Errorf("bob after the handover: %d new, %v erased", added, erased)
+	}
+}
+
+func TestChangelogKeyedByOwner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db, filesDB := newTestLibrary(t, dir)
+
+	// the changelog as earlier versions left it
+	_, err = db.database.Exec(`
+		BEGIN;
+		DROP TRIGGER t_photos_erase;
+		DROP TRIGGER t_photos_attach;
+		DROP TRIGGER t_photos_detach;
+		DROP TABLE photos_changelog;
+		CREATE TABLE photos_changelog (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			photo_id INTEGER,
+			action INTEGER,
+			owner TEXT COLLATE NOCASE,
+
+			CONSTRAINT k_lastchange
+				UNIQUE (photo_id)
+				ON CONFLICT REPLACE
+		);
+		INSERT INTO photos_changelog (id, photo_id, action, owner) VALUES (5, 1, 0, 'alice'), (9, 2, 1, 'alice');
+		UPDATE sqlite_sequence SET seq = 12 WHERE name = 'photos_changelog';
+		COMMIT;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db = NewPhotosDB(path.Join(dir, ".meta.db"), filesDB).(*photosDB)
+	for _, owner := range []string{"alice", "bob"} {
+		if _, err := db.database.Exec(`INSERT INTO photos_changelog (photo_id, action, owner) VALUES (1, 1, ?)`, owner); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var count, last int64
+	if err := db.database.QueryRow(`SELECT count(*), max(id) FROM photos_changelog`).Scan(&count, &last); err != nil {
+		t.Fatal(err)
+	}
+	// the change for alice replaces the one before, that for bob is added, anchors go on from where they were
+	if count != 3 || last != 14 {
+		t.Errorf("changelog: %d records up to %d", count, last)
+	}
+}
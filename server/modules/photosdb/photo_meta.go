@@ -0,0 +1,98 @@
+package photosdb
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+type location struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lon"`
+}
+
+type photoMeta struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Size        int64           `json:"size"`
+	CType       string          `json:"ctype"`
+	Kind        string          `json:"kind"`
+	CaptureDate int64           `json:"capturedate"`
+	Make        string          `json:"make,omitempty"`
+	Model       string          `json:"model,omitempty"`
+	Width       int             `json:"width,omitempty"`
+	Height      int             `json:"height,omitempty"`
+	Orientation int             `json:"orientation,omitempty"`
+	Location    *location       `json:"location,omitempty"`
+	Place       *placeMeta      `json:"place,omitempty"`
+	Source      int64           `json:"source,omitempty"`
+	Duration    float64         `json:"duration,omitempty"`
+	Codec       string          `json:"codec,omitempty"`
+	Asset       int64           `json:"asset,omitempty"`  // set for resources which are paired into another asset
+	Edit        json.RawMessage `json:"edit,omitempty"`   // recipe of the edited rendition
+	Rating      int             `json:"rating,omitempty"` // -1 for rejected photos
+	Title       string          `json:"title,omitempty"`
+	Keywords    []string        `json:"keywords,omitempty"`
+
+	Resources []*resourceMeta `json:"resources,omitempty"`
+}
+
+type timelineMeta struct {
+	Offset int          `json:"offset"`
+	Photos []*photoMeta `json:"photos"`
+	Size   int64        `json:"size"`
+}
+
+// photoColumns is the select list scanPhotoMeta expects. Queries alias photos as p and files as f.
+// Columns are coalesced so that a LEFT JOIN missing the photo still scans.
+const photoColumns = `
+	coalesce(p.id, 0), f.name, f.size, f.ctype, coalesce(p.kind, ''), coalesce(p.ctime, 0),
+	p.make, p.model, coalesce(p.width, 0), coalesce(p.height, 0), coalesce(p.orientation, 0),
+	coalesce(p.has_gps, 0), coalesce(p.latitude, 0), coalesce(p.longitude, 0),
+	coalesce(p.source_id, 0), coalesce(p.duration, 0), p.codec, coalesce(p.asset_id, 0),
+	coalesce(p.country, ''), coalesce(p.region, ''), coalesce(p.city, ''), p.edit,
+	coalesce(p.rating, 0), p.title`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPhotoMeta(row scanner) (*photoMeta, error) {
+	pm := new(photoMeta)
+	var name, ctype, camMake, camModel, codec, edit, title sql.NullString
+	var size sql.NullInt64
+	var hasGPS bool
+	var lat, lon float64
+	place := new(placeMeta)
+	err := row.Scan(
+		&pm.ID, &name, &size, &ctype, &pm.Kind, &pm.CaptureDate,
+		&camMake, &camModel, &pm.Width, &pm.Height, &pm.Orientation,
+		&hasGPS, &lat, &lon, &pm.Source, &pm.Duration, &codec, &pm.Asset,
+		&place.Country, &place.Region, &place.City, &edit,
+		&pm.Rating, &title)
+	if err != nil {
+		return nil, err
+	}
+
+	pm.Name = name.String
+	pm.Size = size.Int64
+	pm.CType = ctype.String
+	pm.Make = camMake.String
+	pm.Model = camModel.String
+	pm.Codec = codec.String
+	pm.Title = title.String
+	if edit.Valid {
+		pm.Edit = json.RawMessage(edit.String)
+	}
+	if hasGPS {
+		pm.Location = &location{
+			Latitude:  lat,
+			Longitude: lon,
+		}
+	}
+
+	if place.Country != "" {
+		pm.Place = place
+	}
+
+	return pm, nil
+}
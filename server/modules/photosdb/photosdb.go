@@ -0,0 +1,590 @@
+package photosdb
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/akokshar/storage/server/modules"
+	"github.com/akokshar/storage/server/modules/media"
+	// need to call this explicitly so it registers db driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	actionAdd = iota
+	actionErase
+)
+
+var (
+	errNotFound   = modules.NewHandlerErrorWithCode(http.StatusNotFound).(error)
+	errBadRequest = modules.NewHandlerErrorWithCode(http.StatusBadRequest).(error)
+)
+
+type photosDB struct {
+	database *sql.DB
+	filesDB  modules.FilesDB
+}
+
+// NewPhotosDB initializes photo library tables. The tables live in the same database as
+// FilesDB does, since every photo is a file known to FilesDB and shares its ID. Photos belong
+// to whoever filesDB tells owns their files.
+func NewPhotosDB(dbFile string, filesDB modules.FilesDB) modules.PhotosDB {
+	database, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=5000&_foreign_keys=1&_txlock=immediate", dbFile))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := new(photosDB)
+	db.database = database
+	db.filesDB = filesDB
+
+	_, err = database.Exec(`
+		CREATE TABLE IF NOT EXISTS photos (
+			id INTEGER PRIMARY KEY, /* files.id of the photo */
+			kind TEXT NOT NULL,
+			ctime INTEGER NOT NULL, /* capture time */
+
+			make  TEXT,
+			model TEXT,
+			width  INTEGER NOT NULL DEFAULT 0,
+			height INTEGER NOT NULL DEFAULT 0,
+			orientation INTEGER NOT NULL DEFAULT 0,
+
+			has_gps   INTEGER NOT NULL DEFAULT 0,
+			latitude  REAL NOT NULL DEFAULT 0,
+			longitude REAL NOT NULL DEFAULT 0,
+
+			CONSTRAINT fk_file
+				FOREIGN KEY (id)
+				REFERENCES files (id)
+				ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS i_photos_ctime ON photos (ctime, id);
+
+		CREATE TABLE IF NOT EXISTS photos_changelog (
+			id INTEGER PRIMARY KEY AUTOINCREMENT, /* to be used as a sync anchor */
+			photo_id INTEGER,
+			action INTEGER,
+			owner TEXT COLLATE NOCASE, /* whose library changed */
+
+			CONSTRAINT k_lastchange
+				UNIQUE (photo_id, owner)
+				ON CONFLICT REPLACE
+		);
+
+		CREATE TABLE IF NOT EXISTS albums (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			cover_id INTEGER,
+			ctime INTEGER,
+
+			CONSTRAINT fk_cover
+				FOREIGN KEY (cover_id)
+				REFERENCES photos (id)
+				ON DELETE SET NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS album_photos (
+			album_id INTEGER NOT NULL,
+			photo_id INTEGER NOT NULL,
+			position INTEGER NOT NULL,
+
+			CONSTRAINT fk_album
+				FOREIGN KEY (album_id)
+				REFERENCES albums (id)
+				ON DELETE CASCADE,
+
+			CONSTRAINT fk_photo
+				FOREIGN KEY (photo_id)
+				REFERENCES photos (id)
+				ON DELETE CASCADE,
+
+			CONSTRAINT k_membership
+				UNIQUE (album_id, photo_id)
+				ON CONFLICT IGNORE
+		);
+
+		CREATE INDEX IF NOT EXISTS i_album_photos_photo ON album_photos (photo_id);
+
+		CREATE TABLE IF NOT EXISTS photo_sources (
+			id INTEGER PRIMARY KEY, /* files.id of a directory indexed in place */
+			anchor INTEGER NOT NULL DEFAULT 0, /* FilesDB sync anchor the source is up to date with */
+			ctime INTEGER
+		);
+
+		/* photos clustered by capture time and location, see refreshMoments */
+		CREATE TABLE IF NOT EXISTS moments (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			stime INTEGER NOT NULL, /* capture time of the first and the last photo */
+			etime INTEGER NOT NULL,
+			count INTEGER NOT NULL DEFAULT 0,
+			cover_id INTEGER,
+			title TEXT,
+
+			country TEXT,
+			region TEXT,
+			city TEXT,
+
+			has_gps INTEGER NOT NULL DEFAULT 0,
+			latitude REAL NOT NULL DEFAULT 0,
+			longitude REAL NOT NULL DEFAULT 0,
+
+			dirty INTEGER NOT NULL DEFAULT 0, /* lost photos, to be recomputed */
+
+			CONSTRAINT fk_cover
+				FOREIGN KEY (cover_id)
+				REFERENCES photos (id)
+				ON DELETE SET NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS i_moments_time ON moments (stime, etime);
+
+		CREATE TABLE IF NOT EXISTS photo_keywords (
+			photo_id INTEGER NOT NULL,
+			keyword TEXT NOT NULL COLLATE NOCASE,
+			position INTEGER NOT NULL,
+
+			PRIMARY KEY (photo_id, keyword),
+
+			CONSTRAINT fk_photo
+				FOREIGN KEY (photo_id)
+				REFERENCES photos (id)
+				ON DELETE CASCADE
+		);
+
+		CREATE INDEX IF NOT EXISTS i_photo_keywords_keyword ON photo_keywords (keyword);
+
+		CREATE TABLE IF NOT EXISTS smart_albums (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			rules TEXT NOT NULL, /* JSON encoded smartRules */
+			ctime INTEGER
+		);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db.addColumn("photos", "source_id", "INTEGER REFERENCES photo_sources (id) ON DELETE CASCADE")
+	db.addColumn("photos", "duration", "REAL NOT NULL DEFAULT 0")
+	db.addColumn("photos", "codec", "TEXT")
+	db.addColumn("photos", "content_id", "TEXT")
+	db.addColumn("photos", "asset_id", "INTEGER REFERENCES photos (id) ON DELETE SET NULL")
+	db.addColumn("photos", "country", "TEXT")
+	db.addColumn("photos", "region", "TEXT")
+	db.addColumn("photos", "city", "TEXT")
+	db.addColumn("photos", "moment_id", "INTEGER REFERENCES moments (id) ON DELETE SET NULL")
+	db.addColumn("photos", "edit", "TEXT") /* JSON encoded imaging.Recipe */
+	db.addColumn("photos", "rating", "INTEGER NOT NULL DEFAULT 0")
+	db.addColumn("photos", "title", "TEXT")
+	db.addColumn("photos", "hash", "TEXT")  /* hex SHA-256 of the content, computed when needed */
+	db.addColumn("photos", "phash", "TEXT") /* hex perceptual hash, empty if the image can not be decoded */
+	// the file as it was when the metadata was read, see HasCurrentPhoto
+	db.addColumn("photos", "fsize", "INTEGER")
+	db.addColumn("photos", "fmdate", "INTEGER")
+	// users see what they own only, photos and sources belong to the owner of their files
+	for _, table := range []string{"photos", "photo_sources", "albums", "smart_albums", "moments"} {
+		db.addColumn(table, "owner", "TEXT NOT NULL DEFAULT '' COLLATE NOCASE")
+	}
+	db.addColumn("photos_changelog", "owner", "TEXT COLLATE NOCASE")
+	db.keyChangelogByOwner()
+
+	_, err = database.Exec(fmt.Sprintf(`
+		CREATE INDEX IF NOT EXISTS i_photos_asset ON photos (asset_id);
+		CREATE INDEX IF NOT EXISTS i_photos_content ON photos (content_id);
+		CREATE INDEX IF NOT EXISTS i_photos_place ON photos (country, region, city);
+		CREATE INDEX IF NOT EXISTS i_photos_owner ON photos (owner, ctime);
+		CREATE INDEX IF NOT EXISTS i_photos_changelog_owner ON photos_changelog (owner, id);
+
+		/* earlier versions read photos from the files as they are now */
+		UPDATE photos SET
+			fsize = (SELECT size FROM files WHERE files.id = photos.id),
+			fmdate = (SELECT mdate FROM files WHERE files.id = photos.id)
+		WHERE fsize IS NULL;
+
+		/* photos also go away by cascade from files, make sure clients hear about it. Earlier
+		   versions did not tell whose photo it was. */
+		DROP TRIGGER IF EXISTS t_photos_erase;
+		CREATE TRIGGER t_photos_erase AFTER DELETE ON photos
+		BEGIN
+			INSERT INTO photos_changelog (photo_id, action, owner) VALUES (old.id, %d, old.owner);
+		END;
+
+		/* changes of a photo are told to its owner */
+		CREATE TRIGGER IF NOT EXISTS t_photos_changelog_owner AFTER INSERT ON photos_changelog
+		WHEN new.owner IS NULL
+		BEGIN
+			UPDATE photos_changelog SET owner = coalesce((SELECT owner FROM photos WHERE id = new.photo_id), '')
+			WHERE id = new.id;
+		END;
+
+		/* resources paired into an asset disappear from listings, and show up when their asset is gone */
+		CREATE TRIGGER IF NOT EXISTS t_photos_attach AFTER UPDATE OF asset_id ON photos
+		WHEN old.asset_id IS NULL AND new.asset_id IS NOT NULL
+		BEGIN
+			INSERT INTO photos_changelog (photo_id, action) VALUES (new.id, %d);
+		END;
+
+		/* resources are detached by the foreign key of asset_id when their asset is erased, and
+		   conflicts are not replaced there. Earlier versions relied on that. */
+		DROP TRIGGER IF EXISTS t_photos_detach;
+		CREATE TRIGGER t_photos_detach AFTER UPDATE OF asset_id ON photos
+		WHEN old.asset_id IS NOT NULL AND new.asset_id IS NULL
+		BEGIN
+			DELETE FROM photos_changelog WHERE photo_id = new.id AND owner = new.owner;
+			INSERT INTO photos_changelog (photo_id, action, owner) VALUES (new.id, %d, new.owner);
+		END;
+
+		CREATE INDEX IF NOT EXISTS i_photos_moment ON photos (moment_id);
+
+		/* moments which lose photos or whose photos change are recomputed on the next refreshMoments */
+		CREATE TRIGGER IF NOT EXISTS t_photos_moment_erase AFTER DELETE ON photos
+		WHEN old.moment_id IS NOT NULL
+		BEGIN
+			UPDATE moments SET dirty = 1 WHERE id = old.moment_id;
+		END;
+
+		CREATE TRIGGER IF NOT EXISTS t_photos_moment_update
+		AFTER UPDATE OF ctime, latitude, longitude, asset_id ON photos
+		WHEN old.moment_id IS NOT NULL
+		BEGIN
+			UPDATE moments SET dirty = 1 WHERE id = old.moment_id;
+		END;
+	`, actionErase, actionErase, actionAdd))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db.resolveOwners()
+	db.resolvePlaces()
+	if err := db.updateMoments(); err != nil {
+		log.Fatal(err)
+	}
+
+	return db
+}
+
+// keyChangelogByOwner keeps the last change of a photo for each owner, so that a photo handed
+// over is erased for its former owner and added for the new one. Earlier versions kept one
+// change per photo. The sequence carries over, anchors of clients stay valid.
+func (m *photosDB) keyChangelogByOwner() {
+	var key sql.NullString
+	row := m.database.QueryRow(`
+		SELECT group_concat(c.name) FROM pragma_index_list('photos_changelog') AS l, pragma_index_info(l.name) AS c
+		WHERE l."unique" AND l.origin = 'u'`)
+	if err := row.Scan(&key); err != nil {
+		log.Fatal(err)
+	}
+	if key.String != "photo_id" {
+		return
+	}
+
+	// triggers writing to the changelog are created again once it is back
+	_, err := m.database.Exec(`
+		BEGIN;
+		DROP TRIGGER IF EXISTS t_photos_erase;
+		DROP TRIGGER IF EXISTS t_photos_attach;
+		DROP TRIGGER IF EXISTS t_photos_detach;
+		CREATE TABLE photos_changelog_owner (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			photo_id INTEGER,
+			action INTEGER,
+			owner TEXT COLLATE NOCASE,
+
+			CONSTRAINT k_lastchange
+				UNIQUE (photo_id, owner)
+				ON CONFLICT REPLACE
+		);
+		INSERT INTO photos_changelog_owner (id, photo_id, action, owner)
+			SELECT id, photo_id, action, owner FROM photos_changelog;
+		DELETE FROM sqlite_sequence WHERE name = 'photos_changelog_owner';
+		INSERT INTO sqlite_sequence (name, seq)
+			SELECT 'photos_changelog_owner', seq FROM sqlite_sequence WHERE name = 'photos_changelog';
+		DROP TABLE photos_changelog;
+		ALTER TABLE photos_changelog_owner RENAME TO photos_changelog;
+		COMMIT;
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// addColumn extends tables created by earlier versions
+func (m *photosDB) addColumn(table string, column string, definition string) {
+	rows, err := m.database.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		log.Fatal(err)
+	}
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, ctype string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			log.Fatal(err)
+		}
+		if name == column {
+			rows.Close()
+			return
+		}
+	}
+	rows.Close()
+
+	_, err = m.database.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// AddPhoto adds a file to the library. sourceID is 0 for files uploaded to the library itself.
+// Companions of the photo which are already in the library are paired with it into one asset,
+// moments around it are recomputed.
+func (m *photosDB) AddPhoto(id int64, sourceID int64, meta *media.Metadata) (err error) {
+	country, region, city := placeColumns(meta.HasGPS, meta.Latitude, meta.Longitude)
+
+	owner, _ := m.filesDB.GetOwner(id)
+
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	// a photo handed to someone else is gone for its former owner
+	_, err = tx.Exec(`
+		INSERT INTO photos_changelog (photo_id, action, owner)
+			SELECT id, $1, owner FROM photos WHERE id = $2 AND owner != $3`,
+		actionErase, id, owner)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO photos
+			(id, kind, ctime, make, model, width, height, orientation, has_gps, latitude, longitude,
+			source_id, duration, codec, content_id, country, region, city, owner, fsize, fmdate)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+				(SELECT size FROM files WHERE id = $1), (SELECT mdate FROM files WHERE id = $1))
+		ON CONFLICT (id) DO
+			UPDATE SET kind=$2, ctime=$3, make=$4, model=$5, width=$6, height=$7, orientation=$8,
+				has_gps=$9, latitude=$10, longitude=$11, source_id=$12, duration=$13, codec=$14,
+				content_id=$15, country=$16, region=$17, city=$18, owner=$19, hash=NULL, phash=NULL,
+				fsize=excluded.fsize, fmdate=excluded.fmdate,
+				moment_id=CASE WHEN owner = $19 THEN moment_id END`,
+		id, meta.Kind, meta.CaptureTime.Unix(), meta.Make, meta.Model,
+		meta.Width, meta.Height, meta.Orientation, meta.HasGPS, meta.Latitude, meta.Longitude,
+		sql.NullInt64{Int64: sourceID, Valid: sourceID != 0}, meta.Duration, meta.Codec, meta.ContentID,
+		country, region, city, owner)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	_, err = tx.Exec(
+		"insert into photos_changelog (photo_id, action) values ($1, $2)",
+		id, actionAdd)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err = storeXMP(tx, id, meta.XMP); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err = m.pairCompanions(tx, id); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err = m.refreshMoments(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// RemovePhoto drops the photo from the library, t_photos_erase records the change
+func (m *photosDB) RemovePhoto(id int64) (err error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	if _, err = tx.Exec("delete from photos where id = $1", id); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err = m.refreshMoments(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+func (m *photosDB) HasPhoto(id int64) bool {
+	var count int
+	row := m.database.QueryRow(`SELECT count(*) FROM photos WHERE id = ?`, id)
+	if err := row.Scan(&count); err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// HasCurrentPhoto tells whether the file is in the library as it is now. A file written again
+// since its metadata was read is not, it has to be added again.
+func (m *photosDB) HasCurrentPhoto(id int64) bool {
+	var count int
+	row := m.database.QueryRow(`
+		SELECT count(*) FROM photos JOIN files ON files.id = photos.id
+		WHERE photos.id = ? AND photos.fsize IS files.size AND photos.fmdate IS files.mdate`, id)
+	if err := row.Scan(&count); err != nil {
+		return false
+	}
+	return count > 0
+}
+
+func (m *photosDB) GetPhotoWithID(id int64) interface{} {
+	row := m.database.QueryRow(`
+		SELECT `+photoColumns+`
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE p.id = $1`,
+		id)
+
+	pm, err := scanPhotoMeta(row)
+	if err != nil {
+		return nil
+	}
+	if err := m.addDetails([]*photoMeta{pm}); err != nil {
+		log.Printf("%v", err)
+	}
+	return pm
+}
+
+func (m *photosDB) GetTimeline(owner string, offset int, count int) interface{} {
+	rows, err := m.database.Query(`
+		SELECT `+photoColumns+`
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE p.asset_id IS NULL AND p.owner = $1
+		ORDER BY p.ctime DESC, p.id DESC
+		LIMIT $2 OFFSET $3`,
+		owner, count, offset)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	result := &timelineMeta{
+		Offset: offset,
+		Photos: make([]*photoMeta, 0, count),
+	}
+
+	for rows.Next() {
+		pm, err := scanPhotoMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Photos = append(result.Photos, pm)
+	}
+	if err := m.addDetails(result.Photos); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	row := m.database.QueryRow(`SELECT count(*) FROM photos WHERE asset_id IS NULL AND owner = ?`, owner)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
+
+// GetChangesSince tells the owner what happened to their photos after the anchor
+func (m *photosDB) GetChangesSince(owner string, syncAnchor int64, count int) interface{} {
+	changes, err := m.database.Query(`
+		SELECT  c.id, c.photo_id, c.action, p.id IS NOT NULL AND p.asset_id IS NULL AND p.owner = c.owner,
+				`+photoColumns+`
+				FROM photos_changelog AS c
+				LEFT JOIN photos AS p ON c.photo_id = p.id
+				LEFT JOIN files AS f ON f.id = p.id
+				WHERE c.id > $1 AND c.owner = $2
+				ORDER BY c.id ASC
+				LIMIT $3`,
+		syncAnchor, owner, count)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer changes.Close()
+
+	result := struct {
+		New    []*photoMeta `json:"new"`
+		Erase  []int64      `json:"erase"`
+		Anchor int64        `json:"anchor"`
+		Remain int          `json:"remain"`
+		Size   int64        `json:"size"`
+	}{
+		New:    make([]*photoMeta, 0, count),
+		Erase:  make([]int64, 0, count),
+		Anchor: syncAnchor,
+		Remain: 0,
+		Size:   0,
+	}
+
+	for changes.Next() {
+		var photoID, action int64
+		var exists bool
+		pm, err := scanPhotoMeta(rowPrefix{changes, []interface{}{&result.Anchor, &photoID, &action, &exists}})
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+
+		switch {
+		case action == actionAdd && exists:
+			result.New = append(result.New, pm)
+		case action == actionErase:
+			result.Erase = append(result.Erase, photoID)
+		}
+	}
+
+	if err := m.addDetails(result.New); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	recordsLeft := m.database.QueryRow(`SELECT count(*) FROM photos_changelog WHERE id > $1 AND owner = $2`, result.Anchor, owner)
+	if err := recordsLeft.Scan(&result.Remain); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	itemSize := m.database.QueryRow(`SELECT count(*) FROM photos WHERE asset_id IS NULL AND owner = ?`, owner)
+	if err := itemSize.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
+
+// rowPrefix lets scanPhotoMeta read a row that has extra leading columns
+type rowPrefix struct {
+	row    scanner
+	prefix []interface{}
+}
+
+func (r rowPrefix) Scan(dest ...interface{}) error {
+	return r.row.Scan(append(r.prefix, dest...)...)
+}
@@ -0,0 +1,314 @@
+package photosdb
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/akokshar/storage/server/modules/geocode"
+)
+
+// placeLevels maps a level of the places listing to the columns photos are grouped by
+var placeLevels = map[string][]string{
+	"country": {"country"},
+	"region":  {"country", "region"},
+	"city":    {"country", "region", "city"},
+}
+
+type placeMeta struct {
+	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
+	City    string `json:"city,omitempty"`
+}
+
+type placeGroupMeta struct {
+	placeMeta
+	Count    int64     `json:"count"`
+	Cover    int64     `json:"cover"`
+	Location *location `json:"location"` // center of the photos taken there
+}
+
+type placeListMeta struct {
+	Level  string            `json:"level"`
+	Offset int               `json:"offset"`
+	Places []*placeGroupMeta `json:"places"`
+	Size   int64             `json:"size"`
+}
+
+type placeContentMeta struct {
+	Place  *placeMeta   `json:"place"`
+	Offset int          `json:"offset"`
+	Photos []*photoMeta `json:"photos"`
+	Size   int64        `json:"size"`
+}
+
+type geoJSONGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"` // longitude first
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties interface{}     `json:"properties"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string            `json:"type"`
+	Features []*geoJSONFeature `json:"features"`
+}
+
+func newGeoJSONFeature(loc *location, properties interface{}) *geoJSONFeature {
+	return &geoJSONFeature{
+		Type: "Feature",
+		Geometry: geoJSONGeometry{
+			Type:        "Point",
+			Coordinates: [2]float64{loc.Longitude, loc.Latitude},
+		},
+		Properties: properties,
+	}
+}
+
+// placeColumns resolves the location, photos without GPS have NULL places,
+// photos far from anything known have empty ones
+func placeColumns(hasGPS bool, latitude float64, longitude float64) (country, region, city sql.NullString) {
+	if !hasGPS {
+		return
+	}
+	country.Valid, region.Valid, city.Valid = true, true, true
+	if place := geocode.Lookup(latitude, longitude); place != nil {
+		country.String, region.String, city.String = place.Country, place.Region, place.City
+	}
+	return
+}
+
+// resolvePlaces geocodes photos added before places were known
+func (m *photosDB) resolvePlaces() {
+	rows, err := m.database.Query(`SELECT id, latitude, longitude FROM photos WHERE has_gps AND country IS NULL`)
+	if err != nil {
+		log.Fatal(err)
+	}
+	type pending struct {
+		id        int64
+		latitude  float64
+		longitude float64
+	}
+	photos := make([]pending, 0)
+	for rows.Next() {
+		var p pending
+		if err := rows.Scan(&p.id, &p.latitude, &p.longitude); err != nil {
+			log.Fatal(err)
+		}
+		photos = append(photos, p)
+	}
+	rows.Close()
+
+	for _, p := range photos {
+		country, region, city := placeColumns(true, p.latitude, p.longitude)
+		_, err := m.database.Exec(
+			"update photos set country = ?, region = ?, city = ? where id = ?",
+			country, region, city, p.id)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// placeFilter narrows the listing of the owner down to a country or a region, empty values match anything
+func placeFilter(owner string, country string, region string) (string, []interface{}) {
+	conditions := []string{"p.asset_id IS NULL", "p.owner = ?", "coalesce(p.country, '') != ''"}
+	args := []interface{}{owner}
+	if country != "" {
+		conditions = append(conditions, "p.country = ?")
+		args = append(args, country)
+	}
+	if region != "" {
+		conditions = append(conditions, "p.region = ?")
+		args = append(args, region)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// placeGroupColumns selects the place of a group, levels below the grouping one are empty
+func placeGroupColumns(level string) (string, string) {
+	columns := placeLevels[level]
+	selected := make([]string, 0, 3)
+	grouped := make([]string, 0, len(columns))
+	for _, column := range placeLevels["city"] {
+		if len(grouped) < len(columns) {
+			selected = append(selected, "coalesce(p."+column+", '')")
+			grouped = append(grouped, "p."+column)
+		} else {
+			selected = append(selected, "''")
+		}
+	}
+	return strings.Join(selected, ", "), strings.Join(grouped, ", ")
+}
+
+func (m *photosDB) GetPlaces(owner string, level string, country string, region string, offset int, count int) interface{} {
+	if _, ok := placeLevels[level]; !ok {
+		return nil
+	}
+	selected, grouped := placeGroupColumns(level)
+	where, args := placeFilter(owner, country, region)
+
+	// the cover is the latest photo, SQLite takes bare columns from the row max() picks
+	rows, err := m.database.Query(`
+		SELECT `+selected+`, count(*), p.id, max(p.ctime), avg(p.latitude), avg(p.longitude)
+		FROM photos AS p
+		WHERE `+where+`
+		GROUP BY `+grouped+`
+		ORDER BY count(*) DESC, `+grouped+`
+		LIMIT ? OFFSET ?`,
+		append(args, count, offset)...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	result := &placeListMeta{
+		Level:  level,
+		Offset: offset,
+		Places: make([]*placeGroupMeta, 0),
+	}
+
+	for rows.Next() {
+		pg := &placeGroupMeta{Location: new(location)}
+		var latest int64
+		err := rows.Scan(&pg.Country, &pg.Region, &pg.City, &pg.Count, &pg.Cover, &latest,
+			&pg.Location.Latitude, &pg.Location.Longitude)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Places = append(result.Places, pg)
+	}
+
+	row := m.database.QueryRow(`
+		SELECT count(*) FROM (SELECT 1 FROM photos AS p WHERE `+where+` GROUP BY `+grouped+`)`,
+		args...)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
+
+// GetPlacePhotos lists photos of a place as GetPlaces reported it, levels below the given one are not compared
+func (m *photosDB) GetPlacePhotos(owner string, level string, country string, region string, city string, offset int, count int) interface{} {
+	columns, ok := placeLevels[level]
+	if !ok {
+		return nil
+	}
+	place := &placeMeta{Country: country, Region: region, City: city}
+	values := []string{country, region, city}
+
+	conditions := []string{"p.asset_id IS NULL", "p.owner = ?"}
+	args := []interface{}{owner}
+	for i, column := range columns {
+		conditions = append(conditions, "coalesce(p."+column+", '') = ?")
+		args = append(args, values[i])
+	}
+	where := strings.Join(conditions, " AND ")
+
+	rows, err := m.database.Query(`
+		SELECT `+photoColumns+`
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE `+where+`
+		ORDER BY p.ctime DESC, p.id DESC
+		LIMIT ? OFFSET ?`,
+		append(args, count, offset)...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	result := &placeContentMeta{
+		Place:  place,
+		Offset: offset,
+		Photos: make([]*photoMeta, 0, count),
+	}
+
+	for rows.Next() {
+		pm, err := scanPhotoMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Photos = append(result.Photos, pm)
+	}
+	if err := m.addDetails(result.Photos); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	row := m.database.QueryRow(`SELECT count(*) FROM photos AS p WHERE `+where, args...)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
+
+// GetPlacesGeoJSON exports places of the level as points for map views. The "photo" level
+// has a point per photo with GPS data, including photos which did not resolve to a place.
+func (m *photosDB) GetPlacesGeoJSON(owner string, level string, country string, region string) interface{} {
+	result := &geoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]*geoJSONFeature, 0),
+	}
+
+	if level != "photo" {
+		places, ok := m.GetPlaces(owner, level, country, region, 0, -1).(*placeListMeta)
+		if !ok {
+			return nil
+		}
+		for _, pg := range places.Places {
+			result.Features = append(result.Features, newGeoJSONFeature(pg.Location, struct {
+				placeMeta
+				Count int64 `json:"count"`
+				Cover int64 `json:"cover"`
+			}{pg.placeMeta, pg.Count, pg.Cover}))
+		}
+		return result
+	}
+
+	where, args := placeFilter(owner, country, region)
+	if country == "" && region == "" {
+		where = "p.asset_id IS NULL AND p.owner = ?"
+	}
+	rows, err := m.database.Query(`
+		SELECT p.id, p.ctime, p.latitude, p.longitude,
+			coalesce(p.country, ''), coalesce(p.region, ''), coalesce(p.city, '')
+		FROM photos AS p
+		WHERE p.has_gps AND `+where+`
+		ORDER BY p.ctime, p.id`,
+		args...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var properties struct {
+			ID          int64 `json:"id"`
+			CaptureDate int64 `json:"capturedate"`
+			placeMeta
+		}
+		loc := new(location)
+		err := rows.Scan(&properties.ID, &properties.CaptureDate, &loc.Latitude, &loc.Longitude,
+			&properties.Country, &properties.Region, &properties.City)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Features = append(result.Features, newGeoJSONFeature(loc, properties))
+	}
+
+	return result
+}
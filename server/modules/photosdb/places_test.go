@@ -0,0 +1,158 @@
+package photosdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+func TestPlaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	photos := []struct {
+		path                string
+		hasGPS              bool
+		latitude, longitude float64
+	}{
+		{"alice/eiffel.jpg", true, 48.8584, 2.2945},
+		{"alice/louvre.jpg", true, 48.8606, 2.3376},
+		{"alice/port.jpg", true, 43.2965, 5.3698},
+		{"alice/promenade.jpg", true, 43.6950, 7.2650},
+		{"alice/scan.jpg", false, 0, 0},
+		// far from anything known
+		{"alice/atlantic.jpg", true, 0, -30},
+		{"bob/eiffel.jpg", true, 48.8584, 2.2945},
+	}
+	for _, p := range photos {
+		os.MkdirAll(path.Dir(path.Join(dir, p.path)), 0755)
+		ioutil.WriteFile(path.Join(dir, p.path), []byte(p.path), 0644)
+	}
+	db, filesDB := newTestLibrary(t, dir, "alice", "bob")
+	id := func(p string) int64 {
+		id, err := filesDB.GetIDForPath(path.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	for i, p := range photos {
+		meta := &media.Metadata{
+			Kind:        media.KindPhoto,
+			CaptureTime: time.Date(2020, time.May, i+1, 12, 0, 0, 0, time.UTC),
+			HasGPS:      p.hasGPS,
+			Latitude:    p.latitude,
+			Longitude:   p.longitude,
+		}
+		if err := db.AddPhoto(id(p.path), 0, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paris := "France/Île-de-France/Paris"
+
+	// places tells the groups of the level as place:count:cover
+	places := func(level string, country string, region string) string {
+		list, ok := db.GetPlaces("alice", level, country, region, 0, 100).(*placeListMeta)
+		if !ok {
+			t.Fatalf("%s: no places", level)
+		}
+		groups := make([]string, 0)
+		for _, pg := range list.Places {
+			groups = append(groups, fmt.Sprintf("%s/%s/%s:%d:%d", pg.Country, pg.Region, pg.City, pg.Count, pg.Cover))
+		}
+		if list.Size != int64(len(groups)) {
+			t.Errorf("%s: %d places of %d", level, len(groups), list.Size)
+		}
+		return fmt.Sprint(groups)
+	}
+
+	// photos without GPS or a place are left out, the latest photo is the cover
+	cases := []struct {
+		level, country, region string
+		want                   string
+	}{
+		{"country", "", "", fmt.Sprintf("[France//:4:%d]", id("alice/promenade.jpg"))},
+		{"region", "", "", fmt.Sprintf("[France/Provence-Alpes-Côte d'Azur/:2:%d France/Île-de-France/:2:%d]", id("alice/promenade.jpg"), id("alice/louvre.jpg"))},
+		{"city", "", "", fmt.Sprintf("[%s:2:%d France/Provence-Alpes-Côte d'Azur/Marseille:1:%d France/Provence-Alpes-Côte d'Azur/Nice:1:%d]", paris, id("alice/louvre.jpg"), id("alice/port.jpg"), id("alice/promenade.jpg"))},
+		{"city", "France", "Provence-Alpes-Côte d'Azur", fmt.Sprintf("[France/Provence-Alpes-Côte d'Azur/Marseille:1:%d France/Provence-Alpes-Côte d'Azur/Nice:1:%d]", id("alice/port.jpg"), id("alice/promenade.jpg"))},
+		{"city", "Italy", "", "[]"},
+	}
+	for _, c := range cases {
+		if got := places(c.level, c.country, c.region); got != c.want {
+			t.Errorf("%s in %q %q: %s", c.level, c.country, c.region, got)
+		}
+	}
+	if db.GetPlaces("alice", "street", "", "", 0, 100) != nil {
+		t.Errorf("unknown level listed")
+	}
+
+	content := db.GetPlacePhotos("alice", "city", "France", "Île-de-France", "Paris", 0, 100).(*placeContentMeta)
+	if len(content.Photos) != 2 || content.Size != 2 || content.Photos[0].ID != id("alice/louvre.jpg") || content.Photos[1].ID != id("alice/eiffel.jpg") {
+		t.Errorf("photos of Paris: %+v", content.Photos)
+	}
+
+	// geoJSON reads the export as a client would
+	geoJSON := func(level string) (features []map[string]interface{}) {
+		var collection struct {
+			Type     string `json:"type"`
+			Features []struct {
+				Type     string `json:"type"`
+				Geometry struct {
+					Type        string    `json:"type"`
+					Coordinates []float64 `json:"coordinates"`
+				} `json:"geometry"`
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"features"`
+		}
+		data, _ := json.Marshal(db.GetPlacesGeoJSON("alice", level, "", ""))
+		if err := json.Unmarshal(data, &collection); err != nil || collection.Type != "FeatureCollection" {
+			t.Fatalf("%s: %s", level, data)
+		}
+		for _, f := range collection.Features {
+			if f.Type != "Feature" || f.Geometry.Type != "Point" || len(f.Geometry.Coordinates) != 2 {
+				t.Errorf("%s: feature %+v", level, f)
+			}
+			// longitude goes first
+			f.Properties["lon"], f.Properties["lat"] = f.Geometry.Coordinates[0], f.Geometry.Coordinates[1]
+			features = append(features, f.Properties)
+		}
+		return features
+	}
+
+	cities := geoJSON("city")
+	if len(cities) != 3 {
+		t.Fatalf("cities: %v", cities)
+	}
+	// a place is where its photos were taken on average
+	if f := cities[0]; f["city"] != "Paris" || f["count"] != 2.0 || f["cover"] != float64(id("alice/louvre.jpg")) ||
+		math.Abs(f["lat"].(float64)-48.8595) > 1e-9 || math.Abs(f["lon"].(float64)-2.31605) > 1e-9 {
+		t.Errorf("Paris: %v", f)
+	}
+
+	// every photo with GPS is a point, those which did not resolve to a place too
+	points := geoJSON("photo")
+	got := make([]string, 0)
+	for _, f := range points {
+		city, _ := f["city"].(string)
+		got = append(got, fmt.Sprintf("%v:%s:%.4f,%.4f", f["id"], city, f["lat"], f["lon"]))
+	}
+	want := []string{
+		fmt.Sprintf("%d:Paris:48.8584,2.2945", id("alice/eiffel.jpg")),
+		fmt.Sprintf("%d:Paris:48.8606,2.3376", id("alice/louvre.jpg")),
+		fmt.Sprintf("%d:Marseille:43.2965,5.3698", id("alice/port.jpg")),
+		fmt.Sprintf("%d:Nice:43.6950,7.2650", id("alice/promenade.jpg")),
+		fmt.Sprintf("%d::0.0000,-30.0000", id("alice/atlantic.jpg")),
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("photos: %v", got)
+	}
+}
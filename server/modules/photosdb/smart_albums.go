@@ -0,0 +1,328 @@
+package photosdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+// smartRules select photos by their metadata. All the rules that are set must match.
+type smartRules struct {
+	From      int64  `json:"from,omitempty"` // capture time range, unix time
+	To        int64  `json:"to,omitempty"`
+	Make      string `json:"make,omitempty"` // camera, case insensitive substring
+	Model     string `json:"model,omitempty"`
+	HasGPS    *bool  `json:"hasgps,omitempty"`
+	Kind      string `json:"kind,omitempty"`
+	MinWidth  int    `json:"minwidth,omitempty"`
+	MaxWidth  int    `json:"maxwidth,omitempty"`
+	MinHeight int    `json:"minheight,omitempty"`
+	MaxHeight int    `json:"maxheight,omitempty"`
+	NoCamera  bool   `json:"nocamera,omitempty"` // e.g. screenshots and downloaded images
+	Folder    int64  `json:"folder,omitempty"`   // files.id of a directory photos come from, recursively
+	MinRating int    `json:"minrating,omitempty"`
+	Keyword   string `json:"keyword,omitempty"` // case insensitive, whole keyword
+
+	owner string // the rules select photos of the owner of the album only
+}
+
+type smartAlbumMeta struct {
+	ID    int64       `json:"id"`
+	Name  string      `json:"name"`
+	Rules *smartRules `json:"rules"`
+	Cover int64       `json:"cover"`
+	Count int64       `json:"count"`
+	CDate int64       `json:"cdate"`
+}
+
+type smartAlbumListMeta struct {
+	Offset int               `json:"offset"`
+	Albums []*smartAlbumMeta `json:"albums"`
+	Size   int64             `json:"size"`
+}
+
+type smartAlbumContentMeta struct {
+	Album  *smartAlbumMeta `json:"album"`
+	Offset int             `json:"offset"`
+	Photos []*photoMeta    `json:"photos"`
+	Size   int64           `json:"size"`
+}
+
+func parseSmartRules(data []byte) (*smartRules, error) {
+	rules := new(smartRules)
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(rules); err != nil {
+		return nil, errBadRequest
+	}
+	if rules.Kind != "" && rules.Kind != media.KindPhoto && rules.Kind != media.KindVideo {
+		return nil, errBadRequest
+	}
+	if rules.To != 0 && rules.From > rules.To {
+		return nil, errBadRequest
+	}
+	return rules, nil
+}
+
+// where compiles the rules into a condition over photos aliased as p. Values are never
+// put into the SQL text, they go into args.
+func (r *smartRules) where() (string, []interface{}) {
+	conditions := []string{"p.asset_id IS NULL", "p.owner = ?"}
+	args := []interface{}{r.owner}
+
+	add := func(condition string, values ...interface{}) {
+		conditions = append(conditions, condition)
+		args = append(args, values...)
+	}
+
+	if r.From != 0 {
+		add("p.ctime >= ?", r.From)
+	}
+	if r.To != 0 {
+		add("p.ctime <= ?", r.To)
+	}
+	if r.Make != "" {
+		add("p.make LIKE ? ESCAPE '\\'", "%"+escapeLike(r.Make)+"%")
+	}
+	if r.Model != "" {
+		add("p.model LIKE ? ESCAPE '\\'", "%"+escapeLike(r.Model)+"%")
+	}
+	if r.HasGPS != nil {
+		add("p.has_gps = ?", *r.HasGPS)
+	}
+	if r.Kind != "" {
+		add("p.kind = ?", r.Kind)
+	}
+	if r.MinWidth != 0 {
+		add("p.width >= ?", r.MinWidth)
+	}
+	if r.MaxWidth != 0 {
+		add("p.width <= ?", r.MaxWidth)
+	}
+	if r.MinHeight != 0 {
+		add("p.height >= ?", r.MinHeight)
+	}
+	if r.MaxHeight != 0 {
+		add("p.height <= ?", r.MaxHeight)
+	}
+	if r.NoCamera {
+		add("coalesce(p.make, '') = '' AND coalesce(p.model, '') = ''")
+	}
+	if r.MinRating != 0 {
+		add("p.rating >= ?", r.MinRating)
+	}
+	if r.Keyword != "" {
+		add("EXISTS (SELECT 1 FROM photo_keywords AS k WHERE k.photo_id = p.id AND k.keyword = ?)", r.Keyword)
+	}
+	if r.Folder != 0 {
+		add(`p.id IN (
+			WITH RECURSIVE subtree(id) AS (
+				SELECT ?
+				UNION ALL
+				SELECT files.id FROM files JOIN subtree ON files.parent_id = subtree.id
+			)
+			SELECT id FROM subtree)`, r.Folder)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (m *photosDB) CreateSmartAlbum(owner string, name string, rules []byte) (id int64, err error) {
+	if name == "" {
+		return 0, errBadRequest
+	}
+	if _, err = parseSmartRules(rules); err != nil {
+		return
+	}
+
+	res, err := m.database.Exec(
+		"insert into smart_albums (name, rules, ctime, owner) values (?, ?, ?, ?)",
+		name, string(rules), time.Now().Unix(), owner)
+	if err != nil {
+		return
+	}
+	return res.LastInsertId()
+}
+
+// UpdateSmartAlbum replaces the name and rules, empty values leave them as they are
+func (m *photosDB) UpdateSmartAlbum(id int64, name string, rules []byte) error {
+	if len(rules) > 0 {
+		if _, err := parseSmartRules(rules); err != nil {
+			return err
+		}
+	}
+
+	res, err := m.database.Exec(`
+		update smart_albums
+		set name = coalesce(nullif(?, ''), name), rules = coalesce(nullif(?, ''), rules)
+		where id = ?`,
+		name, string(rules), id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (m *photosDB) DeleteSmartAlbum(id int64) error {
+	res, err := m.database.Exec("delete from smart_albums where id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+// evaluateSmartAlbum fills in the count and the cover, which depend on the photos the rules select now
+func (m *photosDB) evaluateSmartAlbum(sm *smartAlbumMeta) error {
+	where, args := sm.Rules.where()
+	row := m.database.QueryRow(`SELECT count(*) FROM photos AS p WHERE `+where, args...)
+	if err := row.Scan(&sm.Count); err != nil {
+		return err
+	}
+
+	row = m.database.QueryRow(`
+		SELECT p.id FROM photos AS p WHERE `+where+`
+		ORDER BY p.ctime DESC, p.id DESC LIMIT 1`, args...)
+	if err := row.Scan(&sm.Cover); err != nil {
+		sm.Cover = 0
+	}
+	return nil
+}
+
+func (m *photosDB) getSmartAlbum(id int64) (*smartAlbumMeta, error) {
+	sm := new(smartAlbumMeta)
+	var rules, owner string
+	row := m.database.QueryRow(`SELECT id, name, rules, ctime, owner FROM smart_albums WHERE id = ?`, id)
+	if err := row.Scan(&sm.ID, &sm.Name, &rules, &sm.CDate, &owner); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if sm.Rules, err = parseSmartRules([]byte(rules)); err != nil {
+		return nil, err
+	}
+	sm.Rules.owner = owner
+	if err = m.evaluateSmartAlbum(sm); err != nil {
+		return nil, err
+	}
+	return sm, nil
+}
+
+// GetSmartAlbumOwner is empty for smart albums which do not exist
+func (m *photosDB) GetSmartAlbumOwner(id int64) string {
+	var owner string
+	if err := m.database.QueryRow(`SELECT owner FROM smart_albums WHERE id = ?`, id).Scan(&owner); err != nil {
+		return ""
+	}
+	return owner
+}
+
+func (m *photosDB) GetSmartAlbumWithID(id int64) interface{} {
+	sm, err := m.getSmartAlbum(id)
+	if err != nil {
+		return nil
+	}
+	return sm
+}
+
+func (m *photosDB) GetSmartAlbums(owner string, offset int, count int) interface{} {
+	rows, err := m.database.Query(`
+		SELECT id FROM smart_albums
+		WHERE owner = ?
+		ORDER BY name COLLATE NOCASE, id
+		LIMIT ? OFFSET ?`,
+		owner, count, offset)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	ids := make([]int64, 0, count)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			log.Printf("%v", err)
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	result := &smartAlbumListMeta{
+		Offset: offset,
+		Albums: make([]*smartAlbumMeta, 0, len(ids)),
+	}
+
+	for _, id := range ids {
+		sm, err := m.getSmartAlbum(id)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Albums = append(result.Albums, sm)
+	}
+
+	row := m.database.QueryRow(`SELECT count(*) FROM smart_albums WHERE owner = ?`, owner)
+	if err := row.Scan(&result.Size); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
+
+func (m *photosDB) GetSmartAlbumPhotos(id int64, offset int, count int) interface{} {
+	sm, err := m.getSmartAlbum(id)
+	if err != nil {
+		return nil
+	}
+
+	where, args := sm.Rules.where()
+	rows, err := m.database.Query(`
+		SELECT `+photoColumns+`
+		FROM photos AS p LEFT JOIN files AS f ON f.id = p.id
+		WHERE `+where+`
+		ORDER BY p.ctime DESC, p.id DESC
+		LIMIT ? OFFSET ?`,
+		append(args, count, offset)...)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	result := &smartAlbumContentMeta{
+		Album:  sm,
+		Offset: offset,
+		Photos: make([]*photoMeta, 0, count),
+		Size:   sm.Count,
+	}
+
+	for rows.Next() {
+		pm, err := scanPhotoMeta(rows)
+		if err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		result.Photos = append(result.Photos, pm)
+	}
+	if err := m.addDetails(result.Photos); err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+
+	return result
+}
@@ -0,0 +1,130 @@
+package photosdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+func TestParseSmartRules(t *testing.T) {
+	cases := []struct {
+		rules string
+		ok    bool
+	}{
+		{`{}`, true},
+		{`{"kind": "video", "hasgps": false}`, true},
+		{`{"from": 100}`, true},
+		{`{"from": 100, "to": 200, "folder": 7, "keyword": "sea"}`, true},
+		{`{"from": 200, "to": 100}`, false},
+		{`{"kind": "gif"}`, false},
+		{`{"colour": "red"}`, false},
+		{`{"minwidth": "wide"}`, false},
+		{`[]`, false},
+		{``, false},
+	}
+	for _, c := range cases {
+		rules, err := parseSmartRules([]byte(c.rules))
+		if (err == nil) != c.ok {
+			t.Errorf("%s: %v", c.rules, err)
+		}
+		if !c.ok && err != errBadRequest {
+			t.Errorf("%s: %v, want errBadRequest", c.rules, err)
+		}
+		if c.ok && c.rules == `{"kind": "video", "hasgps": false}` && (rules.HasGPS == nil || *rules.HasGPS) {
+			t.Errorf("%s: hasgps %v", c.rules, rules.HasGPS)
+		}
+	}
+}
+
+func TestSmartAlbums(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	photos := []struct {
+		path string
+		make string
+		gps  bool
+	}{
+		{"alice/trips/2020/sea/1.jpg", "Canon", true},
+		{"alice/trips/2020/2.jpg", "100% Camera", false},
+		{"alice/trips/3.jpg", "", true},
+		{"alice/other/4.jpg", "Canon", false},
+		{"bob/trips/5.jpg", "Canon", true},
+	}
+	for _, p := range photos {
+		os.MkdirAll(path.Dir(path.Join(dir, p.path)), 0755)
+		ioutil.WriteFile(path.Join(dir, p.path), []byte(p.path), 0644)
+	}
+	db, filesDB := newTestLibrary(t, dir, "alice", "bob")
+	id := func(p string) int64 {
+		id, err := filesDB.GetIDForPath(path.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	names := make(map[int64]string)
+	for i, p := range photos {
+		meta := &media.Metadata{
+			Kind:        media.KindPhoto,
+			CaptureTime: time.Date(2020, time.May, i+1, 12, 0, 0, 0, time.UTC),
+			Make:        p.make,
+			HasGPS:      p.gps,
+		}
+		if err := db.AddPhoto(id(p.path), 0, meta); err != nil {
+			t.Fatal(err)
+		}
+		names[id(p.path)] = path.Base(p.path)
+	}
+
+	cases := []struct {
+		rules string
+		want  []string
+	}{
+		// folders take photos of every directory below them
+		{fmt.Sprintf(`{"folder": %d}`, id("alice/trips")), []string{"1.jpg", "2.jpg", "3.jpg"}},
+		{fmt.Sprintf(`{"folder": %d}`, id("alice/trips/2020")), []string{"1.jpg", "2.jpg"}},
+		{fmt.Sprintf(`{"folder": %d}`, id("alice/trips/2020/sea/1.jpg")), []string{"1.jpg"}},
+		// and photos of the owner only
+		{fmt.Sprintf(`{"folder": %d}`, id("bob/trips")), []string{}},
+		{fmt.Sprintf(`{"folder": %d, "hasgps": true}`, id("alice/trips")), []string{"1.jpg", "3.jpg"}},
+		{`{"hasgps": false}`, []string{"2.jpg", "4.jpg"}},
+		{`{"make": "canon"}`, []string{"1.jpg", "4.jpg"}},
+		// wildcards of LIKE are taken literally
+		{`{"make": "0%"}`, []string{"2.jpg"}},
+		{`{"make": "_"}`, []string{}},
+		{`{"nocamera": true}`, []string{"3.jpg"}},
+		{fmt.Sprintf(`{"from": %d, "to": %d}`, time.Date(2020, time.May, 2, 0, 0, 0, 0, time.UTC).Unix(),
+			time.Date(2020, time.May, 3, 23, 0, 0, 0, time.UTC).Unix()), []string{"2.jpg", "3.jpg"}},
+	}
+	for _, c := range cases {
+		album, err := db.CreateSmartAlbum("alice", "smart", []byte(c.rules))
+		if err != nil {
+			t.Fatalf("%s: %v", c.rules, err)
+		}
+		content, ok := db.GetSmartAlbumPhotos(album, 0, 100).(*smartAlbumContentMeta)
+		if !ok {
+			t.Fatalf("%s: no album", c.rules)
+		}
+		got := make([]string, 0)
+		for _, pm := range content.Photos {
+			got = append(got, names[pm.ID])
+		}
+		sort.Strings(got)
+		if fmt.Sprint(got) != fmt.Sprint(c.want) || content.Album.Count != int64(len(c.want)) {
+			t.Errorf("%s: %v of %d, want %v", c.rules, got, content.Album.Count, c.want)
+		}
+	}
+
+	if _, err := db.CreateSmartAlbum("alice", "smart", []byte(`{"kind": "gif"}`)); err != errBadRequest {
+		t.Errorf("bad rules: %v", err)
+	}
+}
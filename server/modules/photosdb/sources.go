@@ -0,0 +1,155 @@
+package photosdb
+
+import (
+	"log"
+	"time"
+)
+
+type sourceMeta struct {
+	ID     int64  `json:"id"`
+	Name   string `json:"name"`
+	Count  int64  `json:"count"`
+	Anchor int64  `json:"anchor"`
+	CDate  int64  `json:"cdate"`
+}
+
+// AddSource indexes the directory for its owner, the photos found in it are theirs
+func (m *photosDB) AddSource(id int64, syncAnchor int64) error {
+	owner, _ := m.filesDB.GetOwner(id)
+	_, err := m.database.Exec(`
+		INSERT INTO photo_sources (id, anchor, ctime, owner) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO NOTHING`,
+		id, syncAnchor, time.Now().Unix(), owner)
+	return err
+}
+
+// GetSourceOwner is empty for directories which are not sources
+func (m *photosDB) GetSourceOwner(id int64) string {
+	var owner string
+	if err := m.database.QueryRow(`SELECT owner FROM photo_sources WHERE id = ?`, id).Scan(&owner); err != nil {
+		return ""
+	}
+	return owner
+}
+
+// RemoveSource drops the source and every photo indexed from it. The files stay untouched.
+func (m *photosDB) RemoveSource(id int64) (err error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	res, err := tx.Exec("delete from photo_sources where id = $1", id)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return errNotFound
+	}
+
+	if _, err = tx.Exec("delete from photos where source_id = $1", id); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err = m.refreshMoments(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+func (m *photosDB) SetSourceSyncAnchor(id int64, syncAnchor int64) error {
+	_, err := m.database.Exec("update photo_sources set anchor = $1 where id = $2", syncAnchor, id)
+	return err
+}
+
+func (m *photosDB) GetSourceSyncAnchors() map[int64]int64 {
+	anchors := make(map[int64]int64)
+
+	rows, err := m.database.Query(`SELECT id, anchor FROM photo_sources`)
+	if err != nil {
+		log.Printf("%v", err)
+		return anchors
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, anchor int64
+		if err := rows.Scan(&id, &anchor); err != nil {
+			log.Printf("%v", err)
+			return anchors
+		}
+		anchors[id] = anchor
+	}
+	return anchors
+}
+
+// GetSourceOfPhoto returns the source the photo was indexed from, 0 if it was uploaded to the library
+func (m *photosDB) GetSourceOfPhoto(id int64) int64 {
+	var sourceID int64
+	row := m.database.QueryRow(`SELECT coalesce(source_id, 0) FROM photos WHERE id = ?`, id)
+	if err := row.Scan(&sourceID); err != nil {
+		return 0
+	}
+	return sourceID
+}
+
+func (m *photosDB) GetSources(owner string) interface{} {
+	rows, err := m.database.Query(`
+		SELECT s.id, coalesce(f.name, ''), s.anchor, coalesce(s.ctime, 0),
+			(SELECT count(*) FROM photos WHERE source_id = s.id)
+		FROM photo_sources AS s LEFT JOIN files AS f ON f.id = s.id
+		WHERE s.owner = ?
+		ORDER BY s.id`,
+		owner)
+	if err != nil {
+		log.Printf("%v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	sources := make([]*sourceMeta, 0)
+	for rows.Next() {
+		sm := new(sourceMeta)
+		if err := rows.Scan(&sm.ID, &sm.Name, &sm.Anchor, &sm.CDate, &sm.Count); err != nil {
+			log.Printf("%v", err)
+			return nil
+		}
+		sources = append(sources, sm)
+	}
+
+	return sources
+}
+
+// PruneOrphans removes photos whose files are gone from FilesDB, e.g. erased together with
+// their directory or dropped by a rescan, neither of which leaves a record per file in the change feed.
+// Moments which lost photos the files module erased are recomputed as well.
+func (m *photosDB) PruneOrphans() (err error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return
+	}
+
+	if _, err = tx.Exec("delete from photos where id not in (select id from files)"); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if _, err = tx.Exec("delete from photo_sources where id not in (select id from files)"); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	if err = m.refreshMoments(tx); err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
@@ -0,0 +1,143 @@
+package photosdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+func TestPruneOrphans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photosdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := []string{"alice/pics/1.jpg", "alice/pics/2.jpg", "alice/pics/sub/3.jpg", "alice/more/4.jpg", "alice/up.jpg"}
+	for _, p := range files {
+		os.MkdirAll(path.Dir(path.Join(dir, p)), 0755)
+		ioutil.WriteFile(path.Join(dir, p), []byte(p), 0644)
+	}
+	db, filesDB := newTestLibrary(t, dir, "alice")
+	id := func(p string) int64 {
+		id, err := filesDB.GetIDForPath(path.Join(dir, p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	pics, more := id("alice/pics"), id("alice/more")
+	for _, source := range []int64{pics, more} {
+		if err := db.AddSource(source, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, p := range files {
+		sourceID := pics
+		switch path.Dir(p) {
+		case "alice/more":
+			sourceID = more
+		case "alice":
+			sourceID = 0
+		}
+		meta := &media.Metadata{Kind: media.KindPhoto, CaptureTime: time.Date(2020, time.May, i+1, 12, 0, 0, 0, time.UTC)}
+		if err := db.AddPhoto(id(p), sourceID, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var anchor int64
+	// changes tells what the change feed of the user holds since the last call
+	changes := func(user string) (added int, erased []int64) {
+		var result struct {
+			New    []*photoMeta `json:"new"`
+			Erase  []int64      `json:"erase"`
+			Anchor int64        `json:"anchor"`
+		}
+		data, _ := json.Marshal(db.GetChangesSince(user, anchor, 100))
+		if err := json.Unmarshal(data, &result); err != nil {
+			t.Fatal(err)
+		}
+		if user == "alice" {
+			anchor = result.Anchor
+		}
+		sort.Slice(result.Erase, func(i, j int) bool { return result.Erase[i] < result.Erase[j] })
+		return len(result.New), result.Erase
+	}
+	if added, erased := changes("alice"); added != len(files) || len(erased) != 0 {
+		t.Fatalf("added: %d new, %v erased", added, erased)
+	}
+
+	// photos go with the directory the files module erases
+	photo3 := id("alice/pics/sub/3.jpg")
+	if err := filesDB.RemoveItem(id("alice/pics/sub")); err != nil {
+		t.Fatal(err)
+	}
+	if db.HasPhoto(photo3) {
+		t.Errorf("photo of an erased directory is left")
+	}
+	if _, erased := changes("bob"); len(erased) != 0 {
+		t.Errorf("bob is told of erased photos of alice: %v", erased)
+	}
+	if _, erased := changes("alice"); fmt.Sprint(erased) != fmt.Sprint([]int64{photo3}) {
+		t.Errorf("erased directory: %v erased, want %d", erased, photo3)
+	}
+
+	// files erased without foreign keys leave orphans until the library looks for them,
+	// sources go with their directory
+	photo1, photo2 := id("alice/pics/1.jpg"), id("alice/pics/2.jpg")
+	conn, err := db.database.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.ExecContext(context.Background(), "PRAGMA foreign_keys = OFF")
+	if _, err := conn.ExecContext(context.Background(), "DELETE FROM files WHERE id IN (?, ?, ?)", pics, photo1, photo2); err != nil {
+		t.Fatal(err)
+	}
+	conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+	conn.Close()
+	if !db.HasPhoto(photo1) {
+		t.Errorf("orphan is gone before pruning")
+	}
+	if err := db.PruneOrphans(); err != nil {
+		t.Fatal(err)
+	}
+	if db.HasPhoto(photo1) || db.HasPhoto(photo2) {
+		t.Errorf("orphans are left")
+	}
+	if owner := db.GetSourceOwner(pics); owner != "" {
+		t.Errorf("source of %q is left", owner)
+	}
+	if _, erased := changes("alice"); fmt.Sprint(erased) != fmt.Sprint([]int64{photo1, photo2}) {
+		t.Errorf("source: %v erased, want %d %d", erased, photo1, photo2)
+	}
+
+	// a source removed from the library takes its photos along and leaves the files
+	photo4 := id("alice/more/4.jpg")
+	if err := db.RemoveSource(more); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RemoveSource(more); err != errNotFound {
+		t.Errorf("removed again: %v", err)
+	}
+	if _, erased := changes("alice"); fmt.Sprint(erased) != fmt.Sprint([]int64{photo4}) {
+		t.Errorf("removed source: %v erased, want %d", erased, photo4)
+	}
+	if _, err := filesDB.GetIDForPath(path.Join(dir, "alice/more/4.jpg")); err != nil {
+		t.Errorf("file of the removed source: %v", err)
+	}
+
+	if !db.HasPhoto(id("alice/up.jpg")) {
+		t.Errorf("uploaded photo is gone")
+	}
+	if sources, ok := db.GetSources("alice").([]*sourceMeta); !ok || len(sources) != 0 {
+		t.Errorf("sources: %v", sources)
+	}
+}
@@ -0,0 +1,203 @@
+package photosdb
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
+
+// storeXMP sets the properties of one resource as its file has them, nil clears them
+func storeXMP(tx *sql.Tx, id int64, x *media.XMP) error {
+	if x == nil {
+		x = new(media.XMP)
+	}
+	_, err := tx.Exec(
+		"update photos set rating = ?, title = ? where id = ?",
+		x.Rating, sql.NullString{String: x.Title, Valid: x.Title != ""}, id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("delete from photo_keywords where photo_id = ?", id); err != nil {
+		return err
+	}
+	for i, keyword := range x.Keywords {
+		_, err := tx.Exec(
+			"insert or ignore into photo_keywords (photo_id, keyword, position) values (?, ?, ?)",
+			id, keyword, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadXMP(tx *sql.Tx, id int64) (*media.XMP, error) {
+	x := new(media.XMP)
+	row := tx.QueryRow("SELECT rating, coalesce(title, '') FROM photos WHERE id = ?", id)
+	if err := row.Scan(&x.Rating, &x.Title); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query("SELECT keyword FROM photo_keywords WHERE photo_id = ? ORDER BY position", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var keyword string
+		if err := rows.Scan(&keyword); err != nil {
+			return nil, err
+		}
+		x.Keywords = append(x.Keywords, keyword)
+	}
+	return x, rows.Err()
+}
+
+func isEmptyXMP(x *media.XMP) bool {
+	return x.Rating == 0 && x.Title == "" && len(x.Keywords) == 0
+}
+
+// unifyXMP gives all resources of an asset the same properties. Those of the asset win,
+// unless it has none, e.g. a JPEG paired with a RAW file rated in a desktop tool.
+func unifyXMP(tx *sql.Tx, primaryID int64, members []*companion) error {
+	x, err := loadXMP(tx, primaryID)
+	if err != nil {
+		return err
+	}
+	for _, c := range members {
+		if !isEmptyXMP(x) {
+			break
+		}
+		if x, err = loadXMP(tx, c.id); err != nil {
+			return err
+		}
+	}
+
+	for _, c := range members {
+		if err := storeXMP(tx, c.id, x); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPhotoXMP sets rating, title and keywords of the asset the photo belongs to
+func (m *photosDB) SetPhotoXMP(id int64, x *media.XMP) error {
+	if x.Rating < media.RatingRejected || x.Rating > media.RatingMax {
+		return errBadRequest
+	}
+	keywords := make([]string, 0, len(x.Keywords))
+	for _, keyword := range x.Keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			keywords = append(keywords, keyword)
+		}
+	}
+	x = &media.XMP{Rating: x.Rating, Title: strings.TrimSpace(x.Title), Keywords: keywords}
+
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+
+	var assetID int64
+	row := tx.QueryRow(`SELECT coalesce(asset_id, id) FROM photos WHERE id = ?`, id)
+	if err := row.Scan(&assetID); err != nil {
+		tx.Rollback()
+		return errNotFound
+	}
+
+	rows, err := tx.Query(`SELECT id FROM photos WHERE id = ? OR asset_id = ?`, assetID, assetID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	resources := make([]int64, 0)
+	for rows.Next() {
+		var resourceID int64
+		if err := rows.Scan(&resourceID); err != nil {
+			rows.Close()
+			tx.Rollback()
+			return err
+		}
+		resources = append(resources, resourceID)
+	}
+	rows.Close()
+
+	for _, resourceID := range resources {
+		if err := storeXMP(tx, resourceID, x); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	_, err = tx.Exec(
+		"insert into photos_changelog (photo_id, action) values (?, ?)",
+		assetID, actionAdd)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPhotoXMP returns rating, title and keywords of the asset the photo belongs to
+func (m *photosDB) GetPhotoXMP(id int64) *media.XMP {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return nil
+	}
+	defer tx.Rollback()
+
+	x, err := loadXMP(tx, id)
+	if err != nil {
+		return nil
+	}
+	return x
+}
+
+// addKeywords fills keywords of the photos
+func (m *photosDB) addKeywords(photos []*photoMeta) error {
+	if len(photos) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*photoMeta, len(photos))
+	args := make([]interface{}, 0, len(photos))
+	for _, pm := range photos {
+		byID[pm.ID] = pm
+		args = append(args, pm.ID)
+	}
+
+	rows, err := m.database.Query(`
+		SELECT photo_id, keyword
+		FROM photo_keywords
+		WHERE photo_id IN (`+strings.TrimSuffix(strings.Repeat("?, ", len(args)), ", ")+`)
+		ORDER BY photo_id, position`,
+		args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var keyword string
+		if err := rows.Scan(&id, &keyword); err != nil {
+			return err
+		}
+		pm := byID[id]
+		pm.Keywords = append(pm.Keywords, keyword)
+	}
+	return rows.Err()
+}
+
+// addDetails completes listed photos with what is not in the photos table
+func (m *photosDB) addDetails(photos []*photoMeta) error {
+	if err := m.addResources(photos); err != nil {
+		return err
+	}
+	return m.addKeywords(photos)
+}
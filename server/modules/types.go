@@ -1,6 +1,12 @@
 package modules
 
-import "net/http"
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/akokshar/storage/server/modules/media"
+)
 
 // HTTPHandler interface to be implemented by backend instances
 type HTTPHandler interface {
@@ -17,10 +23,153 @@ type FilesDB interface {
 
 	GetMetaDataForItemWithID(int64) interface{}
 	GetChangesInDirectorySince(id int64, syncAnchor int64, count int) interface{}
+	SearchNames(dirID int64, query string, substring bool, offset int, count int) interface{}
+	SearchContent(dirID int64, query string, offset int, count int) interface{}
+	QueryItems(dirID int64, query string, sortBy string, descending bool, offset int, count int) (interface{}, error)
+	GetChildren(dirID int64, sortBy string, descending bool, cursor string, count int) (interface{}, error)
+	WriteTree(dirID int64, depth int, w io.Writer) error
+	GetUsage(dirID int64) interface{}
+
+	// saved searches show as read only directories with negative IDs
+	SaveSearch(parentID int64, name string, query string) (int64, error)
+	UpdateSavedSearch(id int64, name string, query string) error
+	DeleteSavedSearch(id int64) error
+	GetSavedSearchParent(id int64) (int64, error)
+	GetSavedSearches(dirID int64) interface{}
+
+	// directories grant permissions to users and groups, the nearest directory granting any
+	// to the user or their groups decides, and grants to the user go before those to groups
+	GetPermissions(id int64, user string, groups []string) int
+	RestrictedBelow(dirID int64, user string, groups []string) bool
+	GetACL(dirID int64) (interface{}, error)
+	SetACL(dirID int64, principal string, permissions int) error
+	ResetACL(dirID int64, principal string) error
+	GetShared(user string, groups []string) interface{}
+
+	// users own their roots with everything below, nothing is inherited across a root
+	SetOwner(dirID int64, user string) error
+	GetOwner(id int64) (user string, rootID int64)
+	ForgetUser(user string) error
 
 	CreateItemPlaceholder(parentID int64, name string) (id int64, err error)
 	DeleteItemPlaceholder(id int64)
 
 	ImportItem(itemID int64, itemPath string) (err error)
 	RemoveItem(id int64) (err error)
+	MoveItem(id int64, newParent int64, name string) (err error)
+
+	GetSyncAnchor() int64
+	GetChangesSince(syncAnchor int64, count int) ([]FileChange, error)
+}
+
+// FileChange is a record of FilesDB change feed across all directories
+type FileChange struct {
+	Anchor   int64
+	ID       int64
+	ParentID int64
+	Erased   bool
+}
+
+// UsersDB interface to talk to the database of users and their sessions
+type UsersDB interface {
+	AddUser(name string, password string) (int64, error)
+	SetPassword(name string, password string) error
+	RemoveUser(name string) error
+	GetUsers() ([]User, error)
+	AddToGroup(group string, name string) error
+	RemoveFromGroup(group string, name string) error
+
+	// a login opens a session with a short lived access token and a refresh token to renew it
+	Login(name string, password string) (*Session, error)
+	Refresh(refreshToken string) (*Session, error)
+	Logout(refreshToken string) error
+	Authenticate(accessToken string) (*User, error)
+}
+
+// User is someone allowed in
+type User struct {
+	ID     int64    `json:"id"`
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Session carries the tokens of a login
+type Session struct {
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	TokenType    string `json:"tokenType"`
+	ExpiresIn    int64  `json:"expiresIn"` /* seconds the access token is good for */
+}
+
+// PhotosDB interface to talk to photo library database
+type PhotosDB interface {
+	AddPhoto(id int64, sourceID int64, meta *media.Metadata) error
+	RemovePhoto(id int64) error
+	HasPhoto(id int64) bool
+	HasCurrentPhoto(id int64) bool
+	// photos, sources, albums and moments belong to a user, getters of their owner are empty for unknown IDs
+	GetPhotoOwner(id int64) string
+	AdoptAlbums(owner string) error
+	GetAssetResources(id int64) []int64
+
+	SetPhotoEdit(id int64, recipe []byte) error
+	RemovePhotoEdit(id int64) error
+	GetPhotoEdit(id int64) []byte
+
+	SetPhotoXMP(id int64, x *media.XMP) error
+	GetPhotoXMP(id int64) *media.XMP
+
+	GetPhotoIDsWithSize(owner string, size int64) []int64
+	GetPhotoHash(id int64) string
+	SetPhotoHash(id int64, hash string) error
+
+	GetPhotosWithoutPerceptualHash(count int) []int64
+	SetPhotoPerceptualHash(id int64, hash string) error
+	GetDuplicateGroups(owner string, maxDistance int, offset int, count int) interface{}
+	MergePhotos(keepID int64, ids []int64) error
+
+	GetPhotoWithID(id int64) interface{}
+	GetTimeline(owner string, offset int, count int) interface{}
+	GetChangesSince(owner string, syncAnchor int64, count int) interface{}
+
+	CreateAlbum(owner string, name string) (int64, error)
+	RenameAlbum(id int64, name string) error
+	DeleteAlbum(id int64) error
+	AddPhotosToAlbum(id int64, photoIDs []int64) error
+	RemovePhotosFromAlbum(id int64, photoIDs []int64) error
+	ReorderAlbum(id int64, photoIDs []int64) error
+	SetAlbumCover(id int64, photoID int64) error
+
+	GetAlbumOwner(id int64) string
+	GetAlbumWithID(id int64) interface{}
+	GetAlbums(owner string, offset int, count int) interface{}
+	GetAlbumPhotos(id int64, offset int, count int) interface{}
+
+	AddSource(id int64, syncAnchor int64) error
+	RemoveSource(id int64) error
+	SetSourceSyncAnchor(id int64, syncAnchor int64) error
+	GetSourceSyncAnchors() map[int64]int64
+	GetSourceOfPhoto(id int64) int64
+	GetSourceOwner(id int64) string
+	GetSources(owner string) interface{}
+	PruneOrphans() error
+
+	CreateSmartAlbum(owner string, name string, rules []byte) (int64, error)
+	UpdateSmartAlbum(id int64, name string, rules []byte) error
+	DeleteSmartAlbum(id int64) error
+	GetSmartAlbumOwner(id int64) string
+	GetSmartAlbumWithID(id int64) interface{}
+	GetSmartAlbums(owner string, offset int, count int) interface{}
+	GetSmartAlbumPhotos(id int64, offset int, count int) interface{}
+
+	GetPlaces(owner string, level string, country string, region string, offset int, count int) interface{}
+	GetPlacePhotos(owner string, level string, country string, region string, city string, offset int, count int) interface{}
+	GetPlacesGeoJSON(owner string, level string, country string, region string) interface{}
+
+	GetMomentOwner(id int64) string
+	GetMomentWithID(id int64) interface{}
+	GetMoments(owner string, offset int, count int) interface{}
+	GetMomentPhotos(id int64, offset int, count int) interface{}
+
+	GetPhotosOnThisDay(owner string, day time.Time, window int, count int) interface{}
 }
@@ -0,0 +1,62 @@
+package usersdb
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 600000
+	passwordSaltSize   = 16
+	passwordKeySize    = 32
+)
+
+// hashPassword derives a key from the password with a random salt. The result tells how it
+// was derived, "pbkdf2-sha256$<iterations>$<salt>$<key>", so that the iterations can go up
+// without breaking passwords set before.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, passwordKeySize)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s$%d$%s$%s", passwordScheme, passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// checkPassword tells whether the password is the one the hash was made of. A hash that can
+// not be read matches nothing, but still costs as much as one that can.
+func checkPassword(password string, hash string) bool {
+	parts := strings.Split(hash, "$")
+	iterations := passwordIterations
+	var salt, key []byte
+	valid := len(parts) == 4 && parts[0] == passwordScheme
+	if valid {
+		var err1, err2, err3 error
+		iterations, err1 = strconv.Atoi(parts[1])
+		salt, err2 = base64.RawStdEncoding.DecodeString(parts[2])
+		key, err3 = base64.RawStdEncoding.DecodeString(parts[3])
+		valid = err1 == nil && err2 == nil && err3 == nil && iterations > 0 && len(key) > 0
+	}
+	if !valid {
+		iterations = passwordIterations
+		salt = make([]byte, passwordSaltSize)
+		key = make([]byte, passwordKeySize)
+	}
+
+	derived, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(key))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(derived, key) == 1 && valid
+}
@@ -0,0 +1,162 @@
+package usersdb
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/akokshar/storage/server/modules"
+)
+
+const (
+	accessTokenLifetime  = time.Hour
+	refreshTokenLifetime = 30 * 24 * time.Hour
+	tokenType            = "Bearer"
+)
+
+// newToken makes a random token and the hash it is kept as
+func newToken() (token string, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, tokenHash(token), nil
+}
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issue gives the session a fresh pair of tokens, a new session is opened for sessionID 0
+func issue(tx *sql.Tx, userID int64, sessionID int64) (*modules.Session, error) {
+	access, accessHash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	refresh, refreshHash, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	accessExpires := now.Add(accessTokenLifetime).Unix()
+	refreshExpires := now.Add(refreshTokenLifetime).Unix()
+	if sessionID == 0 {
+		_, err = tx.Exec(`
+			INSERT INTO sessions (user_id, access_hash, access_expires, refresh_hash, refresh_expires)
+			VALUES (?, ?, ?, ?, ?)`,
+			userID, accessHash, accessExpires, refreshHash, refreshExpires)
+	} else {
+		_, err = tx.Exec(`
+			UPDATE sessions SET access_hash = ?, access_expires = ?, refresh_hash = ?, refresh_expires = ?
+			WHERE id = ?`,
+			accessHash, accessExpires, refreshHash, refreshExpires, sessionID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &modules.Session{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    tokenType,
+		ExpiresIn:    int64(accessTokenLifetime / time.Second),
+	}, nil
+}
+
+// Login checks the password and opens a session. Sessions expired by now are dropped on the way.
+func (m *usersDB) Login(name string, password string) (*modules.Session, error) {
+	var userID int64
+	var hash string
+	row := m.database.QueryRow(`SELECT id, password FROM users WHERE name = ?`, name)
+	if err := row.Scan(&userID, &hash); err != nil {
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+		// take as long as for a wrong password, so that names can not be told apart by timing
+		checkPassword(password, "")
+		return nil, errUnauthorized
+	}
+	if !checkPassword(password, hash) {
+		return nil, errUnauthorized
+	}
+
+	tx, err := m.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE refresh_expires < ?`, time.Now().Unix()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	session, err := issue(tx, userID, 0)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return session, tx.Commit()
+}
+
+// Refresh renews both tokens of the session, the refresh token can only be used once
+func (m *usersDB) Refresh(refreshToken string) (*modules.Session, error) {
+	tx, err := m.database.Begin()
+	if err != nil {
+		return nil, err
+	}
+
+	var sessionID, userID int64
+	row := tx.QueryRow(`SELECT id, user_id FROM sessions WHERE refresh_hash = ? AND refresh_expires >= ?`,
+		tokenHash(refreshToken), time.Now().Unix())
+	if err := row.Scan(&sessionID, &userID); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return nil, errUnauthorized
+		}
+		return nil, err
+	}
+
+	session, err := issue(tx, userID, sessionID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return session, tx.Commit()
+}
+
+// Logout ends the session, neither of its tokens is good any longer
+func (m *usersDB) Logout(refreshToken string) error {
+	res, err := m.database.Exec(`DELETE FROM sessions WHERE refresh_hash = ?`, tokenHash(refreshToken))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errUnauthorized
+	}
+	return nil
+}
+
+// Authenticate finds who the access token was issued to
+func (m *usersDB) Authenticate(accessToken string) (*modules.User, error) {
+	u := new(modules.User)
+	row := m.database.QueryRow(`
+		SELECT users.id, users.name
+		FROM sessions JOIN users ON users.id = sessions.user_id
+		WHERE sessions.access_hash = ? AND sessions.access_expires >= ?`,
+		tokenHash(accessToken), time.Now().Unix())
+	if err := row.Scan(&u.ID, &u.Name); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errUnauthorized
+		}
+		return nil, err
+	}
+	var err error
+	if u.Groups, err = m.getGroups(u.ID); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
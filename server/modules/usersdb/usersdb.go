@@ -0,0 +1,233 @@
+package usersdb
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/akokshar/storage/server/modules"
+	// need to call this explicitly so it registers db driver
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	errNotFound     = modules.NewHandlerErrorWithCode(http.StatusNotFound).(error)
+	errBadRequest   = modules.NewHandlerErrorWithCode(http.StatusBadRequest).(error)
+	errConflict     = modules.NewHandlerErrorWithCode(http.StatusConflict).(error)
+	errUnauthorized = modules.NewHandlerErrorWithCode(http.StatusUnauthorized).(error)
+)
+
+// names end up in paths, so they are kept to what is safe there
+var validName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,63}$`)
+
+type usersDB struct {
+	database *sql.DB
+}
+
+// NewUsersDB opens the user store. Users are kept in a database of their own, apart from
+// the metadata of files, so that the credentials do not travel with it.
+func NewUsersDB(dbFile string) modules.UsersDB {
+	database, err := sql.Open("sqlite3", fmt.Sprintf("%s?_busy_timeout=5000&_foreign_keys=1", dbFile))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db := new(usersDB)
+	db.database = database
+
+	_, err = database.Exec(`
+		CREATE TABLE IF NOT EXISTS users (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE COLLATE NOCASE,
+			password TEXT NOT NULL, /* see hashPassword */
+			cdate INTEGER
+		);
+
+		/* tokens are kept as SHA-256 hashes, so that a copy of the database lets nobody in */
+		CREATE TABLE IF NOT EXISTS sessions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id INTEGER NOT NULL,
+			access_hash TEXT NOT NULL UNIQUE,
+			access_expires INTEGER NOT NULL,
+			refresh_hash TEXT NOT NULL UNIQUE,
+			refresh_expires INTEGER NOT NULL,
+
+			CONSTRAINT fk_user
+				FOREIGN KEY (user_id)
+				REFERENCES users (id)
+				ON DELETE CASCADE
+		);
+
+		/* a group is there as long as it has members */
+		CREATE TABLE IF NOT EXISTS group_members (
+			group_name TEXT NOT NULL COLLATE NOCASE,
+			user_id INTEGER NOT NULL,
+
+			PRIMARY KEY (group_name, user_id),
+
+			CONSTRAINT fk_member
+				FOREIGN KEY (user_id)
+				REFERENCES users (id)
+				ON DELETE CASCADE
+		);
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return db
+}
+
+func (m *usersDB) AddUser(name string, password string) (int64, error) {
+	if !validName.MatchString(name) || password == "" {
+		return 0, errBadRequest
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return 0, err
+	}
+
+	var taken int
+	row := m.database.QueryRow(`SELECT count(*) FROM users WHERE name = ?`, name)
+	if err := row.Scan(&taken); err != nil {
+		return 0, err
+	}
+	if taken > 0 {
+		return 0, errConflict
+	}
+
+	res, err := m.database.Exec(`INSERT INTO users (name, password, cdate) VALUES (?, ?, ?)`, name, hash, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// SetPassword changes the password and ends the sessions opened with the old one
+func (m *usersDB) SetPassword(name string, password string) error {
+	if password == "" {
+		return errBadRequest
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.database.Begin()
+	if err != nil {
+		return err
+	}
+	var id int64
+	row := tx.QueryRow(`SELECT id FROM users WHERE name = ?`, name)
+	if err := row.Scan(&id); err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			return errNotFound
+		}
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE users SET password = ? WHERE id = ?`, hash, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM sessions WHERE user_id = ?`, id); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveUser removes the user along with the sessions, files of the user stay where they are
+func (m *usersDB) RemoveUser(name string) error {
+	res, err := m.database.Exec(`DELETE FROM users WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotFound
+	}
+	return nil
+}
+
+func (m *usersDB) GetUsers() ([]modules.User, error) {
+	rows, err := m.database.Query(`SELECT id, name FROM users ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]modules.User, 0)
+	for rows.Next() {
+		var u modules.User
+		if err := rows.Scan(&u.ID, &u.Name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	rows.Close()
+
+	for i := range users {
+		if users[i].Groups, err = m.getGroups(users[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return users, nil
+}
+
+// getGroups lists the groups the user is a member of
+func (m *usersDB) getGroups(userID int64) ([]string, error) {
+	rows, err := m.database.Query(`SELECT group_name FROM group_members WHERE user_id = ? ORDER BY group_name`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make([]string, 0)
+	for rows.Next() {
+		var group string
+		if err := rows.Scan(&group); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+func (m *usersDB) AddToGroup(group string, name string) error {
+	if !validName.MatchString(group) {
+		return errBadRequest
+	}
+	res, err := m.database.Exec(`
+		INSERT OR IGNORE INTO group_members (group_name, user_id)
+		SELECT ?, id FROM users WHERE name = ?`,
+		group, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var known int
+		if err := m.database.QueryRow(`SELECT count(*) FROM users WHERE name = ?`, name).Scan(&known); err != nil {
+			return err
+		}
+		if known == 0 {
+			return errNotFound
+		}
+	}
+	return nil
+}
+
+func (m *usersDB) RemoveFromGroup(group string, name string) error {
+	res, err := m.database.Exec(`
+		DELETE FROM group_members
+		WHERE group_name = ? AND user_id = (SELECT id FROM users WHERE name = ?)`,
+		group, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNotFound
+	}
+	return nil
+}
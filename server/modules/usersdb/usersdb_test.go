@@ -0,0 +1,105 @@
+package usersdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestPassword(t *testing.T) {
+	hash, err := hashPassword("correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !checkPassword("correct horse", hash) {
+		t.Errorf("password does not match its hash %s", hash)
+	}
+	if checkPassword("correct horse ", hash) {
+		t.Errorf("wrong password matches")
+	}
+	if other, _ := hashPassword("correct horse"); other == hash {
+		t.Errorf("hashes are not salted")
+	}
+	for _, bad := range []string{"", "correct horse", "pbkdf2-sha256$x$$", "md5$1$AAAA$AAAA"} {
+		if checkPassword("correct horse", bad) {
+			t.Errorf("malformed hash %q matches", bad)
+		}
+	}
+}
+
+func TestUsers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db := NewUsersDB(path.Join(dir, "users.db"))
+
+	for _, name := range []string{"", "../etc", ".hidden", "a/b"} {
+		if _, err := db.AddUser(name, "pw"); err != errBadRequest {
+			t.Errorf("name %q: %v", name, err)
+		}
+	}
+	if _, err := db.AddUser("bob", "pw"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.AddUser("Bob", "pw"); err != errConflict {
+		t.Errorf("duplicate name: %v", err)
+	}
+
+	session, err := db.Login("bob", "pw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := db.Authenticate(session.AccessToken); err != nil || u.Name != "bob" {
+		t.Errorf("%v %v", u, err)
+	}
+	if _, err := db.Authenticate(session.RefreshToken); err != errUnauthorized {
+		t.Errorf("refresh token accepted as an access token: %v", err)
+	}
+
+	// a new password ends the sessions opened with the old one
+	if err := db.SetPassword("bob", "new"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Authenticate(session.AccessToken); err != errUnauthorized {
+		t.Errorf("session survived a password change: %v", err)
+	}
+	if _, err := db.Login("bob", "pw"); err != errUnauthorized {
+		t.Errorf("old password: %v", err)
+	}
+	if _, err := db.Login("nobody", "pw"); err != errUnauthorized {
+		t.Errorf("unknown user: %v", err)
+	}
+
+	if err := db.AddToGroup("team", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AddToGroup("team", "nobody"); err != errNotFound {
+		t.Errorf("unknown member: %v", err)
+	}
+	session, err = db.Login("bob", "new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, err := db.Authenticate(session.AccessToken); err != nil || len(u.Groups) != 1 || u.Groups[0] != "team" {
+		t.Errorf("%v %v", u, err)
+	}
+	if err := db.RemoveFromGroup("team", "bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RemoveFromGroup("team", "bob"); err != errNotFound {
+		t.Errorf("removed twice: %v", err)
+	}
+
+	if err := db.RemoveUser("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RemoveUser("bob"); err != errNotFound {
+		t.Errorf("removed twice: %v", err)
+	}
+	if users, err := db.GetUsers(); err != nil || len(users) != 0 {
+		t.Errorf("%v %v", users, err)
+	}
+}
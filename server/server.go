@@ -12,14 +12,26 @@ import (
 	"github.com/akokshar/storage/server/modules/files"
 	"github.com/akokshar/storage/server/modules/filesdb"
 	"github.com/akokshar/storage/server/modules/photos"
+	"github.com/akokshar/storage/server/modules/photosdb"
+	"github.com/akokshar/storage/server/modules/usersdb"
 )
 
 type application struct {
 	handlers []modules.HTTPHandler
 	filesDB  modules.FilesDB
+	photosDB modules.PhotosDB
+	usersDB  modules.UsersDB
 }
 
 func (app *application) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == authRoutePrefix || strings.HasPrefix(r.URL.Path, authRoutePrefix+"/") {
+		app.serveAuth(w, r)
+		return
+	}
+	if !app.authenticate(w, r) {
+		return
+	}
+
 	for _, h := range app.handlers {
 		if !strings.HasPrefix(r.URL.Path, h.GetRoutePrefix()) {
 			continue
@@ -30,7 +42,7 @@ func (app *application) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 		//r.URL.Path = localFilePath
-		r.Header.Add("X-Local-Filepath", localFilePath)
+		r.Header.Set("X-Local-Filepath", localFilePath)
 		h.ServeHTTPRequest(w, r)
 		return
 	}
@@ -64,11 +76,23 @@ func (app *application) registerHandler(h modules.HTTPHandler) {
 func CreateApplication(basedir string) http.Handler {
 	app := &application{
 		handlers: make([]modules.HTTPHandler, 0, 3),
-		filesDB:  filesdb.NewFilesDB(path.Join(basedir, ".meta.db")),
+		filesDB:  filesdb.NewFilesDB(MetaDBPath(basedir)),
 	}
+	app.photosDB = photosdb.NewPhotosDB(MetaDBPath(basedir), app.filesDB)
+	app.usersDB = usersdb.NewUsersDB(UsersDBPath(basedir))
 
 	app.registerHandler(files.New(app.filesDB, "/files", path.Join(basedir, "files")))
-	app.registerHandler(photos.New("/photos", path.Join(basedir, "photos")))
+	app.registerHandler(photos.New(app.filesDB, app.photosDB, "/photos", path.Join(basedir, "photos"), path.Join(basedir, "files")))
 
 	return app
 }
+
+// MetaDBPath is where what is known of the files of the application in basedir is kept
+func MetaDBPath(basedir string) string {
+	return path.Join(basedir, ".meta.db")
+}
+
+// UsersDBPath is where the users of the application in basedir are kept
+func UsersDBPath(basedir string) string {
+	return path.Join(basedir, ".users.db")
+}
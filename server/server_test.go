@@ -1,11 +1,18 @@
 package server
 
 import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/akokshar/storage/server/modules"
+	"github.com/akokshar/storage/server/modules/usersdb"
 )
 
 type module struct {
@@ -25,10 +32,18 @@ func (m *module) ServeHTTPRequest(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func createTestApplication() application {
+// createTestApplication serves the test module to alice, who has the returned access token
+func createTestApplication(t *testing.T) (application, string) {
+	dir, err := ioutil.TempDir("", "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
 	app := application{
 		handlers: make([]modules.HTTPHandler, 0, 1),
 		filesDB:  nil,
+		usersDB:  usersdb.NewUsersDB(UsersDBPath(dir)),
 	}
 
 	app.handlers = append(
@@ -38,15 +53,24 @@ func createTestApplication() application {
 			baseDir: "/tmp/test/dir",
 		},
 	)
-	return app
+
+	if _, err := app.usersDB.AddUser("alice", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	session, err := app.usersDB.Login("alice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, session.AccessToken
 }
 
 func TestServerModulePath(t *testing.T) {
-	app := createTestApplication()
+	app, token := createTestApplication(t)
 	request, err := http.NewRequest("GET", "/test", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	request.Header.Set("Authorization", "Bearer "+token)
 
 	recorder := httptest.NewRecorder()
 	handler := http.HandlerFunc(app.ServeHTTP)
@@ -59,11 +83,12 @@ func TestServerModulePath(t *testing.T) {
 }
 
 func TestServerEscapeModulePath(t *testing.T) {
-	app := createTestApplication()
+	app, token := createTestApplication(t)
 	request, err := http.NewRequest("GET", "../test", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	request.Header.Set("Authorization", "Bearer "+token)
 
 	recorder := httptest.NewRecorder()
 	handler := http.HandlerFunc(app.ServeHTTP)
@@ -76,11 +101,12 @@ func TestServerEscapeModulePath(t *testing.T) {
 }
 
 func TestServerEscapeModulePath2(t *testing.T) {
-	app := createTestApplication()
+	app, token := createTestApplication(t)
 	request, err := http.NewRequest("GET", "/test/../../", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	request.Header.Set("Authorization", "Bearer "+token)
 
 	recorder := httptest.NewRecorder()
 	handler := http.HandlerFunc(app.ServeHTTP)
@@ -93,11 +119,12 @@ func TestServerEscapeModulePath2(t *testing.T) {
 }
 
 func TestServerWrongModulePath(t *testing.T) {
-	app := createTestApplication()
+	app, token := createTestApplication(t)
 	request, err := http.NewRequest("GET", "/wrongpath", nil)
 	if err != nil {
 		t.Fatal(err)
 	}
+	request.Header.Set("Authorization", "Bearer "+token)
 
 	recorder := httptest.NewRecorder()
 	handler := http.HandlerFunc(app.ServeHTTP)
@@ -108,3 +135,64 @@ func TestServerWrongModulePath(t *testing.T) {
 		t.Error("/wrongpath route failed")
 	}
 }
+
+func TestServerAuthentication(t *testing.T) {
+	app, _ := createTestApplication(t)
+
+	serve := func(method string, target string, form url.Values, token string) *httptest.ResponseRecorder {
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		request := httptest.NewRequest(method, target, body)
+		if form != nil {
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		app.ServeHTTP(recorder, request)
+		return recorder
+	}
+	login := func(recorder *httptest.ResponseRecorder) *modules.Session {
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%d", recorder.Code)
+		}
+		session := new(modules.Session)
+		if err := json.Unmarshal(recorder.Body.Bytes(), session); err != nil {
+			t.Fatal(err)
+		}
+		return session
+	}
+
+	if code := serve("GET", "/test", nil, "").Code; code != http.StatusUnauthorized {
+		t.Errorf("no token: %d", code)
+	}
+	if code := serve("GET", "/test", nil, "bogus").Code; code != http.StatusUnauthorized {
+		t.Errorf("bogus token: %d", code)
+	}
+	if code := serve("POST", "/auth/login", url.Values{"username": {"alice"}, "password": {"wrong"}}, "").Code; code != http.StatusUnauthorized {
+		t.Errorf("wrong password: %d", code)
+	}
+
+	session := login(serve("POST", "/auth/login", url.Values{"username": {"alice"}, "password": {"secret"}}, ""))
+	if code := serve("GET", "/test", nil, session.AccessToken).Code; code != http.StatusOK {
+		t.Errorf("access token: %d", code)
+	}
+
+	renewed := login(serve("POST", "/auth/refresh", url.Values{"refreshToken": {session.RefreshToken}}, ""))
+	if code := serve("GET", "/test", nil, session.AccessToken).Code; code != http.StatusUnauthorized {
+		t.Errorf("replaced access token: %d", code)
+	}
+	if code := serve("POST", "/auth/refresh", url.Values{"refreshToken": {session.RefreshToken}}, "").Code; code != http.StatusUnauthorized {
+		t.Errorf("used refresh token: %d", code)
+	}
+
+	if code := serve("POST", "/auth/logout", url.Values{"refreshToken": {renewed.RefreshToken}}, "").Code; code != http.StatusOK {
+		t.Errorf("logout: %d", code)
+	}
+	if code := serve("GET", "/test", nil, renewed.AccessToken).Code; code != http.StatusUnauthorized {
+		t.Errorf("token of a closed session: %d", code)
+	}
+}
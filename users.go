@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/akokshar/storage/server"
+	"github.com/akokshar/storage/server/modules"
+	"github.com/akokshar/storage/server/modules/filesdb"
+	"github.com/akokshar/storage/server/modules/usersdb"
+)
+
+const usersUsage = `Usage: storage [-basedir dir] user <command>
+
+  add <name>      add a user, the password is read from stdin
+  passwd <name>   change the password of a user and end their sessions
+  remove <name>   remove a user, who has to own no directories
+  list            list users
+`
+
+const groupsUsage = `Usage: storage [-basedir dir] group <command>
+
+  add <group> <name>      add a user to a group
+  remove <group> <name>   remove a user from a group
+  list                    list groups with their members
+`
+
+// openUsers opens the user store in basedir
+func openUsers(basedir string) modules.UsersDB {
+	if err := os.MkdirAll(basedir, os.ModePerm); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return usersdb.NewUsersDB(server.UsersDBPath(basedir))
+}
+
+// manageUsers runs the user command given on the command line against the user store in basedir
+func manageUsers(basedir string, args []string) {
+	if len(args) == 0 || (args[0] != "list" && len(args) != 2) {
+		fmt.Fprint(os.Stderr, usersUsage)
+		os.Exit(2)
+	}
+	users := openUsers(basedir)
+
+	var err error
+	switch args[0] {
+	case "add":
+		_, err = users.AddUser(args[1], readPassword())
+	case "passwd":
+		err = users.SetPassword(args[1], readPassword())
+	case "remove":
+		err = removeUser(basedir, users, args[1])
+	case "list":
+		list, listErr := users.GetUsers()
+		for _, u := range list {
+			fmt.Println(u.Name)
+		}
+		err = listErr
+	default:
+		fmt.Fprint(os.Stderr, usersUsage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", args[0], strings.Join(args[1:], " "), userError(err))
+		os.Exit(1)
+	}
+}
+
+// removeUser removes the user once nothing in the store is theirs any more, what was shared
+// with them is not handed on to a user added later under the name
+func removeUser(basedir string, users modules.UsersDB, name string) error {
+	filesDB := filesdb.NewFilesDB(server.MetaDBPath(basedir))
+	if err := filesDB.ForgetUser(name); err != nil {
+		if handlerErr, ok := err.(modules.HandlerError); ok && handlerErr.Code() == http.StatusConflict {
+			return fmt.Errorf("the user owns directories, hand them over with adopt first")
+		}
+		return err
+	}
+	return users.RemoveUser(name)
+}
+
+// manageGroups runs the group command given on the command line against the user store in basedir
+func manageGroups(basedir string, args []string) {
+	if len(args) == 0 || (args[0] != "list" && len(args) != 3) {
+		fmt.Fprint(os.Stderr, groupsUsage)
+		os.Exit(2)
+	}
+	users := openUsers(basedir)
+
+	var err error
+	switch args[0] {
+	case "add":
+		err = users.AddToGroup(args[1], args[2])
+	case "remove":
+		err = users.RemoveFromGroup(args[1], args[2])
+	case "list":
+		list, listErr := users.GetUsers()
+		members := make(map[string][]string)
+		groups := make([]string, 0)
+		for _, u := range list {
+			for _, g := range u.Groups {
+				if members[g] == nil {
+					groups = append(groups, g)
+				}
+				members[g] = append(members[g], u.Name)
+			}
+		}
+		sort.Strings(groups)
+		for _, g := range groups {
+			fmt.Printf("%s: %s\n", g, strings.Join(members[g], ", "))
+		}
+		err = listErr
+	default:
+		fmt.Fprint(os.Stderr, groupsUsage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %s: %s\n", args[0], strings.Join(args[1:], " "), userError(err))
+		os.Exit(1)
+	}
+}
+
+// userError tells what went wrong in words for the command line
+func userError(err error) string {
+	if handlerErr, ok := err.(modules.HandlerError); ok {
+		switch handlerErr.Code() {
+		case http.StatusBadRequest:
+			return "names are letters, digits, '_', '.' and '-', passwords can not be empty"
+		case http.StatusNotFound:
+			return "no such user or group member"
+		case http.StatusConflict:
+			return "the user exists already"
+		}
+	}
+	return err.Error()
+}
+
+// readPassword reads a line from stdin, so that passwords can be piped in by scripts
+func readPassword() string {
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		fmt.Fprint(os.Stderr, "Password: ")
+	}
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}